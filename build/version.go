@@ -18,6 +18,8 @@ const UBITaskImageAmdCpu = "filswan/ubi-worker-cpu-amd:latest"
 const UBITaskImageAmdGpu = "filswan/ubi-worker-gpu-amd:latest"
 const UBIResourceExporterDockerImage = "filswan/resource-exporter:v12.0.0"
 const TraefikServerDockerImage = "traefik:v2.10"
+const BuildkitRootlessDockerImage = "moby/buildkit:v0.19.0-rootless"
+const ModelPrefetchDockerImage = "curlimages/curl:8.11.1"
 
 const ResourceExporterVersion = "v12.0.0"
 
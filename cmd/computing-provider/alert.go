@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/internal/computing"
+	"github.com/swanchain/go-computing-provider/internal/contract"
+	"github.com/swanchain/go-computing-provider/internal/models"
+	"github.com/urfave/cli/v2"
+)
+
+var alertCmd = &cli.Command{
+	Name:  "alert",
+	Usage: "Manage the alerts on the balances, the collateral and the slashes of the provider",
+	Subcommands: []*cli.Command{
+		alertTestCmd,
+		alertCheckCmd,
+		alertListCmd,
+	},
+	Before: func(c *cli.Context) error {
+		cpRepoPath, _ := os.LookupEnv("CP_PATH")
+		if err := conf.InitConfig(cpRepoPath, true); err != nil {
+			return fmt.Errorf("load config file failed, error: %+v", err)
+		}
+		return nil
+	},
+}
+
+var alertTestCmd = &cli.Command{
+	Name:  "test",
+	Usage: "Send a test alert to the webhooks, the chat webhooks and the smtp server of the config",
+	Action: func(cctx *cli.Context) error {
+		sinks, err := computing.SendTestAlert()
+		if err != nil {
+			return fmt.Errorf("failed to send the test alert, error: %v", err)
+		}
+		printMessage("sent the test alert to %d sinks\n", sinks)
+		return nil
+	},
+}
+
+var alertCheckCmd = &cli.Command{
+	Name:  "check",
+	Usage: "Check the balances, the collateral and the slashes once, and send the alerts",
+	Action: func(cctx *cli.Context) error {
+		if err := computing.CheckAlerts(); err != nil {
+			return fmt.Errorf("failed to check the alerts, error: %v", err)
+		}
+		printMessage("checked the alerts\n")
+		return nil
+	},
+}
+
+var alertListCmd = &cli.Command{
+	Name:  "list",
+	Usage: "List the alerts firing",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "all",
+			Usage: "List the state of all the alerts",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		alertService := computing.NewAlertService()
+		var alerts []models.AlertEntity
+		var err error
+		if cctx.Bool("all") {
+			alerts, err = alertService.GetAlerts()
+		} else {
+			alerts, err = alertService.GetFiringAlerts()
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get the alerts, error: %v", err)
+		}
+
+		var items []alertState
+		var taskData [][]string
+		for _, entity := range alerts {
+			item := alertState{
+				Key:      entity.Key,
+				Firing:   entity.Firing,
+				Severity: entity.Severity,
+				Value:    entity.Value,
+				Message:  entity.Message,
+			}
+			if entity.Key != computing.AlertKeySlashing {
+				item.Value = contract.FormatWei(entity.Value, -1)
+			}
+			if entity.SentTime > 0 {
+				sentTime := time.Unix(entity.SentTime, 0)
+				item.SentTime = &sentTime
+			}
+			items = append(items, item)
+
+			var sent string
+			if item.SentTime != nil {
+				sent = item.SentTime.Format(time.DateTime)
+			}
+			taskData = append(taskData, []string{item.Key, fmt.Sprintf("%t", item.Firing), item.Severity, item.Value, sent, item.Message})
+		}
+
+		header := []string{"KEY", "FIRING", "SEVERITY", "VALUE", "SENT", "MESSAGE"}
+		return printResult(items, func() {
+			NewVisualTable(header, taskData, []RowColor{}).SetAutoWrapText(false).Generate(false)
+		})
+	},
+}
+
+type alertState struct {
+	Key      string     `json:"key"`
+	Firing   bool       `json:"firing"`
+	Severity string     `json:"severity"`
+	Value    string     `json:"value"`
+	SentTime *time.Time `json:"sent_time"`
+	Message  string     `json:"message"`
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/internal/computing"
+	"github.com/swanchain/go-computing-provider/internal/contract"
+	"github.com/swanchain/go-computing-provider/internal/models"
+	"github.com/urfave/cli/v2"
+)
+
+var chainCmd = &cli.Command{
+	Name:  "chain",
+	Usage: "Manage the data the provider scans from the chain",
+	Subcommands: []*cli.Command{
+		chainBackfillCmd,
+		chainEventsCmd,
+	},
+	Before: func(c *cli.Context) error {
+		cpRepoPath, _ := os.LookupEnv("CP_PATH")
+		if err := conf.InitConfig(cpRepoPath, true); err != nil {
+			return fmt.Errorf("load config file failed, error: %+v", err)
+		}
+		return nil
+	},
+}
+
+var chainBackfillCmd = &cli.Command{
+	Name:  "backfill",
+	Usage: "Scan a block range again to rebuild the rewards of the fcp or ecp tasks, or the indexed events",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "kind",
+			Usage:    "The data to rebuild: fcp or ecp for the rewards, events for the indexed events",
+			Required: true,
+		},
+		&cli.Uint64Flag{
+			Name:  "from-block",
+			Usage: "The first block of the range, default is the block the contract was created in",
+		},
+		&cli.Uint64Flag{
+			Name:  "to-block",
+			Usage: "The last block of the range, default is the end of the unfinished backfill from the same block, or the confirmed block below the chain head",
+		},
+		&cli.Uint64Flag{
+			Name:  "window",
+			Usage: "The number of blocks scanned per request",
+			Value: 1000,
+		},
+		&cli.BoolFlag{
+			Name:  "restart",
+			Usage: "Scan the range from the first block, ignoring the checkpoint",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		kind := cctx.String("kind")
+		if kind != computing.BackfillKindFcp && kind != computing.BackfillKindEcp && kind != computing.BackfillKindEvents {
+			return fmt.Errorf("unsupported kind: %s, must be one of fcp, ecp or events", kind)
+		}
+
+		backfill, err := computing.Backfill(kind, cctx.Uint64("from-block"), cctx.Uint64("to-block"), cctx.Uint64("window"),
+			cctx.Bool("restart"), func(backfill *models.BackfillEntity) {
+				printMessage("scanned %s to block %d of %d-%d\n", backfill.Kind, backfill.ScannedBlock, backfill.FromBlock, backfill.ToBlock)
+			})
+		if err != nil {
+			if backfill != nil && backfill.Status == models.BackfillFailed {
+				return fmt.Errorf("%v, run the same command again to resume after block %d", err, backfill.ScannedBlock)
+			}
+			return err
+		}
+		printMessage("backfill of the %s of blocks %d-%d is done\n", backfill.Kind, backfill.FromBlock, backfill.ToBlock)
+		return nil
+	},
+}
+
+const (
+	eventTypeAccount    = "account"
+	eventTypeCollateral = "collateral"
+	eventTypeSequencer  = "sequencer"
+	eventTypeTask       = "task"
+)
+
+var chainEventsCmd = &cli.Command{
+	Name:  "events",
+	Usage: "List the indexed events of the account, collateral, sequencer, task and payment contracts",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "type",
+			Usage: "The events to list: account, collateral, sequencer or task, default is all",
+		},
+		&cli.StringFlag{
+			Name:  "source",
+			Usage: "The contract of the events: account, fcp_collateral, ecp_collateral, sequencer, fcp_task or ecp_payment",
+		},
+		&cli.StringFlag{
+			Name:  "event",
+			Usage: "The name of the events, e.g. Deposit, CollateralSlashed, WorkerChanged",
+		},
+		&cli.Uint64Flag{
+			Name:  "from-block",
+			Usage: "The first block of the events",
+		},
+		&cli.Uint64Flag{
+			Name:  "to-block",
+			Usage: "The last block of the events",
+		},
+		&cli.IntFlag{
+			Name:  "limit",
+			Usage: "The number of the last events listed, 0 for all",
+			Value: 50,
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		eventType := cctx.String("type")
+		switch eventType {
+		case "", eventTypeAccount, eventTypeCollateral, eventTypeSequencer, eventTypeTask:
+		default:
+			return fmt.Errorf("unsupported type: %s, must be one of account, collateral, sequencer or task", eventType)
+		}
+		filter := computing.ChainEventFilter{
+			Source:    cctx.String("source"),
+			Event:     cctx.String("event"),
+			FromBlock: cctx.Uint64("from-block"),
+			ToBlock:   cctx.Uint64("to-block"),
+			Limit:     cctx.Int("limit"),
+		}
+
+		var events []chainEvent
+		eventService := computing.NewChainEventService()
+		if eventType == "" || eventType == eventTypeAccount {
+			var accountEvents []models.AccountEventEntity
+			if err := eventService.GetChainEvents(filter, &accountEvents); err != nil {
+				return fmt.Errorf("failed to get the account events, error: %v", err)
+			}
+			for _, event := range accountEvents {
+				item := newChainEvent(event.ChainEvent, event.CpAccount, "")
+				item.OldValue = event.OldValue
+				item.NewValue = event.NewValue
+				events = append(events, item)
+			}
+		}
+		if eventType == "" || eventType == eventTypeCollateral {
+			var collateralEvents []models.CollateralEventEntity
+			if err := eventService.GetChainEvents(filter, &collateralEvents); err != nil {
+				return fmt.Errorf("failed to get the collateral events, error: %v", err)
+			}
+			for _, event := range collateralEvents {
+				item := newChainEvent(event.ChainEvent, event.CpAccount, event.Amount)
+				item.Wallet = event.Wallet
+				item.TaskUuid = event.TaskUuid
+				events = append(events, item)
+			}
+		}
+		if eventType == "" || eventType == eventTypeSequencer {
+			var sequencerEvents []models.SequencerEventEntity
+			if err := eventService.GetChainEvents(filter, &sequencerEvents); err != nil {
+				return fmt.Errorf("failed to get the sequencer events, error: %v", err)
+			}
+			for _, event := range sequencerEvents {
+				events = append(events, newChainEvent(event.ChainEvent, event.CpAccount, event.Amount))
+			}
+		}
+		if eventType == "" || eventType == eventTypeTask {
+			var taskEvents []models.TaskEventEntity
+			if err := eventService.GetChainEvents(filter, &taskEvents); err != nil {
+				return fmt.Errorf("failed to get the task events, error: %v", err)
+			}
+			for _, event := range taskEvents {
+				item := newChainEvent(event.ChainEvent, event.CpAccount, event.Amount)
+				item.Wallet = event.Wallet
+				item.TaskUuid = event.TaskUuid
+				events = append(events, item)
+			}
+		}
+
+		sort.SliceStable(events, func(i, j int) bool {
+			if events[i].BlockNumber != events[j].BlockNumber {
+				return events[i].BlockNumber > events[j].BlockNumber
+			}
+			return events[i].LogIndex > events[j].LogIndex
+		})
+		if filter.Limit > 0 && len(events) > filter.Limit {
+			events = events[:filter.Limit]
+		}
+
+		var taskData [][]string
+		for _, event := range events {
+			detail := event.Wallet
+			if event.Source == models.EventSourceAccount {
+				detail = fmt.Sprintf("%s -> %s", event.OldValue, event.NewValue)
+			}
+			var amount string
+			if event.AmountWei != "" {
+				amount = contract.FormatWei(event.AmountWei, 4)
+			}
+			taskData = append(taskData, []string{event.Time.Format(time.DateTime), event.Source, event.Event,
+				strconv.FormatUint(event.BlockNumber, 10), event.TaskUuid, detail, amount, strconv.FormatBool(event.Confirmed), event.TxHash})
+		}
+
+		header := []string{"TIME", "SOURCE", "EVENT", "BLOCK", "TASK", "WALLET / CHANGE", "AMOUNT", "CONFIRMED", "TX HASH"}
+		return printResult(events, func() {
+			NewVisualTable(header, taskData, []RowColor{}).SetAutoWrapText(false).Generate(false)
+		})
+	},
+}
+
+type chainEvent struct {
+	Time        time.Time `json:"time"`
+	Source      string    `json:"source"`
+	Event       string    `json:"event"`
+	Contract    string    `json:"contract"`
+	BlockNumber uint64    `json:"block_number"`
+	TxHash      string    `json:"tx_hash"`
+	LogIndex    uint      `json:"log_index"`
+	Confirmed   bool      `json:"confirmed"`
+	CpAccount   string    `json:"cp_account"`
+	TaskUuid    string    `json:"task_uuid"`
+	Wallet      string    `json:"wallet"`
+	OldValue    string    `json:"old_value"`
+	NewValue    string    `json:"new_value"`
+	Amount      string    `json:"amount"`
+	AmountWei   string    `json:"amount_wei"`
+	Data        string    `json:"data"`
+}
+
+func newChainEvent(event models.ChainEvent, cpAccount, amountWei string) chainEvent {
+	item := chainEvent{
+		Time:        time.Unix(event.EventTime, 0),
+		Source:      event.Source,
+		Event:       event.Event,
+		Contract:    event.Contract,
+		BlockNumber: event.BlockNumber,
+		TxHash:      event.TxHash,
+		LogIndex:    event.LogIndex,
+		Confirmed:   event.Confirmed,
+		CpAccount:   cpAccount,
+		AmountWei:   amountWei,
+		Data:        event.Data,
+	}
+	if amountWei != "" {
+		item.Amount = contract.FormatWei(amountWei, -1)
+	}
+	return item
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/internal/computing"
+	"github.com/swanchain/go-computing-provider/internal/contract"
+	"github.com/swanchain/go-computing-provider/internal/models"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	groupByDay      = "day"
+	groupByTaskType = "task-type"
+	groupByWallet   = "wallet"
+)
+
+var earningsCmd = &cli.Command{
+	Name:  "earnings",
+	Usage: "Report the rewards, the expenses and the collateral movements recorded in the ledger",
+	Subcommands: []*cli.Command{
+		earningsReportCmd,
+		earningsListCmd,
+	},
+	Before: func(c *cli.Context) error {
+		cpRepoPath, _ := os.LookupEnv("CP_PATH")
+		return conf.InitConfig(cpRepoPath, true)
+	},
+}
+
+var earningsRangeFlags = []cli.Flag{
+	&cli.StringFlag{
+		Name:  "from",
+		Usage: "The first day of the range, formatted as 2006-01-02",
+	},
+	&cli.StringFlag{
+		Name:  "to",
+		Usage: "The last day of the range, formatted as 2006-01-02",
+	},
+}
+
+var earningsReportCmd = &cli.Command{
+	Name:  "report",
+	Usage: "Sum the rewards and the expenses of the ledger by day, task type or wallet",
+	Flags: append([]cli.Flag{
+		&cli.StringFlag{
+			Name:  "group-by",
+			Usage: "Group the entries by day, task-type or wallet",
+			Value: groupByDay,
+		},
+	}, earningsRangeFlags...),
+	Action: func(cctx *cli.Context) error {
+		groupBy := cctx.String("group-by")
+		if groupBy != groupByDay && groupBy != groupByTaskType && groupBy != groupByWallet {
+			return fmt.Errorf("unsupported group-by: %s, must be one of day, task-type or wallet", groupBy)
+		}
+		entries, err := getLedgerEntries(cctx)
+		if err != nil {
+			return err
+		}
+
+		type total struct {
+			rewards     *big.Int
+			expenses    *big.Int
+			deposits    *big.Int
+			withdrawals *big.Int
+			events      int
+		}
+		type groupKey struct {
+			group string
+			token string
+		}
+		totals := make(map[groupKey]*total)
+		for _, entry := range entries {
+			amount, ok := new(big.Int).SetString(entry.Amount, 10)
+			if !ok {
+				return fmt.Errorf("invalid amount of ledger entry %d: %s", entry.Id, entry.Amount)
+			}
+			var group string
+			switch groupBy {
+			case groupByDay:
+				group = time.Unix(entry.EventTime, 0).Format(time.DateOnly)
+			case groupByTaskType:
+				group = entry.TaskType
+			case groupByWallet:
+				group = entry.Wallet
+			}
+			if group == "" {
+				group = "-"
+			}
+
+			key := groupKey{group: group, token: entry.Token}
+			t, ok := totals[key]
+			if !ok {
+				t = &total{rewards: new(big.Int), expenses: new(big.Int), deposits: new(big.Int), withdrawals: new(big.Int)}
+				totals[key] = t
+			}
+			// the collateral movements are the funds of the provider, they do not count in the net
+			switch entry.Kind {
+			case models.LedgerReward:
+				t.rewards.Add(t.rewards, amount)
+			case models.LedgerDeposit:
+				t.deposits.Add(t.deposits, amount)
+			case models.LedgerWithdrawal:
+				t.withdrawals.Add(t.withdrawals, amount)
+			default:
+				t.expenses.Add(t.expenses, amount)
+			}
+			t.events++
+		}
+
+		keys := make([]groupKey, 0, len(totals))
+		for key := range totals {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if keys[i].group != keys[j].group {
+				return keys[i].group < keys[j].group
+			}
+			return keys[i].token < keys[j].token
+		})
+
+		var result []earningsReportRow
+		var taskData [][]string
+		for _, key := range keys {
+			t := totals[key]
+			row := earningsReportRow{
+				Group:       key.group,
+				Token:       key.token,
+				Events:      t.events,
+				Rewards:     contract.BalanceToExactStr(t.rewards),
+				Expenses:    contract.BalanceToExactStr(t.expenses),
+				Net:         contract.BalanceToExactStr(new(big.Int).Sub(t.rewards, t.expenses)),
+				Deposits:    contract.BalanceToExactStr(t.deposits),
+				Withdrawals: contract.BalanceToExactStr(t.withdrawals),
+			}
+			result = append(result, row)
+			taskData = append(taskData, []string{row.Group, row.Token, strconv.Itoa(row.Events), row.Rewards, row.Expenses, row.Net,
+				row.Deposits, row.Withdrawals})
+		}
+
+		header := []string{"GROUP", "TOKEN", "EVENTS", "REWARDS", "EXPENSES", "NET", "DEPOSITS", "WITHDRAWALS"}
+		return printResult(result, func() {
+			NewVisualTable(header, taskData, []RowColor{}).Generate(false)
+		})
+	},
+}
+
+var earningsListCmd = &cli.Command{
+	Name:  "list",
+	Usage: "List the entries of the ledger",
+	Flags: earningsRangeFlags,
+	Action: func(cctx *cli.Context) error {
+		entries, err := getLedgerEntries(cctx)
+		if err != nil {
+			return err
+		}
+
+		var result []ledgerEntry
+		var taskData [][]string
+		for _, entry := range entries {
+			amount, ok := new(big.Int).SetString(entry.Amount, 10)
+			if !ok {
+				return fmt.Errorf("invalid amount of ledger entry %d: %s", entry.Id, entry.Amount)
+			}
+			item := ledgerEntry{
+				Time:        time.Unix(entry.EventTime, 0),
+				Kind:        entry.Kind,
+				Category:    entry.Category,
+				TaskType:    entry.TaskType,
+				TaskUuid:    entry.TaskUuid,
+				Wallet:      entry.Wallet,
+				Token:       entry.Token,
+				Amount:      contract.BalanceToExactStr(amount),
+				AmountWei:   entry.Amount,
+				Contract:    entry.Contract,
+				TxHash:      entry.TxHash,
+				BlockNumber: entry.BlockNumber,
+			}
+			result = append(result, item)
+			taskData = append(taskData, []string{item.Time.Format(time.DateTime), item.Category, item.TaskType, item.TaskUuid,
+				item.Wallet, item.Amount, item.Token, item.TxHash})
+		}
+
+		header := []string{"TIME", "CATEGORY", "TASK TYPE", "TASK", "WALLET", "AMOUNT", "TOKEN", "TX HASH"}
+		return printResult(result, func() {
+			NewVisualTable(header, taskData, []RowColor{}).SetAutoWrapText(false).Generate(false)
+		})
+	},
+}
+
+type earningsReportRow struct {
+	Group       string `json:"group"`
+	Token       string `json:"token"`
+	Events      int    `json:"events"`
+	Rewards     string `json:"rewards"`
+	Expenses    string `json:"expenses"`
+	Net         string `json:"net"`
+	Deposits    string `json:"deposits"`
+	Withdrawals string `json:"withdrawals"`
+}
+
+type ledgerEntry struct {
+	Time        time.Time `json:"time"`
+	Kind        string    `json:"kind"`
+	Category    string    `json:"category"`
+	TaskType    string    `json:"task_type"`
+	TaskUuid    string    `json:"task_uuid"`
+	Wallet      string    `json:"wallet"`
+	Token       string    `json:"token"`
+	Amount      string    `json:"amount"`
+	AmountWei   string    `json:"amount_wei"`
+	Contract    string    `json:"contract"`
+	TxHash      string    `json:"tx_hash"`
+	BlockNumber uint64    `json:"block_number"`
+}
+
+// getLedgerEntries returns the entries of the ledger in the days of the --from and --to flags
+func getLedgerEntries(cctx *cli.Context) ([]models.LedgerEntity, error) {
+	var from, to int64
+	if value := cctx.String("from"); value != "" {
+		day, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("invalid from: %s, must be formatted as 2006-01-02", value)
+		}
+		from = day.Unix()
+	}
+	if value := cctx.String("to"); value != "" {
+		day, err := time.ParseInLocation(time.DateOnly, value, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("invalid to: %s, must be formatted as 2006-01-02", value)
+		}
+		to = day.AddDate(0, 0, 1).Unix()
+	}
+	if from > 0 && to > 0 && from >= to {
+		return nil, fmt.Errorf("the from day must not be after the to day")
+	}
+
+	if err := computing.UpdatePendingTxGas(); err != nil {
+		printMessage("failed to record the gas of the pending transactions, error: %v\n", err)
+	}
+	entries, err := computing.NewLedgerService().GetLedgerEntries(from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the ledger, error: %v", err)
+	}
+	return entries, nil
+}
+
+// recordTxGas records the gas of a transaction sent by a command in the ledger, without waiting for it to be mined
+func recordTxGas(txHash, taskType string) {
+	if err := computing.RecordTxGas(txHash, taskType); err != nil {
+		printMessage("failed to record the gas of tx %s in the ledger, error: %v\n", txHash, err)
+	}
+}
@@ -30,6 +30,7 @@ func main() {
 		Version:              build.UserVersion(),
 		Flags: []cli.Flag{
 			FlagRepo,
+			FlagOutput,
 		},
 		Commands: []*cli.Command{
 			initCmd,
@@ -46,8 +47,17 @@ func main() {
 			priceCmd,
 			networkCmd,
 			ubiZeroCmd,
+			modelsCmd,
+			earningsCmd,
+			chainCmd,
+			alertCmd,
+			topUpCmd,
 		},
 		Before: func(c *cli.Context) error {
+			if err := setOutputFormat(c.String(FlagOutput.Name)); err != nil {
+				return err
+			}
+
 			cpRepoPath, err := homedir.Expand(c.String(FlagRepo.Name))
 			if err != nil {
 				return fmt.Errorf("missing CP_PATH env, please set export CP_PATH=<YOUR CP_PATH>")
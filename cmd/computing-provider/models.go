@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/internal/computing"
+	"github.com/swanchain/go-computing-provider/internal/models"
+	"github.com/urfave/cli/v2"
+)
+
+var modelsCmd = &cli.Command{
+	Name:  "models",
+	Usage: "Manage the model cache of the inference jobs",
+	Subcommands: []*cli.Command{
+		modelsList,
+		modelsPrefetch,
+		modelsEvict,
+	},
+}
+
+var modelsList = &cli.Command{
+	Name:  "list",
+	Usage: "List the models in the model cache",
+	Action: func(cctx *cli.Context) error {
+		if err := initModelsConfig(); err != nil {
+			return err
+		}
+
+		caches, err := computing.NewModelCacheService().GetModelCaches()
+		if err != nil {
+			return fmt.Errorf("failed to get the model cache, error: %v", err)
+		}
+
+		var taskData [][]string
+		var rowColorList []RowColor
+		for i, cache := range caches {
+			node := cache.Node
+			if node == "" {
+				node = "local"
+			}
+			var lastUsed string
+			if cache.LastUsedTime > 0 {
+				lastUsed = time.Unix(cache.LastUsedTime, 0).Format("2006-01-02 15:04:05")
+			}
+			taskData = append(taskData, []string{cache.Key, cache.Name, node, computing.BytesToHumanReadable(cache.Size),
+				cache.Status, strconv.Itoa(cache.UseCount), lastUsed, cache.Message})
+			rowColorList = append(rowColorList, RowColor{
+				row:    i,
+				column: []int{4},
+				color:  getModelCacheStatusColor(cache.Status),
+			})
+		}
+		header := []string{"KEY", "NAME", "NODE", "SIZE", "STATUS", "USE COUNT", "LAST USED", "MESSAGE"}
+		return printResult(caches, func() {
+			NewVisualTable(header, taskData, rowColorList).Generate(true)
+		})
+	},
+}
+
+var modelsPrefetch = &cli.Command{
+	Name:  "prefetch",
+	Usage: "Download a model into the model cache",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "url",
+			Usage:    "The url of the model file",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:     "name",
+			Usage:    "The file name of the model",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:  "sha256",
+			Usage: "The hex sha256 of the model file, verified after the download",
+		},
+		&cli.StringFlag{
+			Name:  "node",
+			Usage: "The k8s node to prefetch the model into, the docker host by default",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if err := initModelsConfig(); err != nil {
+			return err
+		}
+
+		// the dir is where a job mounts the model, it is only checked to be absolute here
+		model := models.JobModel{
+			Name:   cctx.String("name"),
+			Url:    cctx.String("url"),
+			Sha256: cctx.String("sha256"),
+			Dir:    "/",
+		}
+		var modelPath string
+		var err error
+		if node := cctx.String("node"); node != "" {
+			modelPath, err = computing.PrefetchModelToNode(context.Background(), node, model)
+		} else {
+			modelPath, err = computing.PrefetchModel(context.Background(), model)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("the model is in %s\n", modelPath)
+		return nil
+	},
+}
+
+var modelsEvict = &cli.Command{
+	Name:      "evict",
+	Usage:     "Remove a model from the model cache, or evict the least recently used models beyond ModelCache.MaxSize",
+	ArgsUsage: "[key]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "node",
+			Usage: "The k8s node of the model cache, the docker host by default",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if err := initModelsConfig(); err != nil {
+			return err
+		}
+
+		node := cctx.String("node")
+		if cctx.NArg() > 0 {
+			key := cctx.Args().First()
+			if err := computing.RemoveModelCache(key, node); err != nil {
+				return err
+			}
+			fmt.Printf("removed model %s from the model cache\n", key)
+			return nil
+		}
+
+		if conf.GetConfig().ModelCache.MaxSize <= 0 {
+			return fmt.Errorf("ModelCache.MaxSize is not set, specify the key of the model to remove")
+		}
+		return computing.EvictModelCache(node)
+	},
+}
+
+func initModelsConfig() error {
+	cpRepoPath, ok := os.LookupEnv("CP_PATH")
+	if !ok {
+		return fmt.Errorf("missing CP_PATH env, please set export CP_PATH=<YOUR CP_PATH>")
+	}
+	if err := conf.InitConfig(cpRepoPath, true); err != nil {
+		return fmt.Errorf("load config file failed, error: %+v", err)
+	}
+	return nil
+}
+
+func getModelCacheStatusColor(status string) []tablewriter.Colors {
+	switch status {
+	case models.ModelCacheReady:
+		return []tablewriter.Colors{{tablewriter.Bold, tablewriter.FgGreenColor}}
+	case models.ModelCacheDownloading:
+		return []tablewriter.Colors{{tablewriter.Bold, tablewriter.FgYellowColor}}
+	default:
+		return []tablewriter.Colors{{tablewriter.Bold, tablewriter.FgRedColor}}
+	}
+}
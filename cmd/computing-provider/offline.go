@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/internal/computing"
+	"github.com/swanchain/go-computing-provider/internal/contract"
+	"github.com/swanchain/go-computing-provider/internal/models"
+	"github.com/swanchain/go-computing-provider/wallet"
+	"github.com/urfave/cli/v2"
+)
+
+var unsignedTxFlag = &cli.StringFlag{
+	Name:  "unsigned-tx",
+	Usage: "Write the unsigned transaction to the file, to sign it with 'wallet sign-tx' on another machine instead of with the keystore of the node",
+}
+
+// exportUnsignedTxs writes the unsigned transactions of a command to the file of --unsigned-tx, it returns whether
+// they are exported, so the command does not send them
+func exportUnsignedTxs(cctx *cli.Context, buildCalls func() ([]wallet.TxCall, error)) (bool, error) {
+	path := cctx.String(unsignedTxFlag.Name)
+	if strings.TrimSpace(path) == "" {
+		return false, nil
+	}
+
+	calls, err := buildCalls()
+	if err != nil {
+		return false, err
+	}
+	chainUrl, err := conf.GetRpcByNetWorkName()
+	if err != nil {
+		return false, err
+	}
+	client, err := contract.GetEthClient(chainUrl)
+	if err != nil {
+		return false, err
+	}
+	defer client.Close()
+
+	txs, err := wallet.BuildOfflineTxs(cctx.Context, client, calls)
+	if err != nil {
+		return false, err
+	}
+	if err = wallet.WriteOfflineTxFile(path, txs); err != nil {
+		return false, fmt.Errorf("failed to write the unsigned transaction file, error: %v", err)
+	}
+	printOfflineTxs(os.Stderr, txs)
+	printMessage("the unsigned transaction is written to %s, sign it with 'computing-provider wallet sign-tx %s <signedTxFile>' on the machine of the key, and broadcast the signed file with 'computing-provider wallet broadcast-tx <signedTxFile>'\n", path, path)
+	return true, nil
+}
+
+func printOfflineTxs(w io.Writer, txs []wallet.OfflineTx) {
+	for i, tx := range txs {
+		to := "the deployment of the " + tx.Contract + " contract"
+		if tx.To != nil {
+			to = tx.To.Hex()
+			if tx.Contract != "" {
+				to += " (" + tx.Contract + ")"
+			}
+		}
+		maxFee := new(big.Int).Mul(tx.GasFeeCap, new(big.Int).SetUint64(tx.Gas))
+		fmt.Fprintf(w, "Transaction %d/%d\n", i+1, len(txs))
+		fmt.Fprintf(w, "  Chain id:  %s\n", tx.ChainId)
+		fmt.Fprintf(w, "  From:      %s\n", tx.From.Hex())
+		fmt.Fprintf(w, "  To:        %s\n", to)
+		fmt.Fprintf(w, "  Nonce:     %d\n", tx.Nonce)
+		fmt.Fprintf(w, "  Value:     %s ETH\n", contract.BalanceToExactStr(tx.Value))
+		fmt.Fprintf(w, "  Call:      %s(%s)\n", tx.Method, strings.Join(tx.Args, ", "))
+		fmt.Fprintf(w, "  Gas:       %d\n", tx.Gas)
+		fmt.Fprintf(w, "  Max fee:   %s ETH\n", contract.BalanceToExactStr(maxFee))
+		if tx.Hash != "" {
+			fmt.Fprintf(w, "  Hash:      %s\n", tx.Hash)
+		}
+	}
+}
+
+var walletSignTx = &cli.Command{
+	Name:      "sign-tx",
+	Usage:     "Sign the unsigned transactions exported with --unsigned-tx, with the keys of the keystore, without network",
+	ArgsUsage: "[unsignedTxFile] [signedTxFile]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "yes",
+			Usage: "Sign the transactions without the confirmation, for scripts",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 2 {
+			return fmt.Errorf(" need two params: the unsigned transaction file and the signed transaction file")
+		}
+		unsignedPath, signedPath := cctx.Args().Get(0), cctx.Args().Get(1)
+
+		txs, err := wallet.ReadOfflineTxFile(unsignedPath)
+		if err != nil {
+			return err
+		}
+		for i := range txs {
+			method, args, _, err := txs[i].DecodeCall()
+			if err != nil {
+				return fmt.Errorf("failed to decode the transaction %d, error: %v", i+1, err)
+			}
+			if method != txs[i].Method {
+				return fmt.Errorf("the data of the transaction %d calls %s, not %s", i+1, method, txs[i].Method)
+			}
+			txs[i].Args = args
+		}
+
+		printOfflineTxs(os.Stderr, txs)
+		if !cctx.Bool("yes") {
+			ok, err := askConfirm(fmt.Sprintf("Sign %d transaction(s)?", len(txs)))
+			if err != nil {
+				return err
+			}
+			if !ok {
+				fmt.Fprintln(os.Stderr, "aborted, no transaction is signed")
+				return nil
+			}
+		}
+
+		localWallet, err := wallet.SetupWallet(wallet.WalletRepo)
+		if err != nil {
+			return err
+		}
+		signed, err := localWallet.SignOfflineTxs(txs)
+		if err != nil {
+			return err
+		}
+		if err = wallet.WriteOfflineTxFile(signedPath, signed); err != nil {
+			return fmt.Errorf("failed to write the signed transaction file, error: %v", err)
+		}
+		for _, tx := range signed {
+			fmt.Println(tx.Hash)
+		}
+		printMessage("the signed transactions are written to %s, broadcast them with 'computing-provider wallet broadcast-tx %s' on the node\n", signedPath, signedPath)
+		return nil
+	},
+}
+
+var walletBroadcastTx = &cli.Command{
+	Name:      "broadcast-tx",
+	Usage:     "Broadcast the transactions signed with 'wallet sign-tx', and update the node with their results",
+	ArgsUsage: "[signedTxFile]",
+	Action: func(cctx *cli.Context) error {
+		if cctx.NArg() != 1 {
+			return fmt.Errorf(" need one param: the signed transaction file")
+		}
+		txs, err := wallet.ReadOfflineTxFile(cctx.Args().Get(0))
+		if err != nil {
+			return err
+		}
+
+		chainUrl, err := conf.GetRpcByNetWorkName()
+		if err != nil {
+			return err
+		}
+		client, err := contract.GetEthClient(chainUrl)
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+
+		cpRepoPath, _ := os.LookupEnv("CP_PATH")
+		for _, tx := range txs {
+			signedTx, err := wallet.BroadcastOfflineTx(cctx.Context, client, tx)
+			if err != nil {
+				return fmt.Errorf("failed to broadcast the %s transaction, error: %v", tx.Method, err)
+			}
+			fmt.Println(signedTx.Hash().Hex())
+
+			ctx, cancel := context.WithTimeout(cctx.Context, 3*time.Minute)
+			receipt, err := bind.WaitMined(ctx, client, signedTx)
+			cancel()
+			if err != nil {
+				return fmt.Errorf("timeout waiting for transaction confirmation, tx: %s", signedTx.Hash().Hex())
+			}
+			if receipt.Status != types.ReceiptStatusSuccessful {
+				return fmt.Errorf("the %s transaction failed, tx: %s", tx.Method, signedTx.Hash().Hex())
+			}
+			if err = applyOfflineTx(cpRepoPath, tx, receipt); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// applyOfflineTx updates the node with a transaction mined, as the command that exported it does after sending it
+func applyOfflineTx(cpRepoPath string, tx wallet.OfflineTx, receipt *types.Receipt) error {
+	txHash := receipt.TxHash.Hex()
+	switch tx.Contract {
+	case wallet.ContractFcpCollateral:
+		recordTxGas(txHash, computing.LedgerTaskTypeFcp)
+	case wallet.ContractEcpCollateral:
+		recordTxGas(txHash, computing.LedgerTaskTypeEcp)
+	case wallet.ContractSequencer:
+		computing.GetCpBalance()
+		recordTxGas(txHash, computing.LedgerTaskTypeUbi)
+	case wallet.ContractCpAccount:
+		method, _, values, err := tx.DecodeCall()
+		if err != nil {
+			return err
+		}
+		nodeId := computing.GetNodeId(cpRepoPath)
+		var cpInfo *models.CpInfoEntity
+		switch method {
+		case "constructor":
+			if values[0].(string) != nodeId {
+				printMessage("the cp account %s is created for the node %s, not this node %s\n", receipt.ContractAddress.Hex(), values[0], nodeId)
+				return nil
+			}
+			if err = saveCpAccount(cpRepoPath, nodeId, receipt.ContractAddress.Hex(), tx.From.Hex(), values[2].(common.Address).Hex(),
+				values[3].(common.Address).Hex(), values[1].([]string), values[5].([]uint8)); err != nil {
+				return err
+			}
+			fmt.Printf("Contract deployed! Address: %s\n", receipt.ContractAddress.Hex())
+			return nil
+		case "changeOwnerAddress":
+			cpInfo = &models.CpInfoEntity{NodeId: nodeId, OwnerAddress: values[0].(common.Address).Hex()}
+		case "changeBeneficiary":
+			cpInfo = &models.CpInfoEntity{NodeId: nodeId, Beneficiary: values[0].(common.Address).Hex()}
+		case "changeWorker":
+			cpInfo = &models.CpInfoEntity{NodeId: nodeId, WorkerAddress: values[0].(common.Address).Hex()}
+		case "changeTaskTypes":
+			cpInfo = &models.CpInfoEntity{NodeId: nodeId, TaskTypes: values[0].([]uint8)}
+		case "changeMultiaddrs":
+			cpInfo = &models.CpInfoEntity{NodeId: nodeId, MultiAddresses: values[0].([]string)}
+			printMessage("please manually update the `MultiAddress` in the config file\n")
+		default:
+			return nil
+		}
+		if err = computing.NewCpInfoService().UpdateCpInfoByNodeId(cpInfo); err != nil {
+			return fmt.Errorf("update the %s of cp to db failed, error: %v", method, err)
+		}
+		if method == "changeWorker" {
+			computing.GetCpBalance()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	FlagCpOutput = "output"
+
+	outputTable = "table"
+	outputJson  = "json"
+	outputCsv   = "csv"
+	outputYaml  = "yaml"
+)
+
+var FlagOutput = &cli.StringFlag{
+	Name:    FlagCpOutput,
+	Aliases: []string{"o"},
+	Usage:   "output format of the results: table, json, csv or yaml",
+	Value:   outputTable,
+}
+
+// outputFormat is the format the commands print their results in, set by the global --output flag
+var outputFormat = outputTable
+
+func setOutputFormat(format string) error {
+	format = strings.ToLower(strings.TrimSpace(format))
+	switch format {
+	case outputTable, outputJson, outputCsv, outputYaml:
+		outputFormat = format
+		return nil
+	}
+	return fmt.Errorf("unsupported output format: %s, must be one of table, json, csv or yaml", format)
+}
+
+// printResult prints the typed result of a command in the output format, printTable prints it in the table format.
+// The csv has a record per element of a slice result, or a single record for a struct result.
+func printResult(result interface{}, printTable func()) error {
+	switch outputFormat {
+	case outputJson:
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	case outputYaml:
+		data, err := marshalYaml(result)
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(data)
+		return err
+	case outputCsv:
+		return writeCsv(os.Stdout, result)
+	default:
+		printTable()
+		return nil
+	}
+}
+
+// printMessage prints a note of a command, it goes to the stderr when the result is not a table, to keep the stdout parsable
+func printMessage(format string, a ...interface{}) {
+	if outputFormat == outputTable {
+		fmt.Printf(format, a...)
+		return
+	}
+	fmt.Fprintf(os.Stderr, format, a...)
+}
+
+// marshalYaml marshals the result with the names and the order of its json fields
+func marshalYaml(result interface{}) ([]byte, error) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	if reflect.Indirect(reflect.ValueOf(result)).Kind() == reflect.Slice {
+		var list []yaml.MapSlice
+		err = yaml.Unmarshal(data, &list)
+		doc = list
+	} else {
+		var object yaml.MapSlice
+		err = yaml.Unmarshal(data, &object)
+		doc = object
+	}
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(doc)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// writeCsv writes the header of the json names of the fields, then the records of the result.
+// The fields of a nested struct are named parent.field, the elements of a slice are joined with ";".
+func writeCsv(w io.Writer, result interface{}) error {
+	value := reflect.Indirect(reflect.ValueOf(result))
+	var items []reflect.Value
+	var itemType reflect.Type
+	if value.Kind() == reflect.Slice {
+		itemType = value.Type().Elem()
+		for i := 0; i < value.Len(); i++ {
+			items = append(items, reflect.Indirect(value.Index(i)))
+		}
+	} else {
+		itemType = value.Type()
+		items = append(items, value)
+	}
+	if itemType.Kind() == reflect.Ptr {
+		itemType = itemType.Elem()
+	}
+	if itemType.Kind() != reflect.Struct {
+		return fmt.Errorf("the csv output is not supported for %s", itemType)
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader(itemType, "")); err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err := writer.Write(csvRecord(item)); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func csvHeader(t reflect.Type, prefix string) []string {
+	var header []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := csvFieldName(field)
+		if name == "" {
+			continue
+		}
+		if field.Type.Kind() == reflect.Struct && field.Type != timeType {
+			header = append(header, csvHeader(field.Type, prefix+name+".")...)
+		} else {
+			header = append(header, prefix+name)
+		}
+	}
+	return header
+}
+
+func csvRecord(v reflect.Value) []string {
+	var record []string
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if csvFieldName(field) == "" {
+			continue
+		}
+		if field.Type.Kind() == reflect.Struct && field.Type != timeType {
+			record = append(record, csvRecord(v.Field(i))...)
+		} else {
+			record = append(record, csvValue(v.Field(i)))
+		}
+	}
+	return record
+}
+
+func csvValue(v reflect.Value) string {
+	switch {
+	case v.Type() == timeType:
+		if t := v.Interface().(time.Time); !t.IsZero() {
+			return t.Format(time.RFC3339)
+		}
+		return ""
+	case v.Kind() == reflect.Ptr:
+		if v.IsNil() {
+			return ""
+		}
+		return csvValue(v.Elem())
+	case v.Kind() == reflect.Slice:
+		var values []string
+		for i := 0; i < v.Len(); i++ {
+			values = append(values, csvValue(v.Index(i)))
+		}
+		return strings.Join(values, ";")
+	default:
+		return fmt.Sprint(v.Interface())
+	}
+}
+
+func csvFieldName(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		name = field.Name
+	}
+	return name
+}
+
+// splitList splits a list joined for the table into the elements of the result
+func splitList(s, sep string) []string {
+	var list []string
+	for _, item := range strings.Split(s, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/internal/contract"
+	"github.com/swanchain/go-computing-provider/wallet"
+	"github.com/urfave/cli/v2"
+)
+
+var dryRunFlag = &cli.BoolFlag{
+	Name:  "dry-run",
+	Usage: "Simulate the transactions and print the decoded calls, the gas and the fee without sending them",
+}
+
+var yesFlag = &cli.BoolFlag{
+	Name:  "yes",
+	Usage: "Send the transactions without the preview and the confirmation, for scripts",
+}
+
+type txPreviewItem struct {
+	Contract string   `json:"contract"`
+	From     string   `json:"from"`
+	To       string   `json:"to"`
+	Value    string   `json:"value"`
+	Method   string   `json:"method"`
+	Args     []string `json:"args"`
+	Data     string   `json:"data"`
+	Gas      uint64   `json:"gas"`
+	GasPrice string   `json:"gas_price"`
+	Fee      string   `json:"fee"`
+	MaxFee   string   `json:"max_fee"`
+	Revert   string   `json:"revert"`
+	Note     string   `json:"note"`
+}
+
+// confirmTxs previews the transactions of a command and asks to confirm them, it returns whether to send them.
+// With --dry-run the preview is the result of the command and nothing is sent, with --yes the transactions are
+// sent right away. A call reverting in the simulation aborts the command, except a call that depends on the
+// previous one being mined.
+func confirmTxs(cctx *cli.Context, buildCalls func() ([]wallet.TxCall, error)) (bool, error) {
+	dryRun := cctx.Bool(dryRunFlag.Name)
+	if cctx.Bool(yesFlag.Name) && !dryRun {
+		return true, nil
+	}
+
+	calls, err := buildCalls()
+	if err != nil {
+		return false, err
+	}
+	chainUrl, err := conf.GetRpcByNetWorkName()
+	if err != nil {
+		return false, err
+	}
+	client, err := contract.GetEthClient(chainUrl)
+	if err != nil {
+		return false, err
+	}
+	defer client.Close()
+
+	previews, err := wallet.PreviewTxs(cctx.Context, client, calls)
+	if err != nil {
+		return false, fmt.Errorf("failed to simulate the transactions, error: %v", err)
+	}
+
+	var reverts bool
+	for _, preview := range previews {
+		if preview.Revert != "" && !preview.Call.AfterPrevious {
+			reverts = true
+		}
+	}
+
+	if dryRun {
+		var items []txPreviewItem
+		for _, preview := range previews {
+			items = append(items, toTxPreviewItem(preview))
+		}
+		if err = printResult(items, func() { printTxPreviews(os.Stdout, previews) }); err != nil {
+			return false, err
+		}
+		if reverts {
+			return false, fmt.Errorf("the transaction reverts in the simulation")
+		}
+		return false, nil
+	}
+
+	printTxPreviews(os.Stderr, previews)
+	if reverts {
+		return false, fmt.Errorf("the transaction reverts in the simulation, it is not sent")
+	}
+	ok, err := askConfirm(fmt.Sprintf("Send %d transaction(s)?", len(previews)))
+	if err == nil && !ok {
+		fmt.Fprintln(os.Stderr, "aborted, no transaction is sent")
+	}
+	return ok, err
+}
+
+// askConfirm asks a yes or no question on the terminal, it fails when the stdin is not a terminal
+func askConfirm(question string) (bool, error) {
+	if stat, err := os.Stdin.Stat(); err != nil || stat.Mode()&os.ModeCharDevice == 0 {
+		return false, fmt.Errorf("the stdin is not a terminal to confirm, pass --yes to skip the confirmation")
+	}
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+func toTxPreviewItem(preview wallet.TxPreview) txPreviewItem {
+	var to string
+	if preview.Call.To != nil {
+		to = preview.Call.To.Hex()
+	}
+	return txPreviewItem{
+		Contract: preview.Call.Contract,
+		From:     preview.Call.From.Hex(),
+		To:       to,
+		Value:    contract.BalanceToExactStr(preview.Call.Value),
+		Method:   preview.Method,
+		Args:     preview.Args,
+		Data:     hexutil.Encode(preview.Call.Data),
+		Gas:      preview.Gas,
+		GasPrice: preview.GasPrice.String(),
+		Fee:      contract.BalanceToExactStr(preview.Fee),
+		MaxFee:   contract.BalanceToExactStr(preview.MaxFee),
+		Revert:   preview.Revert,
+		Note:     preview.Note,
+	}
+}
+
+func printTxPreviews(w io.Writer, previews []wallet.TxPreview) {
+	for i, preview := range previews {
+		item := toTxPreviewItem(preview)
+		to := item.To
+		if preview.Call.To == nil {
+			to = "the deployment of the " + item.Contract + " contract"
+		} else if item.Contract != "" {
+			to += " (" + item.Contract + ")"
+		}
+		fmt.Fprintf(w, "Transaction %d/%d\n", i+1, len(previews))
+		fmt.Fprintf(w, "  From:      %s\n", item.From)
+		fmt.Fprintf(w, "  To:        %s\n", to)
+		fmt.Fprintf(w, "  Value:     %s ETH\n", item.Value)
+		fmt.Fprintf(w, "  Call:      %s(%s)\n", item.Method, strings.Join(item.Args, ", "))
+		if preview.Revert != "" {
+			fmt.Fprintf(w, "  Reverts:   %s\n", item.Revert)
+		} else {
+			fmt.Fprintf(w, "  Gas:       %d\n", item.Gas)
+			fmt.Fprintf(w, "  Gas price: %s wei\n", item.GasPrice)
+			fmt.Fprintf(w, "  Fee:       %s ETH (max %s ETH)\n", item.Fee, item.MaxFee)
+		}
+		if item.Note != "" {
+			fmt.Fprintf(w, "  Note:      %s\n", item.Note)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/swanchain/go-computing-provider/internal/computing"
 	"github.com/urfave/cli/v2"
 	"os"
+	"strings"
 )
 
 var priceCmd = &cli.Command{
@@ -32,6 +33,13 @@ var generateCmd = &cli.Command{
 	},
 }
 
+// hardwarePriceResult is a price in the result of the price view command
+type hardwarePriceResult struct {
+	Name  string `json:"name"`
+	Price string `json:"price"`
+	Unit  string `json:"unit"`
+}
+
 var viewCmd = &cli.Command{
 	Name:  "view",
 	Usage: "View resource price configuration information",
@@ -54,6 +62,7 @@ var viewCmd = &cli.Command{
 		}
 
 		var taskData [][]string
+		result := make([]hardwarePriceResult, 0, len(hardwareFields))
 		for _, field := range hardwareFields {
 			var valStr string
 			switch field.Name {
@@ -72,9 +81,15 @@ var viewCmd = &cli.Command{
 				valStr = field.Value + " SWAN/GPU unit a hour"
 			}
 			taskData = append(taskData, []string{fmt.Sprintf("%s:", field.Name), valStr})
+			result = append(result, hardwarePriceResult{
+				Name:  field.Name,
+				Price: field.Value,
+				Unit:  strings.TrimSpace(strings.TrimPrefix(valStr, field.Value)),
+			})
 		}
 		header := []string{"CP Hardware Price Info:"}
-		NewVisualTable(header, taskData, []RowColor{}).SetAutoWrapText(false).Generate(false)
-		return nil
+		return printResult(result, func() {
+			NewVisualTable(header, taskData, []RowColor{}).SetAutoWrapText(false).Generate(false)
+		})
 	},
 }
@@ -21,6 +21,7 @@ import (
 	"github.com/swanchain/go-computing-provider/util"
 	"github.com/swanchain/go-computing-provider/wallet"
 	"github.com/urfave/cli/v2"
+	"math/big"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -79,11 +80,16 @@ func cpManager(router *gin.RouterGroup) {
 	router.POST("/lagrange/jobs", computing.ReceiveJob)
 	router.DELETE("/lagrange/jobs", computing.CancelJob)
 	router.POST("/lagrange/jobs/renew", computing.ReNewJob)
+	router.POST("/lagrange/jobs/pause", computing.PauseJob)
+	router.POST("/lagrange/jobs/resume", computing.ResumeJob)
+	router.POST("/lagrange/jobs/resize", computing.ResizeJob)
 	router.GET("/lagrange/spaces/log", computing.GetSpaceLog)
 	router.POST("/lagrange/cp/proof", computing.DoProof)
 	router.GET("/lagrange/cp/whitelist", computing.WhiteList)
 	router.GET("/lagrange/cp/blacklist", computing.BlackList)
 	router.GET("/lagrange/job/:job_uuid", computing.GetJobStatus)
+	router.GET("/cp/job/:job_uuid/events", computing.GetJobEvents)
+	router.GET("/cp/job/:job_uuid/build_log", computing.GetJobBuildLog)
 	router.GET("/lagrange/cp/public_key", computing.GetPublicKey)
 	router.GET("/lagrange/cp/price", computing.GetPrice)
 	router.GET("/lagrange/cp/check_node_port", computing.CheckNodeportServiceEnv)
@@ -95,6 +101,64 @@ func cpManager(router *gin.RouterGroup) {
 
 }
 
+// cpAccountInfo is the result of the info, state cp-info and ubi-0 info commands
+type cpAccountInfo struct {
+	Network            string     `json:"network,omitempty"`
+	CpAccount          string     `json:"cp_account"`
+	Version            string     `json:"version"`
+	Name               string     `json:"name,omitempty"`
+	Owner              string     `json:"owner"`
+	NodeId             string     `json:"node_id"`
+	Domain             string     `json:"domain,omitempty"`
+	MultiAddresses     []string   `json:"multi_addresses"`
+	WorkerAddress      string     `json:"worker_address"`
+	BeneficiaryAddress string     `json:"beneficiary_address"`
+	TaskTypes          []string   `json:"task_types,omitempty"`
+	Applications       int        `json:"applications"`
+	Balances           cpBalances `json:"balances"`
+}
+
+// cpBalances are the balances of a cp account, in ETH for the wallets and the sequencer, in SWAN for the collaterals
+type cpBalances struct {
+	Owner         string `json:"owner"`
+	Worker        string `json:"worker"`
+	Sequencer     string `json:"sequencer,omitempty"`
+	EcpCollateral string `json:"ecp_collateral"`
+	EcpEscrow     string `json:"ecp_escrow"`
+	FcpCollateral string `json:"fcp_collateral"`
+	FcpEscrow     string `json:"fcp_escrow"`
+}
+
+// chainTaskInfo is the result of the state task-info command, an aggregated task only has the mcs cid
+type chainTaskInfo struct {
+	TaskContract string `json:"task_contract"`
+	Version      string `json:"version,omitempty"`
+	TaskId       string `json:"task_id,omitempty"`
+	ZkType       string `json:"zk_type,omitempty"`
+	ResourceType string `json:"resource_type,omitempty"`
+	Owner        string `json:"owner,omitempty"`
+	CpAccount    string `json:"cp_account,omitempty"`
+	Deadline     string `json:"deadline,omitempty"`
+	InputParam   string `json:"input_param,omitempty"`
+	VerifyParam  string `json:"verify_param,omitempty"`
+	CheckCode    string `json:"check_code,omitempty"`
+	Proof        string `json:"proof,omitempty"`
+	McsCid       string `json:"mcs_cid,omitempty"`
+}
+
+// contractInfo is the result of the contract default command
+type contractInfo struct {
+	Network                string `json:"network"`
+	SwanToken              string `json:"swan_token"`
+	OrchestratorCollateral string `json:"orchestrator_collateral"`
+	TaskManager            string `json:"task_manager"`
+	RegisterCp             string `json:"register_cp"`
+	RegisterTask           string `json:"register_task"`
+	ZkCollateral           string `json:"zk_collateral"`
+	Sequencer              string `json:"sequencer"`
+	EdgeTaskPayment        string `json:"edge_task_payment"`
+}
+
 var infoCmd = &cli.Command{
 	Name:  "info",
 	Usage: "Print computing-provider info",
@@ -191,7 +255,10 @@ var infoCmd = &cli.Command{
 
 		sequencerStub, err := ecp.NewSequencerStub(client, ecp.WithSequencerCpAccountAddress(contractAddress))
 		if err == nil {
-			sequencerBalance, err = sequencerStub.GetCPBalance()
+			var balance *big.Int
+			if balance, err = sequencerStub.GetCPBalance(); err == nil {
+				sequencerBalance = contract.BalanceToStr2(balance)
+			}
 		}
 
 		var domain = conf.GetConfig().API.Domain
@@ -239,19 +306,46 @@ var infoCmd = &cli.Command{
 					color:  []tablewriter.Colors{{tablewriter.Bold, tablewriter.FgGreenColor}},
 				})
 		}
+		result := cpAccountInfo{
+			Network:            netWork,
+			CpAccount:          contractAddress,
+			Version:            version,
+			Name:               conf.GetConfig().API.NodeName,
+			Owner:              ownerAddress,
+			NodeId:             localNodeId,
+			Domain:             domain,
+			MultiAddresses:     []string{conf.GetConfig().API.MultiAddress},
+			WorkerAddress:      workerAddress,
+			BeneficiaryAddress: beneficiaryAddress,
+			TaskTypes:          splitList(taskTypes, ", "),
+			Applications:       count,
+			Balances: cpBalances{
+				Owner:         ownerBalance,
+				Worker:        workerBalance,
+				Sequencer:     sequencerBalance,
+				EcpCollateral: ecpCollateralBalance,
+				EcpEscrow:     ecpEscrowBalance,
+				FcpCollateral: fcpCollateralBalance,
+				FcpEscrow:     fcpEscrowBalance,
+			},
+		}
 		header := []string{"CP Account Info:"}
-		NewVisualTable(header, taskData, rowColorList).SetAutoWrapText(false).Generate(false)
+		if printErr := printResult(result, func() {
+			NewVisualTable(header, taskData, rowColorList).SetAutoWrapText(false).Generate(false)
+		}); printErr != nil {
+			return printErr
+		}
 		if err != nil {
 			return err
 		}
 
 		if contractAddress == "" {
-			fmt.Printf("Error: CP Account does not exist, please run 'computing-provider account create'.\n")
+			printMessage("Error: CP Account does not exist, please run 'computing-provider account create'.\n")
 			return nil
 		}
 
 		if localNodeId != chainNodeId {
-			fmt.Printf("NodeId mismatch, local node id: %s, chain node id: %s.\n", localNodeId, chainNodeId)
+			printMessage("NodeId mismatch, local node id: %s, chain node id: %s.\n", localNodeId, chainNodeId)
 		}
 		return nil
 	},
@@ -362,7 +456,10 @@ var stateInfoCmd = &cli.Command{
 
 		sequencerStub, err := ecp.NewSequencerStub(client, ecp.WithSequencerCpAccountAddress(contractAddress))
 		if err == nil {
-			sequencerBalance, err = sequencerStub.GetCPBalance()
+			var balance *big.Int
+			if balance, err = sequencerStub.GetCPBalance(); err == nil {
+				sequencerBalance = contract.BalanceToStr2(balance)
+			}
 		}
 
 		var taskData [][]string
@@ -394,9 +491,29 @@ var stateInfoCmd = &cli.Command{
 				color:  rowColor,
 			})
 		}
+		result := cpAccountInfo{
+			CpAccount:          contractAddress,
+			Version:            version,
+			Owner:              ownerAddress,
+			NodeId:             chainNodeId,
+			MultiAddresses:     splitList(chainMultiAddress, ","),
+			WorkerAddress:      workerAddress,
+			BeneficiaryAddress: beneficiaryAddress,
+			TaskTypes:          splitList(taskTypes, ","),
+			Balances: cpBalances{
+				Owner:         ownerBalance,
+				Worker:        workerBalance,
+				Sequencer:     sequencerBalance,
+				EcpCollateral: ecpCollateralBalance,
+				EcpEscrow:     ecpEscrowBalance,
+				FcpCollateral: fcpCollateralBalance,
+				FcpEscrow:     fcpEscrowBalance,
+			},
+		}
 		header := []string{fmt.Sprintf("CP Account Address(%s):", version), contractAddress}
-		NewVisualTable(header, taskData, rowColorList).Generate(false)
-		return nil
+		return printResult(result, func() {
+			NewVisualTable(header, taskData, rowColorList).Generate(false)
+		})
 	},
 }
 
@@ -465,21 +582,39 @@ var taskInfoCmd = &cli.Command{
 				taskData = append(taskData, []string{"Proof:", taskInfo.Proof})
 			}
 
-			header := []string{fmt.Sprintf("Task Contract(%s):", taskInfo.Version), taskContract}
-			NewVisualTable(header, taskData, []RowColor{}).Generate(false)
-		} else {
-			cid, err := computing.GetAggregatedTaskInfo(taskContract)
-			if err != nil {
-				return err
+			result := chainTaskInfo{
+				TaskContract: taskContract,
+				Version:      taskInfo.Version,
+				TaskId:       taskInfo.TaskID.String(),
+				ZkType:       models.TaskTypeStr(int(taskInfo.TaskType.Int64())),
+				ResourceType: models.GetResourceTypeStr(int(taskInfo.ResourceType.Int64())),
+				Owner:        taskInfo.Owner.Hex(),
+				CpAccount:    taskInfo.CpAccount.Hex(),
+				Deadline:     taskInfo.Deadline.String(),
+				InputParam:   taskInfo.InputParam,
+				VerifyParam:  taskInfo.VerifyParam,
+				CheckCode:    taskInfo.CheckCode,
 			}
+			if showProof {
+				result.Proof = taskInfo.Proof
+			}
+			header := []string{fmt.Sprintf("Task Contract(%s):", taskInfo.Version), taskContract}
+			return printResult(result, func() {
+				NewVisualTable(header, taskData, []RowColor{}).Generate(false)
+			})
+		}
 
-			var taskData [][]string
-			taskData = append(taskData, []string{"MCS CID:", cid})
-			header := []string{"Task Contract:", taskContract}
-			NewVisualTable(header, taskData, []RowColor{}).Generate(false)
+		cid, err := computing.GetAggregatedTaskInfo(taskContract)
+		if err != nil {
+			return err
 		}
 
-		return nil
+		var taskData [][]string
+		taskData = append(taskData, []string{"MCS CID:", cid})
+		header := []string{"Task Contract:", taskContract}
+		return printResult(chainTaskInfo{TaskContract: taskContract, McsCid: cid}, func() {
+			NewVisualTable(header, taskData, []RowColor{}).Generate(false)
+		})
 
 	},
 }
@@ -555,6 +690,7 @@ var createAccountCmd = &cli.Command{
 			Name:  "task-types",
 			Usage: "Task types of CP (1:Fil-C2, 2:Mining, 3:AI, 4:Inference, 5:NodePort, 100:Exit), separated by commas",
 		},
+		unsignedTxFlag,
 	},
 	Action: func(cctx *cli.Context) error {
 		ownerAddress := cctx.String("ownerAddress")
@@ -627,6 +763,15 @@ var createAccountCmd = &cli.Command{
 		}
 
 		cpRepoPath, _ := os.LookupEnv("CP_PATH")
+		if exported, err := exportUnsignedTxs(cctx, func() ([]wallet.TxCall, error) {
+			multiAddress, err := accountMultiAddress()
+			if err != nil {
+				return nil, err
+			}
+			return wallet.AccountCreateCalls(ownerAddress, computing.GetNodeId(cpRepoPath), []string{multiAddress}, beneficiaryAddress, workerAddress, taskTypesUint)
+		}); err != nil || exported {
+			return err
+		}
 		return createAccount(cpRepoPath, ownerAddress, beneficiaryAddress, workerAddress, taskTypesUint)
 	},
 }
@@ -641,6 +786,7 @@ var changeMultiAddressCmd = &cli.Command{
 			Usage:    "Specify a OwnerAddress",
 			Required: true,
 		},
+		unsignedTxFlag,
 	},
 	Action: func(cctx *cli.Context) error {
 		ownerAddress := cctx.String("ownerAddress")
@@ -657,6 +803,13 @@ var changeMultiAddressCmd = &cli.Command{
 			return fmt.Errorf("multiAddress is required")
 		}
 
+		newMultiAddress := []string{strings.TrimSpace(multiAddr)}
+		if exported, err := exportUnsignedTxs(cctx, func() ([]wallet.TxCall, error) {
+			return wallet.AccountCalls(ownerAddress, "", "changeMultiaddrs", newMultiAddress)
+		}); err != nil || exported {
+			return err
+		}
+
 		cpRepoPath, _ := os.LookupEnv("CP_PATH")
 
 		client, cpStub, err := getVerifyAccountClient(ownerAddress)
@@ -665,7 +818,6 @@ var changeMultiAddressCmd = &cli.Command{
 		}
 		defer client.Close()
 
-		newMultiAddress := []string{strings.TrimSpace(multiAddr)}
 		changeMultiAddressTx, err := cpStub.ChangeMultiAddress(newMultiAddress)
 		if err != nil {
 			return fmt.Errorf("changeMultiAddress tx failed, error: %v", err)
@@ -692,6 +844,9 @@ var changeOwnerAddressCmd = &cli.Command{
 			Usage:    "Specify a OwnerAddress",
 			Required: true,
 		},
+		dryRunFlag,
+		yesFlag,
+		unsignedTxFlag,
 	},
 	Action: func(cctx *cli.Context) error {
 		ownerAddress := cctx.String("ownerAddress")
@@ -712,6 +867,16 @@ var changeOwnerAddressCmd = &cli.Command{
 			return fmt.Errorf("the target newOwnerAddress is invalid wallet address")
 		}
 
+		buildCalls := func() ([]wallet.TxCall, error) {
+			return wallet.AccountCalls(ownerAddress, "", "changeOwnerAddress", common.HexToAddress(newOwnerAddr))
+		}
+		if exported, err := exportUnsignedTxs(cctx, buildCalls); err != nil || exported {
+			return err
+		}
+		if ok, err := confirmTxs(cctx, buildCalls); err != nil || !ok {
+			return err
+		}
+
 		cpRepoPath, _ := os.LookupEnv("CP_PATH")
 
 		client, cpStub, err := getVerifyAccountClient(ownerAddress)
@@ -746,6 +911,9 @@ var changeBeneficiaryAddressCmd = &cli.Command{
 			Usage:    "Specify a OwnerAddress",
 			Required: true,
 		},
+		dryRunFlag,
+		yesFlag,
+		unsignedTxFlag,
 	},
 	Action: func(cctx *cli.Context) error {
 
@@ -771,6 +939,16 @@ var changeBeneficiaryAddressCmd = &cli.Command{
 			return err
 		}
 
+		buildCalls := func() ([]wallet.TxCall, error) {
+			return wallet.AccountCalls(ownerAddress, "", "changeBeneficiary", common.HexToAddress(beneficiaryAddress))
+		}
+		if exported, err := exportUnsignedTxs(cctx, buildCalls); err != nil || exported {
+			return err
+		}
+		if ok, err := confirmTxs(cctx, buildCalls); err != nil || !ok {
+			return err
+		}
+
 		cpRepoPath, _ := os.LookupEnv("CP_PATH")
 
 		client, cpStub, err := getVerifyAccountClient(ownerAddress)
@@ -805,6 +983,9 @@ var changeWorkerAddressCmd = &cli.Command{
 			Usage:    "Specify a OwnerAddress",
 			Required: true,
 		},
+		dryRunFlag,
+		yesFlag,
+		unsignedTxFlag,
 	},
 	Action: func(cctx *cli.Context) error {
 
@@ -830,6 +1011,16 @@ var changeWorkerAddressCmd = &cli.Command{
 			return err
 		}
 
+		buildCalls := func() ([]wallet.TxCall, error) {
+			return wallet.AccountCalls(ownerAddress, "", "changeWorker", common.HexToAddress(workerAddress))
+		}
+		if exported, err := exportUnsignedTxs(cctx, buildCalls); err != nil || exported {
+			return err
+		}
+		if ok, err := confirmTxs(cctx, buildCalls); err != nil || !ok {
+			return err
+		}
+
 		cpRepoPath, _ := os.LookupEnv("CP_PATH")
 
 		client, cpStub, err := getVerifyAccountClient(ownerAddress)
@@ -865,6 +1056,9 @@ var changeTaskTypesCmd = &cli.Command{
 			Usage:    "Specify a OwnerAddress",
 			Required: true,
 		},
+		dryRunFlag,
+		yesFlag,
+		unsignedTxFlag,
 	},
 	Action: func(cctx *cli.Context) error {
 
@@ -906,6 +1100,16 @@ var changeTaskTypesCmd = &cli.Command{
 			taskTypesUint = append(taskTypesUint, uint8(tt))
 		}
 
+		buildCalls := func() ([]wallet.TxCall, error) {
+			return wallet.AccountCalls(ownerAddress, "", "changeTaskTypes", taskTypesUint)
+		}
+		if exported, err := exportUnsignedTxs(cctx, buildCalls); err != nil || exported {
+			return err
+		}
+		if ok, err := confirmTxs(cctx, buildCalls); err != nil || !ok {
+			return err
+		}
+
 		cpRepoPath, _ := os.LookupEnv("CP_PATH")
 
 		client, cpStub, err := getVerifyAccountClient(ownerAddress)
@@ -972,9 +1176,21 @@ var contractCmd = &cli.Command{
 						color:  []tablewriter.Colors{{tablewriter.Bold, tablewriter.FgBlueColor}},
 					})
 
+				result := contractInfo{
+					Network:                netWork,
+					SwanToken:              contract.SwanToken,
+					OrchestratorCollateral: contract.JobCollateral,
+					TaskManager:            contract.JobManager,
+					RegisterCp:             contract.CpAccountRegister,
+					RegisterTask:           contract.TaskRegister,
+					ZkCollateral:           contract.ZkCollateral,
+					Sequencer:              contract.Sequencer,
+					EdgeTaskPayment:        contract.EdgeTaskPayment,
+				}
 				header := []string{"CP Contract Info:"}
-				NewVisualTable(header, taskData, rowColorList).Generate(false)
-				return nil
+				return printResult(result, func() {
+					NewVisualTable(header, taskData, rowColorList).Generate(false)
+				})
 
 			},
 		},
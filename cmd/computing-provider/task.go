@@ -11,10 +11,83 @@ import (
 	"github.com/olekukonko/tablewriter"
 	"github.com/swanchain/go-computing-provider/conf"
 	"github.com/swanchain/go-computing-provider/internal/computing"
+	"github.com/swanchain/go-computing-provider/internal/contract"
 	"github.com/swanchain/go-computing-provider/internal/models"
 	"github.com/urfave/cli/v2"
 )
 
+// fcpTask is a FCP job in the results of the task list and task get commands
+type fcpTask struct {
+	JobUuid       string    `json:"job_uuid"`
+	TaskUuid      string    `json:"task_uuid"`
+	TaskType      string    `json:"task_type"`
+	WalletAddress string    `json:"wallet_address"`
+	SpaceUuid     string    `json:"space_uuid"`
+	SpaceName     string    `json:"space_name"`
+	SpaceUrl      string    `json:"space_url"`
+	Hardware      string    `json:"hardware"`
+	Status        string    `json:"status"`
+	Reward        string    `json:"reward"`
+	ResultUrl     string    `json:"result_url"`
+	CreateTime    time.Time `json:"create_time"`
+	ExpireTime    time.Time `json:"expire_time"`
+}
+
+func newFcpTask(job models.JobEntity) fcpTask {
+	return fcpTask{
+		JobUuid:       job.JobUuid,
+		TaskUuid:      job.TaskUuid,
+		TaskType:      job.ResourceType,
+		WalletAddress: job.WalletAddress,
+		SpaceUuid:     job.SpaceUuid,
+		SpaceName:     job.Name,
+		SpaceUrl:      job.RealUrl,
+		Hardware:      job.Hardware,
+		Status:        models.GetJobStatus(job.Status),
+		Reward:        contract.FormatWei(job.Reward, -1),
+		ResultUrl:     job.ResultUrl,
+		CreateTime:    time.Unix(job.CreateTime, 0),
+		ExpireTime:    time.Unix(computing.GetJobExpireTime(job), 0),
+	}
+}
+
+// ecpTask is a ECP job in the results of the task list and task get commands
+type ecpTask struct {
+	TaskUuid      string    `json:"task_uuid"`
+	TaskName      string    `json:"task_name"`
+	TaskType      string    `json:"task_type"`
+	Image         string    `json:"image"`
+	ContainerName string    `json:"container_name"`
+	Status        string    `json:"status"`
+	GpuName       string    `json:"gpu_name"`
+	GpuIndex      string    `json:"gpu_index"`
+	ServiceUrl    string    `json:"service_url"`
+	Ports         string    `json:"ports"`
+	Reward        string    `json:"reward"`
+	CreateTime    time.Time `json:"create_time"`
+}
+
+func newEcpTask(job models.EcpJobEntity, status string) ecpTask {
+	jobType := "Mining"
+	if job.JobType == models.InferenceJobType {
+		jobType = "Inference"
+	}
+	return ecpTask{
+		TaskUuid:      job.Uuid,
+		TaskName:      job.Name,
+		TaskType:      jobType,
+		Image:         job.Image,
+		ContainerName: job.ContainerName,
+		Status:        status,
+		GpuName:       job.GpuName,
+		GpuIndex:      job.GpuIndex,
+		ServiceUrl:    job.ServiceUrl,
+		Ports:         job.PortMap,
+		Reward:        contract.FormatWei(job.Reward, -1),
+		CreateTime:    time.Unix(job.CreateTime, 0),
+	}
+}
+
 var taskCmd = &cli.Command{
 	Name:  "task",
 	Usage: "Manage tasks",
@@ -143,7 +216,7 @@ var taskDetail = &cli.Command{
 			taskData = append(taskData, []string{"STATUS:", models.GetJobStatus(job.Status)})
 			taskData = append(taskData, []string{"RESULT URL:", job.ResultUrl})
 			taskData = append(taskData, []string{"CREATE TIME:", time.Unix(job.CreateTime, 0).Format("2006-01-02 15:04:05")})
-			taskData = append(taskData, []string{"EXPIRE TIME:", time.Unix(job.ExpireTime, 0).Format("2006-01-02 15:04:05")})
+			taskData = append(taskData, []string{"EXPIRE TIME:", time.Unix(computing.GetJobExpireTime(job), 0).Format("2006-01-02 15:04:05")})
 
 			rowColor := getColor(job.Status)
 			header := []string{"JOB UUID:", job.JobUuid}
@@ -154,7 +227,9 @@ var taskDetail = &cli.Command{
 				column: []int{1},
 				color:  rowColor,
 			})
-			NewVisualTable(header, taskData, rowColorList).SetAutoWrapText(false).Generate(false)
+			return printResult(newFcpTask(job), func() {
+				NewVisualTable(header, taskData, rowColorList).SetAutoWrapText(false).Generate(false)
+			})
 		} else {
 			job, err := computing.NewEcpJobService().GetEcpJobByUuid(jobUuid)
 			if err != nil {
@@ -178,10 +253,10 @@ var taskDetail = &cli.Command{
 			taskData = append(taskData, []string{"CREATE TIME:", time.Unix(job.CreateTime, 0).Format("2006-01-02 15:04:05")})
 
 			header := []string{"TASK UUID:", job.Uuid}
-			NewVisualTable(header, taskData, []RowColor{}).SetAutoWrapText(false).Generate(false)
+			return printResult(newEcpTask(*job, job.Status), func() {
+				NewVisualTable(header, taskData, []RowColor{}).SetAutoWrapText(false).Generate(false)
+			})
 		}
-
-		return nil
 	},
 }
 
@@ -300,15 +375,14 @@ func fcpTaskList(showCompleted, fullFlag bool, tailNum int) error {
 	if err != nil {
 		return fmt.Errorf("get jobs failed, error: %+v", err)
 	}
+	result := make([]fcpTask, 0, len(list))
 	for i, job := range list {
+		result = append(result, newFcpTask(*job))
 
-		expireTime := time.Unix(job.ExpireTime, 0).Format("2006-01-02 15:04:05")
+		expireTime := time.Unix(computing.GetJobExpireTime(*job), 0).Format("2006-01-02 15:04:05")
 		createTime := time.Unix(job.CreateTime, 0).Format("2006-01-02 15:04:05")
 
-		var reward = "0.00"
-		if len(strings.TrimSpace(job.Reward)) > 0 {
-			reward = job.Reward
-		}
+		reward := contract.FormatWei(job.Reward, 4)
 
 		rowColor := getColor(job.Status)
 
@@ -351,14 +425,15 @@ func fcpTaskList(showCompleted, fullFlag bool, tailNum int) error {
 		}
 	}
 
-	if fullFlag {
-		header := []string{"JOB UUID", "TASK UUID", "TASK TYPE", "WALLET ADDRESS", "SPACE UUID", "SPACE NAME", "STATUS", "REWARD", "CREATE TIME", "EXPIRE TIME"}
-		NewVisualTable(header, taskData, rowColorList).Generate(true)
-	} else {
-		header := []string{"JOB UUID", "TASK TYPE", "WALLET ADDRESS", "SPACE UUID", "SPACE NAME", "STATUS", "CREATE TIME", "EXPIRE TIME"}
-		NewVisualTable(header, taskData, rowColorList).Generate(true)
-	}
-	return nil
+	return printResult(result, func() {
+		if fullFlag {
+			header := []string{"JOB UUID", "TASK UUID", "TASK TYPE", "WALLET ADDRESS", "SPACE UUID", "SPACE NAME", "STATUS", "REWARD", "CREATE TIME", "EXPIRE TIME"}
+			NewVisualTable(header, taskData, rowColorList).Generate(true)
+		} else {
+			header := []string{"JOB UUID", "TASK TYPE", "WALLET ADDRESS", "SPACE UUID", "SPACE NAME", "STATUS", "CREATE TIME", "EXPIRE TIME"}
+			NewVisualTable(header, taskData, rowColorList).Generate(true)
+		}
+	})
 }
 
 func ecpTaskList(tailNum int) error {
@@ -375,6 +450,7 @@ func ecpTaskList(tailNum int) error {
 		return err
 	}
 
+	result := make([]ecpTask, 0, len(ecpJobs))
 	for i, entity := range ecpJobs {
 		createTime := time.Unix(entity.CreateTime, 0).Format("2006-01-02 15:04:05")
 		statusStr := "terminated"
@@ -384,7 +460,8 @@ func ecpTaskList(tailNum int) error {
 			}
 			statusStr = status
 		}
-		taskData = append(taskData, []string{entity.Uuid, entity.Name, entity.Image, entity.ContainerName, statusStr, fmt.Sprintf("%.4f", entity.Reward), createTime})
+		result = append(result, newEcpTask(entity, statusStr))
+		taskData = append(taskData, []string{entity.Uuid, entity.Name, entity.Image, entity.ContainerName, statusStr, contract.FormatWei(entity.Reward, 4), createTime})
 		rowColorList = append(rowColorList, RowColor{
 			row:    i,
 			column: []int{4},
@@ -392,8 +469,9 @@ func ecpTaskList(tailNum int) error {
 		})
 	}
 	header := []string{"TASK UUID", "TASK NAME", "IMAGE NAME", "CONTAINER NAME", "CONTAINER STATUS", "REWARD", "CREATE TIME"}
-	NewVisualTable(header, taskData, rowColorList).Generate(true)
-	return nil
+	return printResult(result, func() {
+		NewVisualTable(header, taskData, rowColorList).Generate(true)
+	})
 }
 
 func getContainerStatusColor(status string) []tablewriter.Colors {
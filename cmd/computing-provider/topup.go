@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/internal/computing"
+	"github.com/swanchain/go-computing-provider/internal/contract"
+	"github.com/swanchain/go-computing-provider/internal/models"
+	"github.com/urfave/cli/v2"
+)
+
+var topUpCmd = &cli.Command{
+	Name:  "topup",
+	Usage: "Manage the automatic top ups of the sequencer, the collateral and the worker address",
+	Subcommands: []*cli.Command{
+		topUpRunCmd,
+		topUpListCmd,
+	},
+	Before: func(c *cli.Context) error {
+		cpRepoPath, _ := os.LookupEnv("CP_PATH")
+		if err := conf.InitConfig(cpRepoPath, true); err != nil {
+			return fmt.Errorf("load config file failed, error: %+v", err)
+		}
+		return nil
+	},
+}
+
+var topUpRunCmd = &cli.Command{
+	Name:  "run",
+	Usage: "Top up the balances below their minimum once, within the daily caps of the TopUp config",
+	Action: func(cctx *cli.Context) error {
+		topUps, err := computing.TopUpBalances()
+		for _, topUp := range topUps {
+			printMessage("%s: %s %s from %s, tx: %s %s\n", topUp.Kind, topUp.Status, contract.FormatWei(topUp.Amount, -1),
+				topUp.FromAddress, topUp.TxHash, topUp.Message)
+		}
+		if err != nil {
+			return err
+		}
+		if len(topUps) == 0 {
+			printMessage("no balance is below its minimum\n")
+		}
+		return nil
+	},
+}
+
+var topUpListCmd = &cli.Command{
+	Name:  "list",
+	Usage: "List the audit trail of the top ups",
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:  "limit",
+			Usage: "The number of the last top ups listed, 0 for all",
+			Value: 50,
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		topUps, err := computing.NewTopUpService().GetTopUps(cctx.Int("limit"))
+		if err != nil {
+			return fmt.Errorf("failed to get the top ups, error: %v", err)
+		}
+
+		var items []topUpItem
+		var taskData [][]string
+		for _, topUp := range topUps {
+			item := topUpItem{
+				Time:        time.Unix(topUp.CreateTime, 0),
+				Kind:        topUp.Kind,
+				FromAddress: topUp.FromAddress,
+				ToAddress:   topUp.ToAddress,
+				Balance:     contract.FormatWei(topUp.Balance, -1),
+				Amount:      contract.FormatWei(topUp.Amount, -1),
+				Unit:        topUpUnit(topUp.Kind),
+				Status:      topUp.Status,
+				TxHash:      topUp.TxHash,
+				Message:     topUp.Message,
+			}
+			items = append(items, item)
+			taskData = append(taskData, []string{item.Time.Format(time.DateTime), item.Kind, item.FromAddress, item.ToAddress,
+				contract.FormatWei(topUp.Balance, 4) + " " + item.Unit, contract.FormatWei(topUp.Amount, 4) + " " + item.Unit,
+				item.Status, item.TxHash, item.Message})
+		}
+
+		header := []string{"TIME", "KIND", "FROM", "TO", "BALANCE", "AMOUNT", "STATUS", "TX HASH", "MESSAGE"}
+		return printResult(items, func() {
+			NewVisualTable(header, taskData, []RowColor{}).SetAutoWrapText(false).Generate(false)
+		})
+	},
+}
+
+type topUpItem struct {
+	Time        time.Time `json:"time"`
+	Kind        string    `json:"kind"`
+	FromAddress string    `json:"from_address"`
+	ToAddress   string    `json:"to_address"`
+	Balance     string    `json:"balance"`
+	Amount      string    `json:"amount"`
+	Unit        string    `json:"unit"`
+	Status      string    `json:"status"`
+	TxHash      string    `json:"tx_hash"`
+	Message     string    `json:"message"`
+}
+
+func topUpUnit(kind string) string {
+	if kind == models.TopUpKindFcpCollateral || kind == models.TopUpKindEcpCollateral {
+		return "SWAN"
+	}
+	return "ETH"
+}
@@ -29,6 +29,43 @@ var ubiTaskCmd = &cli.Command{
 	},
 }
 
+// ubiTask is a ubi task in the result of the ubi list command
+type ubiTask struct {
+	TaskId       string    `json:"task_id"`
+	TaskContract string    `json:"task_contract"`
+	TaskType     string    `json:"task_type"`
+	ZkType       string    `json:"zk_type"`
+	CheckCode    string    `json:"check_code"`
+	Signature    string    `json:"signature"`
+	Status       string    `json:"status"`
+	Sequencer    bool      `json:"sequencer"`
+	CreateTime   time.Time `json:"create_time"`
+}
+
+func newUbiTask(task *models.TaskEntity) ubiTask {
+	taskId := strconv.Itoa(int(task.Id))
+	if task.Type == models.Mining {
+		taskId = task.Uuid
+	}
+	var contract string
+	if task.Sequencer == 1 {
+		contract = task.SequenceTaskAddr
+	} else if task.Sequencer == 0 {
+		contract = task.Contract
+	}
+	return ubiTask{
+		TaskId:       taskId,
+		TaskContract: contract,
+		TaskType:     models.GetResourceTypeStr(task.ResourceType),
+		ZkType:       models.UbiTaskTypeStr(task.Type),
+		CheckCode:    task.CheckCode,
+		Signature:    task.Sign,
+		Status:       models.TaskStatusStr(task.Status),
+		Sequencer:    task.Sequencer == 1,
+		CreateTime:   time.Unix(task.CreateTime, 0),
+	}
+}
+
 var listCmd = &cli.Command{
 	Name:  "list",
 	Usage: "List ubi task",
@@ -78,6 +115,14 @@ var listCmd = &cli.Command{
 			}
 		}
 
+		result := make([]ubiTask, 0, len(taskList))
+		for _, task := range taskList {
+			result = append(result, newUbiTask(task))
+		}
+		if outputFormat != outputTable {
+			return printResult(result, nil)
+		}
+
 		if fullFlag {
 			for i, task := range taskList {
 				createTime := time.Unix(task.CreateTime, 0).Format("2006-01-02 15:04:05")
@@ -186,20 +231,13 @@ var daemonCmd = &cli.Command{
 			}
 		}
 
-		traefikServiceContainerName := "traefik-service"
-		tsExist, _, err := computing.NewDockerService().CheckRunningContainer(traefikServiceContainerName)
-		if err != nil {
-			return fmt.Errorf("check %s container failed, error: %v", traefikServiceContainerName, err)
-		}
-		if !tsExist {
-			if err = computing.RestartTraefikService(); err != nil {
-				logs.GetLogger().Errorf("restartTraefikService failed, error: %v", err)
-			}
-		}
-
 		if err := conf.InitConfig(cpRepoPath, true); err != nil {
 			logs.GetLogger().Fatal(err)
 		}
+
+		if err = computing.EnsureTraefikService(); err != nil {
+			logs.GetLogger().Errorf("restartTraefikService failed, error: %v", err)
+		}
 		logs.GetLogger().Info("Your config file is:", filepath.Join(cpRepoPath, "config.toml"))
 
 		computing.SyncCpAccountInfo()
@@ -228,6 +266,8 @@ var daemonCmd = &cli.Command{
 		router.POST("/cp/deploy", ecpImageService.DeployJob)
 		router.GET("/cp/job/status", ecpImageService.GetJobStatus)
 		router.GET("/cp/job/log", ecpImageService.DockerLogsHandler)
+		router.GET("/cp/job/:job_uuid/events", computing.GetJobEvents)
+		router.GET("/cp/job/:job_uuid/build_log", computing.GetJobBuildLog)
 		router.DELETE("/cp/job/:job_uuid", ecpImageService.DeleteJob)
 		router.POST("/cp/zk_task", computing.DoZkTask)
 
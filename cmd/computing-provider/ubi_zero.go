@@ -127,19 +127,43 @@ var collateralInfoCmd = &cli.Command{
 		taskData = append(taskData, []string{"   Collateral:", fcpCollateralBalance})
 		taskData = append(taskData, []string{"   Escrow:", fcpEscrowBalance})
 
+		result := cpAccountInfo{
+			Network:            netWork,
+			CpAccount:          contractAddress,
+			Version:            version,
+			Name:               conf.GetConfig().API.NodeName,
+			Owner:              ownerAddress,
+			NodeId:             localNodeId,
+			Domain:             domain,
+			MultiAddresses:     []string{conf.GetConfig().API.MultiAddress},
+			WorkerAddress:      workerAddress,
+			BeneficiaryAddress: beneficiaryAddress,
+			Balances: cpBalances{
+				Owner:         ownerBalance,
+				Worker:        workerBalance,
+				EcpCollateral: ecpCollateralBalance,
+				EcpEscrow:     ecpEscrowBalance,
+				FcpCollateral: fcpCollateralBalance,
+				FcpEscrow:     fcpEscrowBalance,
+			},
+		}
 		header := []string{"CP Account Info:"}
-		NewVisualTable(header, taskData, []RowColor{}).SetAutoWrapText(false).Generate(false)
+		if printErr := printResult(result, func() {
+			NewVisualTable(header, taskData, []RowColor{}).SetAutoWrapText(false).Generate(false)
+		}); printErr != nil {
+			return printErr
+		}
 		if err != nil {
 			return err
 		}
 
 		if contractAddress == "" {
-			fmt.Printf("Error: CP Account does not exist, please run 'computing-provider account create'.\n")
+			printMessage("Error: CP Account does not exist, please run 'computing-provider account create'.\n")
 			return nil
 		}
 
 		if localNodeId != chainNodeId {
-			fmt.Printf("NodeId mismatch, local node id: %s, chain node id: %s.\n", localNodeId, chainNodeId)
+			printMessage("NodeId mismatch, local node id: %s, chain node id: %s.\n", localNodeId, chainNodeId)
 		}
 		return nil
 	},
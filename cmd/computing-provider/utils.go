@@ -76,10 +76,9 @@ func createAccount(cpRepoPath, ownerAddress, beneficiaryAddress string, workerAd
 	auth.Context = context.Background()
 
 	nodeID := computing.GetNodeId(cpRepoPath)
-	multiAddresses := conf.GetConfig().API.MultiAddress
-
-	if strings.Contains(conf.GetConfig().API.MultiAddress, "<") || strings.Contains(conf.GetConfig().API.MultiAddress, "PUBLIC") {
-		return fmt.Errorf("the multi-address field needs to be configured, by modify config file or computing-provider init")
+	multiAddresses, err := accountMultiAddress()
+	if err != nil {
+		return err
 	}
 
 	contractAddress, tx, _, err := account.DeployAccount(auth, client, nodeID, []string{multiAddresses}, common.HexToAddress(beneficiaryAddress),
@@ -89,7 +88,28 @@ func createAccount(cpRepoPath, ownerAddress, beneficiaryAddress string, workerAd
 	}
 	cpAccountAddress := contractAddress.Hex()
 
-	err = os.WriteFile(filepath.Join(cpRepoPath, "account"), []byte(cpAccountAddress), 0666)
+	if err = saveCpAccount(cpRepoPath, nodeID, cpAccountAddress, ownerAddress, beneficiaryAddress, workerAddress, []string{multiAddresses}, taskTypes); err != nil {
+		return err
+	}
+
+	fmt.Printf("Contract deployed! Address: %s\n", cpAccountAddress)
+	fmt.Printf("Transaction hash: %s\n", tx.Hash().Hex())
+	fmt.Println("computing-provider account is created successfully! You can now start it with 'computing-provider run' or 'computing-provider ubi daemon'")
+	return nil
+}
+
+// accountMultiAddress returns the multi-address of the config the cp account is created with
+func accountMultiAddress() (string, error) {
+	multiAddresses := conf.GetConfig().API.MultiAddress
+	if strings.Contains(multiAddresses, "<") || strings.Contains(multiAddresses, "PUBLIC") {
+		return "", fmt.Errorf("the multi-address field needs to be configured, by modify config file or computing-provider init")
+	}
+	return multiAddresses, nil
+}
+
+// saveCpAccount writes the address of the cp account created to the account file, and saves its info to the db
+func saveCpAccount(cpRepoPath, nodeID, cpAccountAddress, ownerAddress, beneficiaryAddress, workerAddress string, multiAddresses []string, taskTypes []uint8) error {
+	err := os.WriteFile(filepath.Join(cpRepoPath, "account"), []byte(cpAccountAddress), 0666)
 	if err != nil {
 		return fmt.Errorf("write cp account contract address to fie failed, error: %v", err)
 	}
@@ -102,15 +122,11 @@ func createAccount(cpRepoPath, ownerAddress, beneficiaryAddress string, workerAd
 	cpInfo.ContractAddress = cpAccountAddress
 	cpInfo.CreateAt = time.Now().Format("2006-01-02 15:04:05")
 	cpInfo.UpdateAt = time.Now().Format("2006-01-02 15:04:05")
-	cpInfo.MultiAddresses = []string{multiAddresses}
+	cpInfo.MultiAddresses = multiAddresses
 	cpInfo.TaskTypes = taskTypes
 	if err = computing.NewCpInfoService().SaveCpInfoEntity(cpInfo); err != nil {
 		return fmt.Errorf("save cp info to db failed, error: %v", err)
 	}
-
-	fmt.Printf("Contract deployed! Address: %s\n", cpAccountAddress)
-	fmt.Printf("Transaction hash: %s\n", tx.Hash().Hex())
-	fmt.Println("computing-provider account is created successfully! You can now start it with 'computing-provider run' or 'computing-provider ubi daemon'")
 	return nil
 }
 
@@ -31,10 +31,13 @@ var walletCmd = &cli.Command{
 		walletSign,
 		walletVerify,
 		walletSend,
+		walletSignTx,
+		walletBroadcastTx,
 	},
 	Before: func(c *cli.Context) error {
 		if c.Args().Present() {
-			if strings.EqualFold(c.Args().First(), walletList.Name) || strings.EqualFold(c.Args().First(), walletSend.Name) {
+			if strings.EqualFold(c.Args().First(), walletList.Name) || strings.EqualFold(c.Args().First(), walletSend.Name) ||
+				strings.EqualFold(c.Args().First(), walletBroadcastTx.Name) {
 				cpRepoPath, _ := os.LookupEnv("CP_PATH")
 				if err := conf.InitConfig(cpRepoPath, true); err != nil {
 					return err
@@ -83,7 +86,14 @@ var walletList = &cli.Command{
 			return err
 		}
 
-		return localWallet.WalletList(ctx, swanContractFlag)
+		if outputFormat == outputTable {
+			return localWallet.WalletList(ctx, swanContractFlag)
+		}
+		wallets, err := localWallet.WalletBalances(ctx, swanContractFlag)
+		if err != nil {
+			return err
+		}
+		return printResult(wallets, nil)
 	},
 }
 
@@ -267,6 +277,8 @@ var walletSend = &cli.Command{
 			Usage: "optionally specify the nonce to use",
 			Value: 0,
 		},
+		dryRunFlag,
+		yesFlag,
 	},
 	Action: func(cctx *cli.Context) error {
 		ctx := reqContext(cctx)
@@ -290,6 +302,12 @@ var walletSend = &cli.Command{
 		if strings.TrimSpace(amount) == "" {
 			return fmt.Errorf("failed to get amount: %s", amount)
 		}
+		if ok, err := confirmTxs(cctx, func() ([]wallet.TxCall, error) {
+			return wallet.SendCalls(from, to, amount)
+		}); err != nil || !ok {
+			return err
+		}
+
 		localWallet, err := wallet.SetupWallet(wallet.WalletRepo)
 		if err != nil {
 			return err
@@ -299,6 +317,7 @@ var walletSend = &cli.Command{
 			return err
 		}
 		fmt.Println(txHash)
+		recordTxGas(txHash, "")
 		return nil
 	},
 }
@@ -344,6 +363,8 @@ var collateralAddCmd = &cli.Command{
 			Name:  "account",
 			Usage: "Specify the cp account address, if not specified, cp account is the content of the account file under the CP_PATH variable",
 		},
+		dryRunFlag,
+		yesFlag,
 	},
 	ArgsUsage: "[amount]",
 	Action: func(cctx *cli.Context) error {
@@ -378,6 +399,12 @@ var collateralAddCmd = &cli.Command{
 			return fmt.Errorf("failed to get amount: %s", amount)
 		}
 
+		if ok, err := confirmTxs(cctx, func() ([]wallet.TxCall, error) {
+			return wallet.CollateralCalls(fromAddress, amount, cpAccountAddress, collateralType)
+		}); err != nil || !ok {
+			return err
+		}
+
 		localWallet, err := wallet.SetupWallet(wallet.WalletRepo)
 		if err != nil {
 			return err
@@ -388,6 +415,7 @@ var collateralAddCmd = &cli.Command{
 		}
 
 		fmt.Printf("collateral TX Hash: %s \n", txHash)
+		recordTxGas(txHash, collateralType)
 		return nil
 	},
 }
@@ -412,6 +440,9 @@ var collateralWithdrawCmd = &cli.Command{
 			Name:  "account",
 			Usage: "Specify the cp account address, if not specified, cp account is the content of the account file under the CP_PATH variable",
 		},
+		dryRunFlag,
+		yesFlag,
+		unsignedTxFlag,
 	},
 	ArgsUsage: "[amount]",
 	Action: func(cctx *cli.Context) error {
@@ -445,6 +476,16 @@ var collateralWithdrawCmd = &cli.Command{
 			return fmt.Errorf("the amount param is required")
 		}
 
+		buildCalls := func() ([]wallet.TxCall, error) {
+			return wallet.CollateralWithdrawCalls(ownerAddress, amount, cpAccountAddress, withdrawType, "withdraw")
+		}
+		if exported, err := exportUnsignedTxs(cctx, buildCalls); err != nil || exported {
+			return err
+		}
+		if ok, err := confirmTxs(cctx, buildCalls); err != nil || !ok {
+			return err
+		}
+
 		localWallet, err := wallet.SetupWallet(wallet.WalletRepo)
 		if err != nil {
 			return err
@@ -454,6 +495,7 @@ var collateralWithdrawCmd = &cli.Command{
 			return err
 		}
 		fmt.Println(txHash)
+		recordTxGas(txHash, withdrawType)
 		return nil
 	},
 }
@@ -478,6 +520,9 @@ var collateralWithDrawRequestCmd = &cli.Command{
 			Name:  "account",
 			Usage: "Specify the cp account address, if not specified, cp account is the content of the account file under the CP_PATH variable",
 		},
+		dryRunFlag,
+		yesFlag,
+		unsignedTxFlag,
 	},
 	ArgsUsage: "[amount]",
 	Action: func(cctx *cli.Context) error {
@@ -506,6 +551,16 @@ var collateralWithDrawRequestCmd = &cli.Command{
 			return fmt.Errorf("the amount param is required")
 		}
 
+		buildCalls := func() ([]wallet.TxCall, error) {
+			return wallet.CollateralWithdrawCalls(ownerAddress, amount, cpAccountAddress, withdrawType, "requestWithdraw")
+		}
+		if exported, err := exportUnsignedTxs(cctx, buildCalls); err != nil || exported {
+			return err
+		}
+		if ok, err := confirmTxs(cctx, buildCalls); err != nil || !ok {
+			return err
+		}
+
 		localWallet, err := wallet.SetupWallet(wallet.WalletRepo)
 		if err != nil {
 			return err
@@ -515,6 +570,7 @@ var collateralWithDrawRequestCmd = &cli.Command{
 			return err
 		}
 		fmt.Println(txHash)
+		recordTxGas(txHash, withdrawType)
 		return nil
 	},
 }
@@ -539,6 +595,9 @@ var collateralWithDrawConfirmCmd = &cli.Command{
 			Name:  "account",
 			Usage: "Specify the cp account address, if not specified, cp account is the content of the account file under the CP_PATH variable",
 		},
+		dryRunFlag,
+		yesFlag,
+		unsignedTxFlag,
 	},
 	Action: func(cctx *cli.Context) error {
 		ctx := reqContext(cctx)
@@ -562,6 +621,16 @@ var collateralWithDrawConfirmCmd = &cli.Command{
 
 		cpAccountAddress := cctx.String("account")
 
+		buildCalls := func() ([]wallet.TxCall, error) {
+			return wallet.CollateralWithdrawCalls(ownerAddress, "", cpAccountAddress, withdrawType, "confirmWithdraw")
+		}
+		if exported, err := exportUnsignedTxs(cctx, buildCalls); err != nil || exported {
+			return err
+		}
+		if ok, err := confirmTxs(cctx, buildCalls); err != nil || !ok {
+			return err
+		}
+
 		localWallet, err := wallet.SetupWallet(wallet.WalletRepo)
 		if err != nil {
 			return err
@@ -571,10 +640,20 @@ var collateralWithDrawConfirmCmd = &cli.Command{
 			return err
 		}
 		fmt.Println(txHash)
+		recordTxGas(txHash, withdrawType)
 		return nil
 	},
 }
 
+// withdrawViewResult is the result of the collateral withdraw-view command, without a confirmable time before a request
+type withdrawViewResult struct {
+	Amount           string     `json:"amount"`
+	LatestBlock      uint64     `json:"latest_block"`
+	RequestBlock     int64      `json:"request_block"`
+	ConfirmableBlock int64      `json:"confirmable_block"`
+	ConfirmableTime  *time.Time `json:"confirmable_time,omitempty"`
+}
+
 var collateralWithDrawViewCmd = &cli.Command{
 	Name:  "withdraw-view",
 	Usage: "View a request to withdraw tokens from the collateral escrow account from the collateral contract",
@@ -632,6 +711,7 @@ var collateralWithDrawViewCmd = &cli.Command{
 		var requestBlock int64
 		var confirmableBlock int64
 		var confirmableBlockStr string
+		var confirmableTime *time.Time
 		if withdrawView.RequestBlock != 0 {
 			amount = withdrawView.Amount
 			requestBlock = withdrawView.RequestBlock
@@ -652,6 +732,7 @@ var collateralWithDrawViewCmd = &cli.Command{
 			}
 
 			currentTime := time.Unix(int64(block.Time)+withdrawView.WithdrawDelay*secondFlag, 0)
+			confirmableTime = &currentTime
 			timeStr := currentTime.Format("2006-01-02T15:04:05")
 			timeZone, _ := currentTime.Zone()
 			confirmableBlockStr = fmt.Sprintf("%d\u200B(%s+\u200B%s)", confirmableBlock, timeStr, timeZone)
@@ -662,10 +743,17 @@ var collateralWithDrawViewCmd = &cli.Command{
 		taskData = append(taskData, []string{"Request Block:", strconv.Itoa(int(requestBlock))})
 		taskData = append(taskData, []string{"Confirmable Block:", confirmableBlockStr})
 
+		result := withdrawViewResult{
+			Amount:           amount,
+			LatestBlock:      latestBlockNumber,
+			RequestBlock:     requestBlock,
+			ConfirmableBlock: confirmableBlock,
+			ConfirmableTime:  confirmableTime,
+		}
 		header := []string{"Withdraw View:"}
-		NewVisualTable(header, taskData, []RowColor{}).SetAutoWrapText(false).Generate(false)
-
-		return nil
+		return printResult(result, func() {
+			NewVisualTable(header, taskData, []RowColor{}).SetAutoWrapText(false).Generate(false)
+		})
 	},
 }
 
@@ -709,6 +797,7 @@ var collateralSendCmd = &cli.Command{
 			return err
 		}
 		fmt.Println(txHash)
+		recordTxGas(txHash, "")
 		return nil
 	},
 }
@@ -756,6 +845,8 @@ var sequencerAddCmd = &cli.Command{
 			Name:  "account",
 			Usage: "Specify the cp account address, if not specified, cp account is the content of the account file under the CP_PATH variable",
 		},
+		dryRunFlag,
+		yesFlag,
 	},
 	ArgsUsage: "[amount]",
 	Action: func(cctx *cli.Context) error {
@@ -773,6 +864,12 @@ var sequencerAddCmd = &cli.Command{
 			return fmt.Errorf("failed to get amount: %s", amount)
 		}
 
+		if ok, err := confirmTxs(cctx, func() ([]wallet.TxCall, error) {
+			return wallet.SequencerDepositCalls(fromAddress, amount, cpAccountAddress)
+		}); err != nil || !ok {
+			return err
+		}
+
 		localWallet, err := wallet.SetupWallet(wallet.WalletRepo)
 		if err != nil {
 			return err
@@ -784,6 +881,7 @@ var sequencerAddCmd = &cli.Command{
 		computing.GetCpBalance()
 
 		fmt.Printf("Transfer to Sequencer Account Tx Hash: %s \n", txHash)
+		recordTxGas(txHash, computing.LedgerTaskTypeUbi)
 		return nil
 	},
 }
@@ -800,6 +898,9 @@ var sequencerWithdrawCmd = &cli.Command{
 			Name:  "account",
 			Usage: "Specify the cp account address, if not specified, cp account is the content of the account file under the CP_PATH variable",
 		},
+		dryRunFlag,
+		yesFlag,
+		unsignedTxFlag,
 	},
 	ArgsUsage: "[amount]",
 	Action: func(cctx *cli.Context) error {
@@ -816,6 +917,16 @@ var sequencerWithdrawCmd = &cli.Command{
 			return fmt.Errorf("the amount param is required")
 		}
 
+		buildCalls := func() ([]wallet.TxCall, error) {
+			return wallet.SequencerWithdrawCalls(ownerAddress, amount, cpAccountAddress)
+		}
+		if exported, err := exportUnsignedTxs(cctx, buildCalls); err != nil || exported {
+			return err
+		}
+		if ok, err := confirmTxs(cctx, buildCalls); err != nil || !ok {
+			return err
+		}
+
 		localWallet, err := wallet.SetupWallet(wallet.WalletRepo)
 		if err != nil {
 			return err
@@ -826,6 +937,7 @@ var sequencerWithdrawCmd = &cli.Command{
 		}
 		computing.GetCpBalance()
 		fmt.Println(txHash)
+		recordTxGas(txHash, computing.LedgerTaskTypeUbi)
 		return nil
 	},
 }
@@ -35,14 +35,25 @@ func (p *Pricing) UnmarshalTOML(data interface{}) error {
 
 // ComputeNode is a compute node config
 type ComputeNode struct {
-	API      API
-	UBI      UBI
-	LOG      LOG
-	HUB      HUB
-	MCS      MCS
-	Registry Registry
-	RPC      RPC
-	CONTRACT CONTRACT `toml:"CONTRACT,omitempty"`
+	API         API
+	UBI         UBI
+	LOG         LOG
+	HUB         HUB
+	MCS         MCS
+	Registry    Registry
+	Registries  []RegistryAuth `toml:"Registries,omitempty"`
+	ImageCache  ImageCache     `toml:"ImageCache,omitempty"`
+	ImagePolicy ImagePolicy    `toml:"ImagePolicy,omitempty"`
+	Build       Build          `toml:"Build,omitempty"`
+	Download    Download       `toml:"Download,omitempty"`
+	ModelCache  ModelCache     `toml:"ModelCache,omitempty"`
+	RPC         RPC
+	Security    Security `toml:"Security,omitempty"`
+	TLS         TLS      `toml:"TLS,omitempty"`
+	Health      Health   `toml:"Health,omitempty"`
+	Alert       Alert    `toml:"Alert,omitempty"`
+	TopUp       TopUp    `toml:"TopUp,omitempty"`
+	CONTRACT    CONTRACT `toml:"CONTRACT,omitempty"`
 }
 
 type API struct {
@@ -57,6 +68,8 @@ type API struct {
 	ClearLogDuration              int      `toml:"ClearLogDuration"`
 	PortRange                     []string `toml:"PortRange"`
 	GpuUtilizationRejectThreshold float64  `toml:"GpuUtilizationRejectThreshold"`
+	DeployWorkers                 int      `toml:"DeployWorkers"`
+	DeployHealthTimeout           int      `toml:"DeployHealthTimeout"`
 }
 type UBI struct {
 	UbiEnginePk     string
@@ -90,8 +103,152 @@ type Registry struct {
 	Password      string
 }
 
+// RegistryAuth is the credential and the mirrors of an image registry, the Registry block stays the credential of the
+// registry the space images are pushed to
+type RegistryAuth struct {
+	Server   string   `toml:"Server"` // e.g. docker.io, ghcr.io, registry.example.com:5000
+	UserName string   `toml:"UserName"`
+	Password string   `toml:"Password"`
+	Mirrors  []string `toml:"Mirrors"` // the mirrors tried in order before the registry, e.g. mirror.gcr.io for docker.io
+}
+
+// ImageCache keeps the pulled job images on the node, the least recently used ones are removed beyond the size budget
+type ImageCache struct {
+	PullPolicy    string   `toml:"PullPolicy"`    // "IfNotPresent" to run the image on the node, "Always" to pull a tag again for its latest content
+	MaxSize       float64  `toml:"MaxSize"`       // the size budget of the images in GiB, 0 to remove all the unused images like AutoDeleteImage
+	PinnedImages  []string `toml:"PinnedImages"`  // the images never removed
+	PinUseCount   int      `toml:"PinUseCount"`   // the images used by at least this many jobs are never removed, 0 to disable
+	PrewarmImages []string `toml:"PrewarmImages"` // the images pulled at daemon start, they are pinned too
+}
+
+// the pull policies of ImageCache.PullPolicy, named like the image pull policies of k8s
+const (
+	PullIfNotPresent = "IfNotPresent"
+	PullAlways       = "Always"
+)
+
+// ImagePolicy is the operator policy on the images of the jobs, the FROM images of their Dockerfiles included
+type ImagePolicy struct {
+	AllowList     []string `toml:"AllowList"`     // the registries or repositories allowed, e.g. "ghcr.io", "docker.io/library/*", empty allows all
+	DenyList      []string `toml:"DenyList"`      // the registries or repositories denied, checked before AllowList
+	MaxImageSize  float64  `toml:"MaxImageSize"`  // the size limit of an image in the registry (compressed) in GiB, 0 for unlimited
+	RequireDigest bool     `toml:"RequireDigest"` // the images must be pinned by digest, e.g. nginx@sha256:...
+	CosignKeys    []string `toml:"CosignKeys"`    // the cosign public keys, an image must be signed by one of them when set
+	CosignPath    string   `toml:"CosignPath"`    // the cosign binary, empty for cosign in PATH
+}
+
+// Build is the sandbox the Dockerfiles of the jobs are built in, both modes build with BuildKit
+type Build struct {
+	Mode      string  `toml:"Mode"`      // "rootless" for a rootless BuildKit container per build, "daemon" for the BuildKit of the local docker daemon, without the caps of Cpus and Memory
+	Image     string  `toml:"Image"`     // the image of the rootless BuildKit container, empty for the default
+	Cpus      float64 `toml:"Cpus"`      // the cpus of a rootless build, 0 for unlimited
+	Memory    float64 `toml:"Memory"`    // the memory of a rootless build in GiB, 0 for unlimited
+	Timeout   int     `toml:"Timeout"`   // the seconds a build may take
+	NoNetwork bool    `toml:"NoNetwork"` // run the RUN instructions without network, the base images are still pulled
+	CacheDir  string  `toml:"CacheDir"`  // the build cache of the rootless builds, one directory per space, empty for $CP_PATH/build/cache
+}
+
+// Download is how the resource files of the spaces are downloaded before the build
+type Download struct {
+	Workers int     `toml:"Workers"` // the files of a space downloaded at the same time
+	Retries int     `toml:"Retries"` // the retries of a file, an interrupted download resumes from where it stopped
+	MaxSize float64 `toml:"MaxSize"` // the size limit of the files of a space in GiB, 0 for unlimited
+}
+
+// ModelCache is the cache of the model files of the inference jobs, shared by the jobs of a node
+type ModelCache struct {
+	Dir      string  `toml:"Dir"`      // the cache of the docker host, empty for $CP_PATH/model-cache
+	HostPath string  `toml:"HostPath"` // the cache on the nodes of the k8s cluster, mounted into the prefetch jobs and the pods
+	MaxSize  float64 `toml:"MaxSize"`  // the size limit of a cache in GiB, the least recently used models are evicted beyond it, 0 for unlimited
+	Timeout  int     `toml:"Timeout"`  // the time limit of prefetching a model in seconds
+}
+
 type RPC struct {
-	SwanChainRpc string `toml:"SWAN_CHAIN_RPC"`
+	SwanChainRpc  string `toml:"SWAN_CHAIN_RPC"`
+	SwanChainWs   string `toml:"SWAN_CHAIN_WS"` // the websocket endpoint the event indexer subscribes to the new events with, empty to only scan
+	Confirmations uint64 `toml:"Confirmations"` // the blocks below the chain head the scanners stop at, so a reorg rarely orphans a scanned event
+}
+
+// Health is the health monitor of the ECP inference containers
+type Health struct {
+	Interval         int    `toml:"Interval"`         // the seconds between two checks
+	Timeout          int    `toml:"Timeout"`          // the seconds a check waits for the health path or port
+	FailureThreshold int    `toml:"FailureThreshold"` // the consecutive failed checks that make a job unhealthy
+	RestartPolicy    string `toml:"RestartPolicy"`    // "no", "on-failure" to restart the unhealthy or crashed containers, or "always" to restart the exited ones too
+	MaxRestarts      int    `toml:"MaxRestarts"`      // the restarts of a job before the monitor gives up on it
+}
+
+// Alert is the alerts on the balances and the collateral of the provider, a threshold of 0 disables its alert
+type Alert struct {
+	Enable                 bool     `toml:"Enable"`
+	Interval               int      `toml:"Interval"`               // the seconds between two checks
+	RepeatInterval         int      `toml:"RepeatInterval"`         // the seconds before an alert still firing is sent again
+	OwnerBalance           float64  `toml:"OwnerBalance"`           // the ETH of the owner address below which an alert fires
+	WorkerBalance          float64  `toml:"WorkerBalance"`          // the ETH of the worker address below which an alert fires
+	SequencerBalance       float64  `toml:"SequencerBalance"`       // the ETH of the cp account in the sequencer below which an alert fires
+	FcpAvailableCollateral float64  `toml:"FcpAvailableCollateral"` // the available FCP collateral in SWAN below which an alert fires
+	EcpAvailableCollateral float64  `toml:"EcpAvailableCollateral"` // the available ECP collateral in SWAN below which an alert fires
+	FcpFrozenCollateral    float64  `toml:"FcpFrozenCollateral"`    // the locked FCP collateral in SWAN above which an alert fires
+	EcpFrozenCollateral    float64  `toml:"EcpFrozenCollateral"`    // the frozen ECP collateral in SWAN above which an alert fires
+	FrozenGrowth           bool     `toml:"FrozenGrowth"`           // alert each time a frozen collateral grows
+	Slashing               bool     `toml:"Slashing"`               // alert on the slashes of the collateral, from the indexed events
+	Webhooks               []string `toml:"Webhooks"`               // the urls the alerts are posted to as json
+	ChatWebhooks           []string `toml:"ChatWebhooks"`           // the Slack or Discord compatible webhooks the alerts are posted to as a message
+	Smtp                   Smtp     `toml:"Smtp"`
+}
+
+// TopUp is the automatic deposits into the sequencer, the collateral and the worker address when their balances fall
+// below the minimum, a minimum of 0 disables its top up
+type TopUp struct {
+	Enable                 bool    `toml:"Enable"`
+	From                   string  `toml:"From"`                   // the address the funds are moved from, its key must be in the wallet, default is the owner address
+	Interval               int     `toml:"Interval"`               // the seconds between two checks
+	SequencerMinBalance    float64 `toml:"SequencerMinBalance"`    // the ETH of the cp account in the sequencer below which it is topped up
+	SequencerTargetBalance float64 `toml:"SequencerTargetBalance"` // the ETH of the cp account in the sequencer after a top up
+	WorkerMinBalance       float64 `toml:"WorkerMinBalance"`       // the ETH of the worker address below which it is topped up
+	WorkerTargetBalance    float64 `toml:"WorkerTargetBalance"`    // the ETH of the worker address after a top up
+	FcpMinCollateral       float64 `toml:"FcpMinCollateral"`       // the available FCP collateral in SWAN below which it is topped up
+	FcpTargetCollateral    float64 `toml:"FcpTargetCollateral"`    // the available FCP collateral in SWAN after a top up
+	EcpMinCollateral       float64 `toml:"EcpMinCollateral"`       // the available ECP collateral in SWAN below which it is topped up
+	EcpTargetCollateral    float64 `toml:"EcpTargetCollateral"`    // the available ECP collateral in SWAN after a top up
+	DailyEthCap            float64 `toml:"DailyEthCap"`            // the ETH moved to the sequencer and the worker address in 24 hours at most
+	DailySwanCap           float64 `toml:"DailySwanCap"`           // the SWAN deposited to the collateral in 24 hours at most
+}
+
+// Smtp is the mail server the alerts are sent with, the alerts are not mailed when Host is empty
+type Smtp struct {
+	Host     string   `toml:"Host"`
+	Port     int      `toml:"Port"` // 465 for implicit TLS, otherwise STARTTLS when the server offers it
+	UserName string   `toml:"UserName"`
+	Password string   `toml:"Password"`
+	From     string   `toml:"From"`
+	To       []string `toml:"To"`
+}
+
+// TLS is the https entrypoint of traefik for the ECP inference endpoints
+type TLS struct {
+	Mode           string   `toml:"Mode"`           // empty for http only, "acme", or "file" to use LOG.CrtFile and LOG.KeyFile
+	HttpsPort      int      `toml:"HttpsPort"`      // the host port of the https entrypoint
+	AcmeEmail      string   `toml:"AcmeEmail"`      // the ACME account email
+	AcmeCAServer   string   `toml:"AcmeCAServer"`   // empty for Let's Encrypt, or the directory of another ACME server, e.g. a local Pebble
+	AcmeCACert     string   `toml:"AcmeCACert"`     // the root certificate of a private ACME server
+	AcmeChallenge  string   `toml:"AcmeChallenge"`  // "http" for HTTP-01 or "dns" for DNS-01
+	DnsProvider    string   `toml:"DnsProvider"`    // the DNS-01 provider, e.g. cloudflare, see https://go-acme.github.io/lego/dns
+	DnsProviderEnv []string `toml:"DnsProviderEnv"` // the credentials of the DNS-01 provider, e.g. CF_DNS_API_TOKEN=xxx
+	DnsResolvers   []string `toml:"DnsResolvers"`   // the resolvers to check the DNS-01 records with, e.g. 1.1.1.1:53
+}
+
+// Security is the security profile of the tenant containers, in both docker and k8s
+type Security struct {
+	PrivilegedJobTypes []string `toml:"PrivilegedJobTypes"` // the job types that still run privileged: mining, inference, ubi, space, image
+	DropCapabilities   []string `toml:"DropCapabilities"`
+	AddCapabilities    []string `toml:"AddCapabilities"`
+	SeccompProfile     string   `toml:"SeccompProfile"`  // empty for the runtime default, "unconfined", or a profile file (docker) / localhost profile (k8s)
+	AppArmorProfile    string   `toml:"AppArmorProfile"` // empty for the runtime default, "unconfined", or a profile loaded on the host
+	ReadOnlyRootfs     bool     `toml:"ReadOnlyRootfs"`
+	NoNewPrivileges    bool     `toml:"NoNewPrivileges"`
+	PidsLimit          int64    `toml:"PidsLimit"`
+	UserNamespace      bool     `toml:"UserNamespace"` // run the k8s pods in a user namespace, docker needs userns-remap on the daemon
 }
 
 type CONTRACT struct {
@@ -141,6 +298,46 @@ func InitConfig(cpRepoPath string, standalone bool) error {
 		config.API.GpuUtilizationRejectThreshold = 1.0
 	}
 
+	if config.API.DeployWorkers <= 0 {
+		config.API.DeployWorkers = 4
+	}
+	if config.API.DeployHealthTimeout <= 0 {
+		config.API.DeployHealthTimeout = 600
+	}
+
+	setSecurityDefaults(metaData)
+
+	setHealthDefaults(metaData)
+
+	setBuildDefaults()
+
+	if err = setImageCacheDefaults(); err != nil {
+		return err
+	}
+
+	setDownloadDefaults(metaData)
+
+	setModelCacheDefaults(metaData)
+
+	setAlertDefaults(metaData)
+
+	setTopUpDefaults()
+	if err = checkTopUp(); err != nil {
+		return err
+	}
+
+	if !metaData.IsDefined("RPC", "Confirmations") {
+		config.RPC.Confirmations = defaultConfirmations
+	}
+
+	config.TLS.Mode = strings.ToLower(strings.TrimSpace(config.TLS.Mode))
+	if config.TLS.Mode != "" && config.TLS.HttpsPort == 0 {
+		config.TLS.HttpsPort = 9443
+	}
+	if config.TLS.Mode == "acme" && config.TLS.AcmeChallenge == "" {
+		config.TLS.AcmeChallenge = "http"
+	}
+
 	multiAddressSplit := strings.Split(config.API.MultiAddress, "/")
 	if len(multiAddressSplit) < 4 {
 		log.Fatalf("MultiAddress %s is invalid\n", multiAddressSplit[2])
@@ -170,6 +367,212 @@ func InitConfig(cpRepoPath string, standalone bool) error {
 	return nil
 }
 
+func defaultSecurity() Security {
+	return Security{
+		PrivilegedJobTypes: []string{"ubi"},
+		DropCapabilities:   []string{"ALL"},
+		AddCapabilities:    []string{"CHOWN", "DAC_OVERRIDE", "FOWNER", "FSETID", "KILL", "SETGID", "SETUID", "SETPCAP", "NET_BIND_SERVICE", "SYS_CHROOT", "AUDIT_WRITE", "SETFCAP"},
+		NoNewPrivileges:    true,
+		PidsLimit:          4096,
+	}
+}
+
+func setSecurityDefaults(metaData toml.MetaData) {
+	defaults := defaultSecurity()
+	if !metaData.IsDefined("Security", "PrivilegedJobTypes") {
+		config.Security.PrivilegedJobTypes = defaults.PrivilegedJobTypes
+	}
+	if !metaData.IsDefined("Security", "DropCapabilities") {
+		config.Security.DropCapabilities = defaults.DropCapabilities
+	}
+	if !metaData.IsDefined("Security", "AddCapabilities") {
+		config.Security.AddCapabilities = defaults.AddCapabilities
+	}
+	if !metaData.IsDefined("Security", "NoNewPrivileges") {
+		config.Security.NoNewPrivileges = defaults.NoNewPrivileges
+	}
+	if !metaData.IsDefined("Security", "PidsLimit") {
+		config.Security.PidsLimit = defaults.PidsLimit
+	}
+}
+
+func defaultHealth() Health {
+	return Health{
+		Interval:         30,
+		Timeout:          5,
+		FailureThreshold: 3,
+		RestartPolicy:    "on-failure",
+		MaxRestarts:      5,
+	}
+}
+
+func setHealthDefaults(metaData toml.MetaData) {
+	defaults := defaultHealth()
+	if config.Health.Interval <= 0 {
+		config.Health.Interval = defaults.Interval
+	}
+	if config.Health.Timeout <= 0 {
+		config.Health.Timeout = defaults.Timeout
+	}
+	if config.Health.FailureThreshold <= 0 {
+		config.Health.FailureThreshold = defaults.FailureThreshold
+	}
+	config.Health.RestartPolicy = strings.ToLower(strings.TrimSpace(config.Health.RestartPolicy))
+	if config.Health.RestartPolicy == "" {
+		config.Health.RestartPolicy = defaults.RestartPolicy
+	}
+	if !metaData.IsDefined("Health", "MaxRestarts") {
+		config.Health.MaxRestarts = defaults.MaxRestarts
+	}
+}
+
+func defaultBuild() Build {
+	return Build{
+		Mode:    "rootless",
+		Timeout: 3600,
+	}
+}
+
+func setBuildDefaults() {
+	defaults := defaultBuild()
+	config.Build.Mode = strings.ToLower(strings.TrimSpace(config.Build.Mode))
+	if config.Build.Mode == "" {
+		config.Build.Mode = defaults.Mode
+	}
+	if config.Build.Timeout <= 0 {
+		config.Build.Timeout = defaults.Timeout
+	}
+}
+
+func defaultImageCache() ImageCache {
+	return ImageCache{
+		PullPolicy: PullIfNotPresent,
+	}
+}
+
+func setImageCacheDefaults() error {
+	switch strings.ToLower(strings.TrimSpace(config.ImageCache.PullPolicy)) {
+	case "", strings.ToLower(PullIfNotPresent):
+		config.ImageCache.PullPolicy = defaultImageCache().PullPolicy
+	case strings.ToLower(PullAlways):
+		config.ImageCache.PullPolicy = PullAlways
+	default:
+		return fmt.Errorf("unsupported ImageCache.PullPolicy: %s, it must be %s or %s", config.ImageCache.PullPolicy, PullIfNotPresent, PullAlways)
+	}
+	return nil
+}
+
+func defaultDownload() Download {
+	return Download{
+		Workers: 4,
+		Retries: 3,
+		MaxSize: 10,
+	}
+}
+
+func setDownloadDefaults(metaData toml.MetaData) {
+	defaults := defaultDownload()
+	if config.Download.Workers <= 0 {
+		config.Download.Workers = defaults.Workers
+	}
+	if !metaData.IsDefined("Download", "Retries") {
+		config.Download.Retries = defaults.Retries
+	}
+	if !metaData.IsDefined("Download", "MaxSize") {
+		config.Download.MaxSize = defaults.MaxSize
+	}
+}
+
+func defaultAlert() Alert {
+	return Alert{
+		Interval:         600,
+		RepeatInterval:   21600,
+		WorkerBalance:    0.01,
+		SequencerBalance: 0.01,
+		FrozenGrowth:     true,
+		Slashing:         true,
+		Smtp: Smtp{
+			Port: 587,
+		},
+	}
+}
+
+func setAlertDefaults(metaData toml.MetaData) {
+	defaults := defaultAlert()
+	if config.Alert.Interval <= 0 {
+		config.Alert.Interval = defaults.Interval
+	}
+	if config.Alert.RepeatInterval <= 0 {
+		config.Alert.RepeatInterval = defaults.RepeatInterval
+	}
+	if !metaData.IsDefined("Alert", "WorkerBalance") {
+		config.Alert.WorkerBalance = defaults.WorkerBalance
+	}
+	if !metaData.IsDefined("Alert", "SequencerBalance") {
+		config.Alert.SequencerBalance = defaults.SequencerBalance
+	}
+	if !metaData.IsDefined("Alert", "FrozenGrowth") {
+		config.Alert.FrozenGrowth = defaults.FrozenGrowth
+	}
+	if !metaData.IsDefined("Alert", "Slashing") {
+		config.Alert.Slashing = defaults.Slashing
+	}
+	if config.Alert.Smtp.Port <= 0 {
+		config.Alert.Smtp.Port = defaults.Smtp.Port
+	}
+}
+
+func defaultTopUp() TopUp {
+	return TopUp{
+		Interval: 600,
+	}
+}
+
+func setTopUpDefaults() {
+	if config.TopUp.Interval <= 0 {
+		config.TopUp.Interval = defaultTopUp().Interval
+	}
+}
+
+// checkTopUp rejects an enabled top up of a balance without the daily cap of its token, as the caps default to 0
+// and every top up would be skipped
+func checkTopUp() error {
+	topUp := config.TopUp
+	if !topUp.Enable {
+		return nil
+	}
+	if (topUp.SequencerMinBalance > 0 || topUp.WorkerMinBalance > 0) && topUp.DailyEthCap <= 0 {
+		return fmt.Errorf("TopUp.DailyEthCap must be above 0 to top up the sequencer or the worker address")
+	}
+	if (topUp.FcpMinCollateral > 0 || topUp.EcpMinCollateral > 0) && topUp.DailySwanCap <= 0 {
+		return fmt.Errorf("TopUp.DailySwanCap must be above 0 to top up the collateral")
+	}
+	return nil
+}
+
+func defaultModelCache() ModelCache {
+	return ModelCache{
+		HostPath: "/var/lib/computing-provider/models",
+		MaxSize:  200,
+		Timeout:  7200,
+	}
+}
+
+func setModelCacheDefaults(metaData toml.MetaData) {
+	defaults := defaultModelCache()
+	if strings.TrimSpace(config.ModelCache.HostPath) == "" {
+		config.ModelCache.HostPath = defaults.HostPath
+	}
+	if !metaData.IsDefined("ModelCache", "MaxSize") {
+		config.ModelCache.MaxSize = defaults.MaxSize
+	}
+	if config.ModelCache.Timeout <= 0 {
+		config.ModelCache.Timeout = defaults.Timeout
+	}
+}
+
+const defaultConfirmations = 10
+
 func getConfigByHeight() {
 	networkConfig := build.LoadParam()
 	for _, nc := range networkConfig {
@@ -434,8 +837,17 @@ func generateDefaultConfig() ComputeNode {
 			Password:      "",
 		},
 		RPC: RPC{
-			SwanChainRpc: "",
+			SwanChainRpc:  "",
+			Confirmations: defaultConfirmations,
 		},
+		Security:   defaultSecurity(),
+		Health:     defaultHealth(),
+		Build:      defaultBuild(),
+		ImageCache: defaultImageCache(),
+		Download:   defaultDownload(),
+		ModelCache: defaultModelCache(),
+		Alert:      defaultAlert(),
+		TopUp:      defaultTopUp(),
 		CONTRACT: CONTRACT{
 			SwanToken:              "",
 			JobCollateral:          "",
@@ -23,5 +23,6 @@ const (
 	BUILD_IMAGE_PATH_PREFIX = "build"
 	LOG_PATH_PREFIX         = "logs"
 	BUILD_LOG_NAME          = "build.log"
+	BUILD_RECORD_LOG_NAME   = "build.jsonl"
 	Container_LOG_NAME      = "container.log"
 )
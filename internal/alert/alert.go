@@ -0,0 +1,212 @@
+package alert
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/swanchain/go-computing-provider/conf"
+)
+
+const (
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+	SeverityResolved = "resolved"
+)
+
+// Alert is a notification on a balance, the collateral or a slash of the provider
+type Alert struct {
+	Key       string    `json:"key"`
+	Severity  string    `json:"severity"`
+	Title     string    `json:"title"`
+	Message   string    `json:"message"`
+	CpAccount string    `json:"cp_account"`
+	Value     string    `json:"value"`
+	Threshold string    `json:"threshold"`
+	Time      time.Time `json:"time"`
+}
+
+func (a Alert) text() string {
+	return fmt.Sprintf("[%s] %s\n%s\ncp account: %s", strings.ToUpper(a.Severity), a.Title, a.Message, a.CpAccount)
+}
+
+// Sink delivers the alerts to a destination
+type Sink interface {
+	Name() string
+	Send(alert Alert) error
+}
+
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+func postJson(url string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("status: %s, body: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	return nil
+}
+
+// WebhookSink posts the alerts as json
+type WebhookSink struct {
+	Url string
+}
+
+func (s WebhookSink) Name() string {
+	return "webhook " + s.Url
+}
+
+func (s WebhookSink) Send(alert Alert) error {
+	return postJson(s.Url, alert)
+}
+
+// ChatWebhookSink posts the alerts as a message to a Slack or Discord compatible webhook, Slack reads the text and
+// Discord the content
+type ChatWebhookSink struct {
+	Url string
+}
+
+func (s ChatWebhookSink) Name() string {
+	return "chat webhook " + s.Url
+}
+
+func (s ChatWebhookSink) Send(alert Alert) error {
+	text := alert.text()
+	return postJson(s.Url, map[string]string{
+		"text":    text,
+		"content": text,
+	})
+}
+
+// SmtpSink mails the alerts
+type SmtpSink struct {
+	Config conf.Smtp
+}
+
+func (s SmtpSink) Name() string {
+	return "smtp " + s.Config.Host
+}
+
+func (s SmtpSink) Send(alert Alert) error {
+	if s.Config.From == "" || len(s.Config.To) == 0 {
+		return fmt.Errorf("the sender and the recipients of the alert mails are required")
+	}
+	addr := net.JoinHostPort(s.Config.Host, strconv.Itoa(s.Config.Port))
+	tlsConfig := &tls.Config{ServerName: s.Config.Host}
+
+	var client *smtp.Client
+	if s.Config.Port == 465 {
+		conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 15 * time.Second}, "tcp", addr, tlsConfig)
+		if err != nil {
+			return err
+		}
+		if client, err = smtp.NewClient(conn, s.Config.Host); err != nil {
+			conn.Close()
+			return err
+		}
+	} else {
+		conn, err := net.DialTimeout("tcp", addr, 15*time.Second)
+		if err != nil {
+			return err
+		}
+		if client, err = smtp.NewClient(conn, s.Config.Host); err != nil {
+			conn.Close()
+			return err
+		}
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err = client.StartTLS(tlsConfig); err != nil {
+				client.Close()
+				return err
+			}
+		}
+	}
+	defer client.Close()
+
+	if s.Config.UserName != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.Config.UserName, s.Config.Password, s.Config.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(s.Config.From); err != nil {
+		return err
+	}
+	for _, to := range s.Config.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	subject := fmt.Sprintf("[computing-provider] [%s] %s", strings.ToUpper(alert.Severity), alert.Title)
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s\r\n",
+		s.Config.From, strings.Join(s.Config.To, ", "), subject, alert.Time.Format(time.RFC1123Z),
+		strings.ReplaceAll(alert.text(), "\n", "\r\n"))
+	if _, err = writer.Write([]byte(msg)); err != nil {
+		writer.Close()
+		return err
+	}
+	if err = writer.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// Notifier sends the alerts to all its sinks
+type Notifier struct {
+	Sinks []Sink
+}
+
+// NewNotifier returns the notifier of the sinks in the config
+func NewNotifier(config conf.Alert) *Notifier {
+	var notifier Notifier
+	for _, url := range config.Webhooks {
+		if url != "" {
+			notifier.Sinks = append(notifier.Sinks, WebhookSink{Url: url})
+		}
+	}
+	for _, url := range config.ChatWebhooks {
+		if url != "" {
+			notifier.Sinks = append(notifier.Sinks, ChatWebhookSink{Url: url})
+		}
+	}
+	if config.Smtp.Host != "" {
+		notifier.Sinks = append(notifier.Sinks, SmtpSink{Config: config.Smtp})
+	}
+	return &notifier
+}
+
+// Send sends the alert to every sink, it returns whether a sink received it and the errors of the others
+func (n *Notifier) Send(alert Alert) (bool, error) {
+	if alert.Time.IsZero() {
+		alert.Time = time.Now()
+	}
+	var sent bool
+	var errs []error
+	for _, sink := range n.Sinks {
+		if err := sink.Send(alert); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", sink.Name(), err))
+			continue
+		}
+		sent = true
+	}
+	return sent, errors.Join(errs...)
+}
@@ -0,0 +1,55 @@
+package alert
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/swanchain/go-computing-provider/conf"
+)
+
+func TestNotifierSend(t *testing.T) {
+	var received []map[string]interface{}
+	sink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode the alert: %v", err)
+		}
+		received = append(received, body)
+	}))
+	defer sink.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	notifier := NewNotifier(conf.Alert{
+		Webhooks:     []string{sink.URL, failing.URL},
+		ChatWebhooks: []string{sink.URL},
+	})
+	sent, err := notifier.Send(Alert{
+		Key:       "worker_balance",
+		Severity:  SeverityWarning,
+		Title:     "Low worker balance",
+		Message:   "the worker balance is 0.001 ETH",
+		CpAccount: "0x01",
+	})
+	if !sent {
+		t.Fatalf("the alert was not sent to any sink")
+	}
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("expected the error of the failing sink, got: %v", err)
+	}
+	if len(received) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(received))
+	}
+	if received[0]["key"] != "worker_balance" || received[0]["severity"] != SeverityWarning {
+		t.Errorf("unexpected webhook body: %v", received[0])
+	}
+	text, _ := received[1]["text"].(string)
+	if !strings.Contains(text, "Low worker balance") || received[1]["content"] != text {
+		t.Errorf("unexpected chat webhook body: %v", received[1])
+	}
+}
@@ -0,0 +1,351 @@
+package computing
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/internal/alert"
+	"github.com/swanchain/go-computing-provider/internal/contract"
+	"github.com/swanchain/go-computing-provider/internal/contract/ecp"
+	"github.com/swanchain/go-computing-provider/internal/contract/fcp"
+	"github.com/swanchain/go-computing-provider/internal/models"
+	"github.com/swanchain/go-computing-provider/wallet"
+)
+
+const (
+	AlertKeyOwnerBalance           = "owner_balance"
+	AlertKeyWorkerBalance          = "worker_balance"
+	AlertKeySequencerBalance       = "sequencer_balance"
+	AlertKeyFcpAvailableCollateral = "fcp_available_collateral"
+	AlertKeyEcpAvailableCollateral = "ecp_available_collateral"
+	AlertKeyFcpFrozenCollateral    = "fcp_frozen_collateral"
+	AlertKeyEcpFrozenCollateral    = "ecp_frozen_collateral"
+	AlertKeyFcpFrozenGrowth        = "fcp_frozen_growth"
+	AlertKeyEcpFrozenGrowth        = "ecp_frozen_growth"
+	AlertKeySlashing               = "collateral_slashed"
+)
+
+// alertChecker fires and resolves the alerts of a check, with the state of the alerts saved in the db
+type alertChecker struct {
+	notifier       *alert.Notifier
+	alertService   AlertService
+	cpAccount      string
+	repeatInterval int64
+}
+
+// checkThreshold fires the alert of the key when the value is below the threshold, or above it when below is false,
+// and resolves it when the value is back. A threshold of 0 disables the alert. A firing alert is sent again after the
+// repeat interval, and until a sink received it.
+func (c *alertChecker) checkThreshold(key, name string, value *big.Int, threshold float64, below bool, unit string) error {
+	state, err := c.alertService.GetAlert(key)
+	if err != nil {
+		return fmt.Errorf("failed to get the state of alert %s, error: %v", key, err)
+	}
+	if state == nil {
+		state = &models.AlertEntity{Key: key}
+	}
+	state.Value = value.String()
+
+	var firing bool
+	thresholdStr := strconv.FormatFloat(threshold, 'f', -1, 64)
+	if threshold > 0 {
+		thresholdWei, err := contract.StrToBalance(thresholdStr)
+		if err != nil {
+			return fmt.Errorf("invalid threshold of alert %s: %v", key, err)
+		}
+		if below {
+			firing = value.Cmp(thresholdWei) < 0
+		} else {
+			firing = value.Cmp(thresholdWei) > 0
+		}
+	}
+
+	item := alert.Alert{
+		Key:       key,
+		CpAccount: c.cpAccount,
+		Value:     contract.BalanceToStr(value),
+		Threshold: thresholdStr,
+		Time:      time.Now(),
+	}
+	if firing {
+		item.Severity = alert.SeverityWarning
+		if below {
+			item.Title = fmt.Sprintf("Low %s", name)
+			item.Message = fmt.Sprintf("The %s is %s %s, below the threshold of %s %s", name, item.Value, unit, thresholdStr, unit)
+		} else {
+			item.Title = fmt.Sprintf("High %s", name)
+			item.Message = fmt.Sprintf("The %s is %s %s, above the threshold of %s %s", name, item.Value, unit, thresholdStr, unit)
+		}
+		if state.SentTime == 0 || time.Now().Unix()-state.SentTime >= c.repeatInterval {
+			if c.send(item) {
+				state.SentTime = item.Time.Unix()
+			}
+		}
+		state.Firing = true
+		state.Severity = item.Severity
+		state.Message = item.Message
+	} else if state.Firing {
+		if state.SentTime > 0 && threshold > 0 {
+			item.Severity = alert.SeverityResolved
+			item.Title = fmt.Sprintf("The %s is back to normal", name)
+			item.Message = fmt.Sprintf("The %s is %s %s, the threshold is %s %s", name, item.Value, unit, thresholdStr, unit)
+			c.send(item)
+		}
+		state.Firing = false
+		state.Severity = alert.SeverityResolved
+		state.Message = item.Message
+		state.SentTime = 0
+	}
+	return c.alertService.SaveAlert(state)
+}
+
+// checkGrowth sends an alert each time the frozen collateral grows, the first value is saved without an alert
+func (c *alertChecker) checkGrowth(key, name string, value *big.Int) error {
+	state, err := c.alertService.GetAlert(key)
+	if err != nil {
+		return fmt.Errorf("failed to get the state of alert %s, error: %v", key, err)
+	}
+	if state != nil {
+		last, err := contract.ParseWei(state.Value)
+		if err == nil && value.Cmp(last) > 0 {
+			item := alert.Alert{
+				Key:       key,
+				Severity:  alert.SeverityWarning,
+				Title:     fmt.Sprintf("The %s grew", name),
+				Message:   fmt.Sprintf("The %s grew from %s SWAN to %s SWAN", name, contract.BalanceToStr(last), contract.BalanceToStr(value)),
+				CpAccount: c.cpAccount,
+				Value:     contract.BalanceToStr(value),
+				Time:      time.Now(),
+			}
+			if c.send(item) {
+				state.SentTime = item.Time.Unix()
+			}
+			state.Severity = item.Severity
+			state.Message = item.Message
+		}
+	} else {
+		state = &models.AlertEntity{Key: key}
+	}
+	state.Value = value.String()
+	return c.alertService.SaveAlert(state)
+}
+
+// checkSlashing sends an alert for each CollateralSlashed event indexed after the last block checked. The first check
+// only saves the last block indexed, so the slashes before the alerts were enabled are not sent. The checked block
+// only advances past the slashes a sink received.
+func (c *alertChecker) checkSlashing() error {
+	state, err := c.alertService.GetAlert(AlertKeySlashing)
+	if err != nil {
+		return fmt.Errorf("failed to get the state of alert %s, error: %v", AlertKeySlashing, err)
+	}
+
+	filter := ChainEventFilter{Event: "CollateralSlashed"}
+	if state == nil {
+		indexedBlock := loadLastProcessedBlock(models.ScannerEventIndexerId)
+		if indexedBlock < 0 {
+			indexedBlock = 0
+		}
+		state = &models.AlertEntity{Key: AlertKeySlashing, Value: strconv.FormatInt(indexedBlock, 10)}
+		filter.Limit = 1
+	} else {
+		checkedBlock, _ := strconv.ParseUint(state.Value, 10, 64)
+		filter.FromBlock = checkedBlock + 1
+	}
+	var events []models.CollateralEventEntity
+	if err = NewChainEventService().GetChainEvents(filter, &events); err != nil {
+		return fmt.Errorf("failed to get the slash events, error: %v", err)
+	}
+
+	lastBlock, _ := strconv.ParseUint(state.Value, 10, 64)
+	for i := len(events) - 1; i >= 0; i-- {
+		event := events[i]
+		if filter.Limit > 0 {
+			lastBlock = max(lastBlock, event.ChainEvent.BlockNumber)
+			continue
+		}
+		item := alert.Alert{
+			Key:       AlertKeySlashing,
+			Severity:  alert.SeverityCritical,
+			Title:     fmt.Sprintf("The %s was slashed", collateralName(event.ChainEvent.Source)),
+			Message:   fmt.Sprintf("%s SWAN was slashed, task: %s, block: %d, tx: %s", contract.FormatWei(event.Amount, 4), event.TaskUuid, event.ChainEvent.BlockNumber, event.ChainEvent.TxHash),
+			CpAccount: c.cpAccount,
+			Value:     contract.FormatWei(event.Amount, -1),
+			Time:      time.Unix(event.ChainEvent.EventTime, 0),
+		}
+		if !c.send(item) {
+			// no sink received it, the next check sends it again from its block, with the slashes of the block
+			// already sent
+			if lastBlock >= event.ChainEvent.BlockNumber {
+				lastBlock = event.ChainEvent.BlockNumber - 1
+			}
+			break
+		}
+		lastBlock = max(lastBlock, event.ChainEvent.BlockNumber)
+		state.SentTime = time.Now().Unix()
+		state.Severity = item.Severity
+		state.Message = item.Message
+	}
+	state.Value = strconv.FormatUint(lastBlock, 10)
+	return c.alertService.SaveAlert(state)
+}
+
+func (c *alertChecker) send(item alert.Alert) bool {
+	sent, err := c.notifier.Send(item)
+	if err != nil {
+		logs.GetLogger().Errorf("failed to send alert %s, error: %v", item.Key, err)
+	}
+	return sent
+}
+
+func collateralName(source string) string {
+	if source == models.EventSourceEcpCollateral {
+		return "ECP collateral"
+	}
+	return "FCP collateral"
+}
+
+// CheckAlerts reads the balances of the owner and the worker addresses, the balance of the cp account in the
+// sequencer, the FCP and ECP collateral and the indexed slashes, and sends the alerts of the Alert config
+func CheckAlerts() error {
+	config := conf.GetConfig().Alert
+	notifier := alert.NewNotifier(config)
+	if len(notifier.Sinks) == 0 {
+		return fmt.Errorf("no webhook, chat webhook or smtp server is configured for the alerts")
+	}
+
+	cpAccountAddress, err := contract.GetCpAccountAddress()
+	if err != nil {
+		return fmt.Errorf("failed to get cp account contract address, error: %v", err)
+	}
+	chainUrl, err := conf.GetRpcByNetWorkName()
+	if err != nil {
+		return err
+	}
+	client, err := contract.GetEthClient(chainUrl)
+	if err != nil {
+		return fmt.Errorf("failed to dial rpc, error: %v", err)
+	}
+	defer client.Close()
+
+	checker := &alertChecker{
+		notifier:       notifier,
+		alertService:   NewAlertService(),
+		cpAccount:      cpAccountAddress,
+		repeatInterval: int64(config.RepeatInterval),
+	}
+
+	var errs []error
+	ownerAddress, workerAddress, err := GetOwnerAddressAndWorkerAddress()
+	if err != nil {
+		errs = append(errs, err)
+	} else {
+		if config.OwnerBalance > 0 {
+			errs = append(errs, checkAddressBalance(client, checker, AlertKeyOwnerBalance, "owner address balance", ownerAddress, config.OwnerBalance))
+		}
+		if config.WorkerBalance > 0 {
+			errs = append(errs, checkAddressBalance(client, checker, AlertKeyWorkerBalance, "worker address balance", workerAddress, config.WorkerBalance))
+		}
+	}
+
+	if config.SequencerBalance > 0 && conf.GetConfig().CONTRACT.Sequencer != "" {
+		sequencerStub, err := ecp.NewSequencerStub(client, ecp.WithSequencerCpAccountAddress(cpAccountAddress))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to get cp sequencer contract, error: %v", err))
+		} else if balance, err := sequencerStub.GetCPBalance(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to get the sequencer balance, error: %v", err))
+		} else {
+			errs = append(errs, checker.checkThreshold(AlertKeySequencerBalance, "sequencer balance", balance, config.SequencerBalance, true, "ETH"))
+		}
+	}
+
+	if collateralAddress := conf.GetConfig().CONTRACT.JobCollateral; collateralAddress != "" &&
+		(config.FcpAvailableCollateral > 0 || config.FcpFrozenCollateral > 0 || config.FrozenGrowth) {
+		info, err := fcpCollateralInfo(client, collateralAddress, cpAccountAddress)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			errs = append(errs, checker.checkThreshold(AlertKeyFcpAvailableCollateral, "available FCP collateral", info.AvailableBalance, config.FcpAvailableCollateral, true, "SWAN"))
+			errs = append(errs, checker.checkThreshold(AlertKeyFcpFrozenCollateral, "locked FCP collateral", info.LockedBalance, config.FcpFrozenCollateral, false, "SWAN"))
+			if config.FrozenGrowth {
+				errs = append(errs, checker.checkGrowth(AlertKeyFcpFrozenGrowth, "locked FCP collateral", info.LockedBalance))
+			}
+		}
+	}
+
+	if collateralAddress := conf.GetConfig().CONTRACT.ZkCollateral; collateralAddress != "" &&
+		(config.EcpAvailableCollateral > 0 || config.EcpFrozenCollateral > 0 || config.FrozenGrowth) {
+		info, err := ecpCollateralInfo(client, collateralAddress, cpAccountAddress)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			errs = append(errs, checker.checkThreshold(AlertKeyEcpAvailableCollateral, "available ECP collateral", info.Balance, config.EcpAvailableCollateral, true, "SWAN"))
+			errs = append(errs, checker.checkThreshold(AlertKeyEcpFrozenCollateral, "frozen ECP collateral", info.FrozenBalance, config.EcpFrozenCollateral, false, "SWAN"))
+			if config.FrozenGrowth {
+				errs = append(errs, checker.checkGrowth(AlertKeyEcpFrozenGrowth, "frozen ECP collateral", info.FrozenBalance))
+			}
+		}
+	}
+
+	if config.Slashing {
+		errs = append(errs, checker.checkSlashing())
+	}
+	return errors.Join(errs...)
+}
+
+func checkAddressBalance(client *ethclient.Client, checker *alertChecker, key, name, address string, threshold float64) error {
+	balance, err := wallet.BalanceWei(client, address)
+	if err != nil {
+		return fmt.Errorf("failed to get the balance of %s, error: %v", address, err)
+	}
+	return checker.checkThreshold(key, name, balance, threshold, true, "ETH")
+}
+
+func fcpCollateralInfo(client *ethclient.Client, collateralAddress, cpAccountAddress string) (fcp.SwanCreditCollateralCPInfoWithLockedBalance, error) {
+	collateral, err := fcp.NewSwanCreditCollateral(common.HexToAddress(collateralAddress), client)
+	if err != nil {
+		return fcp.SwanCreditCollateralCPInfoWithLockedBalance{}, fmt.Errorf("failed to get fcp collateral contract, error: %v", err)
+	}
+	info, err := collateral.CpInfo(&bind.CallOpts{}, common.HexToAddress(cpAccountAddress))
+	if err != nil {
+		return info, fmt.Errorf("failed to get the fcp collateral info, error: %s", ecp.ParseTooManyError(err))
+	}
+	return info, nil
+}
+
+func ecpCollateralInfo(client *ethclient.Client, collateralAddress, cpAccountAddress string) (ecp.ECPCollateralCPInfo, error) {
+	collateral, err := ecp.NewEcpCollateral(common.HexToAddress(collateralAddress), client)
+	if err != nil {
+		return ecp.ECPCollateralCPInfo{}, fmt.Errorf("failed to get ecp collateral contract, error: %v", err)
+	}
+	info, err := collateral.CpInfo(&bind.CallOpts{}, common.HexToAddress(cpAccountAddress))
+	if err != nil {
+		return info, fmt.Errorf("failed to get the ecp collateral info, error: %s", ecp.ParseTooManyError(err))
+	}
+	return info, nil
+}
+
+// SendTestAlert sends a test alert to every sink of the Alert config, it returns the errors of the sinks that failed
+func SendTestAlert() (int, error) {
+	notifier := alert.NewNotifier(conf.GetConfig().Alert)
+	if len(notifier.Sinks) == 0 {
+		return 0, fmt.Errorf("no webhook, chat webhook or smtp server is configured for the alerts")
+	}
+	cpAccountAddress, _ := contract.GetCpAccountAddress()
+	_, err := notifier.Send(alert.Alert{
+		Key:       "test",
+		Severity:  alert.SeverityWarning,
+		Title:     "Test alert",
+		Message:   "This is a test alert of the computing provider",
+		CpAccount: cpAccountAddress,
+		Time:      time.Now(),
+	})
+	return len(notifier.Sinks), err
+}
@@ -0,0 +1,78 @@
+package computing
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/internal/alert"
+	"github.com/swanchain/go-computing-provider/internal/db"
+	"github.com/swanchain/go-computing-provider/internal/models"
+)
+
+func TestCheckSlashingAdvancesPastSentAlerts(t *testing.T) {
+	db.InitDb(t.TempDir())
+	var received, accepted int
+	sink := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received++
+		if received > accepted {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}
+	}))
+	defer sink.Close()
+
+	checker := &alertChecker{
+		notifier:     alert.NewNotifier(conf.Alert{Webhooks: []string{sink.URL}}),
+		alertService: NewAlertService(),
+		cpAccount:    "0x01",
+	}
+	if err := checker.alertService.SaveAlert(&models.AlertEntity{Key: AlertKeySlashing, Value: "10"}); err != nil {
+		t.Fatal(err)
+	}
+	for i, block := range []uint64{11, 12, 12, 13} {
+		if err := NewChainEventService().SaveChainEvent(&models.CollateralEventEntity{
+			ChainEvent: models.ChainEvent{
+				Source:      models.EventSourceFcpCollateral,
+				Event:       "CollateralSlashed",
+				TxHash:      fmt.Sprintf("0x%d", i),
+				BlockNumber: block,
+			},
+			Amount: "1000000000000000000",
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checkedBlock := func() string {
+		state, err := checker.alertService.GetAlert(AlertKeySlashing)
+		if err != nil || state == nil {
+			t.Fatalf("failed to get the state of the alert: %v", err)
+		}
+		return state.Value
+	}
+
+	// the sink receives the slashes of the blocks 11 and 12, and fails on the second slash of the block 12
+	accepted = 2
+	if err := checker.checkSlashing(); err != nil {
+		t.Fatal(err)
+	}
+	if received != 3 {
+		t.Fatalf("expected the check to stop at the first alert not sent, got %d requests", received)
+	}
+	if got := checkedBlock(); got != "11" {
+		t.Fatalf("expected the checked block 11, before the block of the alert not sent, got %s", got)
+	}
+
+	received, accepted = 0, 10
+	if err := checker.checkSlashing(); err != nil {
+		t.Fatal(err)
+	}
+	if received != 3 {
+		t.Errorf("expected the slashes of the blocks 12 and 13 to be sent, got %d requests", received)
+	}
+	if got := checkedBlock(); got != "13" {
+		t.Errorf("expected the checked block 13, got %s", got)
+	}
+}
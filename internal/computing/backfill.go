@@ -0,0 +1,189 @@
+package computing
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/internal/contract"
+	"github.com/swanchain/go-computing-provider/internal/contract/ecp"
+	"github.com/swanchain/go-computing-provider/internal/models"
+)
+
+const (
+	BackfillKindFcp    = "fcp"
+	BackfillKindEcp    = "ecp"
+	BackfillKindEvents = "events"
+
+	backfillRetries = 3
+)
+
+var backfillRetryDelay = 5 * time.Second
+
+// Backfill scans the reward events of the kind from the block to the block in windows of blocks, and rebuilds the
+// ledger entries and the rewards of the jobs, or the indexed events of the contracts for the events kind. An event
+// already recorded is not counted twice, so a range can be scanned again safely. The checkpoint is saved after each window, a backfill of the same range resumes after the last
+// window scanned unless restart is set.
+// A zero fromBlock is the block the contract was created in, the events kind needs one. A zero toBlock is the end of the last unfinished backfill
+// of the kind from the same block, or the confirmed block below the chain head when there is none.
+func Backfill(kind string, fromBlock, toBlock, window uint64, restart bool, progress func(backfill *models.BackfillEntity)) (*models.BackfillEntity, error) {
+	if window == 0 {
+		return nil, fmt.Errorf("the window must be greater than 0")
+	}
+
+	chainUrl, err := conf.GetRpcByNetWorkName()
+	if err != nil {
+		return nil, err
+	}
+	client, err := contract.GetEthClient(chainUrl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial rpc, error: %v", err)
+	}
+	defer client.Close()
+
+	var scan func(opts *bind.FilterOpts) error
+	var createdBlock uint64
+	switch kind {
+	case BackfillKindFcp:
+		if conf.GetConfig().CONTRACT.JobManager == "" {
+			return nil, fmt.Errorf("the fcp task manager contract is not configured")
+		}
+		taskManager, err := NewTaskManagerContract()
+		if err != nil {
+			return nil, err
+		}
+		scan = taskManager.scanTaskRewards
+		createdBlock = conf.GetConfig().CONTRACT.JobManagerCreated
+	case BackfillKindEcp:
+		if conf.GetConfig().CONTRACT.EdgeTaskPayment == "" {
+			return nil, fmt.Errorf("the ecp task payment contract is not configured")
+		}
+		cpAccountAddress, err := contract.GetCpAccountAddress()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get cp account contract address, error: %v", err)
+		}
+		tps := NewTaskPaymentService()
+		if err = tps.dial(); err != nil {
+			return nil, err
+		}
+		defer tps.client.Close()
+		scan = func(opts *bind.FilterOpts) error {
+			return tps.scanEdgeTaskRewards(opts, cpAccountAddress)
+		}
+		createdBlock = conf.GetConfig().CONTRACT.EdgeTaskPaymentCreated
+	case BackfillKindEvents:
+		if fromBlock == 0 {
+			return nil, fmt.Errorf("the from block is required to backfill the events")
+		}
+		indexer, err := NewEventIndexer(client)
+		if err != nil {
+			return nil, err
+		}
+		scan = indexer.scanEvents
+	default:
+		return nil, fmt.Errorf("unsupported kind: %s, must be one of fcp, ecp or events", kind)
+	}
+
+	headBlock, err := confirmedBlock(client)
+	if err != nil {
+		return nil, err
+	}
+
+	if fromBlock == 0 {
+		fromBlock = createdBlock
+	}
+	backfillService := NewBackfillService()
+	if toBlock == 0 {
+		toBlock = headBlock
+		if !restart {
+			unfinished, err := backfillService.GetUnfinishedBackfill(kind, fromBlock)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get the backfill checkpoint, error: %v", err)
+			}
+			if unfinished != nil {
+				toBlock = unfinished.ToBlock
+			}
+		}
+	}
+	if toBlock > headBlock {
+		return nil, fmt.Errorf("the to block %d is after the confirmed block %d", toBlock, headBlock)
+	}
+	if fromBlock > toBlock {
+		return nil, fmt.Errorf("the from block %d is after the to block %d", fromBlock, toBlock)
+	}
+
+	backfill, err := backfillService.GetBackfill(kind, fromBlock, toBlock)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the backfill checkpoint, error: %v", err)
+	}
+	if backfill == nil {
+		backfill = &models.BackfillEntity{
+			Kind:       kind,
+			FromBlock:  fromBlock,
+			ToBlock:    toBlock,
+			CreateTime: time.Now().Unix(),
+		}
+	} else if restart {
+		backfill.ScannedBlock = 0
+	}
+
+	return backfill, scanBackfill(backfill, window, scan, progress)
+}
+
+// scanBackfill scans the blocks of the backfill after its checkpoint in windows of blocks, each window is retried
+// before the backfill fails, and the checkpoint is saved after it.
+func scanBackfill(backfill *models.BackfillEntity, window uint64, scan func(opts *bind.FilterOpts) error, progress func(backfill *models.BackfillEntity)) error {
+	backfillService := NewBackfillService()
+	kind, fromBlock, toBlock := backfill.Kind, backfill.FromBlock, backfill.ToBlock
+	var err error
+	start := fromBlock
+	if backfill.ScannedBlock >= fromBlock {
+		start = backfill.ScannedBlock + 1
+	}
+	for ; start <= toBlock; start += window {
+		end := start + window - 1
+		if end > toBlock {
+			end = toBlock
+		}
+		opts := &bind.FilterOpts{
+			Start: start,
+			End:   &end,
+		}
+		for i := 1; i <= backfillRetries; i++ {
+			if err = scan(opts); err == nil {
+				break
+			}
+			logs.GetLogger().Warnf("failed to backfill %s rewards, start: %d, end: %d, attempt: %d, error: %s", kind, start, end, i,
+				ecp.ParseTooManyError(err))
+			if i < backfillRetries {
+				time.Sleep(backfillRetryDelay)
+			}
+		}
+		if err != nil {
+			backfill.Status = models.BackfillFailed
+			backfill.Message = fmt.Sprintf("failed to scan blocks %d-%d, error: %s", start, end, ecp.ParseTooManyError(err))
+			if saveErr := backfillService.SaveBackfill(backfill); saveErr != nil {
+				logs.GetLogger().Errorf("failed to save the backfill checkpoint, error: %v", saveErr)
+			}
+			return fmt.Errorf("%s", backfill.Message)
+		}
+
+		backfill.ScannedBlock = end
+		backfill.Status = models.BackfillRunning
+		backfill.Message = ""
+		if err = backfillService.SaveBackfill(backfill); err != nil {
+			return fmt.Errorf("failed to save the backfill checkpoint, error: %v", err)
+		}
+		if progress != nil {
+			progress(backfill)
+		}
+	}
+
+	backfill.Status = models.BackfillDone
+	if err = backfillService.SaveBackfill(backfill); err != nil {
+		return fmt.Errorf("failed to save the backfill checkpoint, error: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package computing
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/swanchain/go-computing-provider/internal/db"
+	"github.com/swanchain/go-computing-provider/internal/models"
+)
+
+func TestScanBackfillWindows(t *testing.T) {
+	db.InitDb(t.TempDir())
+	backfillRetryDelay = 0
+
+	var scanned [][2]uint64
+	var failAt uint64
+	scan := func(opts *bind.FilterOpts) error {
+		if opts.Start == failAt {
+			return fmt.Errorf("rpc unavailable")
+		}
+		scanned = append(scanned, [2]uint64{opts.Start, *opts.End})
+		return nil
+	}
+
+	// the scan stops at the window that keeps failing, the checkpoint is the end of the last window scanned
+	failAt = 120
+	backfill := &models.BackfillEntity{Kind: BackfillKindEcp, FromBlock: 100, ToBlock: 145}
+	if err := scanBackfill(backfill, 10, scan, nil); err == nil {
+		t.Fatalf("expected the failed window to fail the backfill")
+	}
+	if want := [][2]uint64{{100, 109}, {110, 119}}; !reflect.DeepEqual(scanned, want) {
+		t.Errorf("expected the windows %v, got %v", want, scanned)
+	}
+	saved, err := NewBackfillService().GetBackfill(BackfillKindEcp, 100, 145)
+	if err != nil || saved == nil {
+		t.Fatalf("expected the checkpoint to be saved, got %+v, %v", saved, err)
+	}
+	if saved.Status != models.BackfillFailed || saved.ScannedBlock != 119 {
+		t.Errorf("expected the failed checkpoint at block 119, got %+v", saved)
+	}
+
+	// the backfill of the same range resumes after the checkpoint, the last window ends at the to block
+	failAt = 0
+	scanned = nil
+	var progress []uint64
+	if err = scanBackfill(saved, 10, scan, func(backfill *models.BackfillEntity) {
+		progress = append(progress, backfill.ScannedBlock)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if want := [][2]uint64{{120, 129}, {130, 139}, {140, 145}}; !reflect.DeepEqual(scanned, want) {
+		t.Errorf("expected the windows %v, got %v", want, scanned)
+	}
+	if want := []uint64{129, 139, 145}; !reflect.DeepEqual(progress, want) {
+		t.Errorf("expected the progress %v, got %v", want, progress)
+	}
+	if saved, _ = NewBackfillService().GetBackfill(BackfillKindEcp, 100, 145); saved.Status != models.BackfillDone || saved.ScannedBlock != 145 {
+		t.Errorf("expected the backfill to be done at block 145, got %+v", saved)
+	}
+}
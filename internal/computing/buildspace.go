@@ -1,11 +1,16 @@
 package computing
 
 import (
+	"context"
+	"crypto/sha256"
 	"crypto/tls"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
 	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/constants"
 	"github.com/swanchain/go-computing-provider/internal/models"
 	"github.com/swanchain/go-computing-provider/util"
 	"io"
@@ -14,6 +19,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -23,84 +29,91 @@ const (
 	modelSetName   = "model-setting.json"
 )
 
+// DownloadSpaceResources downloads the files of the space into the build directory of the job, the files are
+// downloaded in parallel and verified by their sha256 when the space provides it. The directory is removed when the
+// download fails, and by CleanSpaceResources once the job is deployed.
 func DownloadSpaceResources(jobUuid string, files []models.SpaceFile) (DeployParam, error) {
 	updateJobStatus(jobUuid, models.DEPLOY_DOWNLOAD_SOURCE)
 	var deployParam DeployParam
+	if len(files) == 0 {
+		return deployParam, fmt.Errorf("not found the space")
+	}
 
-	var err error
-	cpRepoPath, _ := os.LookupEnv("CP_PATH")
-	buildFolder := filepath.Join(cpRepoPath, "build")
-	if len(files) > 0 {
-		var containsYaml bool
-		var yamlName string
-		var modelsSettingFileName string
-
-		var fileNames []string
-		for _, file := range files {
-			fileNames = append(fileNames, file.Name)
-			dirPath := filepath.Dir(file.Name)
-			if err = os.MkdirAll(filepath.Join(buildFolder, dirPath), os.ModePerm); err != nil {
-				return deployParam, err
-			}
-			if err = downloadFile(filepath.Join(buildFolder, file.Name), file.URL, file.Iv, file.SymmetricKey); err != nil {
-				return deployParam, fmt.Errorf("failed to downloading file: %w", err)
-			}
+	buildFolder := spaceBuildDir(jobUuid)
+	if err := downloadSpaceFiles(buildFolder, files); err != nil {
+		CleanSpaceResources(jobUuid)
+		return deployParam, err
+	}
 
-			if strings.HasSuffix(strings.ToLower(file.Name), yamlDeployName) ||
-				strings.HasSuffix(strings.ToLower(file.Name), ymlDeployName) {
-				containsYaml = true
-				yamlName = file.Name
-			}
-			if strings.EqualFold(file.Name, modelSetName) {
-				modelsSettingFileName = file.Name
-			}
-		}
-		prefix := commonPrefix(fileNames)
-		imagePath := filepath.Join(buildFolder, prefix)
+	var containsYaml bool
+	var yamlName string
+	var modelsSettingFileName string
 
-		var modelsSettingFilePath string
-		var yamlPath string
-		if modelsSettingFileName != "" {
-			modelsSettingFilePath = filepath.Join(buildFolder, modelsSettingFileName)
+	var fileNames []string
+	for _, file := range files {
+		fileNames = append(fileNames, file.Name)
+		if strings.HasSuffix(strings.ToLower(file.Name), yamlDeployName) ||
+			strings.HasSuffix(strings.ToLower(file.Name), ymlDeployName) {
+			containsYaml = true
+			yamlName = file.Name
 		}
-		if yamlName != "" {
-			yamlPath = filepath.Join(buildFolder, yamlName)
+		if strings.EqualFold(file.Name, modelSetName) {
+			modelsSettingFileName = file.Name
 		}
+	}
+	imagePath := filepath.Join(buildFolder, commonDir(fileNames))
 
-		deployParam.ContainsYaml = containsYaml
-		deployParam.YamlFilePath = yamlPath
-		deployParam.BuildImagePath = imagePath
-		deployParam.ModelsSettingFilePath = modelsSettingFilePath
-
-		return deployParam, nil
-	} else {
-		return deployParam, fmt.Errorf("not found the space")
+	var modelsSettingFilePath string
+	var yamlPath string
+	if modelsSettingFileName != "" {
+		modelsSettingFilePath = filepath.Join(buildFolder, modelsSettingFileName)
 	}
+	if yamlName != "" {
+		yamlPath = filepath.Join(buildFolder, yamlName)
+	}
+
+	deployParam.ContainsYaml = containsYaml
+	deployParam.YamlFilePath = yamlPath
+	deployParam.BuildImagePath = imagePath
+	deployParam.ModelsSettingFilePath = modelsSettingFilePath
+	return deployParam, nil
 }
 
-func commonPrefix(strs []string) string {
-	if len(strs) == 0 {
-		return ""
+// CleanSpaceResources removes the build directory of the job
+func CleanSpaceResources(jobUuid string) {
+	if err := os.RemoveAll(spaceBuildDir(jobUuid)); err != nil {
+		logs.GetLogger().Warnf("failed to remove the space resources, job_uuid: %s, error: %v", jobUuid, err)
 	}
+}
 
-	if len(strs) == 1 {
-		str := strs[0]
-		return str[:strings.LastIndex(str, "/")]
-	} else {
-		prefix := strs[0]
-		for _, str := range strs {
-			for !strings.HasPrefix(str, prefix) {
-				if len(prefix) == 0 {
-					return ""
-				}
-				prefix = prefix[:len(prefix)-1]
-			}
+func spaceBuildDir(jobUuid string) string {
+	cpRepoPath, _ := os.LookupEnv("CP_PATH")
+	return filepath.Join(cpRepoPath, constants.BUILD_IMAGE_PATH_PREFIX, "space", strings.ToLower(jobUuid))
+}
+
+// commonDir returns the deepest directory holding all the files, it is the build context of the space
+func commonDir(names []string) string {
+	var common []string
+	for i, name := range names {
+		var dirs []string
+		if dir := filepath.Dir(filepath.Clean(name)); dir != "." {
+			dirs = strings.Split(dir, string(filepath.Separator))
+		}
+		if i == 0 {
+			common = dirs
+			continue
+		}
+		n := 0
+		for n < len(common) && n < len(dirs) && common[n] == dirs[n] {
+			n++
 		}
-		return prefix
+		common = common[:n]
 	}
+	return filepath.Join(common...)
 }
 
-func BuildImagesByDockerfile(jobUuid, spaceName, imagePath string) (string, string) {
+// BuildImagesByDockerfile builds the image of the space, the build cache is kept per space of the owner
+func BuildImagesByDockerfile(jobUuid, walletAddress, spaceName, imagePath string) (string, string) {
 	updateJobStatus(jobUuid, models.DEPLOY_BUILD_IMAGE)
 	spaceFlag := spaceName + jobUuid[strings.LastIndex(jobUuid, "-"):]
 	imageName := fmt.Sprintf("lagrange/%s:%d", spaceFlag, time.Now().Unix())
@@ -119,10 +132,22 @@ func BuildImagesByDockerfile(jobUuid, spaceName, imagePath string) (string, stri
 		}
 	}
 
+	if err := checkDockerfilePolicy(dockerfilePath); err != nil {
+		logs.GetLogger().Errorf("job_uuid: %s, rejected by the image policy: %v", jobUuid, err)
+		PublishJobEvent(models.JobEvent{
+			JobUuid: jobUuid,
+			Type:    JobEventWarning,
+			Reason:  "ImagePolicyDenied",
+			Object:  "Job/" + jobUuid,
+			Message: err.Error(),
+		})
+		return "", ""
+	}
+
 	log.Printf("Image path: %s", imagePath)
 
 	dockerService := NewDockerService()
-	if err := dockerService.BuildImage(jobUuid, imagePath, imageName); err != nil {
+	if err := dockerService.BuildImage(context.TODO(), jobUuid, imagePath, imageName, spaceBuildCacheKey(walletAddress, spaceName)); err != nil {
 		logs.GetLogger().Errorf("Error building Docker image: %v", err)
 		return "", ""
 	}
@@ -137,48 +162,242 @@ func BuildImagesByDockerfile(jobUuid, spaceName, imagePath string) (string, stri
 	return imageName, dockerfilePath
 }
 
-func downloadFile(filepath, url, iv, symmetricKey string) error {
-	os.Remove(filepath)
-	out, err := os.Create(filepath)
-	if err != nil {
+// downloadBudget caps the bytes of the files of a space on the disk
+type downloadBudget struct {
+	limit int64
+	lock  sync.Mutex
+	total int64
+	files map[string]int64
+}
+
+// set records the bytes of the file, it fails when the files go over the limit
+func (b *downloadBudget) set(name string, size int64) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.total += size - b.files[name]
+	b.files[name] = size
+	if b.limit > 0 && b.total > b.limit {
+		return fmt.Errorf("%w, limit: %s", errSpaceTooLarge, BytesToHumanReadable(b.limit))
+	}
+	return nil
+}
+
+var errSpaceTooLarge = errors.New("the files of the space are too large")
+
+// budgetWriter writes the file and keeps its size in the budget
+type budgetWriter struct {
+	out    io.Writer
+	name   string
+	size   int64
+	budget *downloadBudget
+}
+
+func (w *budgetWriter) Write(p []byte) (int, error) {
+	if err := w.budget.set(w.name, w.size+int64(len(p))); err != nil {
+		return 0, err
+	}
+	n, err := w.out.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+var spaceDownloadClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		TLSClientConfig:       &tls.Config{InsecureSkipVerify: true},
+		ResponseHeaderTimeout: time.Minute,
+	},
+}
+
+// downloadSpaceFiles downloads the files into dir with Download.Workers workers, the first failed file stops the others
+func downloadSpaceFiles(dir string, files []models.SpaceFile) error {
+	downloadConfig := conf.GetConfig().Download
+	budget := &downloadBudget{
+		limit: int64(downloadConfig.MaxSize * 1024 * 1024 * 1024),
+		files: make(map[string]int64),
+	}
+	var declared int64
+	for _, file := range files {
+		if !filepath.IsLocal(file.Name) {
+			return fmt.Errorf("invalid file name: %s", file.Name)
+		}
+		declared += file.Size
+	}
+	if budget.limit > 0 && declared > budget.limit {
+		return fmt.Errorf("%w, size: %s, limit: %s", errSpaceTooLarge, BytesToHumanReadable(declared), BytesToHumanReadable(budget.limit))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	workers := make(chan struct{}, downloadConfig.Workers)
+	for _, file := range files {
+		wg.Add(1)
+		go func(file models.SpaceFile) {
+			defer wg.Done()
+			select {
+			case workers <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-workers }()
+
+			if err := downloadSpaceFile(ctx, filepath.Join(dir, file.Name), file, budget); err != nil {
+				once.Do(func() {
+					firstErr = fmt.Errorf("failed to download %s: %w", file.Name, err)
+					cancel()
+				})
+			}
+		}(file)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// downloadSpaceFile downloads the file with Download.Retries retries, a retry resumes the partial file with a range request
+func downloadSpaceFile(ctx context.Context, path string, file models.SpaceFile, budget *downloadBudget) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
 		return err
 	}
-	defer func(out *os.File) {
-		err := out.Close()
-		if err != nil {
 
+	retries := conf.GetConfig().Download.Retries
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			logs.GetLogger().Warnf("retry downloading %s (%d/%d), error: %v", file.Name, attempt, retries, err)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(attempt) * 2 * time.Second):
+			}
+		}
+
+		if err = fetchSpaceFile(ctx, path+".part", file, budget); err != nil {
+			if errors.Is(err, errSpaceTooLarge) || ctx.Err() != nil {
+				return err
+			}
+			continue
 		}
-	}(out)
+		if err = finishSpaceFile(path, file); err == nil {
+			return nil
+		}
+	}
+	return err
+}
 
-	http.DefaultTransport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
-	resp, err := http.Get(url)
+// fetchSpaceFile downloads the file into partPath, from the end of partPath when it is there
+func fetchSpaceFile(ctx context.Context, partPath string, file models.SpaceFile, budget *downloadBudget) error {
+	var offset int64
+	if info, err := os.Stat(partPath); err == nil {
+		offset = info.Size()
+	}
+	if file.Size > 0 && offset > file.Size {
+		os.Remove(partPath)
+		offset = 0
+	}
+	if err := budget.set(file.Name, offset); err != nil {
+		return err
+	}
+	if file.Size > 0 && offset == file.Size {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, file.URL, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := spaceDownloadClient.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("url: %s, unexpected status code: %d", url, resp.StatusCode)
+	flag := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		// the server ignores the range, download the whole file again
+		flag |= os.O_TRUNC
+		offset = 0
+	case http.StatusRequestedRangeNotSatisfiable:
+		if offset > 0 {
+			return nil
+		}
+		fallthrough
+	default:
+		return fmt.Errorf("url: %s, unexpected status code: %d", file.URL, resp.StatusCode)
+	}
+
+	out, err := os.OpenFile(partPath, flag, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	writer := &budgetWriter{out: out, name: file.Name, size: offset, budget: budget}
+	if _, err = io.Copy(writer, resp.Body); err != nil {
+		return err
 	}
+	if file.Size > 0 && writer.size != file.Size {
+		return fmt.Errorf("incomplete download, %d of %d bytes", writer.size, file.Size)
+	}
+	return nil
+}
 
-	var reader io.Reader
-	if iv != "" && symmetricKey != "" {
-		data, err := decryptData(resp.Body, iv, symmetricKey)
+// finishSpaceFile decrypts the downloaded file into path and verifies its sha256, a bad file is removed to be downloaded again
+func finishSpaceFile(path string, file models.SpaceFile) error {
+	partPath := path + ".part"
+	if file.Iv != "" && file.SymmetricKey != "" {
+		part, err := os.Open(partPath)
+		if err != nil {
+			return err
+		}
+		data, err := decryptData(part, file.Iv, file.SymmetricKey)
+		part.Close()
+		os.Remove(partPath)
 		if err != nil {
 			return err
 		}
-		reader = strings.NewReader(string(data))
-	} else {
-		reader = resp.Body
+		if err = os.WriteFile(path, data, 0644); err != nil {
+			return err
+		}
+	} else if err := os.Rename(partPath, path); err != nil {
+		return err
 	}
-	_, err = io.Copy(out, reader)
+
+	if file.Sha256 == "" {
+		return nil
+	}
+	sum, err := fileSha256(path)
 	if err != nil {
 		return err
 	}
-
+	if !strings.EqualFold(sum, strings.TrimSpace(file.Sha256)) {
+		os.Remove(path)
+		return fmt.Errorf("sha256 mismatch, expected: %s, actual: %s", file.Sha256, sum)
+	}
 	return nil
 }
 
+func fileSha256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err = io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
 func decryptData(body io.ReadCloser, ivData, symmetricKeyData string) ([]byte, error) {
 	encodedData, err := io.ReadAll(body)
 	if err != nil {
@@ -37,16 +37,23 @@ func NewCronTask(nodeId string) *CronTask {
 }
 
 func (task *CronTask) RunTask() {
+	if _, err := StartJobInformer(); err != nil {
+		logs.GetLogger().Errorf("failed to start job informer, fall back to polling, error: %v", err)
+	}
 	checkJobStatus()
 	task.addLabelToNode()
 	task.checkCollateralBalance()
+	task.checkAlerts()
+	task.topUpBalances()
 	task.cleanAbnormalDeployment()
 	task.setFailedUbiTaskStatus()
 	task.watchNameSpaceForDeleted()
 	//task.reportClusterResource()
 	task.watchExpiredTask()
+	task.reconcileNamespaceQuota()
 	task.getUbiTaskReward()
 	task.checkJobReward()
+	task.indexChainEvents()
 	task.cleanImageResource()
 	task.CheckCpBalance()
 	task.UpdateContainerLog()
@@ -210,12 +217,21 @@ func (task *CronTask) watchNameSpaceForDeleted() {
 			return
 		}
 
+		informer := GetJobInformer()
 		for _, namespace := range namespaces {
-			getPods, err := service.GetPods(namespace, "")
+			var getPods bool
+			if informer != nil {
+				getPods, err = informer.HasPods(namespace)
+			} else {
+				getPods, err = service.GetPods(namespace, "")
+			}
 			if err != nil {
 				logs.GetLogger().Errorf("Failed get pods form namespace,namepace: %s, error: %+v", namespace, err)
 				continue
 			}
+			if !getPods && strings.HasPrefix(namespace, constants.K8S_NAMESPACE_NAME_PREFIX) && NewJobService().CountPausedJobByWallet(walletOfNamespace(namespace)) > 0 {
+				continue
+			}
 			if !getPods && (strings.HasPrefix(namespace, constants.K8S_NAMESPACE_NAME_PREFIX) || strings.HasPrefix(namespace, "ubi-task")) {
 				if err = service.DeleteNameSpace(context.TODO(), namespace); err != nil {
 					logs.GetLogger().Errorf("Failed delete namespace, namepace: %s, error: %+v", namespace, err)
@@ -262,17 +278,28 @@ func (task *CronTask) watchExpiredTask() {
 			return
 		}
 
-		deployments, err := k8sService.k8sClient.AppsV1().Deployments(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{})
-		if err != nil {
-			fmt.Println("Error listing deployments:", err)
-			return
-		}
-
 		var deployOnK8s = make(map[string]string)
-		for _, deploy := range deployments.Items {
-			if strings.HasPrefix(deploy.Namespace, constants.K8S_NAMESPACE_NAME_PREFIX) {
+		informer := GetJobInformer()
+		if informer != nil {
+			deployments, err := informer.ListTenantDeployments()
+			if err != nil {
+				logs.GetLogger().Errorf("failed to list deployments from informer, error: %v", err)
+				return
+			}
+			for _, deploy := range deployments {
 				deployOnK8s[deploy.Name] = deploy.Namespace
 			}
+		} else {
+			deployments, err := k8sService.k8sClient.AppsV1().Deployments(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{})
+			if err != nil {
+				fmt.Println("Error listing deployments:", err)
+				return
+			}
+			for _, deploy := range deployments.Items {
+				if strings.HasPrefix(deploy.Namespace, constants.K8S_NAMESPACE_NAME_PREFIX) {
+					deployOnK8s[deploy.Name] = deploy.Namespace
+				}
+			}
 		}
 
 		var deleteSpaceIdAndJobUuid = make(map[string]string)
@@ -300,7 +327,8 @@ func (task *CronTask) watchExpiredTask() {
 			createdTime := time.Unix(job.CreateTime, 0)
 			createDuration := currentTime.Sub(createdTime)
 
-			if job.NameSpace != "" && job.K8sDeployName != "" {
+			// the informer fails, runs and completes the jobs from the events of their deployments
+			if informer == nil && job.NameSpace != "" && job.K8sDeployName != "" {
 				foundDeployment, err := k8sService.k8sClient.AppsV1().Deployments(job.NameSpace).Get(context.TODO(), job.K8sDeployName, metav1.GetOptions{})
 				if err != nil {
 					if createDuration.Hours() <= 2 && job.Status != models.JOB_RUNNING_STATUS {
@@ -316,11 +344,13 @@ func (task *CronTask) watchExpiredTask() {
 					continue
 				}
 
-				if foundDeployment.Status.AvailableReplicas == 0 && createDuration.Hours() > 2 { // need to delete
+				// the deployment of a paused job is scaled to zero on purpose
+				paused := job.Status == models.JOB_PAUSED_STATUS
+				if foundDeployment.Status.AvailableReplicas == 0 && createDuration.Hours() > 2 && !paused { // need to delete
 					DeleteJob(job.NameSpace, job.JobUuid, "cron-task correction status")
 					deleteSpaceIdAndJobUuid[job.JobUuid] = job.SpaceUuid + "_" + job.JobUuid
 					continue
-				} else {
+				} else if !paused {
 					if job.Status != models.JOB_RUNNING_STATUS {
 						job.PodStatus = models.POD_RUNNING_STATUS
 						job.Status = models.JOB_RUNNING_STATUS
@@ -331,9 +361,27 @@ func (task *CronTask) watchExpiredTask() {
 
 			checkFcpJobInfoInChain(job)
 
-			if job.Status == models.JOB_TERMINATED_STATUS || job.Status == models.JOB_COMPLETED_STATUS || time.Now().Unix() > job.ExpireTime {
-				expireTime := time.Unix(job.ExpireTime, 0).Format("2006-01-02 15:04:05")
+			jobExpireTime := GetJobExpireTime(*job)
+			if remaining := time.Until(time.Unix(jobExpireTime, 0)); remaining > 0 && remaining <= jobExpiryWarningPeriod {
+				PublishJobEvent(models.JobEvent{
+					JobUuid: job.JobUuid,
+					Type:    JobEventWarning,
+					Reason:  "ExpiringSoon",
+					Object:  "Job/" + job.JobUuid,
+					Message: fmt.Sprintf("job will expire at %s", time.Unix(jobExpireTime, 0).Format("2006-01-02 15:04:05")),
+				})
+			}
+
+			if job.Status == models.JOB_TERMINATED_STATUS || job.Status == models.JOB_COMPLETED_STATUS || time.Now().Unix() > jobExpireTime {
+				expireTime := time.Unix(jobExpireTime, 0).Format("2006-01-02 15:04:05")
 				logs.GetLogger().Infof("job_uuid: %s, current status is %s, expire time: %s, starting to delete it.", job.JobUuid, models.GetJobStatus(job.Status), expireTime)
+				PublishJobEvent(models.JobEvent{
+					JobUuid: job.JobUuid,
+					Type:    JobEventNormal,
+					Reason:  "Expired",
+					Object:  "Job/" + job.JobUuid,
+					Message: fmt.Sprintf("job is %s, expire time: %s, deleting it", models.GetJobStatus(job.Status), expireTime),
+				})
 				if err = DeleteJob(job.NameSpace, job.JobUuid, "cron-task abnormal state"); err != nil {
 					logs.GetLogger().Errorf("failed to use jobUuid: %s delete job, error: %v", job.JobUuid, err)
 					continue
@@ -353,6 +401,51 @@ func (task *CronTask) watchExpiredTask() {
 	c.Start()
 }
 
+func (task *CronTask) reconcileNamespaceQuota() {
+	c := cron.New(cron.WithSeconds())
+	c.AddFunc("0 5/15 * * * ?", func() {
+		defer func() {
+			if err := recover(); err != nil {
+				logs.GetLogger().Errorf("reconcileNamespaceQuota catch panic error: %+v", err)
+			}
+		}()
+
+		k8sService := NewK8sService()
+		if k8sService == nil || k8sService.k8sClient == nil {
+			logs.GetLogger().Errorf("failed to create k8s client, please check that the k8s service is running normally")
+			return
+		}
+		namespaces, err := k8sService.ListNamespace(context.TODO())
+		if err != nil {
+			logs.GetLogger().Errorf("Failed get all namespace, error: %+v", err)
+			return
+		}
+
+		for _, namespace := range namespaces {
+			walletAddress := walletOfNamespace(namespace)
+			if walletAddress == "" {
+				continue
+			}
+
+			jobList, err := NewJobService().GetActiveJobListByWallet(walletAddress)
+			if err != nil {
+				logs.GetLogger().Errorf("failed to get job data, namespace: %s, error: %+v", namespace, err)
+				continue
+			}
+			for _, job := range jobList {
+				if job.Cpu == 0 && job.Memory == 0 {
+					backfillJobResource(k8sService, job)
+				}
+			}
+
+			if err = SyncNamespaceQuota(walletAddress); err != nil {
+				logs.GetLogger().Errorf("failed to reconcile namespace quota, namespace: %s, error: %v", namespace, err)
+			}
+		}
+	})
+	c.Start()
+}
+
 func (task *CronTask) checkCollateralBalance() {
 	c := cron.New(cron.WithSeconds())
 	c.AddFunc("0 0/10 * * * * ?", func() {
@@ -381,6 +474,44 @@ func (task *CronTask) checkCollateralBalance() {
 	c.Start()
 }
 
+func (task *CronTask) checkAlerts() {
+	if !conf.GetConfig().Alert.Enable {
+		return
+	}
+	c := cron.New(cron.WithSeconds())
+	c.AddFunc(fmt.Sprintf("@every %ds", conf.GetConfig().Alert.Interval), func() {
+		defer func() {
+			if err := recover(); err != nil {
+				logs.GetLogger().Errorf("task job: [checkAlerts], error: %+v", err)
+			}
+		}()
+
+		if err := CheckAlerts(); err != nil {
+			logs.GetLogger().Errorf("failed to check the alerts, error: %v", err)
+		}
+	})
+	c.Start()
+}
+
+func (task *CronTask) topUpBalances() {
+	if !conf.GetConfig().TopUp.Enable {
+		return
+	}
+	c := cron.New(cron.WithSeconds())
+	c.AddFunc(fmt.Sprintf("@every %ds", conf.GetConfig().TopUp.Interval), func() {
+		defer func() {
+			if err := recover(); err != nil {
+				logs.GetLogger().Errorf("task job: [topUpBalances], error: %+v", err)
+			}
+		}()
+
+		if _, err := TopUpBalances(); err != nil {
+			logs.GetLogger().Errorf("failed to top up the balances, error: %v", err)
+		}
+	})
+	c.Start()
+}
+
 func (task *CronTask) cleanAbnormalDeployment() {
 	c := cron.New(cron.WithSeconds())
 	c.AddFunc("* 0/30 * * * ?", func() {
@@ -413,6 +544,9 @@ func (task *CronTask) cleanAbnormalDeployment() {
 					creationTimestamp := deployment.ObjectMeta.CreationTimestamp.Time
 					currentTime := time.Now()
 					age := currentTime.Sub(creationTimestamp)
+					if _, paused := deployment.Annotations[pausedReplicasAnnotation]; paused {
+						continue
+					}
 					if deployment.Status.AvailableReplicas == 0 && age.Hours() >= 2 {
 						logs.GetLogger().Infof("Cleaning up deployment %s in namespace %s", deployment.Name, namespace)
 						err := k8sService.k8sClient.AppsV1().Deployments(namespace).Delete(context.TODO(), deployment.Name, metav1.DeleteOptions{})
@@ -495,6 +629,23 @@ func (task *CronTask) checkJobReward() {
 	c.Start()
 }
 
+func (task *CronTask) indexChainEvents() {
+	go SubscribeChainEvents(context.Background())
+
+	c := cron.New(cron.WithSeconds(), cron.WithChain(cron.DelayIfStillRunning(cron.DefaultLogger)))
+	c.AddFunc("@every 5m", func() {
+		defer func() {
+			if err := recover(); err != nil {
+				logs.GetLogger().Errorf("task job: [indexChainEvents], error: %+v", err)
+			}
+		}()
+		if err := ScanChainEvents(); err != nil {
+			logs.GetLogger().Errorf("failed to index chain events, error: %v", err)
+		}
+	})
+	c.Start()
+}
+
 func (task *CronTask) getUbiTaskReward() {
 	c := cron.New(cron.WithSeconds())
 	c.AddFunc("0 */10 * * * ?", func() {
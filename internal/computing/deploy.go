@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/swanchain/go-computing-provider/build"
 	"github.com/swanchain/go-computing-provider/conf"
 	"github.com/swanchain/go-computing-provider/constants"
 	"github.com/swanchain/go-computing-provider/internal/models"
@@ -43,6 +44,7 @@ type Deploy struct {
 	taskUuid          string
 	gpuProductName    string
 	gpuIndex          []string
+	nodeName          string
 
 	// ===
 	spaceType   string
@@ -118,6 +120,11 @@ func (d *Deploy) WithGpuIndex(index []string) *Deploy {
 	return d
 }
 
+func (d *Deploy) WithNodeName(nodeName string) *Deploy {
+	d.nodeName = nodeName
+	return d
+}
+
 func (d *Deploy) WithYamlInfo(yamlPath string) *Deploy {
 	d.yamlPath = yamlPath
 	return d
@@ -173,12 +180,14 @@ func (d *Deploy) DockerfileToK8s() {
 
 			Template: coreV1.PodTemplateSpec{
 				ObjectMeta: metaV1.ObjectMeta{
-					Labels:    map[string]string{"lad_app": d.jobUuid},
-					Namespace: d.k8sNameSpace,
+					Labels:      map[string]string{"lad_app": d.jobUuid},
+					Namespace:   d.k8sNameSpace,
+					Annotations: d.gpuAnnotation(),
 				},
 
 				Spec: coreV1.PodSpec{
-					NodeSelector: generateLabel(d.gpuProductName),
+					NodeSelector: d.nodeSelector(),
+					Affinity:     generateNodeAffinity(d.nodeName),
 					Containers: []coreV1.Container{{
 						Name:            constants.K8S_CONTAINER_NAME_PREFIX + d.jobUuid,
 						Image:           d.image,
@@ -192,6 +201,7 @@ func (d *Deploy) DockerfileToK8s() {
 				},
 			},
 		}}
+	applyPodSecurityProfile(SecurityJobSpace, &deployment.Spec.Template)
 	_, err = k8sService.CreateDeployment(context.TODO(), d.k8sNameSpace, deployment)
 	if err != nil {
 		logs.GetLogger().Error(err)
@@ -280,6 +290,19 @@ func (d *Deploy) YamlToK8s(nodePort int32) error {
 			}
 		}
 
+		// the models are prefetched into the cache of the node, without a node they are downloaded into the pod once
+		// it is running
+		podModels := cr.Models
+		var initContainers []coreV1.Container
+		var prefetch []models.JobModel
+		if len(cr.Models) > 0 && d.nodeName != "" {
+			modelVolumes, modelMounts, waitContainer, cacheModels := d.modelCacheMounts(cr.Models)
+			volumes = append(volumes, modelVolumes...)
+			volumeMount = append(volumeMount, modelMounts...)
+			initContainers = append(initContainers, waitContainer)
+			prefetch, podModels = cacheModels, nil
+		}
+
 		var containers []coreV1.Container
 		for _, depend := range cr.Depends {
 			var ports []coreV1.ContainerPort
@@ -349,21 +372,31 @@ func (d *Deploy) YamlToK8s(nodePort int32) error {
 				},
 				Template: coreV1.PodTemplateSpec{
 					ObjectMeta: metaV1.ObjectMeta{
-						Labels:    map[string]string{"lad_app": d.jobUuid},
-						Namespace: d.k8sNameSpace,
+						Labels:      map[string]string{"lad_app": d.jobUuid},
+						Namespace:   d.k8sNameSpace,
+						Annotations: d.gpuAnnotation(),
 					},
 					Spec: coreV1.PodSpec{
-						NodeSelector: generateLabel(d.gpuProductName),
-						Containers:   containers,
-						Volumes:      volumes,
+						NodeSelector:   d.nodeSelector(),
+						Affinity:       generateNodeAffinity(d.nodeName),
+						InitContainers: initContainers,
+						Containers:     containers,
+						Volumes:        volumes,
 					},
 				},
 			}}
+		if len(prefetch) > 0 {
+			// the deployment makes no progress while the pod waits for the models
+			progressDeadline := int32(conf.GetConfig().ModelCache.Timeout) + 600
+			deployment.Spec.ProgressDeadlineSeconds = &progressDeadline
+		}
 
+		applyPodSecurityProfile(SecurityJobSpace, &deployment.Spec.Template)
 		if _, err = k8sService.CreateDeployment(context.TODO(), d.k8sNameSpace, deployment); err != nil {
 			logs.GetLogger().Error(err)
 			return err
 		}
+		d.prefetchModels(prefetch)
 		updateJobStatus(d.originalJobUuid, models.DEPLOY_PULL_IMAGE)
 
 		serviceHost, err := d.deployK8sResource(cr.Ports[0].ContainerPort)
@@ -374,8 +407,8 @@ func (d *Deploy) YamlToK8s(nodePort int32) error {
 
 		updateJobStatus(d.originalJobUuid, models.DEPLOY_TO_K8S, "https://"+d.hostName)
 
-		if len(cr.Models) > 0 {
-			for _, res := range cr.Models {
+		if len(podModels) > 0 {
+			for _, res := range podModels {
 				go func(res yaml.ModelResource) {
 					downloadModelUrl(d.k8sNameSpace, d.jobUuid, serviceHost, []string{"wget", res.Url, "-O", filepath.Join(res.Dir, res.Name)})
 				}(res)
@@ -386,6 +419,93 @@ func (d *Deploy) YamlToK8s(nodePort int32) error {
 	return nil
 }
 
+// modelCacheMounts mounts the models of the model cache of the node read-only into the pod, and returns the models to
+// prefetch into the cache. The pod is gated on them by the init container returned, which waits until the files are on
+// the node.
+func (d *Deploy) modelCacheMounts(resources []yaml.ModelResource) ([]coreV1.Volume, []coreV1.VolumeMount, coreV1.Container, []models.JobModel) {
+	var volumes []coreV1.Volume
+	var volumeMounts, waitMounts []coreV1.VolumeMount
+	var files []string
+	var prefetch []models.JobModel
+	keyVolumes := make(map[string]string)
+	mounted := make(map[string]bool)
+	dirType := coreV1.HostPathDirectoryOrCreate
+	for _, res := range resources {
+		model := models.JobModel{Name: res.Name, Url: res.Url, Sha256: res.Sha256, Dir: res.Dir}
+		key := modelCacheKey(model.Url, model.Sha256)
+		volumeName, ok := keyVolumes[key]
+		if !ok {
+			volumeName = fmt.Sprintf("model-%d", len(volumes))
+			keyVolumes[key] = volumeName
+			volumes = append(volumes, coreV1.Volume{
+				Name: volumeName,
+				VolumeSource: coreV1.VolumeSource{
+					HostPath: &coreV1.HostPathVolumeSource{
+						Path: filepath.Join(conf.GetConfig().ModelCache.HostPath, key),
+						Type: &dirType,
+					},
+				},
+			})
+			waitMounts = append(waitMounts, coreV1.VolumeMount{
+				Name:      volumeName,
+				MountPath: filepath.Join(modelCacheMountPath, key),
+				ReadOnly:  true,
+			})
+			prefetch = append(prefetch, model)
+		}
+		files = append(files, filepath.Join(modelCacheMountPath, key, res.Name))
+
+		mountPath := filepath.Join(res.Dir, res.Name)
+		if mounted[mountPath] {
+			continue
+		}
+		mounted[mountPath] = true
+		volumeMounts = append(volumeMounts, coreV1.VolumeMount{
+			Name:      volumeName,
+			MountPath: mountPath,
+			SubPath:   res.Name,
+			ReadOnly:  true,
+		})
+	}
+
+	uid := modelCacheUid
+	nonRoot, noEscalation, readOnly := true, false, true
+	waitContainer := coreV1.Container{
+		Name:            d.jobUuid + "-models",
+		Image:           build.ModelPrefetchDockerImage,
+		ImagePullPolicy: coreV1.PullIfNotPresent,
+		Command:         []string{"sh", "-c", modelWaitScript},
+		Env: []coreV1.EnvVar{
+			{Name: "MODEL_FILES", Value: strings.Join(files, "\n")},
+			{Name: "MODEL_TIMEOUT", Value: strconv.Itoa(conf.GetConfig().ModelCache.Timeout)},
+		},
+		SecurityContext: &coreV1.SecurityContext{
+			RunAsUser:                &uid,
+			RunAsNonRoot:             &nonRoot,
+			AllowPrivilegeEscalation: &noEscalation,
+			ReadOnlyRootFilesystem:   &readOnly,
+			Capabilities:             &coreV1.Capabilities{Drop: []coreV1.Capability{"ALL"}},
+		},
+		VolumeMounts: waitMounts,
+	}
+	return volumes, volumeMounts, waitContainer, prefetch
+}
+
+// prefetchModels prefetches the models into the model cache of the node in the background, while the pod waits for
+// them. A model failed to prefetch fails the job.
+func (d *Deploy) prefetchModels(prefetch []models.JobModel) {
+	var failOnce sync.Once
+	for _, model := range prefetch {
+		go func(model models.JobModel) {
+			if _, err := PrefetchModelToNode(context.Background(), d.nodeName, model); err != nil {
+				failOnce.Do(func() {
+					failJob(d.k8sNameSpace, d.jobUuid, "ModelPrefetchFailed", err.Error())
+				})
+			}
+		}(model)
+	}
+}
+
 func (d *Deploy) ModelInferenceToK8s() error {
 	var modelSetting struct {
 		ModelId string `json:"model_id"`
@@ -467,12 +587,14 @@ func (d *Deploy) ModelInferenceToK8s() error {
 
 			Template: coreV1.PodTemplateSpec{
 				ObjectMeta: metaV1.ObjectMeta{
-					Labels:    map[string]string{"lad_app": d.jobUuid},
-					Namespace: d.k8sNameSpace,
+					Labels:      map[string]string{"lad_app": d.jobUuid},
+					Namespace:   d.k8sNameSpace,
+					Annotations: d.gpuAnnotation(),
 				},
 
 				Spec: coreV1.PodSpec{
-					NodeSelector: generateLabel(d.gpuProductName),
+					NodeSelector: d.nodeSelector(),
+					Affinity:     generateNodeAffinity(d.nodeName),
 					Containers: []coreV1.Container{{
 						Name:            constants.K8S_CONTAINER_NAME_PREFIX + d.jobUuid,
 						Image:           d.image,
@@ -480,12 +602,13 @@ func (d *Deploy) ModelInferenceToK8s() error {
 						Ports: []coreV1.ContainerPort{{
 							ContainerPort: int32(80),
 						}},
-						Env: d.createEnv(modelEnvs...),
-						//Resources: d.createResources(),
+						Env:       d.createEnv(modelEnvs...),
+						Resources: d.createResources(),
 					}},
 				},
 			},
 		}}
+	applyPodSecurityProfile(SecurityJobSpace, &deployment.Spec.Template)
 	if _, err = k8sService.CreateDeployment(context.TODO(), d.k8sNameSpace, deployment); err != nil {
 		logs.GetLogger().Error(err)
 		return err
@@ -532,13 +655,15 @@ func (d *Deploy) DeploySshTaskToK8s(containerResource yaml.ContainerResource, no
 
 			Template: coreV1.PodTemplateSpec{
 				ObjectMeta: metaV1.ObjectMeta{
-					Labels:    map[string]string{"hub-private": d.jobUuid},
-					Namespace: d.k8sNameSpace,
+					Labels:      map[string]string{"hub-private": d.jobUuid},
+					Namespace:   d.k8sNameSpace,
+					Annotations: d.gpuAnnotation(),
 				},
 
 				Spec: coreV1.PodSpec{
 					Hostname:     d.spaceName + "-" + generateString(4),
-					NodeSelector: generateLabel(d.gpuProductName),
+					NodeSelector: d.nodeSelector(),
+					Affinity:     generateNodeAffinity(d.nodeName),
 					Containers: []coreV1.Container{
 						{
 							Name:            constants.K8S_PRIVATE_CONTAINER_PREFIX + d.jobUuid,
@@ -554,6 +679,7 @@ func (d *Deploy) DeploySshTaskToK8s(containerResource yaml.ContainerResource, no
 				},
 			},
 		}}
+	applyPodSecurityProfile(SecurityJobSpace, &deployment.Spec.Template)
 	if _, err := k8sService.CreateDeployment(context.TODO(), d.k8sNameSpace, deployment); err != nil {
 		return fmt.Errorf("failed to create deployment, job_uuid: %s error: %v", d.jobUuid, err)
 	}
@@ -600,6 +726,7 @@ func (d *Deploy) DeployImageToK8s(containerResource models.DeployJobParam) error
 	k8sService := NewK8sService()
 	volumeMounts, volumes := generateVolume()
 
+	d.hardwareResource = getHardwareDetailForImage(containerResource.K8sResourceForImage)
 	if err := d.deployNamespace(); err != nil {
 		logs.GetLogger().Error(err)
 		return err
@@ -649,9 +776,7 @@ func (d *Deploy) DeployImageToK8s(containerResource models.DeployJobParam) error
 				},
 				Spec: coreV1.PodSpec{
 					Hostname: d.spaceName + "-" + generateString(4),
-					NodeSelector: map[string]string{
-						"kubernetes.io/hostname": d.gpuProductName,
-					},
+					Affinity: generateNodeAffinity(d.nodeName),
 					Containers: []coreV1.Container{
 						{
 							Name:            constants.K8S_PRIVATE_CONTAINER_PREFIX + d.jobUuid,
@@ -667,6 +792,7 @@ func (d *Deploy) DeployImageToK8s(containerResource models.DeployJobParam) error
 				},
 			},
 		}}
+	applyPodSecurityProfile(SecurityJobImage, &deployment.Spec.Template)
 	if _, err := k8sService.CreateDeployment(context.TODO(), d.k8sNameSpace, deployment); err != nil {
 		return fmt.Errorf("failed to create deployment, job_uuid: %s error: %v", d.jobUuid, err)
 	}
@@ -694,6 +820,30 @@ func (d *Deploy) DeployImageToK8s(containerResource models.DeployJobParam) error
 	return nil
 }
 
+// nodeSelector keeps the gpu model label for the jobs that were not placed on a node by the scheduler
+func (d *Deploy) nodeSelector() map[string]string {
+	if d.nodeName != "" {
+		return nil
+	}
+	return generateLabel(d.gpuProductName)
+}
+
+// gpuAnnotation records the gpus of the pod, the pod is no longer selected by the gpu model label when placed on a node
+func (d *Deploy) gpuAnnotation() map[string]string {
+	if d.nodeName == "" || d.gpuProductName == "" || len(d.gpuIndex) == 0 {
+		return nil
+	}
+	gpuIndex := d.gpuIndex
+	if int(d.hardwareResource.Gpu.Quantity) < len(gpuIndex) {
+		gpuIndex = gpuIndex[:d.hardwareResource.Gpu.Quantity]
+	}
+	return generateGpuAnnotation([]models.PodGpu{{
+		Gname:  d.gpuProductName,
+		Guse:   len(gpuIndex),
+		Gindex: gpuIndex,
+	}})
+}
+
 func generateGpuAnnotation(gpus []models.PodGpu) map[string]string {
 	var annotationMap = make(map[string]string)
 	for _, g := range gpus {
@@ -728,7 +878,23 @@ func (d *Deploy) deployNamespace() error {
 			return err
 		}
 	}
-	return nil
+
+	if err := d.saveJobResource(); err != nil {
+		return err
+	}
+	return SyncNamespaceQuota(d.walletAddress)
+}
+
+func (d *Deploy) saveJobResource() error {
+	memory, err := resource.ParseQuantity(fmt.Sprintf("%d%s", d.hardwareResource.Memory.Quantity, d.hardwareResource.Memory.Unit))
+	if err != nil {
+		return fmt.Errorf("failed to parse memory, job_uuid: %s, error: %v", d.jobUuid, err)
+	}
+	storage, err := resource.ParseQuantity(fmt.Sprintf("%d%s", d.hardwareResource.Storage.Quantity, d.hardwareResource.Storage.Unit))
+	if err != nil {
+		return fmt.Errorf("failed to parse storage, job_uuid: %s, error: %v", d.jobUuid, err)
+	}
+	return NewJobService().UpdateJobResourceByJobUuid(d.originalJobUuid, d.hardwareResource.Cpu.Quantity, memory.Value(), storage.Value(), d.hardwareResource.Gpu.Quantity)
 }
 
 func (d *Deploy) createEnv(envs ...coreV1.EnvVar) []coreV1.EnvVar {
@@ -968,6 +1134,21 @@ func getHardwareDetailByByte(spaceHardware models.SpaceHardware) (string, models
 	return taskType, hardwareResource
 }
 
+func getHardwareDetailForImage(k8sResourceImage models.K8sResourceForImage) models.Resource {
+	var hardwareResource models.Resource
+	hardwareResource.Cpu.Quantity = k8sResourceImage.Cpu
+	hardwareResource.Cpu.Unit = "vCPU"
+	hardwareResource.Memory.Quantity = int64(k8sResourceImage.Memory)
+	hardwareResource.Memory.Unit = "Gi"
+	hardwareResource.Storage.Quantity = int64(k8sResourceImage.Storage)
+	hardwareResource.Storage.Unit = "Gi"
+	for _, g := range k8sResourceImage.Gpus {
+		hardwareResource.Gpu.Quantity += int64(g.GPU)
+		hardwareResource.Gpu.Unit = g.GpuModel
+	}
+	return hardwareResource
+}
+
 func getHardwareDetailForPrivate(cpu, memory, storage int, gpuModel string, gpuNum int) (string, models.Resource) {
 	var taskType string
 	var hardwareResource models.Resource
@@ -0,0 +1,287 @@
+package computing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/internal/models"
+)
+
+// deployQueueSize bounds the jobs waiting for a deploy worker, the jobs beyond it are rejected
+const deployQueueSize = 100
+
+const (
+	deployHealthInterval = 5 * time.Second
+	// deployStartupWindow is how long a container without health check has to keep running before it is ready
+	deployStartupWindow = 5 * time.Second
+)
+
+// deployTask deploys the container of an ECP job through the stages prefetch, pull/build, create, start, health check and ready.
+// The stages are saved on the job, a failed stage removes the container and releases the ports of the job.
+type deployTask struct {
+	jobUuid        string
+	image          string
+	buildImagePath string
+	buildImageName string
+	buildCacheKey  string
+	// the model files prefetched into the model cache, and mounted read-only into the container
+	models        []models.JobModel
+	containerName string
+	config        *container.Config
+	hostConfig    *container.HostConfig
+	networkConfig *network.NetworkingConfig
+	// the http path and the container port checked before the job is ready, the job is ready once its container
+	// keeps running when they are not set
+	healthPath string
+	healthPort int
+	onReady    func()
+	onFailed   func()
+
+	ctx         context.Context
+	cancel      context.CancelFunc
+	stages      []models.JobStage
+	containerId string
+}
+
+var (
+	deployQueue    chan *deployTask
+	deployPoolOnce sync.Once
+	deployTasks    sync.Map // job uuid -> *deployTask
+)
+
+func startDeployWorkers() {
+	deployQueue = make(chan *deployTask, deployQueueSize)
+	for i := 0; i < conf.GetConfig().API.DeployWorkers; i++ {
+		go func() {
+			for task := range deployQueue {
+				task.run()
+			}
+		}()
+	}
+}
+
+// submitDeployTask queues the job for the deploy workers, it fails when the queue is full
+func submitDeployTask(task *deployTask) error {
+	deployPoolOnce.Do(startDeployWorkers)
+
+	task.ctx, task.cancel = context.WithCancel(context.Background())
+	deployTasks.Store(task.jobUuid, task)
+	task.enterStage(models.DeployStagePending)
+	select {
+	case deployQueue <- task:
+		return nil
+	default:
+		deployTasks.CompareAndDelete(task.jobUuid, task)
+		task.cancel()
+		return fmt.Errorf("the deploy queue is full, %d jobs are waiting", deployQueueSize)
+	}
+}
+
+// cancelDeployTask stops deploying the job, the container it created so far is removed
+func cancelDeployTask(jobUuid string) {
+	if task, ok := deployTasks.LoadAndDelete(jobUuid); ok {
+		task.(*deployTask).cancel()
+	}
+}
+
+func (t *deployTask) run() {
+	defer func() {
+		if err := recover(); err != nil {
+			logs.GetLogger().Errorf("deploy job catch panic error, job_uuid: %s, error: %+v", t.jobUuid, err)
+			t.fail("DeployFailed", fmt.Errorf("%v", err))
+		}
+		deployTasks.CompareAndDelete(t.jobUuid, t)
+		t.cancel()
+	}()
+
+	imageStage, imageReason, imageStep := models.DeployStagePulling, "ErrImagePull", t.pullImage
+	if t.buildImagePath != "" && t.buildImageName != "" {
+		imageStage, imageReason, imageStep = models.DeployStageBuilding, "BuildFailed", t.buildImage
+	}
+	type step struct {
+		stage  string
+		reason string
+		run    func() error
+	}
+	var steps []step
+	if len(t.models) > 0 {
+		steps = append(steps, step{models.DeployStagePrefetching, "ModelPrefetchFailed", t.prefetchModels})
+	}
+	steps = append(steps,
+		step{imageStage, imageReason, imageStep},
+		step{models.DeployStageCreating, "CreateContainerError", t.createContainer},
+		step{models.DeployStageStarting, "StartContainerError", t.startContainer},
+		step{models.DeployStageHealthCheck, "Unhealthy", t.healthCheck},
+	)
+
+	for _, step := range steps {
+		if t.ctx.Err() != nil {
+			t.canceled()
+			return
+		}
+		t.enterStage(step.stage)
+		if err := step.run(); err != nil {
+			if t.ctx.Err() != nil {
+				t.canceled()
+				return
+			}
+			logs.GetLogger().Errorf("failed to deploy job at stage %s, job_uuid: %s, error: %v", step.stage, t.jobUuid, err)
+			t.fail(step.reason, err)
+			return
+		}
+	}
+
+	t.enterStage(models.DeployStageReady)
+	logs.GetLogger().Infof("job_uuid: %s, container %s is ready", t.jobUuid, t.containerName)
+	confirmPorts(t.jobUuid)
+	if t.onReady != nil {
+		t.onReady()
+	}
+}
+
+func (t *deployTask) prefetchModels() error {
+	for _, model := range t.models {
+		modelPath, err := PrefetchModel(t.ctx, model)
+		if err != nil {
+			return err
+		}
+		t.hostConfig.Mounts = append(t.hostConfig.Mounts, mount.Mount{
+			Type:     mount.TypeBind,
+			Source:   modelPath,
+			Target:   path.Join(model.Dir, model.Name),
+			ReadOnly: true,
+		})
+	}
+	return nil
+}
+
+func (t *deployTask) pullImage() error {
+	return NewDockerService().PullImageForJob(t.ctx, t.jobUuid, t.image)
+}
+
+func (t *deployTask) buildImage() error {
+	return NewDockerService().BuildImage(t.ctx, t.jobUuid, t.buildImagePath, t.buildImageName, t.buildCacheKey)
+}
+
+func (t *deployTask) createContainer() error {
+	resp, err := NewDockerService().c.ContainerCreate(t.ctx, t.config, t.hostConfig, t.networkConfig, nil, t.containerName)
+	if err != nil {
+		return err
+	}
+	t.containerId = resp.ID
+	return nil
+}
+
+func (t *deployTask) startContainer() error {
+	return NewDockerService().c.ContainerStart(t.ctx, t.containerId, container.StartOptions{})
+}
+
+func (t *deployTask) healthCheck() error {
+	timeout := time.Duration(conf.GetConfig().API.DeployHealthTimeout) * time.Second
+	ctx, cancel := context.WithTimeout(t.ctx, timeout)
+	defer cancel()
+
+	dockerService := NewDockerService()
+	var runningSince time.Time
+	for {
+		info, err := dockerService.c.ContainerInspect(ctx, t.containerId)
+		if err == nil && info.State != nil {
+			switch {
+			case info.State.Status == "exited" || info.State.Status == "dead":
+				return fmt.Errorf("container %s, exit code: %d, error: %s", info.State.Status, info.State.ExitCode, info.State.Error)
+			case info.State.Running && !info.State.Restarting:
+				if runningSince.IsZero() {
+					runningSince = time.Now()
+				}
+				if t.healthPath == "" || t.healthPort == 0 {
+					if time.Since(runningSince) >= deployStartupWindow {
+						return nil
+					}
+				} else if probeContainer(ctx, info, t.healthPath, t.healthPort, deployHealthInterval) == nil {
+					return nil
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if t.ctx.Err() != nil {
+				return t.ctx.Err()
+			}
+			if t.healthPath != "" && t.healthPort != 0 {
+				return fmt.Errorf("health path %s not ready within %s", t.healthPath, timeout)
+			}
+			return fmt.Errorf("container not running within %s", timeout)
+		case <-time.After(deployHealthInterval):
+		}
+	}
+}
+
+// enterStage ends the current stage and starts the next one
+func (t *deployTask) enterStage(stage string) {
+	now := time.Now().Unix()
+	if n := len(t.stages); n > 0 && t.stages[n-1].EndTime == 0 {
+		t.stages[n-1].EndTime = now
+	}
+	t.stages = append(t.stages, models.JobStage{Stage: stage, StartTime: now})
+	t.saveStages()
+}
+
+func (t *deployTask) fail(reason string, err error) {
+	now := time.Now().Unix()
+	var stage string
+	if n := len(t.stages); n > 0 {
+		stage = t.stages[n-1].Stage
+		t.stages[n-1].EndTime = now
+		t.stages[n-1].Error = err.Error()
+	}
+	t.stages = append(t.stages, models.JobStage{Stage: models.DeployStageFailed, StartTime: now, EndTime: now, Error: err.Error()})
+	t.saveStages()
+
+	t.cleanup()
+	failEcpJob(t.jobUuid, reason, fmt.Sprintf("failed at stage %s: %v", stage, err))
+	if t.onFailed != nil {
+		t.onFailed()
+	}
+}
+
+func (t *deployTask) canceled() {
+	logs.GetLogger().Warnf("job_uuid: %s, deploy canceled", t.jobUuid)
+	now := time.Now().Unix()
+	if n := len(t.stages); n > 0 && t.stages[n-1].EndTime == 0 {
+		t.stages[n-1].EndTime = now
+		t.stages[n-1].Error = "canceled"
+	}
+	t.saveStages()
+	t.cleanup()
+}
+
+// cleanup removes the container created by the task, and releases the ports of the job
+func (t *deployTask) cleanup() {
+	if t.containerId != "" {
+		err := NewDockerService().c.ContainerRemove(context.Background(), t.containerId, container.RemoveOptions{Force: true})
+		if err != nil {
+			logs.GetLogger().Errorf("failed to remove container, job_uuid: %s, error: %v", t.jobUuid, err)
+		}
+	}
+	releasePorts(t.jobUuid)
+}
+
+func (t *deployTask) saveStages() {
+	stages, err := json.Marshal(t.stages)
+	if err != nil {
+		return
+	}
+	if err = NewEcpJobService().UpdateEcpJobEntityStage(t.jobUuid, t.stages[len(t.stages)-1].Stage, string(stages)); err != nil {
+		logs.GetLogger().Errorf("failed to save deploy stage, job_uuid: %s, error: %v", t.jobUuid, err)
+	}
+}
@@ -0,0 +1,264 @@
+package computing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/internal/models"
+)
+
+// dockerJobUuidLabel is set on the containers of ECP jobs, so the docker events can be mapped back to the job
+const dockerJobUuidLabel = "cp.job_uuid"
+
+const pullProgressInterval = 5 * time.Second
+
+// WatchContainerEvents follows the docker events of the job containers and publishes them as job events,
+// reconnecting to the docker daemon when the event stream breaks.
+func WatchContainerEvents() {
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				logs.GetLogger().Errorf("watch container events catch panic error: %+v", err)
+			}
+		}()
+
+		for {
+			dockerService := NewDockerService()
+			if dockerService == nil {
+				time.Sleep(30 * time.Second)
+				continue
+			}
+			if err := dockerService.watchContainerEvents(context.Background()); err != nil {
+				logs.GetLogger().Warnf("docker event stream closed, retry later, error: %v", err)
+			}
+			dockerService.c.Close()
+			time.Sleep(10 * time.Second)
+		}
+	}()
+}
+
+func (ds *DockerService) watchContainerEvents(ctx context.Context) error {
+	filterArgs := filters.NewArgs()
+	filterArgs.Add("type", string(events.ContainerEventType))
+	for _, action := range []events.Action{events.ActionStart, events.ActionRestart, events.ActionDie, events.ActionOOM,
+		events.ActionKill, events.ActionDestroy, events.ActionHealthStatus} {
+		filterArgs.Add("event", string(action))
+	}
+
+	messages, errs := ds.c.Events(ctx, events.ListOptions{Filters: filterArgs})
+	for {
+		select {
+		case msg := <-messages:
+			publishContainerEvent(msg)
+		case err := <-errs:
+			return err
+		}
+	}
+}
+
+func publishContainerEvent(msg events.Message) {
+	containerName := msg.Actor.Attributes["name"]
+	jobUuid := jobUuidOfContainer(containerName, msg.Actor.Attributes)
+	if jobUuid == "" {
+		return
+	}
+
+	event := models.JobEvent{
+		JobUuid: jobUuid,
+		Type:    JobEventNormal,
+		Object:  "Container/" + containerName,
+		Time:    msg.Time,
+	}
+
+	action := string(msg.Action)
+	switch {
+	case msg.Action == events.ActionStart:
+		event.Reason = "Started"
+		event.Message = fmt.Sprintf("container %s started", containerName)
+	case msg.Action == events.ActionRestart:
+		event.Type = JobEventWarning
+		event.Reason = "Restarted"
+		event.Message = fmt.Sprintf("container %s restarted", containerName)
+	case msg.Action == events.ActionDie:
+		exitCode := msg.Actor.Attributes["exitCode"]
+		event.Reason = "Exited"
+		event.Message = fmt.Sprintf("container %s exited with code %s", containerName, exitCode)
+		if exitCode != "0" {
+			event.Type = JobEventWarning
+		}
+	case msg.Action == events.ActionOOM:
+		event.Type = JobEventWarning
+		event.Reason = "OOMKilled"
+		event.Message = fmt.Sprintf("container %s ran out of memory", containerName)
+	case msg.Action == events.ActionKill:
+		event.Reason = "Killing"
+		event.Message = fmt.Sprintf("container %s killed by signal %s", containerName, msg.Actor.Attributes["signal"])
+	case msg.Action == events.ActionDestroy:
+		event.Reason = "Removed"
+		event.Message = fmt.Sprintf("container %s removed", containerName)
+	case strings.HasPrefix(action, string(events.ActionHealthStatus)):
+		status := strings.TrimSpace(strings.TrimPrefix(action, string(events.ActionHealthStatus)+":"))
+		if status == "unhealthy" {
+			event.Type = JobEventWarning
+			event.Reason = "Unhealthy"
+		} else {
+			event.Reason = "HealthCheck"
+		}
+		event.Message = fmt.Sprintf("container %s is %s", containerName, status)
+	default:
+		return
+	}
+	PublishJobEvent(event)
+}
+
+func jobUuidOfContainer(containerName string, attributes map[string]string) string {
+	if jobUuid, ok := attributes[dockerJobUuidLabel]; ok {
+		return jobUuid
+	}
+	if containerName == "" {
+		return ""
+	}
+
+	var job models.EcpJobEntity
+	if err := NewEcpJobService().Model(&models.EcpJobEntity{}).Where("container_name=?", containerName).Limit(1).Find(&job).Error; err == nil && job.Uuid != "" {
+		return job.Uuid
+	}
+	// the containers of ubi mining tasks are named by the task uuid
+	if len(containerName) == 36 && strings.Count(containerName, "-") == 4 {
+		return containerName
+	}
+	return ""
+}
+
+// PullImageForJob pulls the image like PullImage, and publishes the pull progress as events of the job.
+// Cancelling ctx aborts the pull.
+func (ds *DockerService) PullImageForJob(ctx context.Context, jobUuid, imageName string) error {
+	if ds.checkImageExists(imageName) && !alwaysPull(conf.GetConfig().ImageCache.PullPolicy, imageName) {
+		PublishJobEvent(models.JobEvent{
+			JobUuid: jobUuid,
+			Reason:  "Pulled",
+			Object:  "Image/" + imageName,
+			Message: fmt.Sprintf("image %s already present on machine", imageName),
+		})
+		ds.touchImageCache(imageName)
+		return nil
+	}
+
+	PublishJobEvent(models.JobEvent{
+		JobUuid: jobUuid,
+		Reason:  "Pulling",
+		Object:  "Image/" + imageName,
+		Message: fmt.Sprintf("pulling image %s", imageName),
+	})
+	err := ds.pullImageFromRegistries(ctx, imageName, func(rd io.Reader) error {
+		return publishPullProgress(jobUuid, imageName, rd)
+	})
+	if err != nil {
+		PublishJobEvent(models.JobEvent{
+			JobUuid: jobUuid,
+			Type:    JobEventWarning,
+			Reason:  "ErrImagePull",
+			Object:  "Image/" + imageName,
+			Message: fmt.Sprintf("failed to pull image %s: %v", imageName, err),
+		})
+		return err
+	}
+
+	PublishJobEvent(models.JobEvent{
+		JobUuid: jobUuid,
+		Reason:  "Pulled",
+		Object:  "Image/" + imageName,
+		Message: fmt.Sprintf("successfully pulled image %s", imageName),
+	})
+	ds.touchImageCache(imageName)
+	return nil
+}
+
+func publishPullProgress(jobUuid, imageName string, rd io.Reader) error {
+	type layerProgress struct {
+		current, total int64
+		done           bool
+	}
+	layers := make(map[string]*layerProgress)
+	var lastPublish time.Time
+
+	decoder := json.NewDecoder(rd)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := decoder.Decode(&msg); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if msg.Error != nil {
+			return errors.New(msg.Error.Message)
+		}
+		if msg.ID == "" {
+			continue
+		}
+
+		layer, ok := layers[msg.ID]
+		if !ok {
+			layer = &layerProgress{}
+			layers[msg.ID] = layer
+		}
+		switch msg.Status {
+		case "Downloading":
+			if msg.Progress != nil {
+				layer.current, layer.total = msg.Progress.Current, msg.Progress.Total
+			}
+		case "Download complete", "Pull complete", "Already exists":
+			layer.done = true
+			layer.current = layer.total
+		}
+
+		if time.Since(lastPublish) < pullProgressInterval {
+			continue
+		}
+		lastPublish = time.Now()
+
+		var doneLayers int
+		var current, total int64
+		for _, l := range layers {
+			if l.done {
+				doneLayers++
+			}
+			current += l.current
+			total += l.total
+		}
+		message := fmt.Sprintf("pulling image %s, layers: %d/%d", imageName, doneLayers, len(layers))
+		if total > 0 {
+			message += fmt.Sprintf(", downloaded: %s/%s", BytesToHumanReadable(current), BytesToHumanReadable(total))
+		}
+		PublishJobEvent(models.JobEvent{
+			JobUuid: jobUuid,
+			Reason:  "Pulling",
+			Object:  "Image/" + imageName,
+			Message: message,
+		})
+	}
+}
+
+// failEcpJob marks the ECP job as failed and publishes the reason as a warning event of the job.
+func failEcpJob(jobUuid, reason, message string) {
+	PublishJobEvent(models.JobEvent{
+		JobUuid: jobUuid,
+		Type:    JobEventWarning,
+		Reason:  reason,
+		Object:  "Job/" + jobUuid,
+		Message: message,
+	})
+	NewEcpJobService().UpdateEcpJobEntityMessage(jobUuid, message)
+	releasePorts(jobUuid)
+}
@@ -1,9 +1,7 @@
 package computing
 
 import (
-	"archive/tar"
 	"bufio"
-	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
@@ -17,7 +15,6 @@ import (
 	"github.com/docker/docker/api/types/registry"
 	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
 	"github.com/swanchain/go-computing-provider/build"
-	"github.com/swanchain/go-computing-provider/constants"
 	"io"
 	"os"
 	"path/filepath"
@@ -28,7 +25,6 @@ import (
 	"github.com/swanchain/go-computing-provider/conf"
 
 	"github.com/containerd/containerd"
-	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/client"
 )
 
@@ -73,71 +69,6 @@ func ExtractExposedPort(dockerfilePath string) (string, error) {
 	return exposedPort, nil
 }
 
-func (ds *DockerService) BuildImage(jobUuid, buildPath, imageName string) error {
-	// Create a buffer
-	buf := new(bytes.Buffer)
-	tw := tar.NewWriter(buf)
-	defer tw.Close()
-
-	filepath.Walk(buildPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		header, err := tar.FileInfoHeader(info, info.Name())
-		if err != nil {
-			return err
-		}
-		relPath, err := filepath.Rel(buildPath, path)
-		if err != nil {
-			return err
-		}
-		header.Name = relPath
-		if err := tw.WriteHeader(header); err != nil {
-			return err
-		}
-		if !info.IsDir() {
-			file, err := os.Open(path)
-			if err != nil {
-				return err
-			}
-			defer file.Close()
-			if _, err := io.Copy(tw, file); err != nil {
-				return err
-			}
-		}
-		return nil
-	})
-
-	dockerFileTarReader := bytes.NewReader(buf.Bytes())
-	buildResponse, err := ds.c.ImageBuild(context.Background(), dockerFileTarReader, types.ImageBuildOptions{
-		Context: dockerFileTarReader,
-		Tags:    []string{imageName},
-	})
-	if err != nil {
-		return err
-	}
-	defer buildResponse.Body.Close()
-
-	cpRepoPath, _ := os.LookupEnv("CP_PATH")
-	logDir := filepath.Join(cpRepoPath, constants.LOG_PATH_PREFIX, jobUuid)
-	os.MkdirAll(logDir, os.ModePerm)
-
-	logFile, err := os.Create(filepath.Join(logDir, constants.BUILD_LOG_NAME))
-	if err != nil {
-		return err
-	}
-	defer logFile.Close()
-
-	logWriters := []io.Writer{logFile, os.Stdout}
-	multiWriter := io.MultiWriter(logWriters...)
-
-	_, err = io.Copy(multiWriter, buildResponse.Body)
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
 type ErrorLine struct {
 	Error       string `json:"error"`
 	ErrorDetail struct {
@@ -278,31 +209,24 @@ func (ds *DockerService) CleanResourceForDocker(onlyClearContainer bool) {
 		return
 	}
 
-	imagesToKeep := []string{
-		build.UBITaskImageIntelCpu,
-		build.UBITaskImageIntelGpu,
-		build.UBITaskImageAmdCpu,
-		build.UBITaskImageAmdGpu,
-		build.UBIResourceExporterDockerImage,
-	}
-
-	keepSet := make(map[string]bool)
-	for _, imageName := range imagesToKeep {
-		keepSet[imageName] = true
-	}
-
-	allImages, err := ds.c.ImageList(context.Background(), image.ListOptions{})
-	if err != nil {
-		logs.GetLogger().Errorf("Failed get image list, error: %+v", err)
-		return
-	}
-	for _, img := range allImages {
-		for _, tag := range img.RepoTags {
-			if !keepSet[tag] {
-				ds.c.ImageRemove(context.Background(), tag, image.RemoveOptions{
-					Force:         false,
-					PruneChildren: true,
-				})
+	if conf.GetConfig().ImageCache.MaxSize > 0 {
+		ds.c.ContainersPrune(ctx, filters.NewArgs())
+		ds.EvictImageCache()
+	} else {
+		keepSet := keptImages()
+		allImages, err := ds.c.ImageList(context.Background(), image.ListOptions{})
+		if err != nil {
+			logs.GetLogger().Errorf("Failed get image list, error: %+v", err)
+			return
+		}
+		for _, img := range allImages {
+			for _, tag := range img.RepoTags {
+				if !keepSet[tag] {
+					ds.c.ImageRemove(context.Background(), tag, image.RemoveOptions{
+						Force:         false,
+						PruneChildren: true,
+					})
+				}
 			}
 		}
 	}
@@ -324,17 +248,10 @@ func (ds *DockerService) PullImage(imageName string) error {
 		logs.GetLogger().Errorf("get %s image failed, error: %+v", imageName, err)
 		return err
 	}
-	if len(images) > 0 {
-		return nil
-	} else {
-		resp, err := ds.c.ImagePull(context.TODO(), imageName, image.PullOptions{})
-		if err != nil {
-			return err
-		}
-		defer resp.Close()
-		printOut(resp)
+	if len(images) > 0 && !alwaysPull(conf.GetConfig().ImageCache.PullPolicy, imageName) {
 		return nil
 	}
+	return ds.pullImageFromRegistries(context.Background(), imageName, printOut)
 }
 
 func (ds *DockerService) CheckRunningContainer(containerName string) (bool, string, error) {
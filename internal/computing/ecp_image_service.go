@@ -273,6 +273,7 @@ func (imageJob *ImageJobService) DeployJob(c *gin.Context) {
 	deployJob.HealthPath = job.HealthPath
 	deployJob.NeedResource = needResource
 	deployJob.IpWhiteList = job.IpWhiteList
+	deployJob.Models = job.Models
 	deployJob.Envs = append(deployJob.Envs, envs...)
 
 	if job.DeployType == 0 {
@@ -308,6 +309,7 @@ func (imageJob *ImageJobService) DeployJob(c *gin.Context) {
 		deployJob.Image = buildParams.BuildImageName
 		deployJob.BuildImagePath = buildParams.BuildImagePath
 		deployJob.BuildImageName = buildParams.BuildImageName
+		deployJob.BuildCacheKey = spaceBuildCacheKey(job.WalletAddress, job.Name)
 		deployJob.Ports = buildParams.Ports
 		deployJob.Envs = append(deployJob.Envs, buildParams.Envs...)
 		deployJob.Cmd = buildParams.Cmd
@@ -331,6 +333,32 @@ func (imageJob *ImageJobService) DeployJob(c *gin.Context) {
 		return
 	}
 
+	if err := checkJobModels(deployJob.Models); err != nil {
+		if job.Price == "-1" && job.JobType == models.MiningJobType {
+			NewTaskService().UpdateTaskStatusByUuid(job.Uuid, models.TASK_REJECTED_STATUS)
+		}
+		c.JSON(http.StatusBadRequest, util.CreateErrorResponse(util.BadParamError, err.Error()))
+		return
+	}
+
+	var policyErr error
+	if deployJob.BuildImagePath != "" {
+		policyErr = checkDockerfilePolicy(filepath.Join(deployJob.BuildImagePath, "Dockerfile"))
+	} else {
+		var pinned map[string]string
+		if pinned, policyErr = checkImagePolicy(deployJob.Image); policyErr == nil {
+			deployJob.Image = pinImage(pinned, deployJob.Image)
+		}
+	}
+	if policyErr != nil {
+		if job.Price == "-1" && job.JobType == models.MiningJobType {
+			NewTaskService().UpdateTaskStatusByUuid(job.Uuid, models.TASK_REJECTED_STATUS)
+		}
+		logs.GetLogger().Warnf("job_uuid: %s, rejected by the image policy: %v", job.Uuid, policyErr)
+		c.JSON(http.StatusForbidden, util.CreateErrorResponse(util.ImagePolicyError, policyErr.Error()))
+		return
+	}
+
 	if job.Price == "-1" {
 		if job.JobType == models.MiningJobType {
 			var maxID int64
@@ -368,6 +396,11 @@ func (imageJob *ImageJobService) DeployJob(c *gin.Context) {
 			}
 		}
 
+		var healthPort int
+		if len(deployJob.Ports) > 0 {
+			healthPort = deployJob.Ports[0]
+		}
+
 		if err = NewEcpJobService().SaveEcpJobEntity(&models.EcpJobEntity{
 			Uuid:          job.Uuid,
 			Name:          job.Name,
@@ -382,6 +415,7 @@ func (imageJob *ImageJobService) DeployJob(c *gin.Context) {
 			GpuIndex:      gIndexStr,
 			Status:        models.CreatedStatus,
 			HealthUrlPath: job.HealthPath,
+			HealthPort:    healthPort,
 			CreateTime:    time.Now().Unix(),
 		}); err != nil {
 			logs.GetLogger().Errorf("failed to save job to db, error: %v", err)
@@ -501,6 +535,30 @@ func (*ImageJobService) GetJobStatus(c *gin.Context) {
 			statusStr = status
 		}
 
+		var health *models.JobHealth
+		if entity.Health != "" {
+			health = &models.JobHealth{
+				Status:        entity.Health,
+				Failures:      entity.HealthFailures,
+				RestartCount:  entity.RestartCount,
+				LastCheckTime: entity.LastCheckTime,
+				Message:       entity.HealthMessage,
+			}
+			if statusStr == models.RunningStatus && entity.StartedTime > 0 {
+				health.Uptime = time.Now().Unix() - entity.StartedTime
+			}
+			if statusStr == models.RunningStatus && entity.Health == models.UnhealthyStatus {
+				statusStr = models.UnhealthyStatus
+			}
+		}
+
+		var stages []models.JobStage
+		if entity.Stages != "" {
+			if err = json.Unmarshal([]byte(entity.Stages), &stages); err != nil {
+				logs.GetLogger().Warnf("failed to parse deploy stages, job_uuid: %s, error: %v", entity.Uuid, err)
+			}
+		}
+
 		var portMap []models.PortMap
 		if len(entity.PortMap) > 1 {
 			splits := strings.Split(entity.PortMap, ",")
@@ -521,6 +579,9 @@ func (*ImageJobService) GetJobStatus(c *gin.Context) {
 			HealthPath:         entity.HealthUrlPath,
 			ServicePortMapping: portMap,
 			Message:            entity.Message,
+			Stage:              entity.Stage,
+			Stages:             stages,
+			Health:             health,
 		})
 	}
 
@@ -533,6 +594,7 @@ func (*ImageJobService) DeleteJob(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, util.CreateErrorResponse(util.BadParamError, "missing required field: [job_uuid]"))
 		return
 	}
+	cancelDeployTask(jobUuId)
 
 	ecpJobEntity, err := NewEcpJobService().GetEcpJobByUuid(jobUuId)
 	if err != nil {
@@ -546,6 +608,7 @@ func (*ImageJobService) DeleteJob(c *gin.Context) {
 			return
 		}
 		NewEcpJobService().DeleteContainerByUuid(jobUuId)
+		releasePorts(jobUuId)
 	} else {
 		NewTaskService().UpdateTaskStatusByUuid(jobUuId, models.TASK_SUBMITTED_STATUS)
 		if err = NewDockerService().RemoveContainerByName(jobUuId); err != nil {
@@ -611,56 +674,50 @@ func (*ImageJobService) DeployMining(c *gin.Context, deployJob models.DeployJobP
 	} else {
 		containerName = deployJob.Name + "-" + generateString(5)
 	}
-	go func() {
-		if deployJob.BuildImagePath != "" && deployJob.BuildImageName != "" {
-			if err := NewDockerService().BuildImage(deployJob.Uuid, deployJob.BuildImagePath, deployJob.BuildImageName); err != nil {
-				logs.GetLogger().Errorf("failed to building %s image, job_uuid: %s, error: %v", deployJob.Image, deployJob.Uuid, err)
-				NewEcpJobService().UpdateEcpJobEntityMessage(deployJob.Uuid, fmt.Sprintf("failed to build image: %s", deployJob.Image))
-			}
-		} else {
-			if err := NewDockerService().PullImage(deployJob.Image); err != nil {
-				logs.GetLogger().Errorf("failed to pull %s image, job_uuid: %s, error: %v", deployJob.Image, deployJob.Uuid, err)
-				NewEcpJobService().UpdateEcpJobEntityMessage(deployJob.Uuid, fmt.Sprintf("failed to pull image: %s", deployJob.Image))
-				return
-			}
-		}
-
-		hostConfig := &container.HostConfig{
-			Resources:  deployJob.NeedResource,
-			Privileged: true,
-		}
-		containerConfig := &container.Config{
-			Image:        deployJob.Image,
-			Env:          deployJob.Envs,
-			AttachStdout: true,
-			AttachStderr: true,
-			Tty:          true,
-		}
-		dockerService := NewDockerService()
-		if err := dockerService.ContainerCreateAndStart(containerConfig, hostConfig, nil, containerName); err != nil {
-			logs.GetLogger().Errorf("failed to create job container, job_uuid: %s, error: %v", deployJob.Uuid, err)
-			NewEcpJobService().UpdateEcpJobEntityMessage(deployJob.Uuid, "failed to create container")
-			return
-		}
-		logs.GetLogger().Warnf("job_uuid: %s, starting container, container name: %s", deployJob.Uuid, containerName)
-
-		time.Sleep(3 * time.Second)
-		if !dockerService.IsExistContainer(containerName) {
-			logs.GetLogger().Warnf("job_uuid: %s, not found container", deployJob.Uuid)
-			NewEcpJobService().UpdateEcpJobEntityMessage(deployJob.Uuid, "failed to start container")
-			return
-		}
-		logs.GetLogger().Warnf("job_uuid: %s, started container, container name: %s", deployJob.Uuid, containerName)
-
-		if price == "-1" {
+	hostConfig := &container.HostConfig{
+		Resources: deployJob.NeedResource,
+	}
+	applyDockerSecurityProfile(SecurityJobMining, hostConfig)
+	containerConfig := &container.Config{
+		Image:        deployJob.Image,
+		Env:          deployJob.Envs,
+		AttachStdout: true,
+		AttachStderr: true,
+		Tty:          true,
+		Labels:       map[string]string{dockerJobUuidLabel: deployJob.Uuid},
+	}
+
+	task := &deployTask{
+		jobUuid:        deployJob.Uuid,
+		image:          deployJob.Image,
+		buildImagePath: deployJob.BuildImagePath,
+		buildImageName: deployJob.BuildImageName,
+		buildCacheKey:  deployJob.BuildCacheKey,
+		models:         deployJob.Models,
+		containerName:  containerName,
+		config:         containerConfig,
+		hostConfig:     hostConfig,
+	}
+	if price == "-1" {
+		task.onReady = func() {
 			NewTaskService().UpdateTaskStatusByUuid(deployJob.Uuid, models.TASK_RUNNING_STATUS)
 		}
-
-		if err = NewEcpJobService().UpdateEcpJobEntityContainerName(deployJob.Uuid, containerName); err != nil {
-			logs.GetLogger().Errorf("failed to save job to db, error: %v", err)
-			return
+		task.onFailed = func() {
+			NewTaskService().UpdateTaskStatusByUuid(deployJob.Uuid, models.TASK_FAILED_STATUS)
+		}
+	}
+	if err = submitDeployTask(task); err != nil {
+		logs.GetLogger().Errorf("failed to deploy job, job_uuid: %s, error: %v", deployJob.Uuid, err)
+		failEcpJob(deployJob.Uuid, "DeployFailed", err.Error())
+		if task.onFailed != nil {
+			task.onFailed()
 		}
-	}()
+		c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.NoAvailableResourcesError, err.Error()))
+		return
+	}
+	if err = NewEcpJobService().UpdateEcpJobEntityContainerName(deployJob.Uuid, containerName); err != nil {
+		logs.GetLogger().Errorf("failed to save job to db, error: %v", err)
+	}
 
 	c.JSON(http.StatusOK, util.CreateSuccessResponse(map[string]interface{}{
 		"uuid":    deployJob.Uuid,
@@ -675,11 +732,17 @@ func (*ImageJobService) DeployInference(c *gin.Context, deployJob models.DeployJ
 	var apiUrl string
 	var portBinding map[nat.Port][]nat.PortBinding
 	var portMaps []models.PortMap
-	var labelMap map[string]string
+	var labelMap = map[string]string{dockerJobUuidLabel: deployJob.Uuid}
 	if len(deployJob.Ports) > 1 {
-		portBinding, portMaps, err = handleMultiPort(deployJob.Ports)
+		portBinding, portMaps, err = allocatePorts(deployJob.Uuid, containerName, deployJob.Ports)
+		if err != nil {
+			logs.GetLogger().Errorf("failed to allocate ports, job_uuid: %s, error: %v", deployJob.Uuid, err)
+			NewEcpJobService().UpdateEcpJobEntity(deployJob.Uuid, models.RejectStatus)
+			c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.PortNoAvailableError, err.Error()))
+			return
+		}
 		multiAddressSplit := strings.Split(conf.GetConfig().API.MultiAddress, "/")
-		apiUrl = multiAddressSplit[2]
+		apiUrl = "http://" + multiAddressSplit[2]
 	} else {
 		prefixStr := generateString(10)
 		if strings.HasPrefix(conf.GetConfig().API.Domain, ".") {
@@ -688,11 +751,7 @@ func (*ImageJobService) DeployInference(c *gin.Context, deployJob models.DeployJ
 			apiUrl = strings.Join([]string{prefixStr, conf.GetConfig().API.Domain}, ".")
 		}
 
-		labelMap = map[string]string{
-			"traefik.enable": "true",
-			fmt.Sprintf("traefik.http.routers.%s.entrypoints", containerName): "web",
-			fmt.Sprintf("traefik.http.routers.%s.rule", containerName):        fmt.Sprintf("Host(`%s`)", apiUrl),
-		}
+		setTraefikRouterLabels(labelMap, containerName, apiUrl)
 
 		if len(deployJob.IpWhiteList) > 0 {
 			whiteListName := fmt.Sprintf("%s-ipallowlist", prefixStr)
@@ -702,74 +761,64 @@ func (*ImageJobService) DeployInference(c *gin.Context, deployJob models.DeployJ
 			whiteRuleName := fmt.Sprintf("traefik.http.routers.%s.middlewares", containerName)
 			labelMap[whiteRuleName] = whiteListName
 		}
-		apiUrl += fmt.Sprintf(":%d", traefikListenPortMapHost)
+		apiUrl = traefikServiceUrl(apiUrl)
 	}
 
-	go func() {
-		if deployJob.BuildImagePath != "" && deployJob.BuildImageName != "" {
-			if err := NewDockerService().BuildImage(deployJob.Uuid, deployJob.BuildImagePath, deployJob.BuildImageName); err != nil {
-				logs.GetLogger().Errorf("failed to building %s image, job_uuid: %s, error: %v", deployJob.Image, deployJob.Uuid, err)
-				NewEcpJobService().UpdateEcpJobEntityMessage(deployJob.Uuid, fmt.Sprintf("failed to build image: %s", deployJob.Image))
-			}
-		} else {
-			if err := NewDockerService().PullImage(deployJob.Image); err != nil {
-				logs.GetLogger().Errorf("failed to pull %s image, job_uuid: %s, error: %v", deployJob.Image, deployJob.Uuid, err)
-				NewEcpJobService().UpdateEcpJobEntityMessage(deployJob.Uuid, fmt.Sprintf("failed to pull image: %s", deployJob.Image))
-				return
-			}
-		}
-
-		hostConfig := &container.HostConfig{
-			Resources:  deployJob.NeedResource,
-			Privileged: true,
-		}
-
-		var containerConfig = &container.Config{
-			Image:        deployJob.Image,
-			Env:          deployJob.Envs,
-			Cmd:          deployJob.Cmd,
-			AttachStdout: true,
-			AttachStderr: true,
-			Tty:          true,
-		}
-
-		var networkConfig *network.NetworkingConfig
-		if len(deployJob.Ports) > 1 {
-			hostConfig.PortBindings = portBinding
-		} else {
-			containerConfig.Labels = labelMap
-			networkConfig = &network.NetworkingConfig{
-				EndpointsConfig: map[string]*network.EndpointSettings{
-					"traefik-net": {},
-				},
-			}
-		}
+	hostConfig := &container.HostConfig{
+		Resources: deployJob.NeedResource,
+	}
+	applyDockerSecurityProfile(SecurityJobInference, hostConfig)
 
-		dockerService := NewDockerService()
-		if err := dockerService.ContainerCreateAndStart(containerConfig, hostConfig, networkConfig, containerName); err != nil {
-			logs.GetLogger().Errorf("failed to create job container, job_uuid: %s, error: %v", deployJob.Uuid, err)
-			NewEcpJobService().UpdateEcpJobEntityMessage(deployJob.Uuid, "failed to create container")
-			return
-		}
-		logs.GetLogger().Warnf("job_uuid: %s, starting container, container name: %s", deployJob.Uuid, containerName)
+	var containerConfig = &container.Config{
+		Image:        deployJob.Image,
+		Env:          deployJob.Envs,
+		Cmd:          deployJob.Cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+		Tty:          true,
+		Labels:       labelMap,
+	}
 
-		time.Sleep(3 * time.Second)
-		if !dockerService.IsExistContainer(containerName) {
-			logs.GetLogger().Warnf("job_uuid: %s, not found container", deployJob.Uuid)
-			NewEcpJobService().UpdateEcpJobEntityMessage(deployJob.Uuid, "failed to start container")
-			return
+	var networkConfig *network.NetworkingConfig
+	if len(deployJob.Ports) > 1 {
+		hostConfig.PortBindings = portBinding
+	} else {
+		networkConfig = &network.NetworkingConfig{
+			EndpointsConfig: map[string]*network.EndpointSettings{
+				"traefik-net": {},
+			},
 		}
-		logs.GetLogger().Warnf("job_uuid: %s, started container, container name: %s", deployJob.Uuid, containerName)
+	}
 
-		if err = NewEcpJobService().UpdateEcpJobEntityContainerName(deployJob.Uuid, containerName); err != nil {
-			logs.GetLogger().Errorf("failed to save job to db, error: %v", err)
-			return
-		}
-	}()
+	task := &deployTask{
+		jobUuid:        deployJob.Uuid,
+		image:          deployJob.Image,
+		buildImagePath: deployJob.BuildImagePath,
+		buildImageName: deployJob.BuildImageName,
+		buildCacheKey:  deployJob.BuildCacheKey,
+		models:         deployJob.Models,
+		containerName:  containerName,
+		config:         containerConfig,
+		hostConfig:     hostConfig,
+		networkConfig:  networkConfig,
+		healthPath:     deployJob.HealthPath,
+	}
+	if len(deployJob.Ports) > 0 {
+		task.healthPort = deployJob.Ports[0]
+	}
+	if err = submitDeployTask(task); err != nil {
+		logs.GetLogger().Errorf("failed to deploy job, job_uuid: %s, error: %v", deployJob.Uuid, err)
+		failEcpJob(deployJob.Uuid, "DeployFailed", err.Error())
+		c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.NoAvailableResourcesError, err.Error()))
+		return
+	}
+	if err = NewEcpJobService().UpdateEcpJobEntityContainerName(deployJob.Uuid, containerName); err != nil {
+		logs.GetLogger().Errorf("failed to save job to db, error: %v", err)
+	}
 
 	var inferenceResp models.EcpImageResp
 	inferenceResp.UUID = deployJob.Uuid
-	inferenceResp.ServiceUrl = "http://" + apiUrl
+	inferenceResp.ServiceUrl = apiUrl
 	inferenceResp.HealthPath = deployJob.HealthPath
 	inferenceResp.ServicePortMapping = portMaps
 	inferenceResp.Price = totalCost
@@ -790,46 +839,6 @@ func (*ImageJobService) DeployInference(c *gin.Context, deployJob models.DeployJ
 	c.JSON(http.StatusOK, util.CreateSuccessResponse(inferenceResp))
 }
 
-func handleMultiPort(ports []int) (map[nat.Port][]nat.PortBinding, []models.PortMap, error) {
-	portRange, err := parsePortRanges(conf.GetConfig().API.PortRange)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to parse ports in config, error:%v", err)
-	}
-	var usedPort = make(map[int]int)
-	var count int
-	for i := 0; i < len(ports); i++ {
-		for j := 0; i < len(portRange); j++ {
-			if _, ok := usedPort[portRange[j]]; !ok {
-				if util.IsPortAvailable(portRange[j]) {
-					usedPort[portRange[j]] = ports[i]
-					count++
-					break
-				}
-			}
-		}
-	}
-	if len(ports) > count {
-		return nil, nil, fmt.Errorf("port number unavailable, need %d, available: %d", len(ports), count)
-	}
-
-	var mapPorts []models.PortMap
-	var portMap = make(map[nat.Port][]nat.PortBinding)
-	for hostP, containerP := range usedPort {
-		portMap[nat.Port(fmt.Sprintf("%d/tcp", containerP))] = []nat.PortBinding{
-			{
-				HostIP:   "0.0.0.0",
-				HostPort: strconv.Itoa(hostP),
-			},
-		}
-		mapPorts = append(mapPorts, models.PortMap{
-			ContainerPort: containerP,
-			ExternalPort:  hostP,
-		})
-	}
-
-	return portMap, mapPorts, nil
-}
-
 func parsePortRanges(portRanges []string) ([]int, error) {
 	var ports []int
 	for _, rangeStr := range portRanges {
@@ -3,9 +3,13 @@ package computing
 import (
 	"fmt"
 	"github.com/google/wire"
+	"github.com/swanchain/go-computing-provider/internal/contract"
 	"github.com/swanchain/go-computing-provider/internal/db"
 	"github.com/swanchain/go-computing-provider/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"math/big"
+	"strings"
 	"time"
 )
 
@@ -128,7 +132,7 @@ func (jobServ JobService) GetJobEntityBySpaceUuid(spaceUuid string) int64 {
 
 func (jobServ JobService) GetJobEntityByJobUuid(jobUuid string) (models.JobEntity, error) {
 	var job models.JobEntity
-	err := jobServ.Model(&models.JobEntity{}).Where("job_uuid=?", jobUuid).Find(&job).Error
+	err := jobServ.Model(&models.JobEntity{}).Where("lower(job_uuid)=?", strings.ToLower(jobUuid)).Find(&job).Error
 	return job, err
 }
 
@@ -184,8 +188,43 @@ func (jobServ JobService) GetJobListByNoRejectStatus() (list []*models.JobEntity
 	return
 }
 
+func (jobServ JobService) GetActiveJobListByWallet(walletAddress string) (list []*models.JobEntity, err error) {
+	err = jobServ.Model(&models.JobEntity{}).Where("lower(wallet_address)=? and delete_at=? and status in ?", strings.ToLower(walletAddress), models.UN_DELETEED_FLAG,
+		[]int{models.JOB_RECEIVED_STATUS, models.JOB_DEPLOY_STATUS, models.JOB_RUNNING_STATUS}).Find(&list).Error
+	return
+}
+
+func (jobServ JobService) UpdateJobResourceByJobUuid(jobUuid string, cpu, memory, storage, gpu int64) (err error) {
+	return jobServ.Model(&models.JobEntity{}).Where("job_uuid=?", jobUuid).Updates(map[string]interface{}{
+		"cpu":     cpu,
+		"memory":  memory,
+		"storage": storage,
+		"gpu":     gpu,
+	}).Error
+}
+
+func (jobServ JobService) UpdateJobPauseByJobUuid(jobUuid string, status int, pausedAt, pausedDuration int64) (err error) {
+	return jobServ.Model(&models.JobEntity{}).Where("job_uuid=? and delete_at=?", jobUuid, models.UN_DELETEED_FLAG).Updates(map[string]interface{}{
+		"status":          status,
+		"paused_at":       pausedAt,
+		"paused_duration": pausedDuration,
+	}).Error
+}
+
+func (jobServ JobService) CountPausedJobByWallet(walletAddress string) int64 {
+	var count int64
+	jobServ.Model(&models.JobEntity{}).Where("lower(wallet_address)=? and delete_at=? and status=?", strings.ToLower(walletAddress), models.UN_DELETEED_FLAG,
+		models.JOB_PAUSED_STATUS).Count(&count)
+	return count
+}
+
+func (jobServ JobService) UpdateActiveJobByJobUuid(jobUuid string, updates map[string]interface{}) (err error) {
+	return jobServ.Model(&models.JobEntity{}).Where("lower(job_uuid)=? and delete_at=? and status in ?", strings.ToLower(jobUuid), models.UN_DELETEED_FLAG,
+		[]int{models.JOB_RECEIVED_STATUS, models.JOB_DEPLOY_STATUS, models.JOB_RUNNING_STATUS}).Updates(updates).Error
+}
+
 func (jobServ JobService) UpdateJobReward(taskUuid string, amount string) (err error) {
-	return jobServ.Model(&models.JobEntity{}).Where("task_uuid=?", taskUuid).Update("reward", amount).Error
+	return jobServ.Model(&models.JobEntity{}).Where("task_uuid=?", taskUuid).Update("reward_wei", amount).Error
 }
 
 func (jobServ JobService) UpdateJobScannedBlock(taskUuid string, end uint64) (err error) {
@@ -193,7 +232,7 @@ func (jobServ JobService) UpdateJobScannedBlock(taskUuid string, end uint64) (er
 }
 
 func (jobServ JobService) GetJobListByNoReward() (list []*models.JobEntity, err error) {
-	err = jobServ.Model(&models.JobEntity{}).Where("status in ? and (reward is null or reward ='')", []int{models.JOB_COMPLETED_STATUS, models.TERMINATED}).Find(&list).Error
+	err = jobServ.Model(&models.JobEntity{}).Where("status in ? and (reward_wei is null or reward_wei ='')", []int{models.JOB_COMPLETED_STATUS, models.TERMINATED}).Find(&list).Error
 	return
 }
 
@@ -277,6 +316,33 @@ func (cpServ EcpJobService) UpdateEcpJobEntityPortsAndServiceUrl(jobUuid, portMa
 	}).Error
 }
 
+func (cpServ EcpJobService) UpdateEcpJobEntityStage(jobUuid, stage, stages string) (err error) {
+	return cpServ.Model(&models.EcpJobEntity{}).Where("uuid =?", jobUuid).Updates(map[string]interface{}{
+		"stage":  stage,
+		"stages": stages,
+	}).Error
+}
+
+// GetEcpJobsForHealthCheck returns the deployed inference jobs that are not deleted
+func (cpServ EcpJobService) GetEcpJobsForHealthCheck() ([]models.EcpJobEntity, error) {
+	var jobs []models.EcpJobEntity
+	err := cpServ.Model(&models.EcpJobEntity{}).Where("delete_at = 0 and job_type =? and container_name != '' and status not in ? and (stage = '' or stage is null or stage =?)",
+		models.InferenceJobType, []string{models.FailedStatus, models.RejectStatus, models.TerminatedStatus}, models.DeployStageReady).Find(&jobs).Error
+	return jobs, err
+}
+
+func (cpServ EcpJobService) UpdateEcpJobEntityHealth(job *models.EcpJobEntity) (err error) {
+	return cpServ.Model(&models.EcpJobEntity{}).Where("uuid =?", job.Uuid).Updates(map[string]interface{}{
+		"health":          job.Health,
+		"health_failures": job.HealthFailures,
+		"health_message":  job.HealthMessage,
+		"last_check_time": job.LastCheckTime,
+		"started_time":    job.StartedTime,
+		"restart_count":   job.RestartCount,
+		"docker_restarts": job.DockerRestarts,
+	}).Error
+}
+
 func (cpServ EcpJobService) UpdateEcpJobEntityMessage(jobUuid string, message string) (err error) {
 	return cpServ.Model(&models.EcpJobEntity{}).Where("uuid =?", jobUuid).Updates(map[string]interface{}{
 		"message":   message,
@@ -285,10 +351,17 @@ func (cpServ EcpJobService) UpdateEcpJobEntityMessage(jobUuid string, message st
 	}).Error
 }
 
-func (cpServ EcpJobService) UpdateEcpJobEntityRewardAndBlock(jobUuid string, blockNumber int64, reward float64) (err error) {
+// UpdateEcpJobReward updates the total reward of the job in wei
+func (cpServ EcpJobService) UpdateEcpJobReward(jobUuid string, reward string) (err error) {
+	return cpServ.Model(&models.EcpJobEntity{}).Where("uuid =?", jobUuid).Update("reward_wei", reward).Error
+}
+
+// UpdateEcpJobEntityRewardAndBlock updates the total reward of the job in wei, and the block it was last paid in.
+// The block never goes back, so a backfill of old blocks does not make a running job look unpaid.
+func (cpServ EcpJobService) UpdateEcpJobEntityRewardAndBlock(jobUuid string, blockNumber int64, reward string) (err error) {
 	return cpServ.Model(&models.EcpJobEntity{}).Where("uuid =?", jobUuid).Updates(map[string]interface{}{
-		"reward":            reward,
-		"last_block_number": blockNumber,
+		"reward_wei":        reward,
+		"last_block_number": gorm.Expr("CASE WHEN last_block_number < ? THEN ? ELSE last_block_number END", blockNumber, blockNumber),
 	}).Error
 }
 
@@ -319,14 +392,472 @@ func (cpServ CpBalanceService) GetCpBalance(cpAccount string) (*models.CpBalance
 
 func (cpServ CpBalanceService) UpdateCpBalance(cpBalance models.CpBalanceEntity) error {
 	err := cpServ.Model(&models.CpBalanceEntity{}).Where("cp_account=? and id=?", cpBalance.CpAccount, cpBalance.Id).Updates(map[string]interface{}{
-		"worker_balance":    cpBalance.WorkerBalance,
-		"sequencer_balance": cpBalance.SequencerBalance,
+		"worker_balance_wei":    cpBalance.WorkerBalance,
+		"sequencer_balance_wei": cpBalance.SequencerBalance,
 	}).Error
 	return err
 }
 
+type PortLeaseService struct {
+	*gorm.DB
+}
+
+func (portServ PortLeaseService) GetPortLeases() ([]models.PortLeaseEntity, error) {
+	var leases []models.PortLeaseEntity
+	err := portServ.Model(&models.PortLeaseEntity{}).Order("port").Find(&leases).Error
+	return leases, err
+}
+
+func (portServ PortLeaseService) GetPortLeasesByJobUuid(jobUuid string) ([]models.PortLeaseEntity, error) {
+	var leases []models.PortLeaseEntity
+	err := portServ.Model(&models.PortLeaseEntity{}).Where("job_uuid =?", jobUuid).Order("port").Find(&leases).Error
+	return leases, err
+}
+
+// SavePortLeases saves the leases of a job in one transaction, it fails when any of the ports is already leased
+func (portServ PortLeaseService) SavePortLeases(leases []models.PortLeaseEntity) error {
+	return portServ.Transaction(func(tx *gorm.DB) error {
+		for i := range leases {
+			if err := tx.Create(&leases[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (portServ PortLeaseService) ConfirmPortLeases(jobUuid string) error {
+	return portServ.Model(&models.PortLeaseEntity{}).Where("job_uuid =?", jobUuid).Update("expire_time", 0).Error
+}
+
+func (portServ PortLeaseService) DeletePortLeasesByJobUuid(jobUuid string) error {
+	return portServ.Where("job_uuid =?", jobUuid).Delete(&models.PortLeaseEntity{}).Error
+}
+
+func (portServ PortLeaseService) DeletePortLease(port int) error {
+	return portServ.Where("port =?", port).Delete(&models.PortLeaseEntity{}).Error
+}
+
+type ImageCacheService struct {
+	*gorm.DB
+}
+
+func (imageServ ImageCacheService) GetImageCaches() ([]models.ImageCacheEntity, error) {
+	var images []models.ImageCacheEntity
+	err := imageServ.Model(&models.ImageCacheEntity{}).Find(&images).Error
+	return images, err
+}
+
+// TouchImageCache records a job using the image
+func (imageServ ImageCacheService) TouchImageCache(imageName string, size int64) error {
+	now := time.Now().Unix()
+	var image models.ImageCacheEntity
+	if err := imageServ.Model(&models.ImageCacheEntity{}).Where("image =?", imageName).Limit(1).Find(&image).Error; err != nil {
+		return err
+	}
+	if image.Id == 0 {
+		return imageServ.Create(&models.ImageCacheEntity{
+			Image:        imageName,
+			Size:         size,
+			UseCount:     1,
+			LastUsedTime: now,
+			CreateTime:   now,
+		}).Error
+	}
+	return imageServ.Model(&models.ImageCacheEntity{}).Where("id =?", image.Id).Updates(map[string]interface{}{
+		"size":           size,
+		"use_count":      gorm.Expr("use_count + 1"),
+		"last_used_time": now,
+	}).Error
+}
+
+func (imageServ ImageCacheService) DeleteImageCache(imageName string) error {
+	return imageServ.Where("image =?", imageName).Delete(&models.ImageCacheEntity{}).Error
+}
+
+type ModelCacheService struct {
+	*gorm.DB
+}
+
+func (modelServ ModelCacheService) GetModelCaches() ([]models.ModelCacheEntity, error) {
+	var caches []models.ModelCacheEntity
+	err := modelServ.Model(&models.ModelCacheEntity{}).Order("last_used_time desc").Find(&caches).Error
+	return caches, err
+}
+
+func (modelServ ModelCacheService) GetModelCache(key, node string) (*models.ModelCacheEntity, error) {
+	var cache models.ModelCacheEntity
+	err := modelServ.Model(&models.ModelCacheEntity{}).Where("key =? and node =?", key, node).Limit(1).Find(&cache).Error
+	return &cache, err
+}
+
+func (modelServ ModelCacheService) SaveModelCache(cache *models.ModelCacheEntity) error {
+	return modelServ.Save(cache).Error
+}
+
+// TouchModelCache records a job using the model
+func (modelServ ModelCacheService) TouchModelCache(key, node string) error {
+	return modelServ.Model(&models.ModelCacheEntity{}).Where("key =? and node =?", key, node).Updates(map[string]interface{}{
+		"use_count":      gorm.Expr("use_count + 1"),
+		"last_used_time": time.Now().Unix(),
+	}).Error
+}
+
+func (modelServ ModelCacheService) DeleteModelCache(key, node string) error {
+	return modelServ.Where("key =? and node =?", key, node).Delete(&models.ModelCacheEntity{}).Error
+}
+
+type LedgerService struct {
+	*gorm.DB
+}
+
+// SaveLedgerEntry saves an entry of the ledger, an entry already recorded is ignored
+func (ledgerServ LedgerService) SaveLedgerEntry(entry *models.LedgerEntity) error {
+	return ledgerServ.Clauses(clause.OnConflict{DoNothing: true}).Create(entry).Error
+}
+
+// UpdateLedgerEntry saves the entry recorded before
+func (ledgerServ LedgerService) UpdateLedgerEntry(entry *models.LedgerEntity) error {
+	return ledgerServ.Save(entry).Error
+}
+
+// HasLedgerEntry reports whether an entry of the category is recorded for the task
+func (ledgerServ LedgerService) HasLedgerEntry(category, taskUuid string) (bool, error) {
+	var count int64
+	err := ledgerServ.Model(&models.LedgerEntity{}).Where("category=? and task_uuid=?", category, taskUuid).Count(&count).Error
+	return count > 0, err
+}
+
+// GetPendingLedgerEntries returns the gas entries of the transactions not mined when they were recorded
+func (ledgerServ LedgerService) GetPendingLedgerEntries() ([]models.LedgerEntity, error) {
+	var entries []models.LedgerEntity
+	err := ledgerServ.Where("pending=?", true).Order("id").Find(&entries).Error
+	return entries, err
+}
+
+// GetLedgerEntries returns the entries of the ledger in [from, to), a zero bound is open, the pending ones excluded
+func (ledgerServ LedgerService) GetLedgerEntries(from, to int64) ([]models.LedgerEntity, error) {
+	var entries []models.LedgerEntity
+	query := ledgerServ.Model(&models.LedgerEntity{}).Where("pending=?", false)
+	if from > 0 {
+		query = query.Where("event_time >=?", from)
+	}
+	if to > 0 {
+		query = query.Where("event_time <?", to)
+	}
+	err := query.Order("event_time, id").Find(&entries).Error
+	return entries, err
+}
+
+// SumLedgerAmounts returns the total amount in wei of the entries of the category for the task
+func (ledgerServ LedgerService) SumLedgerAmounts(category, taskUuid string) (*big.Int, error) {
+	var amounts []string
+	err := ledgerServ.Model(&models.LedgerEntity{}).Where("category=? and task_uuid=?", category, taskUuid).Pluck("amount", &amounts).Error
+	if err != nil {
+		return nil, err
+	}
+	total := new(big.Int)
+	for _, amount := range amounts {
+		value, err := contract.ParseWei(amount)
+		if err != nil {
+			return nil, err
+		}
+		total.Add(total, value)
+	}
+	return total, nil
+}
+
+// ReplaceLedgerEntries replaces the entries of the category recorded from the block range by the entries, in one
+// transaction
+func (ledgerServ LedgerService) ReplaceLedgerEntries(category string, fromBlock, toBlock uint64, entries []models.LedgerEntity) error {
+	return ledgerServ.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("category=? and block_number>=? and block_number<=? and tx_hash!=''", category, fromBlock, toBlock).
+			Delete(&models.LedgerEntity{}).Error
+		if err != nil {
+			return err
+		}
+		for i := range entries {
+			if err = tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&entries[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetLedgerSeed returns the reward of the task saved before the ledger existed, or nil when there is none
+func (ledgerServ LedgerService) GetLedgerSeed(category, taskUuid string) (*models.LedgerEntity, error) {
+	var entries []models.LedgerEntity
+	err := ledgerServ.Where("category=? and task_uuid=? and tx_hash=''", category, taskUuid).Limit(1).Find(&entries).Error
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	return &entries[0], nil
+}
+
+// RemoveLedgerEntriesAfter removes the entries of the category recorded from blocks after the block, and returns them.
+// The rewards saved before the ledger existed are kept.
+func (ledgerServ LedgerService) RemoveLedgerEntriesAfter(category string, blockNumber uint64) ([]models.LedgerEntity, error) {
+	var entries []models.LedgerEntity
+	err := ledgerServ.Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&models.LedgerEntity{}).Where("category=? and block_number>? and tx_hash!=''", category, blockNumber)
+		if err := query.Find(&entries).Error; err != nil {
+			return err
+		}
+		return tx.Where("category=? and block_number>? and tx_hash!=''", category, blockNumber).Delete(&models.LedgerEntity{}).Error
+	})
+	return entries, err
+}
+
+type BackfillService struct {
+	*gorm.DB
+}
+
+// GetBackfill returns the checkpoint of the backfill of the kind in the block range, nil when it never ran
+func (backfillServ BackfillService) GetBackfill(kind string, fromBlock, toBlock uint64) (*models.BackfillEntity, error) {
+	var backfills []models.BackfillEntity
+	err := backfillServ.Model(&models.BackfillEntity{}).Where("kind=? and from_block=? and to_block=?", kind, fromBlock, toBlock).
+		Limit(1).Find(&backfills).Error
+	if err != nil || len(backfills) == 0 {
+		return nil, err
+	}
+	return &backfills[0], nil
+}
+
+// GetUnfinishedBackfill returns the last backfill of the kind from the block which is not done, nil when there is none
+func (backfillServ BackfillService) GetUnfinishedBackfill(kind string, fromBlock uint64) (*models.BackfillEntity, error) {
+	var backfills []models.BackfillEntity
+	err := backfillServ.Model(&models.BackfillEntity{}).Where("kind=? and from_block=? and status<>?", kind, fromBlock, models.BackfillDone).
+		Order("update_time desc").Limit(1).Find(&backfills).Error
+	if err != nil || len(backfills) == 0 {
+		return nil, err
+	}
+	return &backfills[0], nil
+}
+
+func (backfillServ BackfillService) SaveBackfill(backfill *models.BackfillEntity) error {
+	backfill.UpdateTime = time.Now().Unix()
+	return backfillServ.Save(backfill).Error
+}
+
+type ScanBlockService struct {
+	*gorm.DB
+}
+
+// SaveScanBlock saves the hash of a block a scanner saved its checkpoint at, replacing the hash saved before
+func (scanBlockServ ScanBlockService) SaveScanBlock(block *models.ScanBlockEntity) error {
+	return scanBlockServ.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "scanner_id"}, {Name: "block_number"}},
+		DoUpdates: clause.AssignmentColumns([]string{"block_hash", "create_time"}),
+	}).Create(block).Error
+}
+
+// GetScanBlocks returns the blocks the scanner saved its checkpoints at, the newest first
+func (scanBlockServ ScanBlockService) GetScanBlocks(scannerId int) ([]models.ScanBlockEntity, error) {
+	var blocks []models.ScanBlockEntity
+	err := scanBlockServ.Model(&models.ScanBlockEntity{}).Where("scanner_id=?", scannerId).Order("block_number desc").Find(&blocks).Error
+	return blocks, err
+}
+
+// DeleteScanBlocksAfter removes the blocks of the scanner after the block
+func (scanBlockServ ScanBlockService) DeleteScanBlocksAfter(scannerId int, blockNumber int64) error {
+	return scanBlockServ.Where("scanner_id=? and block_number>?", scannerId, blockNumber).Delete(&models.ScanBlockEntity{}).Error
+}
+
+// DeleteScanBlocksBefore removes the blocks of the scanner before the block
+func (scanBlockServ ScanBlockService) DeleteScanBlocksBefore(scannerId int, blockNumber int64) error {
+	return scanBlockServ.Where("scanner_id=? and block_number<?", scannerId, blockNumber).Delete(&models.ScanBlockEntity{}).Error
+}
+
+type ChainEventService struct {
+	*gorm.DB
+}
+
+// chainEventModels is the typed tables of the indexed events
+var chainEventModels = []interface{}{
+	&models.AccountEventEntity{},
+	&models.CollateralEventEntity{},
+	&models.SequencerEventEntity{},
+	&models.TaskEventEntity{},
+}
+
+// ChainEventFilter selects the indexed events, the zero fields match all the events
+type ChainEventFilter struct {
+	Source    string
+	Event     string
+	FromBlock uint64
+	ToBlock   uint64
+	Limit     int
+}
+
+// ReplaceChainEvents replaces the events of the block range by the events scanned from it, in one transaction,
+// so the events the subscription saved from orphaned blocks are removed
+func (eventServ ChainEventService) ReplaceChainEvents(fromBlock, toBlock uint64, events []interface{}) error {
+	return eventServ.Transaction(func(tx *gorm.DB) error {
+		for _, model := range chainEventModels {
+			if err := tx.Where("block_number>=? and block_number<=?", fromBlock, toBlock).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		for _, event := range events {
+			if err := tx.Create(event).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SaveChainEvent saves an event, an event already saved is kept
+func (eventServ ChainEventService) SaveChainEvent(event interface{}) error {
+	return eventServ.Clauses(clause.OnConflict{DoNothing: true}).Create(event).Error
+}
+
+// RemoveChainEvent removes the event of the log from the typed tables
+func (eventServ ChainEventService) RemoveChainEvent(txHash string, logIndex uint) error {
+	for _, model := range chainEventModels {
+		if err := eventServ.Where("tx_hash=? and log_index=?", txHash, logIndex).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteChainEventsAfter removes the events of the blocks after the block from the typed tables
+func (eventServ ChainEventService) DeleteChainEventsAfter(blockNumber uint64) error {
+	for _, model := range chainEventModels {
+		if err := eventServ.Where("block_number>?", blockNumber).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetChainEvents finds the events of the filter into the slice of a typed table, the newest first
+func (eventServ ChainEventService) GetChainEvents(filter ChainEventFilter, events interface{}) error {
+	query := eventServ.DB
+	if filter.Source != "" {
+		query = query.Where("source=?", filter.Source)
+	}
+	if filter.Event != "" {
+		query = query.Where("event=?", filter.Event)
+	}
+	if filter.FromBlock > 0 {
+		query = query.Where("block_number>=?", filter.FromBlock)
+	}
+	if filter.ToBlock > 0 {
+		query = query.Where("block_number<=?", filter.ToBlock)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	return query.Order("block_number desc, log_index desc").Find(events).Error
+}
+
+type AlertService struct {
+	*gorm.DB
+}
+
+// GetAlert returns the state of the alert, or nil when there is none
+func (alertServ AlertService) GetAlert(key string) (*models.AlertEntity, error) {
+	var alerts []models.AlertEntity
+	if err := alertServ.Where("key=?", key).Limit(1).Find(&alerts).Error; err != nil {
+		return nil, err
+	}
+	if len(alerts) == 0 {
+		return nil, nil
+	}
+	return &alerts[0], nil
+}
+
+func (alertServ AlertService) SaveAlert(alert *models.AlertEntity) error {
+	alert.UpdateTime = time.Now().Unix()
+	return alertServ.Save(alert).Error
+}
+
+// GetAlerts returns the state of all the alerts
+func (alertServ AlertService) GetAlerts() ([]models.AlertEntity, error) {
+	var alerts []models.AlertEntity
+	err := alertServ.Order("key").Find(&alerts).Error
+	return alerts, err
+}
+
+// GetFiringAlerts returns the alerts firing, the last updated first
+func (alertServ AlertService) GetFiringAlerts() ([]models.AlertEntity, error) {
+	var alerts []models.AlertEntity
+	err := alertServ.Where("firing=?", true).Order("update_time desc").Find(&alerts).Error
+	return alerts, err
+}
+
+type TopUpService struct {
+	*gorm.DB
+}
+
+func (topUpServ TopUpService) SaveTopUp(topUp *models.TopUpEntity) error {
+	topUp.UpdateTime = time.Now().Unix()
+	return topUpServ.Save(topUp).Error
+}
+
+// SumTopUpAmounts returns the total in wei of the top ups of the kinds sent since the time, the pending ones included
+func (topUpServ TopUpService) SumTopUpAmounts(kinds []string, since int64) (*big.Int, error) {
+	var amounts []string
+	err := topUpServ.Model(&models.TopUpEntity{}).Where("kind in ? and status in ? and create_time>=?", kinds,
+		[]string{models.TopUpPending, models.TopUpSuccess}, since).Pluck("amount", &amounts).Error
+	if err != nil {
+		return nil, err
+	}
+	total := new(big.Int)
+	for _, amount := range amounts {
+		value, err := contract.ParseWei(amount)
+		if err != nil {
+			return nil, err
+		}
+		total.Add(total, value)
+	}
+	return total, nil
+}
+
+// GetLastTopUp returns the last top up of the kind, or nil when there is none
+func (topUpServ TopUpService) GetLastTopUp(kind string) (*models.TopUpEntity, error) {
+	var topUps []models.TopUpEntity
+	if err := topUpServ.Where("kind=?", kind).Order("id desc").Limit(1).Find(&topUps).Error; err != nil {
+		return nil, err
+	}
+	if len(topUps) == 0 {
+		return nil, nil
+	}
+	return &topUps[0], nil
+}
+
+// GetPendingTopUps returns the top ups sent without a receipt yet
+func (topUpServ TopUpService) GetPendingTopUps() ([]models.TopUpEntity, error) {
+	var topUps []models.TopUpEntity
+	err := topUpServ.Where("status=? and tx_hash!=''", models.TopUpPending).Find(&topUps).Error
+	return topUps, err
+}
+
+// GetTopUps returns the last top ups, the newest first, all of them when the limit is 0
+func (topUpServ TopUpService) GetTopUps(limit int) ([]models.TopUpEntity, error) {
+	var topUps []models.TopUpEntity
+	query := topUpServ.Order("id desc")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	err := query.Find(&topUps).Error
+	return topUps, err
+}
+
 var taskSet = wire.NewSet(db.NewDbService, wire.Struct(new(TaskService), "*"))
 var jobSet = wire.NewSet(db.NewDbService, wire.Struct(new(JobService), "*"))
 var cpInfoSet = wire.NewSet(db.NewDbService, wire.Struct(new(CpInfoService), "*"))
 var ecpJobSet = wire.NewSet(db.NewDbService, wire.Struct(new(EcpJobService), "*"))
 var cpBalanceSet = wire.NewSet(db.NewDbService, wire.Struct(new(CpBalanceService), "*"))
+var portLeaseSet = wire.NewSet(db.NewDbService, wire.Struct(new(PortLeaseService), "*"))
+var imageCacheSet = wire.NewSet(db.NewDbService, wire.Struct(new(ImageCacheService), "*"))
+var modelCacheSet = wire.NewSet(db.NewDbService, wire.Struct(new(ModelCacheService), "*"))
+var ledgerSet = wire.NewSet(db.NewDbService, wire.Struct(new(LedgerService), "*"))
+var backfillSet = wire.NewSet(db.NewDbService, wire.Struct(new(BackfillService), "*"))
+var scanBlockSet = wire.NewSet(db.NewDbService, wire.Struct(new(ScanBlockService), "*"))
+var chainEventSet = wire.NewSet(db.NewDbService, wire.Struct(new(ChainEventService), "*"))
+var alertSet = wire.NewSet(db.NewDbService, wire.Struct(new(AlertService), "*"))
+var topUpSet = wire.NewSet(db.NewDbService, wire.Struct(new(TopUpService), "*"))
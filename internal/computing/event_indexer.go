@@ -0,0 +1,493 @@
+package computing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/internal/contract"
+	"github.com/swanchain/go-computing-provider/internal/contract/account"
+	"github.com/swanchain/go-computing-provider/internal/contract/ecp"
+	"github.com/swanchain/go-computing-provider/internal/contract/fcp"
+	"github.com/swanchain/go-computing-provider/internal/models"
+)
+
+// indexedContract is a contract of the CONTRACT config the indexer decodes the logs of
+type indexedContract struct {
+	source string
+	abi    abi.ABI
+}
+
+// EventIndexer indexes the events of the cp account contract, and the events about the cp account of the collateral,
+// sequencer, task manager and task payment contracts, into the typed event tables
+type EventIndexer struct {
+	client    *ethclient.Client
+	cpAccount common.Address
+	contracts map[common.Address]indexedContract
+}
+
+func NewEventIndexer(client *ethclient.Client) (*EventIndexer, error) {
+	cpAccountAddress, err := contract.GetCpAccountAddress()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cp account contract address, error: %v", err)
+	}
+	indexer := &EventIndexer{
+		client:    client,
+		cpAccount: common.HexToAddress(cpAccountAddress),
+		contracts: make(map[common.Address]indexedContract),
+	}
+
+	contractConf := conf.GetConfig().CONTRACT
+	for _, c := range []struct {
+		address string
+		source  string
+		abi     string
+	}{
+		{cpAccountAddress, models.EventSourceAccount, account.AccountMetaData.ABI},
+		{contractConf.JobCollateral, models.EventSourceFcpCollateral, fcp.SwanCreditCollateralMetaData.ABI},
+		{contractConf.ZkCollateral, models.EventSourceEcpCollateral, ecp.EcpCollateralMetaData.ABI},
+		{contractConf.Sequencer, models.EventSourceSequencer, ecp.EcpSequencerMetaData.ABI},
+		{contractConf.JobManager, models.EventSourceFcpTask, fcp.FcpTaskManagerMetaData.ABI},
+		{contractConf.EdgeTaskPayment, models.EventSourceEcpPayment, ecp.TaskPaymentMetaData.ABI},
+	} {
+		if c.address == "" {
+			continue
+		}
+		contractAbi, err := abi.JSON(strings.NewReader(c.abi))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the abi of the %s contract, error: %v", c.source, err)
+		}
+		indexer.contracts[common.HexToAddress(c.address)] = indexedContract{source: c.source, abi: contractAbi}
+	}
+	return indexer, nil
+}
+
+func (indexer *EventIndexer) addresses() []common.Address {
+	var addresses []common.Address
+	for address := range indexer.contracts {
+		addresses = append(addresses, address)
+	}
+	return addresses
+}
+
+// Scan indexes the events of the confirmed blocks after the checkpoint, the first scan starts at the confirmed block
+// as the blocks the contracts were created in are not all configured, `chain backfill --kind events` indexes the past
+func (indexer *EventIndexer) Scan() error {
+	if err := checkReorg(indexer.client, models.ScannerEventIndexerId); err != nil {
+		return err
+	}
+	endBlockNumber, err := confirmedBlock(indexer.client)
+	if err != nil {
+		return err
+	}
+
+	scannedBlock := loadLastProcessedBlock(models.ScannerEventIndexerId)
+	if scannedBlock == 0 {
+		return saveScannedBlock(indexer.client, endBlockNumber, models.ScannerEventIndexerId)
+	}
+
+	var step uint64 = 1000
+	for i := uint64(scannedBlock) + 1; i <= endBlockNumber; i = i + step {
+		end := i + step - 1
+		if end > endBlockNumber {
+			end = endBlockNumber
+		}
+		if err = indexer.scanEvents(&bind.FilterOpts{Start: i, End: &end}); err != nil {
+			return fmt.Errorf("failed to index events, start: %d, end: %d, error: %s", i, end, ecp.ParseTooManyError(err))
+		}
+		if err = saveScannedBlock(indexer.client, end, models.ScannerEventIndexerId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanEvents replaces the indexed events of the block range by the events of its logs
+func (indexer *EventIndexer) scanEvents(opts *bind.FilterOpts) error {
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	eventLogs, err := indexer.client.FilterLogs(ctx, ethereum.FilterQuery{
+		FromBlock: new(big.Int).SetUint64(opts.Start),
+		ToBlock:   new(big.Int).SetUint64(*opts.End),
+		Addresses: indexer.addresses(),
+	})
+	if err != nil {
+		return err
+	}
+
+	blockTimes := make(map[uint64]int64)
+	var events []interface{}
+	var movements []models.LedgerEntity
+	for _, eventLog := range eventLogs {
+		event, err := indexer.decodeEvent(eventLog, true, blockTimes)
+		if err != nil {
+			logs.GetLogger().Warnf("failed to decode the log %d of tx %s, error: %v", eventLog.Index, eventLog.TxHash.Hex(), err)
+			continue
+		}
+		if event != nil {
+			events = append(events, event)
+		}
+		if collateralEvent, ok := event.(*models.CollateralEventEntity); ok {
+			if movement := collateralMovement(collateralEvent); movement != nil {
+				movements = append(movements, *movement)
+			}
+		}
+	}
+	if err = NewChainEventService().ReplaceChainEvents(opts.Start, *opts.End, events); err != nil {
+		return err
+	}
+	return NewLedgerService().ReplaceLedgerEntries(models.LedgerCollateral, opts.Start, *opts.End, movements)
+}
+
+// collateralMovementKinds are the kinds of the ledger entries of the collateral events moving funds in or out
+var collateralMovementKinds = map[string]string{
+	"Deposit":           models.LedgerDeposit,
+	"DepositLocked":     models.LedgerDeposit,
+	"Withdraw":          models.LedgerWithdrawal,
+	"WithdrawConfirmed": models.LedgerWithdrawal,
+}
+
+// collateralMovement returns the ledger entry of a confirmed deposit to or withdrawal from the collateral, nil for the
+// other collateral events
+func collateralMovement(event *models.CollateralEventEntity) *models.LedgerEntity {
+	kind, ok := collateralMovementKinds[event.ChainEvent.Event]
+	if !ok || event.Amount == "" {
+		return nil
+	}
+	taskType := LedgerTaskTypeFcp
+	if event.ChainEvent.Source == models.EventSourceEcpCollateral {
+		taskType = LedgerTaskTypeEcp
+	}
+	return &models.LedgerEntity{
+		Kind:        kind,
+		Category:    models.LedgerCollateral,
+		TaskType:    taskType,
+		Contract:    event.ChainEvent.Contract,
+		TxHash:      event.ChainEvent.TxHash,
+		LogIndex:    event.ChainEvent.LogIndex,
+		BlockNumber: event.ChainEvent.BlockNumber,
+		Wallet:      event.Wallet,
+		Token:       models.LedgerTokenSwan,
+		Amount:      event.Amount,
+		EventTime:   event.ChainEvent.EventTime,
+		CreateTime:  time.Now().Unix(),
+	}
+}
+
+// decodeEvent decodes the log into the entity of its typed table, it returns nil for the events not about the cp account
+func (indexer *EventIndexer) decodeEvent(eventLog types.Log, confirmed bool, blockTimes map[uint64]int64) (interface{}, error) {
+	indexed, ok := indexer.contracts[eventLog.Address]
+	if !ok || len(eventLog.Topics) == 0 {
+		return nil, nil
+	}
+	event, err := indexed.abi.EventByID(eventLog.Topics[0])
+	if err != nil {
+		return nil, nil
+	}
+
+	args := make(map[string]interface{})
+	if len(eventLog.Data) > 0 {
+		if err = indexed.abi.UnpackIntoMap(args, event.Name, eventLog.Data); err != nil {
+			return nil, err
+		}
+	}
+	var indexedArgs abi.Arguments
+	for _, input := range event.Inputs {
+		if input.Indexed {
+			indexedArgs = append(indexedArgs, input)
+		}
+	}
+	if err = abi.ParseTopicsIntoMap(args, indexedArgs, eventLog.Topics[1:]); err != nil {
+		return nil, err
+	}
+
+	cpIndex, ok := findAccount(event.Inputs, args, indexer.cpAccount)
+	if !ok && indexed.source != models.EventSourceAccount {
+		return nil, nil
+	}
+
+	data := make(map[string]interface{})
+	for name, value := range args {
+		data[name] = eventJsonValue(value)
+	}
+	dataJson, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	eventTime, ok := blockTimes[eventLog.BlockNumber]
+	if !ok {
+		eventTime = blockTime(indexer.client, eventLog.BlockNumber)
+		blockTimes[eventLog.BlockNumber] = eventTime
+	}
+	chainEvent := models.ChainEvent{
+		Source:      indexed.source,
+		Contract:    eventLog.Address.Hex(),
+		Event:       event.RawName,
+		TxHash:      eventLog.TxHash.Hex(),
+		LogIndex:    eventLog.Index,
+		BlockNumber: eventLog.BlockNumber,
+		BlockHash:   eventLog.BlockHash.Hex(),
+		EventTime:   eventTime,
+		Confirmed:   confirmed,
+		Data:        string(dataJson),
+	}
+
+	cpAccount := indexer.cpAccount.Hex()
+	amount := eventAmount(event.Inputs, args, cpIndex)
+	switch indexed.source {
+	case models.EventSourceAccount:
+		accountEvent := &models.AccountEventEntity{ChainEvent: chainEvent, CpAccount: eventLog.Address.Hex()}
+		for _, input := range event.Inputs {
+			if strings.HasPrefix(input.Name, "previous") {
+				accountEvent.OldValue = eventStrValue(args[input.Name])
+			} else if strings.HasPrefix(input.Name, "new") {
+				accountEvent.NewValue = eventStrValue(args[input.Name])
+			}
+		}
+		return accountEvent, nil
+	case models.EventSourceFcpCollateral, models.EventSourceEcpCollateral:
+		return &models.CollateralEventEntity{
+			ChainEvent: chainEvent,
+			CpAccount:  cpAccount,
+			Wallet:     eventWallet(event.Inputs, args, indexer.cpAccount),
+			TaskUuid:   eventTaskUuid(event.Inputs, args),
+			Amount:     amount,
+		}, nil
+	case models.EventSourceSequencer:
+		return &models.SequencerEventEntity{ChainEvent: chainEvent, CpAccount: cpAccount, Amount: amount}, nil
+	default:
+		return &models.TaskEventEntity{
+			ChainEvent: chainEvent,
+			CpAccount:  cpAccount,
+			Wallet:     eventWallet(event.Inputs, args, indexer.cpAccount),
+			TaskUuid:   eventTaskUuid(event.Inputs, args),
+			Amount:     amount,
+		}, nil
+	}
+}
+
+// findAccount reports whether an address argument is the account, with its index when it is in an address list
+func findAccount(inputs abi.Arguments, args map[string]interface{}, account common.Address) (int, bool) {
+	for _, input := range inputs {
+		switch value := args[input.Name].(type) {
+		case common.Address:
+			if value == account {
+				return -1, true
+			}
+		case []common.Address:
+			for i, address := range value {
+				if address == account {
+					return i, true
+				}
+			}
+		}
+	}
+	return -1, false
+}
+
+// eventAmount returns the amount of the account in an amount list of a batch event, or the first amount argument, in wei
+func eventAmount(inputs abi.Arguments, args map[string]interface{}, accountIndex int) string {
+	if accountIndex >= 0 {
+		for _, input := range inputs {
+			if amounts, ok := args[input.Name].([]*big.Int); ok && accountIndex < len(amounts) {
+				return amounts[accountIndex].String()
+			}
+		}
+	}
+	for _, input := range inputs {
+		amount, ok := args[input.Name].(*big.Int)
+		if !ok {
+			continue
+		}
+		name := strings.ToLower(input.Name)
+		if strings.Contains(name, "amount") || name == "reward" || name == "collateral" || name == "slashfund" {
+			return amount.String()
+		}
+	}
+	return ""
+}
+
+// eventWallet returns the first funding, owner, beneficiary or user address argument which is not the account
+func eventWallet(inputs abi.Arguments, args map[string]interface{}, account common.Address) string {
+	for _, input := range inputs {
+		value, ok := args[input.Name].(common.Address)
+		if !ok || value == account {
+			continue
+		}
+		name := strings.ToLower(input.Name)
+		for _, role := range []string{"wallet", "owner", "beneficiary", "user"} {
+			if strings.Contains(name, role) {
+				return value.Hex()
+			}
+		}
+	}
+	return ""
+}
+
+// eventTaskUuid returns the task uuid argument, the keccak256 hash of the uuid when the argument is indexed
+func eventTaskUuid(inputs abi.Arguments, args map[string]interface{}) string {
+	for _, input := range inputs {
+		switch strings.ToLower(input.Name) {
+		case "taskuid", "taskuuid", "taskid":
+			return eventStrValue(args[input.Name])
+		}
+	}
+	return ""
+}
+
+func eventStrValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case common.Address:
+		return v.Hex()
+	case common.Hash:
+		return v.Hex()
+	case *big.Int:
+		return v.String()
+	case []common.Address:
+		var values []string
+		for _, address := range v {
+			values = append(values, address.Hex())
+		}
+		return strings.Join(values, ",")
+	case []string:
+		return strings.Join(v, ",")
+	case []uint8:
+		var values []string
+		for _, b := range v {
+			values = append(values, strconv.Itoa(int(b)))
+		}
+		return strings.Join(values, ",")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// eventJsonValue keeps the amounts exact as strings, and the uint8 lists as numbers instead of base64
+func eventJsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case *big.Int:
+		return v.String()
+	case []*big.Int:
+		var values []string
+		for _, amount := range v {
+			values = append(values, amount.String())
+		}
+		return values
+	case []uint8:
+		var values []int
+		for _, b := range v {
+			values = append(values, int(b))
+		}
+		return values
+	default:
+		return v
+	}
+}
+
+// ScanChainEvents indexes the events of the confirmed blocks since the last scan
+func ScanChainEvents() error {
+	chainUrl, err := conf.GetRpcByNetWorkName()
+	if err != nil {
+		return err
+	}
+	client, err := contract.GetEthClient(chainUrl)
+	if err != nil {
+		return fmt.Errorf("failed to dial rpc, error: %v", err)
+	}
+	defer client.Close()
+
+	indexer, err := NewEventIndexer(client)
+	if err != nil {
+		return err
+	}
+	return indexer.Scan()
+}
+
+// SubscribeChainEvents saves the new events as soon as they are mined through the websocket endpoint of the config,
+// until the context is done. They are saved unconfirmed, the scan replaces them once they are confirmed.
+func SubscribeChainEvents(ctx context.Context) {
+	wsUrl := conf.GetConfig().RPC.SwanChainWs
+	if wsUrl == "" {
+		return
+	}
+	for {
+		err := subscribeChainEvents(ctx, wsUrl)
+		if ctx.Err() != nil {
+			return
+		}
+		logs.GetLogger().Warnf("the subscription to the chain events stopped, resubscribing in 30s, error: %v", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(30 * time.Second):
+		}
+	}
+}
+
+func subscribeChainEvents(ctx context.Context, wsUrl string) error {
+	client, err := ethclient.DialContext(ctx, wsUrl)
+	if err != nil {
+		return fmt.Errorf("failed to dial %s, error: %v", wsUrl, err)
+	}
+	defer client.Close()
+
+	indexer, err := NewEventIndexer(client)
+	if err != nil {
+		return err
+	}
+	eventLogs := make(chan types.Log, 128)
+	sub, err := client.SubscribeFilterLogs(ctx, ethereum.FilterQuery{Addresses: indexer.addresses()}, eventLogs)
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+
+	eventService := NewChainEventService()
+	blockTimes := make(map[uint64]int64)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err = <-sub.Err():
+			return err
+		case eventLog := <-eventLogs:
+			if eventLog.Removed {
+				if err = eventService.RemoveChainEvent(eventLog.TxHash.Hex(), eventLog.Index); err != nil {
+					logs.GetLogger().Errorf("failed to remove the event of the reorged log %d of tx %s, error: %v", eventLog.Index,
+						eventLog.TxHash.Hex(), err)
+				}
+				continue
+			}
+			if len(blockTimes) > 1024 {
+				blockTimes = make(map[uint64]int64)
+			}
+			event, err := indexer.decodeEvent(eventLog, false, blockTimes)
+			if err != nil {
+				logs.GetLogger().Warnf("failed to decode the log %d of tx %s, error: %v", eventLog.Index, eventLog.TxHash.Hex(), err)
+				continue
+			}
+			if event == nil {
+				continue
+			}
+			if err = eventService.SaveChainEvent(event); err != nil {
+				logs.GetLogger().Errorf("failed to save the event of the log %d of tx %s, error: %v", eventLog.Index, eventLog.TxHash.Hex(), err)
+			}
+		}
+	}
+}
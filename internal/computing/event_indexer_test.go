@@ -0,0 +1,187 @@
+package computing
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/swanchain/go-computing-provider/internal/db"
+	"github.com/swanchain/go-computing-provider/internal/models"
+)
+
+func testCollateralEvent(source, event, txHash string, blockNumber uint64) *models.CollateralEventEntity {
+	return &models.CollateralEventEntity{
+		ChainEvent: models.ChainEvent{
+			Source:      source,
+			Contract:    "0x0c",
+			Event:       event,
+			TxHash:      txHash,
+			BlockNumber: blockNumber,
+			EventTime:   1700000000,
+		},
+		Wallet: "0x0a",
+		Amount: "1000",
+	}
+}
+
+func TestCollateralMovement(t *testing.T) {
+	for _, c := range []struct {
+		source, event, kind, taskType string
+	}{
+		{models.EventSourceFcpCollateral, "Deposit", models.LedgerDeposit, LedgerTaskTypeFcp},
+		{models.EventSourceFcpCollateral, "DepositLocked", models.LedgerDeposit, LedgerTaskTypeFcp},
+		{models.EventSourceEcpCollateral, "Withdraw", models.LedgerWithdrawal, LedgerTaskTypeEcp},
+		{models.EventSourceEcpCollateral, "WithdrawConfirmed", models.LedgerWithdrawal, LedgerTaskTypeEcp},
+		{models.EventSourceEcpCollateral, "CollateralSlashed", "", ""},
+		{models.EventSourceEcpCollateral, "WithdrawRequested", "", ""},
+	} {
+		movement := collateralMovement(testCollateralEvent(c.source, c.event, "0x01", 10))
+		if c.kind == "" {
+			if movement != nil {
+				t.Errorf("%s: expected no movement, got %+v", c.event, movement)
+			}
+			continue
+		}
+		if movement == nil || movement.Kind != c.kind || movement.TaskType != c.taskType || movement.Category != models.LedgerCollateral ||
+			movement.Amount != "1000" || movement.Wallet != "0x0a" || movement.Token != models.LedgerTokenSwan ||
+			movement.BlockNumber != 10 || movement.EventTime != 1700000000 {
+			t.Errorf("%s: unexpected movement: %+v", c.event, movement)
+		}
+	}
+}
+
+func TestReplaceLedgerEntries(t *testing.T) {
+	db.InitDb(t.TempDir())
+	ledgerService := NewLedgerService()
+	movement := func(txHash string, blockNumber uint64) models.LedgerEntity {
+		return *collateralMovement(testCollateralEvent(models.EventSourceFcpCollateral, "Deposit", txHash, blockNumber))
+	}
+	if err := ledgerService.ReplaceLedgerEntries(models.LedgerCollateral, 1, 20, []models.LedgerEntity{
+		movement("0x01", 5), movement("0x02", 15), movement("0x03", 20),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// a scan of the blocks 11 to 20 again, the deposit of the block 15 was orphaned
+	if err := ledgerService.ReplaceLedgerEntries(models.LedgerCollateral, 11, 20, []models.LedgerEntity{
+		movement("0x03", 20), movement("0x04", 18),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ledgerService.GetLedgerEntries(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var txHashes []string
+	for _, entry := range entries {
+		txHashes = append(txHashes, entry.TxHash)
+	}
+	if len(txHashes) != 3 || txHashes[0] != "0x01" || txHashes[1] != "0x03" || txHashes[2] != "0x04" {
+		t.Errorf("expected the deposits 0x01, 0x03 and 0x04, got %v", txHashes)
+	}
+}
+
+const testEventAbi = `[
+	{"type":"event","name":"Deposit","inputs":[
+		{"name":"fundingWallet","type":"address","indexed":true},
+		{"name":"cpAccount","type":"address","indexed":true},
+		{"name":"depositAmount","type":"uint256","indexed":false}]},
+	{"type":"event","name":"RewardsDistributed","inputs":[
+		{"name":"taskUid","type":"string","indexed":false},
+		{"name":"cpList","type":"address[]","indexed":false},
+		{"name":"rewards","type":"uint256[]","indexed":false}]}
+]`
+
+func TestFindAccountAndEventAmount(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(testEventAbi))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp := common.HexToAddress("0xc0")
+	other := common.HexToAddress("0xc1")
+
+	deposit := parsed.Events["Deposit"].Inputs
+	args := map[string]interface{}{"fundingWallet": other, "cpAccount": cp, "depositAmount": big.NewInt(100)}
+	index, ok := findAccount(deposit, args, cp)
+	if !ok || index != -1 {
+		t.Errorf("expected the account argument, got %d %v", index, ok)
+	}
+	if got := eventAmount(deposit, args, index); got != "100" {
+		t.Errorf("expected the deposit amount 100, got %s", got)
+	}
+	if got := eventWallet(deposit, args, cp); got != other.Hex() {
+		t.Errorf("expected the funding wallet %s, got %s", other.Hex(), got)
+	}
+	if _, ok = findAccount(deposit, map[string]interface{}{"fundingWallet": other, "cpAccount": other}, cp); ok {
+		t.Errorf("expected an event of another account not to match")
+	}
+
+	// the amount of a batch event is the one at the index of the account
+	distributed := parsed.Events["RewardsDistributed"].Inputs
+	args = map[string]interface{}{
+		"taskUid": "task-1",
+		"cpList":  []common.Address{other, cp},
+		"rewards": []*big.Int{big.NewInt(7), big.NewInt(9)},
+	}
+	index, ok = findAccount(distributed, args, cp)
+	if !ok || index != 1 {
+		t.Errorf("expected the account at index 1, got %d %v", index, ok)
+	}
+	if got := eventAmount(distributed, args, index); got != "9" {
+		t.Errorf("expected the reward of the account 9, got %s", got)
+	}
+	if got := eventTaskUuid(distributed, args); got != "task-1" {
+		t.Errorf("expected the task task-1, got %s", got)
+	}
+}
+
+func TestDecodeEvent(t *testing.T) {
+	parsed, err := abi.JSON(strings.NewReader(testEventAbi))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cp := common.HexToAddress("0xc0")
+	wallet := common.HexToAddress("0xc1")
+	collateral := common.HexToAddress("0xcc")
+	indexer := &EventIndexer{
+		cpAccount: cp,
+		contracts: map[common.Address]indexedContract{collateral: {source: models.EventSourceFcpCollateral, abi: parsed}},
+	}
+
+	event := parsed.Events["Deposit"]
+	data, err := event.Inputs.NonIndexed().Pack(big.NewInt(2500))
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventLog := types.Log{
+		Address:     collateral,
+		Topics:      []common.Hash{event.ID, common.BytesToHash(wallet.Bytes()), common.BytesToHash(cp.Bytes())},
+		Data:        data,
+		BlockNumber: 42,
+		TxHash:      common.HexToHash("0x01"),
+		Index:       3,
+	}
+	blockTimes := map[uint64]int64{42: 1700000000}
+	decoded, err := indexer.decodeEvent(eventLog, true, blockTimes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collateralEvent, ok := decoded.(*models.CollateralEventEntity)
+	if !ok {
+		t.Fatalf("expected a collateral event, got %T", decoded)
+	}
+	if collateralEvent.Amount != "2500" || collateralEvent.Wallet != wallet.Hex() || collateralEvent.CpAccount != cp.Hex() ||
+		collateralEvent.ChainEvent.Event != "Deposit" || collateralEvent.ChainEvent.EventTime != 1700000000 ||
+		collateralEvent.ChainEvent.LogIndex != 3 || !collateralEvent.ChainEvent.Confirmed {
+		t.Errorf("unexpected event: %+v", collateralEvent)
+	}
+
+	// the deposit of another account is not indexed
+	eventLog.Topics[2] = common.BytesToHash(wallet.Bytes())
+	if decoded, err = indexer.decodeEvent(eventLog, true, blockTimes); err != nil || decoded != nil {
+		t.Errorf("expected the event of another account to be skipped, got %+v, %v", decoded, err)
+	}
+}
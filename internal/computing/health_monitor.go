@@ -0,0 +1,228 @@
+package computing
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/internal/models"
+)
+
+const (
+	healthRestartNo        = "no"
+	healthRestartOnFailure = "on-failure"
+	healthRestartAlways    = "always"
+)
+
+// StartHealthMonitor checks the inference containers every Health.Interval, and restarts the unhealthy or crashed ones
+// according to Health.RestartPolicy.
+func StartHealthMonitor() {
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				logs.GetLogger().Errorf("health monitor catch panic error: %+v", err)
+			}
+		}()
+
+		ticker := time.NewTicker(time.Duration(conf.GetConfig().Health.Interval) * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			checkEcpJobsHealth()
+		}
+	}()
+}
+
+func checkEcpJobsHealth() {
+	jobs, err := NewEcpJobService().GetEcpJobsForHealthCheck()
+	if err != nil {
+		logs.GetLogger().Errorf("failed to get ecp jobs for health check, error: %v", err)
+		return
+	}
+
+	dockerService := NewDockerService()
+	var wg sync.WaitGroup
+	for i := range jobs {
+		wg.Add(1)
+		go func(job *models.EcpJobEntity) {
+			defer wg.Done()
+			checkEcpJobHealth(dockerService, job)
+		}(&jobs[i])
+	}
+	wg.Wait()
+}
+
+func checkEcpJobHealth(dockerService *DockerService, job *models.EcpJobEntity) {
+	healthConfig := conf.GetConfig().Health
+	timeout := time.Duration(healthConfig.Timeout) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), 2*timeout)
+	defer cancel()
+
+	info, err := dockerService.c.ContainerInspect(ctx, job.ContainerName)
+	if err != nil {
+		if !client.IsErrNotFound(err) {
+			logs.GetLogger().Errorf("failed to inspect container, job_uuid: %s, error: %v", job.Uuid, err)
+		}
+		return
+	}
+	if info.State == nil {
+		return
+	}
+
+	job.LastCheckTime = time.Now().Unix()
+	if startedAt, err := time.Parse(time.RFC3339Nano, info.State.StartedAt); err == nil && startedAt.Unix() > 0 {
+		job.StartedTime = startedAt.Unix()
+	}
+
+	dockerRestarts := job.DockerRestarts
+	job.DockerRestarts = info.RestartCount
+
+	switch {
+	case info.State.Restarting || (info.State.Running && !info.State.Paused):
+		if err = checkContainer(ctx, info, job.HealthUrlPath, job.HealthPort, dockerRestarts, timeout); err == nil {
+			if job.Health == models.UnhealthyStatus {
+				PublishJobEvent(models.JobEvent{
+					JobUuid: job.Uuid,
+					Reason:  "Healthy",
+					Object:  "Container/" + job.ContainerName,
+					Message: "the container is healthy again",
+				})
+			}
+			job.Health = models.HealthyStatus
+			job.HealthFailures = 0
+			job.HealthMessage = ""
+			break
+		}
+
+		job.HealthFailures++
+		job.HealthMessage = err.Error()
+		if job.HealthFailures < healthConfig.FailureThreshold {
+			break
+		}
+		if job.Health != models.UnhealthyStatus {
+			PublishJobEvent(models.JobEvent{
+				JobUuid: job.Uuid,
+				Type:    JobEventWarning,
+				Reason:  "Unhealthy",
+				Object:  "Container/" + job.ContainerName,
+				Message: fmt.Sprintf("health check failed %d times: %v", job.HealthFailures, err),
+			})
+		}
+		job.Health = models.UnhealthyStatus
+		if healthConfig.RestartPolicy == healthRestartOnFailure || healthConfig.RestartPolicy == healthRestartAlways {
+			restartJobContainer(dockerService, job)
+		}
+
+	case info.State.Status == "exited" || info.State.Status == "dead":
+		job.Health = models.UnhealthyStatus
+		job.HealthMessage = fmt.Sprintf("container %s, exit code: %d", info.State.Status, info.State.ExitCode)
+		if healthConfig.RestartPolicy == healthRestartAlways ||
+			(healthConfig.RestartPolicy == healthRestartOnFailure && info.State.ExitCode != 0) {
+			restartJobContainer(dockerService, job)
+		}
+
+	default:
+		// created or paused, check it the next time
+		return
+	}
+
+	if err = NewEcpJobService().UpdateEcpJobEntityHealth(job); err != nil {
+		logs.GetLogger().Errorf("failed to save job health, job_uuid: %s, error: %v", job.Uuid, err)
+	}
+}
+
+func restartJobContainer(dockerService *DockerService, job *models.EcpJobEntity) {
+	maxRestarts := conf.GetConfig().Health.MaxRestarts
+	if job.RestartCount >= maxRestarts {
+		job.HealthMessage += fmt.Sprintf(", not restarted after %d restarts", job.RestartCount)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := dockerService.c.ContainerRestart(ctx, job.ContainerName, container.StopOptions{}); err != nil {
+		logs.GetLogger().Errorf("failed to restart container, job_uuid: %s, error: %v", job.Uuid, err)
+		job.HealthMessage += fmt.Sprintf(", failed to restart: %v", err)
+		return
+	}
+	job.RestartCount++
+	job.HealthFailures = 0
+	logs.GetLogger().Warnf("job_uuid: %s, restarted container %s (%d/%d): %s", job.Uuid, job.ContainerName, job.RestartCount, maxRestarts, job.HealthMessage)
+	PublishJobEvent(models.JobEvent{
+		JobUuid: job.Uuid,
+		Type:    JobEventWarning,
+		Reason:  "Restarted",
+		Object:  "Container/" + job.ContainerName,
+		Message: fmt.Sprintf("restarted the container (%d/%d): %s", job.RestartCount, maxRestarts, job.HealthMessage),
+	})
+}
+
+// checkContainer fails while docker restarts the container, or when docker restarted it since the last check, which
+// saw dockerRestarts restarts. Then it probes the health port, if the job has one.
+func checkContainer(ctx context.Context, info types.ContainerJSON, healthPath string, port int, dockerRestarts int, timeout time.Duration) error {
+	if info.State.Restarting {
+		return fmt.Errorf("the container is restarting, restarts: %d", info.RestartCount)
+	}
+	if info.RestartCount > dockerRestarts {
+		return fmt.Errorf("the container restarted %d times since the last check, restarts: %d", info.RestartCount-dockerRestarts, info.RestartCount)
+	}
+	if port == 0 {
+		return nil
+	}
+	return probeContainer(ctx, info, healthPath, port, timeout)
+}
+
+// probeContainer checks the health path of the container over http, or only connects to the port without a health path.
+// The container is reached through its host port, or its address in the docker network.
+func probeContainer(ctx context.Context, info types.ContainerJSON, healthPath string, port int, timeout time.Duration) error {
+	var address string
+	if info.HostConfig != nil {
+		if bindings := info.HostConfig.PortBindings[nat.Port(fmt.Sprintf("%d/tcp", port))]; len(bindings) > 0 {
+			address = fmt.Sprintf("127.0.0.1:%s", bindings[0].HostPort)
+		}
+	}
+	if address == "" && info.NetworkSettings != nil {
+		for _, endpoint := range info.NetworkSettings.Networks {
+			if endpoint != nil && endpoint.IPAddress != "" {
+				address = fmt.Sprintf("%s:%d", endpoint.IPAddress, port)
+				break
+			}
+		}
+	}
+	if address == "" {
+		return fmt.Errorf("not found the address of port %d", port)
+	}
+
+	if healthPath == "" {
+		conn, err := net.DialTimeout("tcp", address, timeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, "http://"+address+"/"+strings.TrimPrefix(healthPath, "/"), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("health path %s returned %d", healthPath, resp.StatusCode)
+	}
+	return nil
+}
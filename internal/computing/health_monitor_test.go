@@ -0,0 +1,33 @@
+package computing
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestCheckContainerWithoutPort(t *testing.T) {
+	container := func(state types.ContainerState, restarts int) types.ContainerJSON {
+		return types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{State: &state, RestartCount: restarts}}
+	}
+
+	tests := []struct {
+		name           string
+		info           types.ContainerJSON
+		dockerRestarts int
+		healthy        bool
+	}{
+		{"running", container(types.ContainerState{Running: true}, 2), 2, true},
+		{"restarting", container(types.ContainerState{Running: true, Restarting: true}, 2), 2, false},
+		{"restarted since the last check", container(types.ContainerState{Running: true}, 3), 2, false},
+		{"recreated", container(types.ContainerState{Running: true}, 0), 2, true},
+	}
+	for _, tt := range tests {
+		err := checkContainer(context.Background(), tt.info, "", 0, tt.dockerRestarts, time.Second)
+		if (err == nil) != tt.healthy {
+			t.Errorf("%s: expected healthy %v, got %v", tt.name, tt.healthy, err)
+		}
+	}
+}
@@ -0,0 +1,759 @@
+package computing
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/gin-gonic/gin"
+	controlapi "github.com/moby/buildkit/api/services/control"
+	"github.com/swanchain/go-computing-provider/build"
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/constants"
+	"github.com/swanchain/go-computing-provider/internal/models"
+	"github.com/swanchain/go-computing-provider/util"
+)
+
+const (
+	buildModeRootless = "rootless"
+
+	buildStepStarted = "started"
+	buildStepCached  = "cached"
+	buildStepDone    = "done"
+	buildStepError   = "error"
+	buildStepLog     = "log"
+
+	// rootlessBuildUid is the user of the rootless BuildKit image, the directories it writes must belong to it
+	rootlessBuildUid = 1000
+
+	buildkitTraceId = "moby.buildkit.trace"
+
+	// dockerIndexServer is the key of docker.io in the credentials of docker
+	dockerIndexServer = "https://index.docker.io/v1/"
+)
+
+var buildCacheKeyRegexp = regexp.MustCompile(`[^a-z0-9_.-]+`)
+
+// BuildImage builds the Dockerfile in buildPath with BuildKit, in the local docker daemon or in a rootless BuildKit
+// container according to the Build config. The build cache is reused between the builds of the same cacheKey, an empty
+// cacheKey builds without cache reuse. The progress is written to the build log of the job, cancelling ctx aborts the build.
+func (ds *DockerService) BuildImage(ctx context.Context, jobUuid, buildPath, imageName, cacheKey string) error {
+	logger, err := newBuildLogger(jobUuid)
+	if err != nil {
+		return err
+	}
+	defer logger.Close()
+
+	buildConfig := conf.GetConfig().Build
+	timeout := time.Duration(buildConfig.Timeout) * time.Second
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	PublishJobEvent(models.JobEvent{
+		JobUuid: jobUuid,
+		Reason:  "Building",
+		Object:  "Image/" + imageName,
+		Message: fmt.Sprintf("building the image with BuildKit in the %s mode", buildConfig.Mode),
+	})
+	if buildConfig.Mode == buildModeRootless {
+		err = ds.buildImageInSandbox(ctx, jobUuid, buildPath, imageName, cacheKey, logger)
+	} else {
+		err = ds.buildImageWithDaemon(ctx, buildPath, imageName, cacheKey, logger)
+	}
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("the build did not finish within %s", timeout)
+	}
+	if err != nil {
+		logger.write(models.BuildLogRecord{Status: buildStepError, Message: err.Error()})
+		return err
+	}
+
+	steps, cached := logger.summary()
+	PublishJobEvent(models.JobEvent{
+		JobUuid: jobUuid,
+		Reason:  "Built",
+		Object:  "Image/" + imageName,
+		Message: fmt.Sprintf("built the image in %s, %d steps, %d cached", time.Since(start).Round(time.Second), steps, cached),
+	})
+	return nil
+}
+
+// buildImageWithDaemon builds with the BuildKit of the local docker daemon, the cache of the key is the inline cache of
+// the last image built for it. The BuildKit of the daemon ignores the resource options of a build, the builds are not
+// capped by Build.Cpus and Build.Memory in this mode.
+func (ds *DockerService) buildImageWithDaemon(ctx context.Context, buildPath, imageName, cacheKey string, logger *buildLogger) error {
+	if buildConfig := conf.GetConfig().Build; buildConfig.Cpus > 0 || buildConfig.Memory > 0 {
+		logs.GetLogger().Warnf("the builds in the daemon mode are not capped by Build.Cpus and Build.Memory, set Build.Mode to %q to cap them", buildModeRootless)
+	}
+	buildContext, err := archiveBuildContext(buildPath)
+	if err != nil {
+		return fmt.Errorf("failed to archive the build context, error: %v", err)
+	}
+
+	options := types.ImageBuildOptions{
+		Version:     types.BuilderBuildKit,
+		Tags:        []string{imageName},
+		Dockerfile:  dockerfileName(buildPath),
+		Remove:      true,
+		ForceRemove: true,
+		AuthConfigs: buildAuthConfigs(),
+	}
+	if conf.GetConfig().Build.NoNetwork {
+		options.NetworkMode = "none"
+	}
+	cacheTag := buildCacheTag(cacheKey)
+	if cacheTag != "" {
+		inlineCache := "1"
+		options.CacheFrom = []string{cacheTag}
+		options.BuildArgs = map[string]*string{"BUILDKIT_INLINE_CACHE": &inlineCache}
+	}
+
+	resp, err := ds.c.ImageBuild(ctx, buildContext, options)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err = logger.readDaemonOutput(resp.Body); err != nil {
+		return err
+	}
+
+	if cacheTag != "" {
+		if err = ds.c.ImageTag(ctx, imageName, cacheTag); err != nil {
+			logs.GetLogger().Warnf("failed to tag the build cache %s, error: %v", cacheTag, err)
+		}
+	}
+	return nil
+}
+
+// buildImageInSandbox builds in a rootless BuildKit container with the cpu and memory caps of the Build config,
+// the image is exported as a tarball and loaded into the docker daemon
+func (ds *DockerService) buildImageInSandbox(ctx context.Context, jobUuid, buildPath, imageName, cacheKey string, logger *buildLogger) error {
+	buildConfig := conf.GetConfig().Build
+	builderImage := buildConfig.Image
+	if builderImage == "" {
+		builderImage = build.BuildkitRootlessDockerImage
+	}
+	if err := ds.PullImage(builderImage); err != nil {
+		return fmt.Errorf("failed to pull the builder image %s, error: %v", builderImage, err)
+	}
+
+	cpRepoPath, _ := os.LookupEnv("CP_PATH")
+	workDir := filepath.Join(cpRepoPath, constants.BUILD_IMAGE_PATH_PREFIX, "sandbox", jobUuid)
+	outputDir := filepath.Join(workDir, "output")
+	if err := mkdirForBuilder(outputDir); err != nil {
+		return err
+	}
+	defer os.RemoveAll(workDir)
+
+	args := []string{
+		"build",
+		"--frontend", "dockerfile.v0",
+		"--local", "context=/workspace",
+		"--local", "dockerfile=/workspace",
+		"--opt", "filename=" + dockerfileName(buildPath),
+		"--output", fmt.Sprintf("type=docker,name=%s,dest=/output/image.tar", imageName),
+		"--progress", "rawjson",
+	}
+	if buildConfig.NoNetwork {
+		args = append(args, "--opt", "force-network-mode=none")
+	}
+	binds := []string{buildPath + ":/workspace:ro", outputDir + ":/output"}
+
+	if cacheDir := buildCacheDir(cacheKey); cacheDir != "" {
+		if err := mkdirForBuilder(cacheDir); err != nil {
+			return err
+		}
+		if _, err := os.Stat(filepath.Join(cacheDir, "index.json")); err == nil {
+			args = append(args, "--import-cache", "type=local,src=/cache")
+		}
+		args = append(args, "--export-cache", "type=local,dest=/cache,mode=max")
+		binds = append(binds, cacheDir+":/cache")
+	}
+
+	if authConfigs := buildAuthConfigs(); len(authConfigs) > 0 {
+		dockerConfig, err := writeBuilderDockerConfig(workDir, authConfigs)
+		if err != nil {
+			return err
+		}
+		binds = append(binds, dockerConfig+":/home/user/.docker/config.json:ro")
+	}
+
+	hostConfig := &container.HostConfig{
+		Binds: binds,
+		// rootlesskit creates the user namespace and the mounts of BuildKit inside the container, which the default seccomp
+		// and apparmor profiles of docker deny. The container runs as the unprivileged user of the builder image, and the
+		// build steps in the user namespace it owns.
+		SecurityOpt: []string{"seccomp=unconfined", "apparmor=unconfined"},
+		Resources: container.Resources{
+			NanoCPUs: int64(buildConfig.Cpus * 1e9),
+			Memory:   int64(buildConfig.Memory * 1024 * 1024 * 1024),
+		},
+	}
+	if pidsLimit := conf.GetConfig().Security.PidsLimit; pidsLimit > 0 {
+		hostConfig.Resources.PidsLimit = &pidsLimit
+	}
+	resp, err := ds.c.ContainerCreate(ctx, &container.Config{
+		Image:      builderImage,
+		Entrypoint: []string{"buildctl-daemonless.sh"},
+		Cmd:        args,
+		Env:        []string{"BUILDKITD_FLAGS=--oci-worker-no-process-sandbox"},
+	}, hostConfig, nil, nil, "cp-build-"+jobUuid)
+	if err != nil {
+		return fmt.Errorf("failed to create the build container, error: %v", err)
+	}
+	defer func() {
+		err := ds.c.ContainerRemove(context.Background(), resp.ID, container.RemoveOptions{Force: true})
+		if err != nil {
+			logs.GetLogger().Warnf("failed to remove the build container, job_uuid: %s, error: %v", jobUuid, err)
+		}
+	}()
+
+	if err = ds.c.ContainerStart(ctx, resp.ID, container.StartOptions{}); err != nil {
+		return fmt.Errorf("failed to start the build container, error: %v", err)
+	}
+	output, err := ds.c.ContainerLogs(ctx, resp.ID, container.LogsOptions{ShowStdout: true, ShowStderr: true, Follow: true})
+	if err != nil {
+		return fmt.Errorf("failed to read the build output, error: %v", err)
+	}
+	reader, writer := io.Pipe()
+	go func() {
+		_, err := stdcopy.StdCopy(writer, writer, output)
+		output.Close()
+		writer.CloseWithError(err)
+	}()
+	logger.readSandboxOutput(reader)
+
+	statusCh, errCh := ds.c.ContainerWait(ctx, resp.ID, container.WaitConditionNotRunning)
+	select {
+	case err = <-errCh:
+		return err
+	case status := <-statusCh:
+		if status.StatusCode != 0 {
+			if message := logger.lastError(); message != "" {
+				return errors.New(message)
+			}
+			return fmt.Errorf("the build exited with code %d", status.StatusCode)
+		}
+	}
+
+	imageTar, err := os.Open(filepath.Join(outputDir, "image.tar"))
+	if err != nil {
+		return fmt.Errorf("not found the built image, error: %v", err)
+	}
+	defer imageTar.Close()
+	loadResp, err := ds.c.ImageLoad(ctx, imageTar, true)
+	if err != nil {
+		return fmt.Errorf("failed to load the built image, error: %v", err)
+	}
+	defer loadResp.Body.Close()
+	return readErrorLine(loadResp.Body)
+}
+
+// buildLogger writes the progress of a build to the build log of the job, as plain text lines for the log stream and as
+// BuildLogRecord lines for the structured build log
+type buildLogger struct {
+	jobUuid string
+	text    io.Writer
+	files   []*os.File
+	records *json.Encoder
+
+	lock    sync.Mutex
+	steps   map[string]*buildStep
+	lastErr string
+}
+
+type buildStep struct {
+	index   int
+	name    string
+	started bool
+	done    bool
+	cached  bool
+}
+
+// buildVertex and buildVertexLog are the progress of a build step reported by BuildKit, in the rawjson format of buildctl
+type buildVertex struct {
+	Digest    string     `json:"digest"`
+	Name      string     `json:"name"`
+	Started   *time.Time `json:"started,omitempty"`
+	Completed *time.Time `json:"completed,omitempty"`
+	Cached    bool       `json:"cached,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+type buildVertexLog struct {
+	Vertex string `json:"vertex"`
+	Stream int    `json:"stream"`
+	Data   []byte `json:"data"`
+}
+
+type buildStatus struct {
+	Vertexes []buildVertex    `json:"vertexes"`
+	Logs     []buildVertexLog `json:"logs"`
+}
+
+func newBuildLogger(jobUuid string) (*buildLogger, error) {
+	cpRepoPath, _ := os.LookupEnv("CP_PATH")
+	logDir := filepath.Join(cpRepoPath, constants.LOG_PATH_PREFIX, jobUuid)
+	os.MkdirAll(logDir, os.ModePerm)
+
+	logFile, err := os.Create(filepath.Join(logDir, constants.BUILD_LOG_NAME))
+	if err != nil {
+		return nil, err
+	}
+	recordFile, err := os.Create(filepath.Join(logDir, constants.BUILD_RECORD_LOG_NAME))
+	if err != nil {
+		logFile.Close()
+		return nil, err
+	}
+	return &buildLogger{
+		jobUuid: jobUuid,
+		text:    io.MultiWriter(logFile, os.Stdout),
+		files:   []*os.File{logFile, recordFile},
+		records: json.NewEncoder(recordFile),
+		steps:   make(map[string]*buildStep),
+	}, nil
+}
+
+func (l *buildLogger) Close() {
+	for _, f := range l.files {
+		f.Close()
+	}
+}
+
+// readDaemonOutput reads the json messages of the docker daemon, the progress of BuildKit comes in their aux field
+func (l *buildLogger) readDaemonOutput(body io.Reader) error {
+	decoder := json.NewDecoder(body)
+	for {
+		var message struct {
+			Stream string          `json:"stream"`
+			ID     string          `json:"id"`
+			Aux    json.RawMessage `json:"aux"`
+			Error  string          `json:"error"`
+		}
+		if err := decoder.Decode(&message); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		switch {
+		case message.Error != "":
+			return errors.New(message.Error)
+		case message.ID == buildkitTraceId && len(message.Aux) > 0:
+			var data []byte
+			if err := json.Unmarshal(message.Aux, &data); err != nil {
+				continue
+			}
+			var resp controlapi.StatusResponse
+			if err := resp.UnmarshalVT(data); err != nil {
+				continue
+			}
+			l.handleStatus(statusFromTrace(&resp))
+		case message.Stream != "":
+			l.writeText(strings.TrimRight(message.Stream, "\n"))
+		}
+	}
+}
+
+// readSandboxOutput reads the output of buildctl, the rawjson progress lines are parsed and the others kept as they are
+func (l *buildLogger) readSandboxOutput(output io.Reader) {
+	scanner := bufio.NewScanner(output)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		var status buildStatus
+		if bytes.HasPrefix(line, []byte("{")) && json.Unmarshal(line, &status) == nil {
+			l.handleStatus(status)
+			continue
+		}
+		l.writeText(string(line))
+	}
+}
+
+func statusFromTrace(resp *controlapi.StatusResponse) buildStatus {
+	var status buildStatus
+	for _, v := range resp.GetVertexes() {
+		vertex := buildVertex{
+			Digest: v.GetDigest(),
+			Name:   v.GetName(),
+			Cached: v.GetCached(),
+			Error:  v.GetError(),
+		}
+		if v.GetStarted() != nil {
+			started := v.GetStarted().AsTime()
+			vertex.Started = &started
+		}
+		if v.GetCompleted() != nil {
+			completed := v.GetCompleted().AsTime()
+			vertex.Completed = &completed
+		}
+		status.Vertexes = append(status.Vertexes, vertex)
+	}
+	for _, log := range resp.GetLogs() {
+		status.Logs = append(status.Logs, buildVertexLog{
+			Vertex: log.GetVertex(),
+			Stream: int(log.GetStream()),
+			Data:   log.GetMsg(),
+		})
+	}
+	return status
+}
+
+func (l *buildLogger) handleStatus(status buildStatus) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for _, v := range status.Vertexes {
+		step := l.step(v.Digest, v.Name)
+		if step.done {
+			continue
+		}
+		switch {
+		case v.Error != "":
+			step.done = true
+			l.lastErr = fmt.Sprintf("%s: %s", v.Name, v.Error)
+			l.record(step, models.BuildLogRecord{Status: buildStepError, Message: v.Error})
+			PublishJobEvent(models.JobEvent{
+				JobUuid: l.jobUuid,
+				Type:    JobEventWarning,
+				Reason:  "BuildStepFailed",
+				Object:  "Job/" + l.jobUuid,
+				Message: l.lastErr,
+			})
+		case v.Cached && v.Completed != nil:
+			step.done, step.cached = true, true
+			l.record(step, models.BuildLogRecord{Status: buildStepCached})
+		case v.Completed != nil:
+			step.done = true
+			var duration float64
+			if v.Started != nil {
+				duration = v.Completed.Sub(*v.Started).Seconds()
+			}
+			l.record(step, models.BuildLogRecord{Status: buildStepDone, Duration: duration})
+		case v.Started != nil && !step.started:
+			step.started = true
+			l.record(step, models.BuildLogRecord{Status: buildStepStarted})
+		}
+	}
+
+	for _, log := range status.Logs {
+		step := l.step(log.Vertex, "")
+		stream := "stdout"
+		if log.Stream == 2 {
+			stream = "stderr"
+		}
+		for _, line := range strings.Split(strings.TrimRight(string(log.Data), "\n"), "\n") {
+			l.record(step, models.BuildLogRecord{Status: buildStepLog, Stream: stream, Message: strings.TrimRight(line, "\r")})
+		}
+	}
+}
+
+// step returns the step of the vertex, the steps are numbered in the order they show up like the plain progress of docker
+func (l *buildLogger) step(digest, name string) *buildStep {
+	step, ok := l.steps[digest]
+	if !ok {
+		step = &buildStep{index: len(l.steps) + 1}
+		l.steps[digest] = step
+	}
+	if step.name == "" {
+		step.name = name
+	}
+	return step
+}
+
+// record writes a record of the step, the caller must hold the lock
+func (l *buildLogger) record(step *buildStep, record models.BuildLogRecord) {
+	record.Step = step.name
+	l.writeRecord(record)
+
+	var line string
+	switch record.Status {
+	case buildStepStarted:
+		line = fmt.Sprintf("#%d %s", step.index, step.name)
+	case buildStepCached:
+		line = fmt.Sprintf("#%d %s CACHED", step.index, step.name)
+	case buildStepDone:
+		line = fmt.Sprintf("#%d DONE %.1fs", step.index, record.Duration)
+	case buildStepError:
+		line = fmt.Sprintf("#%d ERROR: %s", step.index, record.Message)
+	default:
+		line = fmt.Sprintf("#%d %s", step.index, record.Message)
+	}
+	fmt.Fprintln(l.text, line)
+}
+
+// write records a message of the build itself rather than of a step
+func (l *buildLogger) write(record models.BuildLogRecord) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.writeRecord(record)
+	if record.Status == buildStepError {
+		fmt.Fprintf(l.text, "ERROR: %s\n", record.Message)
+	} else {
+		fmt.Fprintln(l.text, record.Message)
+	}
+}
+
+func (l *buildLogger) writeText(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.writeRecord(models.BuildLogRecord{Status: buildStepLog, Message: line})
+	fmt.Fprintln(l.text, line)
+}
+
+func (l *buildLogger) writeRecord(record models.BuildLogRecord) {
+	if record.Time == 0 {
+		record.Time = time.Now().Unix()
+	}
+	if err := l.records.Encode(record); err != nil {
+		logs.GetLogger().Warnf("failed to write the build log, job_uuid: %s, error: %v", l.jobUuid, err)
+	}
+}
+
+func (l *buildLogger) lastError() string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.lastErr
+}
+
+// summary returns the steps of the build and how many of them came from the cache
+func (l *buildLogger) summary() (int, int) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	var cached int
+	for _, step := range l.steps {
+		if step.cached {
+			cached++
+		}
+	}
+	return len(l.steps), cached
+}
+
+// GetJobBuildLog returns the structured build log of the job, the records are read from the offset of the query
+func GetJobBuildLog(c *gin.Context) {
+	jobUuid := strings.ToLower(c.Param("job_uuid"))
+	if strings.TrimSpace(jobUuid) == "" {
+		c.JSON(http.StatusBadRequest, util.CreateErrorResponse(util.BadParamError, "missing required field: [job_uuid]"))
+		return
+	}
+	if !jobExists(jobUuid) {
+		c.JSON(http.StatusNotFound, util.CreateErrorResponse(util.NotFoundJobEntityError))
+		return
+	}
+
+	cpRepoPath, _ := os.LookupEnv("CP_PATH")
+	recordFile, err := os.Open(filepath.Join(cpRepoPath, constants.LOG_PATH_PREFIX, jobUuid, constants.BUILD_RECORD_LOG_NAME))
+	if err != nil {
+		if os.IsNotExist(err) {
+			c.JSON(http.StatusOK, util.CreateSuccessResponse([]models.BuildLogRecord{}))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.ReadLogError, err.Error()))
+		return
+	}
+	defer recordFile.Close()
+
+	var offset int
+	fmt.Sscanf(c.Query("offset"), "%d", &offset)
+	var records = []models.BuildLogRecord{}
+	decoder := json.NewDecoder(recordFile)
+	for i := 0; ; i++ {
+		var record models.BuildLogRecord
+		if err = decoder.Decode(&record); err != nil {
+			break
+		}
+		if i >= offset {
+			records = append(records, record)
+		}
+	}
+	c.JSON(http.StatusOK, util.CreateSuccessResponse(records))
+}
+
+// archiveBuildContext packs the build path into the tarball of the build context
+func archiveBuildContext(buildPath string) (io.Reader, error) {
+	buf := new(bytes.Buffer)
+	tw := tar.NewWriter(buf)
+	err := filepath.Walk(buildPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, info.Name())
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(buildPath, path)
+		if err != nil {
+			return err
+		}
+		header.Name = relPath
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			if _, err := io.Copy(tw, file); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err = tw.Close(); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func dockerfileName(buildPath string) string {
+	if _, err := os.Stat(filepath.Join(buildPath, "Dockerfile")); err != nil {
+		if _, err = os.Stat(filepath.Join(buildPath, "dockerfile")); err == nil {
+			return "dockerfile"
+		}
+	}
+	return "Dockerfile"
+}
+
+// spaceBuildCacheKey is the cache key of the builds of a space, the builds of the same wallet and space name reuse the
+// cache. The builds without a wallet share no cache, the cache of a build must not leak to another user.
+func spaceBuildCacheKey(walletAddress, spaceName string) string {
+	if strings.TrimSpace(walletAddress) == "" || strings.TrimSpace(spaceName) == "" {
+		return ""
+	}
+	return walletAddress + "-" + spaceName
+}
+
+func normalizeBuildCacheKey(cacheKey string) string {
+	return strings.Trim(buildCacheKeyRegexp.ReplaceAllString(strings.ToLower(cacheKey), "-"), "-.")
+}
+
+// buildCacheTag is the image the daemon builds of the cache key reuse the cache of
+func buildCacheTag(cacheKey string) string {
+	if cacheKey = normalizeBuildCacheKey(cacheKey); cacheKey == "" {
+		return ""
+	}
+	return fmt.Sprintf("cp-build-cache/%s:latest", cacheKey)
+}
+
+// buildCacheDir is the local cache the rootless builds of the cache key import and export
+func buildCacheDir(cacheKey string) string {
+	if cacheKey = normalizeBuildCacheKey(cacheKey); cacheKey == "" {
+		return ""
+	}
+	cacheRoot := conf.GetConfig().Build.CacheDir
+	if cacheRoot == "" {
+		cpRepoPath, _ := os.LookupEnv("CP_PATH")
+		cacheRoot = filepath.Join(cpRepoPath, constants.BUILD_IMAGE_PATH_PREFIX, "cache")
+	}
+	return filepath.Join(cacheRoot, cacheKey)
+}
+
+func mkdirForBuilder(dir string) error {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create dir %s, error: %v", dir, err)
+	}
+	if os.Getuid() == 0 {
+		return os.Chown(dir, rootlessBuildUid, rootlessBuildUid)
+	}
+	return nil
+}
+
+// buildAuthConfigs returns the credentials of the configured registries, for pulling the private base images
+func buildAuthConfigs() map[string]registry.AuthConfig {
+	var servers []conf.RegistryAuth
+	servers = append(servers, conf.GetConfig().Registries...)
+	if registryConfig := conf.GetConfig().Registry; registryConfig.ServerAddress != "" {
+		servers = append(servers, findRegistryAuth(strings.Split(registryConfig.ServerAddress, "/")[0]))
+	}
+
+	authConfigs := make(map[string]registry.AuthConfig)
+	for _, r := range servers {
+		if r.UserName == "" && r.Password == "" {
+			continue
+		}
+		server := normalizeRegistry(r.Server)
+		if server == defaultRegistry {
+			server = dockerIndexServer
+		}
+		authConfigs[server] = registry.AuthConfig{
+			ServerAddress: server,
+			Username:      r.UserName,
+			Password:      r.Password,
+		}
+	}
+	return authConfigs
+}
+
+// writeBuilderDockerConfig writes the credentials into the docker config the rootless BuildKit reads
+func writeBuilderDockerConfig(dir string, authConfigs map[string]registry.AuthConfig) (string, error) {
+	type authEntry struct {
+		Auth string `json:"auth"`
+	}
+	var dockerConfig = struct {
+		Auths map[string]authEntry `json:"auths"`
+	}{Auths: make(map[string]authEntry)}
+	for server, authConfig := range authConfigs {
+		dockerConfig.Auths[server] = authEntry{
+			Auth: base64.StdEncoding.EncodeToString([]byte(authConfig.Username + ":" + authConfig.Password)),
+		}
+	}
+
+	data, err := json.Marshal(dockerConfig)
+	if err != nil {
+		return "", err
+	}
+	configPath := filepath.Join(dir, "config.json")
+	if err = os.WriteFile(configPath, data, 0600); err != nil {
+		return "", fmt.Errorf("failed to write the docker config of the builder, error: %v", err)
+	}
+	if os.Getuid() == 0 {
+		return configPath, os.Chown(configPath, rootlessBuildUid, rootlessBuildUid)
+	}
+	return configPath, nil
+}
+
+// readErrorLine reads the json messages of the docker daemon to the end, and returns the error of the last one
+func readErrorLine(body io.Reader) error {
+	var lastLine string
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		lastLine = scanner.Text()
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	errLine := &ErrorLine{}
+	json.Unmarshal([]byte(lastLine), errLine)
+	if errLine.Error != "" {
+		return errors.New(errLine.Error)
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+package computing
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/swanchain/go-computing-provider/build"
+	"github.com/swanchain/go-computing-provider/conf"
+)
+
+const defaultRegistry = "docker.io"
+
+// imageCacheLock serializes the evictions of the image cache
+var imageCacheLock sync.Mutex
+
+// pullSource is a reference the image can be pulled from, with the credential of its registry
+type pullSource struct {
+	ref  string
+	auth string
+}
+
+// pullSources returns the mirrors of the registry of the image in order, then the registry itself
+func pullSources(imageName string) []pullSource {
+	named, err := reference.ParseNormalizedNamed(imageName)
+	if err != nil {
+		return []pullSource{{ref: imageName}}
+	}
+	server := reference.Domain(named)
+	registryAuth := findRegistryAuth(server)
+
+	var sources []pullSource
+	// a mirror can only serve a tag, the digest of an image is bound to its repository
+	if tagged, ok := reference.TagNameOnly(named).(reference.Tagged); ok {
+		if _, digested := named.(reference.Digested); !digested {
+			for _, mirror := range registryAuth.Mirrors {
+				mirror = normalizeRegistry(mirror)
+				if mirror == "" {
+					continue
+				}
+				sources = append(sources, pullSource{
+					ref:  fmt.Sprintf("%s/%s:%s", mirror, reference.Path(named), tagged.Tag()),
+					auth: encodeRegistryAuth(findRegistryAuth(mirror)),
+				})
+			}
+		}
+	}
+	return append(sources, pullSource{ref: imageName, auth: encodeRegistryAuth(registryAuth)})
+}
+
+// findRegistryAuth returns the Registries entry of the registry, the Registry block is the credential of its server too
+func findRegistryAuth(server string) conf.RegistryAuth {
+	server = normalizeRegistry(server)
+	for _, r := range conf.GetConfig().Registries {
+		if normalizeRegistry(r.Server) == server {
+			return r
+		}
+	}
+	registryConfig := conf.GetConfig().Registry
+	if registryConfig.ServerAddress != "" && normalizeRegistry(strings.Split(registryConfig.ServerAddress, "/")[0]) == server {
+		return conf.RegistryAuth{
+			Server:   server,
+			UserName: registryConfig.UserName,
+			Password: registryConfig.Password,
+		}
+	}
+	return conf.RegistryAuth{Server: server}
+}
+
+func normalizeRegistry(server string) string {
+	server = strings.TrimSpace(server)
+	server = strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://")
+	server = strings.TrimSuffix(server, "/")
+	switch server {
+	case "index.docker.io", "registry-1.docker.io":
+		return defaultRegistry
+	}
+	return server
+}
+
+func encodeRegistryAuth(registryAuth conf.RegistryAuth) string {
+	if registryAuth.UserName == "" && registryAuth.Password == "" {
+		return ""
+	}
+	authConfigBytes, _ := json.Marshal(registry.AuthConfig{
+		ServerAddress: registryAuth.Server,
+		Username:      registryAuth.UserName,
+		Password:      registryAuth.Password,
+	})
+	return base64.URLEncoding.EncodeToString(authConfigBytes)
+}
+
+// alwaysPull reports whether an image on the node is pulled again under the pull policy,
+// an image pinned by digest never changes and is pulled only when it is missing
+func alwaysPull(pullPolicy, imageName string) bool {
+	if pullPolicy != conf.PullAlways {
+		return false
+	}
+	named, err := reference.ParseNormalizedNamed(imageName)
+	if err != nil {
+		return true
+	}
+	_, digested := named.(reference.Digested)
+	return !digested
+}
+
+// pullImageFromRegistries pulls the image from the mirrors of its registry, then from the registry itself.
+// An image pulled from a mirror is tagged with its own name. Cancelling ctx aborts the pull.
+func (ds *DockerService) pullImageFromRegistries(ctx context.Context, imageName string, output func(io.Reader) error) error {
+	var errs []string
+	for _, source := range pullSources(imageName) {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		resp, err := ds.c.ImagePull(ctx, source.ref, image.PullOptions{RegistryAuth: source.auth})
+		if err == nil {
+			err = output(resp)
+			resp.Close()
+		}
+		if err != nil {
+			logs.GetLogger().Warnf("failed to pull image %s, error: %v", source.ref, err)
+			errs = append(errs, fmt.Sprintf("%s: %v", source.ref, err))
+			continue
+		}
+
+		if source.ref != imageName {
+			if err = ds.c.ImageTag(ctx, source.ref, imageName); err != nil {
+				errs = append(errs, fmt.Sprintf("tag %s: %v", source.ref, err))
+				continue
+			}
+			ds.c.ImageRemove(context.TODO(), source.ref, image.RemoveOptions{})
+		}
+		return nil
+	}
+	return fmt.Errorf("%s", strings.Join(errs, "; "))
+}
+
+// touchImageCache records a job using the image, so it is kept longer in the cache
+func (ds *DockerService) touchImageCache(imageName string) {
+	var size int64
+	if info, _, err := ds.c.ImageInspectWithRaw(context.TODO(), imageName); err == nil {
+		size = info.Size
+	}
+	if err := NewImageCacheService().TouchImageCache(imageName, size); err != nil {
+		logs.GetLogger().Errorf("failed to record the image cache, image: %s, error: %v", imageName, err)
+	}
+	if conf.GetConfig().ImageCache.MaxSize > 0 {
+		go ds.EvictImageCache()
+	}
+}
+
+// keptImages returns the images never removed from the node
+func keptImages() map[string]bool {
+	keepSet := make(map[string]bool)
+	for _, imageName := range []string{
+		build.UBITaskImageIntelCpu,
+		build.UBITaskImageIntelGpu,
+		build.UBITaskImageAmdCpu,
+		build.UBITaskImageAmdGpu,
+		build.UBIResourceExporterDockerImage,
+		build.TraefikServerDockerImage,
+		build.BuildkitRootlessDockerImage,
+	} {
+		keepSet[imageName] = true
+	}
+
+	if builderImage := conf.GetConfig().Build.Image; builderImage != "" {
+		keepSet[builderImage] = true
+	}
+
+	cacheConfig := conf.GetConfig().ImageCache
+	for _, imageName := range append(cacheConfig.PinnedImages, cacheConfig.PrewarmImages...) {
+		keepSet[imageName] = true
+		if named, err := reference.ParseNormalizedNamed(imageName); err == nil {
+			keepSet[reference.FamiliarString(reference.TagNameOnly(named))] = true
+		}
+	}
+
+	if cacheConfig.PinUseCount > 0 {
+		images, err := NewImageCacheService().GetImageCaches()
+		if err != nil {
+			logs.GetLogger().Errorf("failed to get the image cache, error: %v", err)
+		}
+		for _, img := range images {
+			if img.UseCount >= cacheConfig.PinUseCount {
+				keepSet[img.Image] = true
+			}
+		}
+	}
+	return keepSet
+}
+
+// EvictImageCache removes the least recently used images that no container uses,
+// until the images on the node fit in ImageCache.MaxSize.
+func (ds *DockerService) EvictImageCache() {
+	imageCacheLock.Lock()
+	defer imageCacheLock.Unlock()
+
+	budget := int64(conf.GetConfig().ImageCache.MaxSize * 1024 * 1024 * 1024)
+	ctx := context.Background()
+	allImages, err := ds.c.ImageList(ctx, image.ListOptions{})
+	if err != nil {
+		logs.GetLogger().Errorf("Failed get image list, error: %+v", err)
+		return
+	}
+	containers, err := ds.c.ContainerList(ctx, container.ListOptions{All: true})
+	if err != nil {
+		logs.GetLogger().Errorf("Failed get container list, error: %+v", err)
+		return
+	}
+	inUse := make(map[string]bool)
+	for _, c := range containers {
+		inUse[c.ImageID] = true
+	}
+
+	lastUsed := make(map[string]int64)
+	if images, err := NewImageCacheService().GetImageCaches(); err == nil {
+		for _, img := range images {
+			lastUsed[img.Image] = img.LastUsedTime
+		}
+	}
+	keepSet := keptImages()
+
+	type candidate struct {
+		summary  image.Summary
+		lastUsed int64
+	}
+	var total int64
+	var candidates []candidate
+	for _, img := range allImages {
+		total += img.Size
+		if inUse[img.ID] {
+			continue
+		}
+		used := img.Created
+		var kept bool
+		for _, tag := range img.RepoTags {
+			if keepSet[tag] {
+				kept = true
+				break
+			}
+			if t, ok := lastUsed[tag]; ok && t > used {
+				used = t
+			}
+		}
+		if !kept {
+			candidates = append(candidates, candidate{summary: img, lastUsed: used})
+		}
+	}
+	if total <= budget {
+		return
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].lastUsed < candidates[j].lastUsed
+	})
+	for _, c := range candidates {
+		if total <= budget {
+			break
+		}
+		if _, err = ds.c.ImageRemove(ctx, c.summary.ID, image.RemoveOptions{Force: true, PruneChildren: true}); err != nil {
+			logs.GetLogger().Warnf("failed to remove image %v, error: %v", c.summary.RepoTags, err)
+			continue
+		}
+		total -= c.summary.Size
+		logs.GetLogger().Infof("removed image %v from the image cache, last used at %s", c.summary.RepoTags,
+			time.Unix(c.lastUsed, 0).Format(time.DateTime))
+		for _, tag := range c.summary.RepoTags {
+			NewImageCacheService().DeleteImageCache(tag)
+		}
+	}
+}
+
+// PrewarmImages pulls the images of ImageCache.PrewarmImages, so the first jobs using them start without pulling
+func PrewarmImages() {
+	images := conf.GetConfig().ImageCache.PrewarmImages
+	if len(images) == 0 {
+		return
+	}
+	go func() {
+		dockerService := NewDockerService()
+		for _, imageName := range images {
+			start := time.Now()
+			if err := dockerService.PullImage(imageName); err != nil {
+				logs.GetLogger().Errorf("failed to pre-warm image %s, error: %v", imageName, err)
+				continue
+			}
+			logs.GetLogger().Infof("pre-warmed image %s in %s", imageName, time.Since(start).Round(time.Second))
+		}
+	}()
+}
@@ -0,0 +1,26 @@
+package computing
+
+import (
+	"testing"
+
+	"github.com/swanchain/go-computing-provider/conf"
+)
+
+func TestAlwaysPull(t *testing.T) {
+	digested := "nginx@sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	tests := []struct {
+		pullPolicy string
+		image      string
+		pull       bool
+	}{
+		{conf.PullIfNotPresent, "nginx:1.27", false},
+		{conf.PullAlways, "nginx:1.27", true},
+		{conf.PullAlways, "ghcr.io/org/app", true},
+		{conf.PullAlways, digested, false},
+	}
+	for _, tt := range tests {
+		if got := alwaysPull(tt.pullPolicy, tt.image); got != tt.pull {
+			t.Errorf("%s %s: expected %v, got %v", tt.pullPolicy, tt.image, tt.pull, got)
+		}
+	}
+}
@@ -0,0 +1,478 @@
+package computing
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/distribution/reference"
+	"github.com/swanchain/go-computing-provider/conf"
+	yaml2 "gopkg.in/yaml.v2"
+)
+
+const (
+	cosignVerifyTimeout = 2 * time.Minute
+
+	registryManifestAccept = "application/vnd.docker.distribution.manifest.list.v2+json," +
+		"application/vnd.oci.image.index.v1+json," +
+		"application/vnd.docker.distribution.manifest.v2+json," +
+		"application/vnd.oci.image.manifest.v1+json"
+)
+
+var registryHttpClient = &http.Client{Timeout: 30 * time.Second}
+
+// ImagePolicyError is the rejection of an image by the ImagePolicy of the config
+type ImagePolicyError struct {
+	Image  string
+	Reason string
+}
+
+func (e *ImagePolicyError) Error() string {
+	return fmt.Sprintf("image %s is not allowed: %s", e.Image, e.Reason)
+}
+
+func imagePolicyEnabled() bool {
+	policy := conf.GetConfig().ImagePolicy
+	return len(policy.AllowList) > 0 || len(policy.DenyList) > 0 || policy.MaxImageSize > 0 ||
+		policy.RequireDigest || len(policy.CosignKeys) > 0
+}
+
+// checkImagePolicy checks the images of a job against the ImagePolicy, the first rejection is returned as *ImagePolicyError.
+// An image checked in its registry, for the size or the cosign signature, is pinned to the digest of the manifest checked,
+// the pinned references of the images are returned by their names, the job must run them instead of the names.
+func checkImagePolicy(images ...string) (map[string]string, error) {
+	pinned := make(map[string]string)
+	if !imagePolicyEnabled() {
+		return pinned, nil
+	}
+	policy := conf.GetConfig().ImagePolicy
+
+	for _, imageName := range images {
+		imageName = strings.TrimSpace(imageName)
+		named, err := reference.ParseNormalizedNamed(imageName)
+		if err != nil {
+			return nil, &ImagePolicyError{Image: imageName, Reason: fmt.Sprintf("invalid image reference, %v", err)}
+		}
+
+		for _, pattern := range policy.DenyList {
+			if matchImagePattern(pattern, named) {
+				return nil, &ImagePolicyError{Image: imageName, Reason: fmt.Sprintf("denied by %s", pattern)}
+			}
+		}
+		if len(policy.AllowList) > 0 {
+			var allowed bool
+			for _, pattern := range policy.AllowList {
+				if matchImagePattern(pattern, named) {
+					allowed = true
+					break
+				}
+			}
+			if !allowed {
+				return nil, &ImagePolicyError{Image: imageName, Reason: "not in the allowlist"}
+			}
+		}
+
+		if _, ok := named.(reference.Digested); policy.RequireDigest && !ok {
+			return nil, &ImagePolicyError{Image: imageName, Reason: "not pinned by digest"}
+		}
+
+		if policy.MaxImageSize <= 0 && len(policy.CosignKeys) == 0 {
+			continue
+		}
+		img, err := inspectRegistryImage(named)
+		if err != nil {
+			return nil, &ImagePolicyError{Image: imageName, Reason: fmt.Sprintf("failed to get the image from its registry, %v", err)}
+		}
+
+		if policy.MaxImageSize > 0 {
+			if maxSize := int64(policy.MaxImageSize * 1024 * 1024 * 1024); img.size > maxSize {
+				return nil, &ImagePolicyError{Image: imageName, Reason: fmt.Sprintf("the size %s exceeds %s",
+					BytesToHumanReadable(img.size), BytesToHumanReadable(maxSize))}
+			}
+		}
+
+		if len(policy.CosignKeys) > 0 {
+			if err = verifyCosignSignature(img.ref.String()); err != nil {
+				return nil, &ImagePolicyError{Image: imageName, Reason: err.Error()}
+			}
+		}
+		pinned[imageName] = reference.FamiliarString(img.ref)
+	}
+	return pinned, nil
+}
+
+// pinImage returns the pinned reference of the image, or the image when it is not pinned
+func pinImage(pinned map[string]string, imageName string) string {
+	if ref, ok := pinned[strings.TrimSpace(imageName)]; ok {
+		return ref
+	}
+	return imageName
+}
+
+// checkDockerfilePolicy checks the FROM images of the Dockerfile against the ImagePolicy, and pins them in the Dockerfile
+func checkDockerfilePolicy(dockerfilePath string) error {
+	if !imagePolicyEnabled() {
+		return nil
+	}
+	content, err := os.ReadFile(dockerfilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read Dockerfile, path: %s, error: %v", dockerfilePath, err)
+	}
+	images, err := dockerfileBaseImages(string(content))
+	if err != nil {
+		return err
+	}
+	pinned, err := checkImagePolicy(images...)
+	if err != nil || len(pinned) == 0 {
+		return err
+	}
+	if err = os.WriteFile(dockerfilePath, []byte(pinDockerfileImages(string(content), pinned)), 0644); err != nil {
+		return fmt.Errorf("failed to pin the images of Dockerfile, path: %s, error: %v", dockerfilePath, err)
+	}
+	return nil
+}
+
+// pinDockerfileImages replaces the images of the FROM instructions with their pinned references
+func pinDockerfileImages(content string, pinned map[string]string) string {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !strings.EqualFold(fields[0], "FROM") {
+			continue
+		}
+		for j, f := range fields[1:] {
+			if strings.HasPrefix(f, "--") {
+				continue
+			}
+			if ref, ok := pinned[f]; ok {
+				fields[j+1] = ref
+				lines[i] = strings.Join(fields, " ")
+			}
+			break
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// pinYamlImages replaces the images of the services of the yaml with their pinned references, keeping the other keys
+func pinYamlImages(content string, pinned map[string]string) (string, error) {
+	var doc yaml2.MapSlice
+	if err := yaml2.Unmarshal([]byte(content), &doc); err != nil {
+		return "", fmt.Errorf("failed to parse yaml content, error: %v", err)
+	}
+	var pin func(node interface{}) interface{}
+	pin = func(node interface{}) interface{} {
+		switch v := node.(type) {
+		case yaml2.MapSlice:
+			for i, item := range v {
+				if imageName, ok := item.Value.(string); ok && item.Key == "image" {
+					v[i].Value = pinImage(pinned, imageName)
+				} else {
+					v[i].Value = pin(item.Value)
+				}
+			}
+		case []interface{}:
+			for i, item := range v {
+				v[i] = pin(item)
+			}
+		}
+		return node
+	}
+	out, err := yaml2.Marshal(pin(doc))
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// dockerfileBaseImages returns the images of the FROM instructions, except scratch and the earlier build stages
+func dockerfileBaseImages(content string) ([]string, error) {
+	var images []string
+	var stages = make(map[string]bool)
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || !strings.EqualFold(fields[0], "FROM") {
+			continue
+		}
+		var args []string
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "--") {
+				args = append(args, f)
+			}
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		imageName := args[0]
+		switch {
+		case strings.EqualFold(imageName, "scratch"), stages[strings.ToLower(imageName)]:
+		case strings.Contains(imageName, "$"):
+			return nil, &ImagePolicyError{Image: imageName, Reason: "the FROM image can not come from a build argument"}
+		default:
+			images = append(images, imageName)
+		}
+		if len(args) >= 3 && strings.EqualFold(args[1], "AS") {
+			stages[strings.ToLower(args[2])] = true
+		}
+	}
+	return images, scanner.Err()
+}
+
+// matchImagePattern matches a registry ("ghcr.io"), a repository ("nginx", "ghcr.io/org/app"),
+// a repository prefix ("ghcr.io/org/*") or a glob ("docker.io/library/python*")
+func matchImagePattern(pattern string, named reference.Named) bool {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		return false
+	}
+	name := named.Name()
+
+	if strings.HasSuffix(pattern, "/*") {
+		return strings.HasPrefix(name, strings.TrimSuffix(pattern, "*"))
+	}
+	if strings.Contains(pattern, "*") {
+		matched, _ := path.Match(pattern, name)
+		return matched
+	}
+	if normalizeRegistry(pattern) == reference.Domain(named) {
+		return true
+	}
+	if patternNamed, err := reference.ParseNormalizedNamed(pattern); err == nil {
+		return patternNamed.Name() == name
+	}
+	return false
+}
+
+func verifyCosignSignature(imageName string) error {
+	policy := conf.GetConfig().ImagePolicy
+	cosign := policy.CosignPath
+	if cosign == "" {
+		cosign = "cosign"
+	}
+
+	var errs []string
+	for _, key := range policy.CosignKeys {
+		ctx, cancel := context.WithTimeout(context.Background(), cosignVerifyTimeout)
+		output, err := exec.CommandContext(ctx, cosign, "verify", "--key", key, imageName).CombinedOutput()
+		cancel()
+		if err == nil {
+			return nil
+		}
+		msg := strings.TrimSpace(string(output))
+		if i := strings.LastIndex(msg, "\n"); i >= 0 {
+			msg = msg[i+1:]
+		}
+		if msg == "" {
+			msg = err.Error()
+		}
+		errs = append(errs, fmt.Sprintf("%s: %s", key, msg))
+	}
+	return fmt.Errorf("no valid cosign signature, %s", strings.Join(errs, "; "))
+}
+
+type registryManifest struct {
+	Config struct {
+		Size int64 `json:"size"`
+	} `json:"config"`
+	Layers []struct {
+		Size int64 `json:"size"`
+	} `json:"layers"`
+	Manifests []struct {
+		Digest   string `json:"digest"`
+		Platform struct {
+			Architecture string `json:"architecture"`
+			OS           string `json:"os"`
+		} `json:"platform"`
+	} `json:"manifests"`
+}
+
+// registryImage is an image resolved in its registry
+type registryImage struct {
+	ref  reference.Canonical // the repository at the digest of the manifest
+	size int64               // the compressed size for the platform of the node
+}
+
+// inspectRegistryImage resolves the tag of the image to the digest of its manifest, and sums its size for the platform of the node
+func inspectRegistryImage(named reference.Named) (*registryImage, error) {
+	named = reference.TagNameOnly(named)
+	var ref string
+	if digested, ok := named.(reference.Digested); ok {
+		ref = digested.Digest().String()
+	} else if tagged, ok := named.(reference.Tagged); ok {
+		ref = tagged.Tag()
+	}
+
+	domain := reference.Domain(named)
+	host := domain
+	if domain == defaultRegistry {
+		host = "registry-1.docker.io"
+	}
+	client := &registryClient{
+		host: host,
+		repo: reference.Path(named),
+		auth: findRegistryAuth(domain),
+	}
+
+	manifest, manifestDigest, err := client.manifest(ref)
+	if err != nil {
+		return nil, err
+	}
+	if digested, ok := named.(reference.Digested); ok && digested.Digest().String() != manifestDigest {
+		return nil, fmt.Errorf("the registry served the manifest %s for %s", manifestDigest, digested.Digest())
+	}
+	pinned, err := reference.ParseNormalizedNamed(named.Name() + "@" + manifestDigest)
+	if err != nil {
+		return nil, err
+	}
+	canonical, ok := pinned.(reference.Canonical)
+	if !ok {
+		return nil, fmt.Errorf("invalid digest %s", manifestDigest)
+	}
+
+	if len(manifest.Manifests) > 0 {
+		digest := manifest.Manifests[0].Digest
+		for _, m := range manifest.Manifests {
+			if m.Platform.OS == "linux" && m.Platform.Architecture == runtime.GOARCH {
+				digest = m.Digest
+				break
+			}
+		}
+		if manifest, _, err = client.manifest(digest); err != nil {
+			return nil, err
+		}
+	}
+
+	size := manifest.Config.Size
+	for _, layer := range manifest.Layers {
+		size += layer.Size
+	}
+	return &registryImage{ref: canonical, size: size}, nil
+}
+
+// registryClient reads the manifests of a repository over the registry v2 api
+type registryClient struct {
+	host  string
+	repo  string
+	auth  conf.RegistryAuth
+	token string
+}
+
+// manifest returns the manifest of the reference, and the digest of its content
+func (rc *registryClient) manifest(ref string) (*registryManifest, string, error) {
+	manifestUrl := fmt.Sprintf("https://%s/v2/%s/manifests/%s", rc.host, rc.repo, ref)
+	for attempt := 0; attempt < 2; attempt++ {
+		req, err := http.NewRequest(http.MethodGet, manifestUrl, nil)
+		if err != nil {
+			return nil, "", err
+		}
+		req.Header.Set("Accept", registryManifestAccept)
+		if rc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+rc.token)
+		} else if rc.auth.UserName != "" {
+			req.SetBasicAuth(rc.auth.UserName, rc.auth.Password)
+		}
+
+		resp, err := registryHttpClient.Do(req)
+		if err != nil {
+			return nil, "", err
+		}
+		if resp.StatusCode == http.StatusUnauthorized && rc.token == "" {
+			challenge := resp.Header.Get("WWW-Authenticate")
+			resp.Body.Close()
+			if err = rc.fetchToken(challenge); err != nil {
+				return nil, "", err
+			}
+			continue
+		}
+
+		var manifest registryManifest
+		var digest string
+		err = func() error {
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return fmt.Errorf("get manifest %s:%s, status: %s", rc.repo, ref, resp.Status)
+			}
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				return err
+			}
+			// the digest of the content served, not the digest the registry claims
+			digest = fmt.Sprintf("sha256:%x", sha256.Sum256(body))
+			return json.Unmarshal(body, &manifest)
+		}()
+		return &manifest, digest, err
+	}
+	return nil, "", fmt.Errorf("get manifest %s:%s, unauthorized", rc.repo, ref)
+}
+
+var challengeParamRegexp = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// fetchToken gets the bearer token asked by the challenge of the registry
+func (rc *registryClient) fetchToken(challenge string) error {
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+		return fmt.Errorf("get manifest %s, unauthorized", rc.repo)
+	}
+	params := make(map[string]string)
+	for _, m := range challengeParamRegexp.FindAllStringSubmatch(challenge, -1) {
+		params[strings.ToLower(m[1])] = m[2]
+	}
+	if params["realm"] == "" {
+		return fmt.Errorf("no realm in the challenge of %s", rc.host)
+	}
+
+	query := url.Values{}
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = fmt.Sprintf("repository:%s:pull", rc.repo)
+	}
+	query.Set("scope", scope)
+
+	req, err := http.NewRequest(http.MethodGet, params["realm"]+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	if rc.auth.UserName != "" {
+		req.SetBasicAuth(rc.auth.UserName, rc.auth.Password)
+	}
+	resp, err := registryHttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("get token of %s, status: %s", rc.host, resp.Status)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return err
+	}
+	rc.token = token.Token
+	if rc.token == "" {
+		rc.token = token.AccessToken
+	}
+	if rc.token == "" {
+		return fmt.Errorf("empty token of %s", rc.host)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package computing
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPinDockerfileImages(t *testing.T) {
+	content := "FROM --platform=linux/amd64 python:3.11 AS base\nRUN pip install torch\nFROM base\nFROM nginx\n"
+	pinned := map[string]string{"python:3.11": "python@sha256:0a", "nginx": "nginx@sha256:0b"}
+
+	got := pinDockerfileImages(content, pinned)
+	want := "FROM --platform=linux/amd64 python@sha256:0a AS base\nRUN pip install torch\nFROM base\nFROM nginx@sha256:0b\n"
+	if got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestPinYamlImages(t *testing.T) {
+	content := "version: \"2.0\"\nservices:\n  image: python:3.11\n  expose_port:\n  - 8080\n  sidecars:\n  - image: nginx\n    name: proxy\n"
+	pinned := map[string]string{"python:3.11": "python@sha256:0a", "nginx": "nginx@sha256:0b"}
+
+	got, err := pinYamlImages(content, pinned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "version: \"2.0\"\nservices:\n  image: python@sha256:0a\n  expose_port:\n  - 8080\n  sidecars:\n  - image: nginx@sha256:0b\n    name: proxy\n"
+	if got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+	yamlStruct, err := handlerYamlStr(got)
+	if err != nil || yamlStruct.Services.Image != "python@sha256:0a" {
+		t.Errorf("expected the service to run the pinned image, got %+v, %v", yamlStruct, err)
+	}
+}
+
+func TestRegistryManifestDigest(t *testing.T) {
+	body := `{"config":{"size":10},"layers":[{"size":20},{"size":30}]}`
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/org/app/manifests/v1" {
+			http.NotFound(w, r)
+			return
+		}
+		// the digest the registry claims is not trusted
+		w.Header().Set("Docker-Content-Digest", "sha256:00")
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	client := registryHttpClient
+	registryHttpClient = srv.Client()
+	defer func() { registryHttpClient = client }()
+
+	rc := &registryClient{host: strings.TrimPrefix(srv.URL, "https://"), repo: "org/app"}
+	manifest, digest, err := rc.manifest("v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(body))); digest != want {
+		t.Errorf("expected the digest of the content %s, got %s", want, digest)
+	}
+	if manifest.Config.Size != 10 || len(manifest.Layers) != 2 {
+		t.Errorf("unexpected manifest: %+v", manifest)
+	}
+}
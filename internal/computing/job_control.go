@@ -0,0 +1,461 @@
+package computing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/gin-gonic/gin"
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/constants"
+	"github.com/swanchain/go-computing-provider/internal/contract"
+	"github.com/swanchain/go-computing-provider/internal/models"
+	"github.com/swanchain/go-computing-provider/util"
+	appV1 "k8s.io/api/apps/v1"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// pausedReplicasAnnotation keeps the replicas of a paused deployment, so that resume can restore them
+const pausedReplicasAnnotation = "computing-provider/paused-replicas"
+
+// maxJobPausedDuration is the most paused time that extends a job, a job paused longer expires and is cleaned up
+const maxJobPausedDuration = int64(24 * time.Hour / time.Second)
+
+// PauseJob scales the deployment of a running job to zero, keeping its namespace, service and ingress.
+// The paused time is not counted towards the duration of the job, up to maxJobPausedDuration.
+func PauseJob(c *gin.Context) {
+	var req models.JobControlReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, util.CreateErrorResponse(util.JsonError))
+		return
+	}
+	logs.GetLogger().Infof("pause job received: %+v", req)
+
+	jobEntity, ok := getControlledJob(c, req.TaskUuid, req.NodeIdTaskUuidSignature)
+	if !ok {
+		return
+	}
+	if jobEntity.Status != models.JOB_RUNNING_STATUS {
+		c.JSON(http.StatusBadRequest, util.CreateErrorResponse(util.JobStateError, fmt.Sprintf("only running job can be paused, current status: %s", models.GetJobStatus(jobEntity.Status))))
+		return
+	}
+
+	k8sService := NewK8sService()
+	deployment, err := k8sService.GetDeployment(context.TODO(), jobNameSpace(jobEntity), jobDeployName(jobEntity))
+	if err != nil {
+		logs.GetLogger().Errorf("failed to get deployment, task_uuid: %s, error: %v", req.TaskUuid, err)
+		c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.UpdateDeploymentError))
+		return
+	}
+
+	var replicas int32 = 1
+	if deployment.Spec.Replicas != nil && *deployment.Spec.Replicas > 0 {
+		replicas = *deployment.Spec.Replicas
+	}
+	if deployment.Annotations == nil {
+		deployment.Annotations = make(map[string]string)
+	}
+	deployment.Annotations[pausedReplicasAnnotation] = strconv.Itoa(int(replicas))
+	var zero int32
+	deployment.Spec.Replicas = &zero
+	if _, err = k8sService.UpdateDeployment(context.TODO(), deployment.Namespace, deployment); err != nil {
+		logs.GetLogger().Errorf("failed to scale deployment to zero, task_uuid: %s, error: %v", req.TaskUuid, err)
+		c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.UpdateDeploymentError))
+		return
+	}
+
+	pausedAt := time.Now().Unix()
+	if err = NewJobService().UpdateJobPauseByJobUuid(jobEntity.JobUuid, models.JOB_PAUSED_STATUS, pausedAt, jobEntity.PausedDuration); err != nil {
+		logs.GetLogger().Errorf("failed to save paused job, task_uuid: %s, error: %v", req.TaskUuid, err)
+		c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.SaveJobEntityError))
+		return
+	}
+	if err = SyncNamespaceQuota(jobEntity.WalletAddress); err != nil {
+		logs.GetLogger().Errorf("failed to sync namespace quota, task_uuid: %s, error: %v", req.TaskUuid, err)
+	}
+
+	PublishJobEvent(models.JobEvent{
+		JobUuid: jobEntity.JobUuid,
+		Reason:  "Paused",
+		Object:  "Deployment/" + deployment.Name,
+		Message: fmt.Sprintf("job paused, deployment %s scaled from %d to 0", deployment.Name, replicas),
+	})
+	c.JSON(http.StatusOK, util.CreateSuccessResponse("success"))
+}
+
+// ResumeJob restores the replicas of a paused job, and extends the job by the time it was paused.
+func ResumeJob(c *gin.Context) {
+	var req models.JobControlReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, util.CreateErrorResponse(util.JsonError))
+		return
+	}
+	logs.GetLogger().Infof("resume job received: %+v", req)
+
+	jobEntity, ok := getControlledJob(c, req.TaskUuid, req.NodeIdTaskUuidSignature)
+	if !ok {
+		return
+	}
+	if jobEntity.Status != models.JOB_PAUSED_STATUS {
+		c.JSON(http.StatusBadRequest, util.CreateErrorResponse(util.JobStateError, fmt.Sprintf("only paused job can be resumed, current status: %s", models.GetJobStatus(jobEntity.Status))))
+		return
+	}
+
+	k8sService := NewK8sService()
+	deployment, err := k8sService.GetDeployment(context.TODO(), jobNameSpace(jobEntity), jobDeployName(jobEntity))
+	if err != nil {
+		logs.GetLogger().Errorf("failed to get deployment, task_uuid: %s, error: %v", req.TaskUuid, err)
+		c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.UpdateDeploymentError))
+		return
+	}
+
+	// the job has to be active again before the quota is synced, otherwise the pods are rejected by the quota
+	pausedDuration := jobPausedDuration(jobEntity, time.Now().Unix())
+	if err = NewJobService().UpdateJobPauseByJobUuid(jobEntity.JobUuid, models.JOB_DEPLOY_STATUS, 0, pausedDuration); err != nil {
+		logs.GetLogger().Errorf("failed to save resumed job, task_uuid: %s, error: %v", req.TaskUuid, err)
+		c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.SaveJobEntityError))
+		return
+	}
+	if err = SyncNamespaceQuota(jobEntity.WalletAddress); err != nil {
+		logs.GetLogger().Errorf("failed to sync namespace quota, task_uuid: %s, error: %v", req.TaskUuid, err)
+	}
+
+	var replicas int32 = 1
+	if n, err := strconv.Atoi(deployment.Annotations[pausedReplicasAnnotation]); err == nil && n > 0 {
+		replicas = int32(n)
+	}
+	delete(deployment.Annotations, pausedReplicasAnnotation)
+	deployment.Spec.Replicas = &replicas
+	if _, err = k8sService.UpdateDeployment(context.TODO(), deployment.Namespace, deployment); err != nil {
+		logs.GetLogger().Errorf("failed to scale deployment, task_uuid: %s, error: %v", req.TaskUuid, err)
+		NewJobService().UpdateJobPauseByJobUuid(jobEntity.JobUuid, models.JOB_PAUSED_STATUS, jobEntity.PausedAt, jobEntity.PausedDuration)
+		c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.UpdateDeploymentError))
+		return
+	}
+
+	PublishJobEvent(models.JobEvent{
+		JobUuid: jobEntity.JobUuid,
+		Reason:  "Resumed",
+		Object:  "Deployment/" + deployment.Name,
+		Message: fmt.Sprintf("job resumed after %d seconds, deployment %s scaled to %d", time.Now().Unix()-jobEntity.PausedAt, deployment.Name, replicas),
+	})
+	c.JSON(http.StatusOK, util.CreateSuccessResponse("success"))
+}
+
+// ResizeJob changes the cpu, memory, storage and gpu of a running or paused job in place.
+// The new hardware is re-quoted for the remaining duration of the job.
+func ResizeJob(c *gin.Context) {
+	var req models.JobResizeReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, util.CreateErrorResponse(util.JsonError))
+		return
+	}
+	logs.GetLogger().Infof("resize job received: %+v", req)
+
+	if req.Cpu <= 0 || req.Memory <= 0 || req.Storage < 0 || req.Gpu < 0 {
+		c.JSON(http.StatusBadRequest, util.CreateErrorResponse(util.BadParamError, "cpu and memory must be greater than 0"))
+		return
+	}
+
+	jobEntity, ok := getControlledJob(c, req.TaskUuid, req.NodeIdTaskUuidSignature)
+	if !ok {
+		return
+	}
+	if jobEntity.Status != models.JOB_RUNNING_STATUS && jobEntity.Status != models.JOB_PAUSED_STATUS {
+		c.JSON(http.StatusBadRequest, util.CreateErrorResponse(util.JobStateError, fmt.Sprintf("only running or paused job can be resized, current status: %s", models.GetJobStatus(jobEntity.Status))))
+		return
+	}
+
+	k8sService := NewK8sService()
+	deployment, err := k8sService.GetDeployment(context.TODO(), jobNameSpace(jobEntity), jobDeployName(jobEntity))
+	if err != nil {
+		logs.GetLogger().Errorf("failed to get deployment, task_uuid: %s, error: %v", req.TaskUuid, err)
+		c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.UpdateDeploymentError))
+		return
+	}
+
+	template := deployment.Spec.Template
+	var gpuProductName string
+	for _, g := range gpuInPod(&coreV1.Pod{ObjectMeta: template.ObjectMeta, Spec: template.Spec}) {
+		if g.Gname != "" && g.Gname != coreV1.LabelHostname {
+			gpuProductName = g.Gname
+		}
+	}
+	nodeName := nodeNameOfPodSpec(template.Spec)
+	if nodeName == "" {
+		nodeName = runningNodeOfDeployment(deployment)
+	}
+	if req.Gpu > 0 && gpuProductName == "" {
+		c.JSON(http.StatusBadRequest, util.CreateErrorResponse(util.BadParamError, "gpu can not be added to a cpu job"))
+		return
+	}
+
+	newStorage := req.Storage << 30
+	if req.Storage == 0 {
+		newStorage = jobEntity.Storage
+	}
+	hardware := models.SpaceHardware{
+		Hardware: gpuProductName,
+		Vcpu:     req.Cpu,
+		Memory:   req.Memory << 30,
+		Storage:  newStorage,
+		Gpu:      req.Gpu,
+	}
+
+	leftTime := GetJobExpireTime(jobEntity) - time.Now().Unix()
+	if leftTime <= 0 {
+		c.JSON(http.StatusBadRequest, util.CreateErrorResponse(util.JobStateError, "The job was terminated due to its expiration date"))
+		return
+	}
+
+	var totalCost float64
+	if !conf.GetConfig().API.Pricing {
+		var checkPriceFlag bool
+		checkPriceFlag, totalCost, err = checkPrice(req.BidPrice, int(leftTime), hardware)
+		if err != nil {
+			logs.GetLogger().Errorf("failed to check price, task_uuid: %s, error: %v", req.TaskUuid, err)
+			c.JSON(http.StatusBadRequest, util.CreateErrorResponse(util.CheckPriceError))
+			return
+		}
+		if !checkPriceFlag {
+			logs.GetLogger().Warnf("the price is too low, task_uuid: %s, paid: %s, required: %0.4f", req.TaskUuid, req.BidPrice, totalCost)
+			c.JSON(http.StatusBadRequest, util.CreateErrorResponse(util.BelowPriceError))
+			return
+		}
+	}
+
+	// only the increased part has to be available on the cluster
+	var newGpuIndex []string
+	delta := models.SpaceHardware{
+		Hardware: gpuProductName,
+		Vcpu:     max(req.Cpu-jobEntity.Cpu, 0),
+		Memory:   max(req.Memory-jobEntity.Memory>>30, 0),
+		Storage:  max((newStorage-jobEntity.Storage)>>30, 0),
+		Gpu:      max(req.Gpu-jobEntity.Gpu, 0),
+	}
+	if delta.Vcpu > 0 || delta.Memory > 0 || delta.Storage > 0 || delta.Gpu > 0 {
+		if delta.Gpu > 0 {
+			delta.HardwareType = "GPU"
+		}
+		// the gpus and the rest of the job stay on the node the job is running on
+		scheduleReq, _ := spaceScheduleRequest(1, delta)
+		scheduleReq.NodeName = nodeName
+		placement, noAvailableMsgs, err := GetScheduler().Schedule(jobEntity.JobUuid, scheduleReq)
+		if err != nil {
+			logs.GetLogger().Errorf("failed to check job resource, task_uuid: %s, error: %v", req.TaskUuid, err)
+			c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.CheckResourcesError))
+			return
+		}
+		if placement == nil {
+			logs.GetLogger().Warnf("task_uuid: %s, not enough resources to resize, reason: %s", req.TaskUuid, strings.Join(noAvailableMsgs, ";"))
+			c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.NoAvailableResourcesError, noAvailableMsgs...))
+			return
+		}
+		nodeName = placement.NodeName
+		newGpuIndex = placement.GpuIndex
+	}
+
+	resizeDeployment(deployment, hardware, nodeName, newGpuIndex)
+
+	oldJob := jobEntity
+	if err = NewJobService().UpdateJobResourceByJobUuid(jobEntity.JobUuid, hardware.Vcpu, hardware.Memory, hardware.Storage, hardware.Gpu); err != nil {
+		GetScheduler().Release(jobEntity.JobUuid)
+		logs.GetLogger().Errorf("failed to save job resource, task_uuid: %s, error: %v", req.TaskUuid, err)
+		c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.SaveJobEntityError))
+		return
+	}
+	if err = SyncNamespaceQuota(jobEntity.WalletAddress); err != nil {
+		logs.GetLogger().Errorf("failed to sync namespace quota, task_uuid: %s, error: %v", req.TaskUuid, err)
+	}
+
+	if _, err = k8sService.UpdateDeployment(context.TODO(), deployment.Namespace, deployment); err != nil {
+		GetScheduler().Release(jobEntity.JobUuid)
+		logs.GetLogger().Errorf("failed to resize deployment, task_uuid: %s, error: %v", req.TaskUuid, err)
+		NewJobService().UpdateJobResourceByJobUuid(oldJob.JobUuid, oldJob.Cpu, oldJob.Memory, oldJob.Storage, oldJob.Gpu)
+		SyncNamespaceQuota(oldJob.WalletAddress)
+		c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.UpdateDeploymentError))
+		return
+	}
+
+	PublishJobEvent(models.JobEvent{
+		JobUuid: jobEntity.JobUuid,
+		Reason:  "Resized",
+		Object:  "Deployment/" + deployment.Name,
+		Message: fmt.Sprintf("job resized from cpu: %d, memory: %s, gpu: %d to cpu: %d, memory: %s, gpu: %d", oldJob.Cpu,
+			BytesToHumanReadable(oldJob.Memory), oldJob.Gpu, hardware.Vcpu, BytesToHumanReadable(hardware.Memory), hardware.Gpu),
+	})
+	c.JSON(http.StatusOK, util.CreateSuccessResponse(models.JobResizeResp{
+		TaskUuid:   req.TaskUuid,
+		Price:      totalCost,
+		ExpireTime: GetJobExpireTime(jobEntity),
+	}))
+}
+
+// resizeDeployment sets the new hardware on the job container of the deployment and keeps the pod on its node.
+// The pod is recreated instead of rolled, as the new pod could not fit in the namespace quota or get the gpu while
+// the old one is still running.
+func resizeDeployment(deployment *appV1.Deployment, hardware models.SpaceHardware, nodeName string, newGpuIndex []string) {
+	d := &Deploy{}
+	d.hardwareResource.Cpu.Quantity = hardware.Vcpu
+	d.hardwareResource.Memory.Quantity = hardware.Memory
+	d.hardwareResource.Storage.Quantity = hardware.Storage
+	d.hardwareResource.Gpu.Quantity = hardware.Gpu
+	resources := d.createResources()
+
+	containers := deployment.Spec.Template.Spec.Containers
+	index := 0
+	for i, container := range containers {
+		if strings.HasPrefix(container.Name, constants.K8S_CONTAINER_NAME_PREFIX) || strings.HasPrefix(container.Name, constants.K8S_PRIVATE_CONTAINER_PREFIX) {
+			index = i
+			break
+		}
+	}
+	containers[index].Resources = resources
+
+	for i, env := range containers[index].Env {
+		if env.Name != "NVIDIA_VISIBLE_DEVICES" {
+			continue
+		}
+		var gpuIndex []string
+		if env.Value != "" {
+			gpuIndex = strings.Split(env.Value, ",")
+		}
+		if int64(len(gpuIndex)) > hardware.Gpu {
+			gpuIndex = gpuIndex[:hardware.Gpu]
+		}
+		for _, idx := range newGpuIndex {
+			if int64(len(gpuIndex)) >= hardware.Gpu {
+				break
+			}
+			gpuIndex = append(gpuIndex, idx)
+		}
+		containers[index].Env[i] = coreV1.EnvVar{Name: env.Name, Value: strings.Join(gpuIndex, ",")}
+
+		// the gpu annotation is what the gpus of the pod are accounted by
+		annotationKey := strings.ReplaceAll(hardware.Hardware, " ", "_")
+		if _, ok := deployment.Spec.Template.Annotations[annotationKey]; ok {
+			if len(gpuIndex) > 0 {
+				deployment.Spec.Template.Annotations[annotationKey] = strings.Join(gpuIndex, ",")
+			} else {
+				delete(deployment.Spec.Template.Annotations, annotationKey)
+			}
+		}
+	}
+
+	if nodeName != "" {
+		deployment.Spec.Template.Spec.Affinity = generateNodeAffinity(nodeName)
+	}
+	deployment.Spec.Strategy = appV1.DeploymentStrategy{Type: appV1.RecreateDeploymentStrategyType}
+}
+
+// runningNodeOfDeployment returns the node the pod of the deployment is running on, for the deployments that are
+// only selected by the gpu model label.
+func runningNodeOfDeployment(deployment *appV1.Deployment) string {
+	pods, err := NewK8sService().k8sClient.CoreV1().Pods(deployment.Namespace).List(context.TODO(), metaV1.ListOptions{
+		LabelSelector: metaV1.FormatLabelSelector(deployment.Spec.Selector),
+	})
+	if err != nil {
+		logs.GetLogger().Errorf("failed to list pods of deployment %s, error: %v", deployment.Name, err)
+		return ""
+	}
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName != "" {
+			return pod.Spec.NodeName
+		}
+	}
+	return ""
+}
+
+// getControlledJob verifies the signature of the orchestrator and returns the job of the task,
+// it writes the error response and returns false when the request can not be handled.
+func getControlledJob(c *gin.Context, taskUuid, signature string) (models.JobEntity, bool) {
+	if strings.TrimSpace(taskUuid) == "" {
+		c.JSON(http.StatusBadRequest, util.CreateErrorResponse(util.BadParamError, "missing required field: task_uuid"))
+		return models.JobEntity{}, false
+	}
+	if !verifyHubTaskSignature(c, taskUuid, signature) {
+		return models.JobEntity{}, false
+	}
+
+	jobEntity, err := NewJobService().GetJobEntityByTaskUuid(taskUuid)
+	if err != nil {
+		logs.GetLogger().Errorf("failed get job from db, taskUuid: %s, error: %+v", taskUuid, err)
+		c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.FoundJobEntityError))
+		return models.JobEntity{}, false
+	}
+	if jobEntity.JobUuid == "" {
+		c.JSON(http.StatusNotFound, util.CreateErrorResponse(util.NotFoundJobEntityError))
+		return models.JobEntity{}, false
+	}
+	return jobEntity, true
+}
+
+// verifyHubTaskSignature checks the node_id_task_uuid_signature signed by the orchestrator,
+// it writes the error response and returns false when the signature is not valid.
+func verifyHubTaskSignature(c *gin.Context, taskUuid, nodeIdAndTaskUuidSignature string) bool {
+	if len(nodeIdAndTaskUuidSignature) == 0 {
+		c.JSON(http.StatusBadRequest, util.CreateErrorResponse(util.SignatureError, "missing node_id_task_uuid_signature field"))
+		return false
+	}
+
+	if conf.GetConfig().HUB.VerifySign {
+		cpRepoPath, _ := os.LookupEnv("CP_PATH")
+		nodeID := GetNodeId(cpRepoPath)
+
+		cpAccountAddress, err := contract.GetCpAccountAddress()
+		if err != nil {
+			logs.GetLogger().Errorf("get cp account contract address failed, error: %v", err)
+			c.JSON(http.StatusBadRequest, util.CreateErrorResponse(util.GetCpAccountError))
+			return false
+		}
+
+		signature, err := verifySignatureForHub(conf.GetConfig().HUB.OrchestratorPk, fmt.Sprintf("%s%s%s", cpAccountAddress, nodeID, taskUuid), nodeIdAndTaskUuidSignature)
+		if err != nil {
+			logs.GetLogger().Errorf("verifySignature for space job failed, error: %+v", err)
+			c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.ServerError, "verify sign data failed"))
+			return false
+		}
+
+		if !signature {
+			logs.GetLogger().Errorf("space job sign verifing, task_id: %s,  verify: %v", taskUuid, signature)
+			c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.SignatureError, "signature verify failed"))
+			return false
+		}
+	}
+	return true
+}
+
+// GetJobExpireTime returns the expire time of the job, extended by the time it has been paused.
+func GetJobExpireTime(job models.JobEntity) int64 {
+	return job.ExpireTime + jobPausedDuration(job, time.Now().Unix())
+}
+
+// jobPausedDuration returns the time the job has been paused until now, including the current pause,
+// at most maxJobPausedDuration.
+func jobPausedDuration(job models.JobEntity, now int64) int64 {
+	duration := job.PausedDuration
+	if job.PausedAt > 0 {
+		duration += now - job.PausedAt
+	}
+	return min(duration, maxJobPausedDuration)
+}
+
+func jobNameSpace(job models.JobEntity) string {
+	if job.NameSpace != "" {
+		return job.NameSpace
+	}
+	return constants.K8S_NAMESPACE_NAME_PREFIX + strings.ToLower(job.WalletAddress)
+}
+
+func jobDeployName(job models.JobEntity) string {
+	if job.K8sDeployName != "" {
+		return job.K8sDeployName
+	}
+	return constants.K8S_DEPLOY_NAME_PREFIX + strings.ToLower(job.JobUuid)
+}
@@ -0,0 +1,38 @@
+package computing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/swanchain/go-computing-provider/internal/models"
+)
+
+func TestGetJobExpireTime(t *testing.T) {
+	now := time.Now().Unix()
+	expireTime := now + 3600
+
+	job := models.JobEntity{ExpireTime: expireTime, PausedDuration: 600}
+	if got := GetJobExpireTime(job); got != expireTime+600 {
+		t.Errorf("expected the expire time extended by the past pauses, got %d", got-expireTime)
+	}
+
+	// the current pause extends the job too
+	job.PausedAt = now - 1200
+	if got := GetJobExpireTime(job); got < expireTime+1800 || got > expireTime+1801 {
+		t.Errorf("expected the expire time extended by 1800s, got %d", got-expireTime)
+	}
+
+	// a job left paused stops being extended, it expires once the paused time passes the limit
+	job.PausedAt = now - 30*24*3600
+	if got := GetJobExpireTime(job); got != expireTime+maxJobPausedDuration {
+		t.Errorf("expected the expire time extended by %ds at most, got %d", maxJobPausedDuration, got-expireTime)
+	}
+	// the job was paused 30 days ago, an hour before it expired
+	job.ExpireTime = job.PausedAt + 3600
+	if got := GetJobExpireTime(job); got >= now {
+		t.Errorf("expected the job paused for 30 days to be expired, it expires in %ds", got-now)
+	}
+	if got := jobPausedDuration(models.JobEntity{PausedDuration: 2 * maxJobPausedDuration}, now); got != maxJobPausedDuration {
+		t.Errorf("expected the resumed pauses to be capped too, got %d", got)
+	}
+}
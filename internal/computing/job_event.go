@@ -0,0 +1,238 @@
+package computing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/swanchain/go-computing-provider/internal/models"
+	"github.com/swanchain/go-computing-provider/util"
+)
+
+const (
+	JobEventNormal  = "Normal"
+	JobEventWarning = "Warning"
+)
+
+const (
+	jobEventHistorySize = 100
+	jobEventHistoryTTL  = 24 * time.Hour
+	jobEventHeartbeat   = 30 * time.Second
+
+	// jobs expiring within this period get an ExpiringSoon warning from the expiry check
+	jobExpiryWarningPeriod = 30 * time.Minute
+)
+
+var jobEvents = &jobEventBus{
+	subscribers: make(map[string]map[chan models.JobEvent]struct{}),
+	history:     make(map[string]*jobEventHistory),
+}
+
+// jobEventBus fans out the lifecycle events of k8s jobs and docker containers to the subscribers of a job,
+// and keeps the latest events of every job so that a late subscriber can still see why a deployment is stuck.
+type jobEventBus struct {
+	lock        sync.RWMutex
+	subscribers map[string]map[chan models.JobEvent]struct{}
+	history     map[string]*jobEventHistory
+}
+
+type jobEventHistory struct {
+	events     []models.JobEvent
+	updateTime time.Time
+}
+
+// PublishJobEvent records the event of a job and pushes it to all subscribers of the job without blocking.
+func PublishJobEvent(event models.JobEvent) {
+	if strings.TrimSpace(event.JobUuid) == "" {
+		return
+	}
+	event.JobUuid = strings.ToLower(event.JobUuid)
+	if event.Type == "" {
+		event.Type = JobEventNormal
+	}
+	if event.Time == 0 {
+		event.Time = time.Now().Unix()
+	}
+
+	jobEvents.lock.Lock()
+	defer jobEvents.lock.Unlock()
+
+	now := time.Now()
+	history, ok := jobEvents.history[event.JobUuid]
+	if !ok {
+		jobEvents.pruneHistory(now)
+		history = &jobEventHistory{}
+		jobEvents.history[event.JobUuid] = history
+	}
+	history.events = append(history.events, event)
+	if len(history.events) > jobEventHistorySize {
+		history.events = history.events[len(history.events)-jobEventHistorySize:]
+	}
+	history.updateTime = now
+
+	for ch := range jobEvents.subscribers[event.JobUuid] {
+		select {
+		case ch <- event:
+		default:
+			// slow subscriber, drop the event rather than block the publisher
+		}
+	}
+}
+
+// SubscribeJobEvents returns the recorded events of the job and a channel of its new events.
+// The returned function must be called to release the subscription.
+func SubscribeJobEvents(jobUuid string) ([]models.JobEvent, <-chan models.JobEvent, func()) {
+	jobUuid = strings.ToLower(jobUuid)
+	ch := make(chan models.JobEvent, 64)
+
+	jobEvents.lock.Lock()
+	var recorded []models.JobEvent
+	if history, ok := jobEvents.history[jobUuid]; ok {
+		recorded = append(recorded, history.events...)
+	}
+	if _, ok := jobEvents.subscribers[jobUuid]; !ok {
+		jobEvents.subscribers[jobUuid] = make(map[chan models.JobEvent]struct{})
+	}
+	jobEvents.subscribers[jobUuid][ch] = struct{}{}
+	jobEvents.lock.Unlock()
+
+	var once sync.Once
+	return recorded, ch, func() {
+		once.Do(func() {
+			jobEvents.lock.Lock()
+			delete(jobEvents.subscribers[jobUuid], ch)
+			if len(jobEvents.subscribers[jobUuid]) == 0 {
+				delete(jobEvents.subscribers, jobUuid)
+			}
+			jobEvents.lock.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// pruneHistory drops the events of jobs that have been quiet for a long time, the caller must hold the lock.
+func (bus *jobEventBus) pruneHistory(now time.Time) {
+	for jobUuid, history := range bus.history {
+		if now.Sub(history.updateTime) > jobEventHistoryTTL {
+			delete(bus.history, jobUuid)
+		}
+	}
+}
+
+// GetJobEvents streams the lifecycle events of a k8s job or a docker container,
+// over a websocket when the request asks for an upgrade and as server-sent events otherwise.
+func GetJobEvents(c *gin.Context) {
+	jobUuid := c.Param("job_uuid")
+	if strings.TrimSpace(jobUuid) == "" {
+		c.JSON(http.StatusBadRequest, util.CreateErrorResponse(util.BadParamError, "missing required field: [job_uuid]"))
+		return
+	}
+
+	if !jobExists(jobUuid) {
+		c.JSON(http.StatusNotFound, util.CreateErrorResponse(util.NotFoundJobEntityError))
+		return
+	}
+
+	recorded, events, unsubscribe := SubscribeJobEvents(jobUuid)
+	defer unsubscribe()
+
+	if websocket.IsWebSocketUpgrade(c.Request) {
+		conn, err := upgrade.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			logs.GetLogger().Errorf("upgrading connection failed, error: %+v", err)
+			return
+		}
+		streamJobEventsByWs(conn, recorded, events)
+		return
+	}
+	streamJobEventsBySse(c, recorded, events)
+}
+
+func streamJobEventsByWs(conn *websocket.Conn, recorded []models.JobEvent, events <-chan models.JobEvent) {
+	defer conn.Close()
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for _, event := range recorded {
+		if err := conn.WriteJSON(event); err != nil {
+			return
+		}
+	}
+
+	ticker := time.NewTicker(PingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case event := <-events:
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(PingMsg)); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+func streamJobEventsBySse(c *gin.Context, recorded []models.JobEvent, events <-chan models.JobEvent) {
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+	c.Writer.Header().Set("X-Accel-Buffering", "no")
+
+	for _, event := range recorded {
+		writeSseJobEvent(c.Writer, event)
+	}
+	c.Writer.Flush()
+
+	ticker := time.NewTicker(jobEventHeartbeat)
+	defer ticker.Stop()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event := <-events:
+			writeSseJobEvent(w, event)
+			return true
+		case <-ticker.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+func writeSseJobEvent(w io.Writer, event models.JobEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", strings.ToLower(event.Type), data)
+}
+
+func jobExists(jobUuid string) bool {
+	if job, err := NewJobService().GetJobEntityByJobUuid(jobUuid); err == nil && job.JobUuid != "" {
+		return true
+	}
+	if job, err := NewEcpJobService().GetEcpJobByUuid(jobUuid); err == nil && job.Uuid != "" {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,566 @@
+package computing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/swanchain/go-computing-provider/constants"
+	"github.com/swanchain/go-computing-provider/internal/models"
+	appV1 "k8s.io/api/apps/v1"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	appListers "k8s.io/client-go/listers/apps/v1"
+	coreListers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const informerResyncPeriod = 10 * time.Minute
+
+var jobInformer *JobInformer
+var jobInformerOnce sync.Once
+
+// deletingJobs holds the jobs whose resources are deleted by DeleteJob, their deployments are not deleted from the cluster.
+var deletingJobs sync.Map
+
+// JobInformer keeps the state of FCP jobs in sync with the deployments, pods, services and events
+// of the tenant namespaces, and publishes the changes as job events. Only the namespaces are watched
+// cluster-wide, the resources are watched in each tenant namespace by its own informer.
+type JobInformer struct {
+	client           kubernetes.Interface
+	namespaceFactory informers.SharedInformerFactory
+	stopCh           chan struct{}
+
+	lock    sync.RWMutex
+	tenants map[string]*tenantInformer
+}
+
+// tenantInformer watches the resources of one tenant namespace.
+type tenantInformer struct {
+	factory          informers.SharedInformerFactory
+	deploymentLister appListers.DeploymentLister
+	podLister        coreListers.PodLister
+	synced           []cache.InformerSynced
+	stopCh           chan struct{}
+}
+
+func StartJobInformer() (*JobInformer, error) {
+	var err error
+	jobInformerOnce.Do(func() {
+		k8sService := NewK8sService()
+		if k8sService == nil || k8sService.k8sClient == nil {
+			err = fmt.Errorf("failed to create k8s client, please check that the k8s service is running normally")
+			return
+		}
+
+		namespaceFactory := informers.NewSharedInformerFactory(k8sService.k8sClient, informerResyncPeriod)
+		informer := &JobInformer{
+			client:           k8sService.k8sClient,
+			namespaceFactory: namespaceFactory,
+			stopCh:           make(chan struct{}),
+			tenants:          make(map[string]*tenantInformer),
+		}
+
+		namespaces := namespaceFactory.Core().V1().Namespaces()
+		namespaces.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				if namespace, ok := obj.(*coreV1.Namespace); ok {
+					informer.watchNamespace(namespace.Name)
+				}
+			},
+			DeleteFunc: func(obj interface{}) {
+				if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+					obj = tombstone.Obj
+				}
+				if namespace, ok := obj.(*coreV1.Namespace); ok {
+					informer.unwatchNamespace(namespace.Name)
+				}
+			},
+		})
+
+		namespaceFactory.Start(informer.stopCh)
+		for informerType, synced := range namespaceFactory.WaitForCacheSync(informer.stopCh) {
+			if !synced {
+				err = fmt.Errorf("failed to sync informer cache: %v", informerType)
+				return
+			}
+		}
+
+		list, listErr := namespaces.Lister().List(labels.Everything())
+		if listErr != nil {
+			err = listErr
+			return
+		}
+		for _, namespace := range list {
+			if !informer.watchNamespace(namespace.Name) {
+				err = fmt.Errorf("failed to sync informer cache of namespace: %s", namespace.Name)
+				return
+			}
+		}
+		jobInformer = informer
+		go informer.reconcileJobs()
+		logs.GetLogger().Info("job informer started")
+	})
+	return jobInformer, err
+}
+
+// GetJobInformer returns the started job informer, or nil when it is not running.
+func GetJobInformer() *JobInformer {
+	return jobInformer
+}
+
+// watchNamespace starts the informers of a tenant namespace and waits for their caches to sync,
+// it returns false when they failed to sync.
+func (ji *JobInformer) watchNamespace(namespace string) bool {
+	if !strings.HasPrefix(namespace, constants.K8S_NAMESPACE_NAME_PREFIX) {
+		return true
+	}
+
+	ji.lock.Lock()
+	tenant, ok := ji.tenants[namespace]
+	if !ok {
+		tenant = ji.newTenantInformer(namespace)
+		ji.tenants[namespace] = tenant
+		tenant.factory.Start(tenant.stopCh)
+	}
+	ji.lock.Unlock()
+
+	return cache.WaitForCacheSync(tenant.stopCh, tenant.synced...)
+}
+
+// unwatchNamespace stops the informers of a deleted tenant namespace.
+func (ji *JobInformer) unwatchNamespace(namespace string) {
+	ji.lock.Lock()
+	defer ji.lock.Unlock()
+	if tenant, ok := ji.tenants[namespace]; ok {
+		close(tenant.stopCh)
+		delete(ji.tenants, namespace)
+	}
+}
+
+func (ji *JobInformer) newTenantInformer(namespace string) *tenantInformer {
+	factory := informers.NewSharedInformerFactoryWithOptions(ji.client, informerResyncPeriod, informers.WithNamespace(namespace))
+	tenant := &tenantInformer{
+		factory:          factory,
+		deploymentLister: factory.Apps().V1().Deployments().Lister(),
+		podLister:        factory.Core().V1().Pods().Lister(),
+		stopCh:           make(chan struct{}),
+	}
+
+	deployments := factory.Apps().V1().Deployments().Informer()
+	deployments.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { ji.onDeployment(nil, obj) },
+		UpdateFunc: ji.onDeployment,
+		DeleteFunc: ji.onDeploymentDeleted,
+	})
+	pods := factory.Core().V1().Pods().Informer()
+	pods.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { ji.onPod(nil, obj) },
+		UpdateFunc: ji.onPod,
+	})
+	services := factory.Core().V1().Services().Informer()
+	services.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { ji.onService(obj, "ServiceCreated") },
+		DeleteFunc: func(obj interface{}) { ji.onService(obj, "ServiceDeleted") },
+	})
+	events := factory.Core().V1().Events().Informer()
+	events.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { ji.onEvent(tenant.podLister, obj) },
+		UpdateFunc: func(_, newObj interface{}) { ji.onEvent(tenant.podLister, newObj) },
+	})
+	tenant.synced = []cache.InformerSynced{deployments.HasSynced, pods.HasSynced, services.HasSynced, events.HasSynced}
+	return tenant
+}
+
+// tenant returns the informers of the namespace once their caches are synced, or nil.
+func (ji *JobInformer) tenant(namespace string) *tenantInformer {
+	ji.lock.RLock()
+	defer ji.lock.RUnlock()
+	tenant, ok := ji.tenants[namespace]
+	if !ok {
+		return nil
+	}
+	for _, synced := range tenant.synced {
+		if !synced() {
+			return nil
+		}
+	}
+	return tenant
+}
+
+// GetDeployment returns the deployment from the informer cache, or from the api server
+// when the informers of the namespace are not synced yet.
+func (ji *JobInformer) GetDeployment(namespace, name string) (*appV1.Deployment, error) {
+	if tenant := ji.tenant(namespace); tenant != nil {
+		return tenant.deploymentLister.Deployments(namespace).Get(name)
+	}
+	return ji.client.AppsV1().Deployments(namespace).Get(context.TODO(), name, metaV1.GetOptions{})
+}
+
+// ListTenantDeployments returns the deployments of all tenant namespaces from the informer cache.
+func (ji *JobInformer) ListTenantDeployments() ([]*appV1.Deployment, error) {
+	ji.lock.RLock()
+	defer ji.lock.RUnlock()
+
+	var result []*appV1.Deployment
+	for _, tenant := range ji.tenants {
+		deployments, err := tenant.deploymentLister.List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, deployments...)
+	}
+	return result, nil
+}
+
+// HasPods reports whether the namespace has any pods, using the informer cache.
+func (ji *JobInformer) HasPods(namespace string) (bool, error) {
+	pods, err := ji.listPods(namespace, labels.Everything())
+	if err != nil {
+		return false, err
+	}
+	return len(pods) > 0, nil
+}
+
+func (ji *JobInformer) listPods(namespace string, selector labels.Selector) ([]*coreV1.Pod, error) {
+	if tenant := ji.tenant(namespace); tenant != nil {
+		return tenant.podLister.Pods(namespace).List(selector)
+	}
+
+	podList, err := ji.client.CoreV1().Pods(namespace).List(context.TODO(), metaV1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+	pods := make([]*coreV1.Pod, 0, len(podList.Items))
+	for i := range podList.Items {
+		pods = append(pods, &podList.Items[i])
+	}
+	return pods, nil
+}
+
+// WaitForPodRunning waits until all pods with the label labelKey=jobUuid are running and returns the name of the first one.
+func (ji *JobInformer) WaitForPodRunning(namespace, labelKey, jobUuid string, timeout time.Duration) (string, error) {
+	_, events, cancel := SubscribeJobEvents(jobUuid)
+	defer cancel()
+
+	selector := labels.SelectorFromSet(labels.Set{labelKey: jobUuid})
+	deadline := time.After(timeout)
+	for {
+		pods, err := ji.listPods(namespace, selector)
+		if err != nil {
+			return "", err
+		}
+		if podName, ok := runningPodName(pods); ok {
+			return podName, nil
+		}
+
+		select {
+		case <-events:
+		case <-time.After(30 * time.Second):
+		case <-deadline:
+			return "", fmt.Errorf("timed out waiting for pods to be running, job_uuid: %s", jobUuid)
+		}
+	}
+}
+
+// reconcileJobs completes the running jobs whose deployment was deleted while the informer was not running,
+// the deployments deleted later are handled by onDeploymentDeleted.
+func (ji *JobInformer) reconcileJobs() {
+	jobList, err := NewJobService().GetJobListByNoRejectStatus()
+	if err != nil {
+		logs.GetLogger().Errorf("failed to get job data, error: %+v", err)
+		return
+	}
+	for _, job := range jobList {
+		if job.DeleteAt == models.DELETED_FLAG || job.Status != models.JOB_RUNNING_STATUS || job.NameSpace == "" || job.K8sDeployName == "" {
+			continue
+		}
+		if _, err = ji.GetDeployment(job.NameSpace, job.K8sDeployName); errors.IsNotFound(err) {
+			logs.GetLogger().Warnf("not found deployment on the cluster, job_uuid: %s, deployment: %s", job.JobUuid, job.K8sDeployName)
+			NewJobService().DeleteJobEntityBySpaceUuId(job.SpaceUuid, job.JobUuid, models.JOB_COMPLETED_STATUS)
+		}
+	}
+}
+
+func runningPodName(pods []*coreV1.Pod) (string, bool) {
+	if len(pods) == 0 {
+		return "", false
+	}
+	for _, pod := range pods {
+		if pod.Status.Phase != coreV1.PodRunning {
+			return "", false
+		}
+	}
+	return pods[0].Name, true
+}
+
+func (ji *JobInformer) onDeployment(oldObj, obj interface{}) {
+	deploy, ok := obj.(*appV1.Deployment)
+	if !ok {
+		return
+	}
+	jobUuid := jobUuidOfName(deploy.Name)
+	if jobUuid == "" {
+		return
+	}
+
+	old, _ := oldObj.(*appV1.Deployment)
+	if reason, message := deploymentFailureReason(deploy); reason != "" {
+		var oldReason string
+		if old != nil {
+			oldReason, _ = deploymentFailureReason(old)
+		}
+		if oldReason != reason {
+			// DeleteJob waits for the resources to be deleted
+			go failJob(deploy.Namespace, jobUuid, reason, message)
+		}
+		return
+	}
+
+	if old != nil && old.Status.AvailableReplicas == deploy.Status.AvailableReplicas {
+		return
+	}
+	if deploy.Status.AvailableReplicas > 0 {
+		if err := NewJobService().UpdateActiveJobByJobUuid(jobUuid, map[string]interface{}{
+			"status":     models.JOB_RUNNING_STATUS,
+			"pod_status": models.POD_RUNNING_STATUS,
+			"error":      "",
+		}); err != nil {
+			logs.GetLogger().Errorf("failed to update job status, job_uuid: %s, error: %v", jobUuid, err)
+		}
+	}
+}
+
+// deploymentFailureReason returns ProgressDeadlineExceeded when the rollout of a deployment
+// without any available replica did not progress within its deadline.
+func deploymentFailureReason(deploy *appV1.Deployment) (string, string) {
+	// the deployment of a paused job is scaled to zero on purpose
+	if deploy.Status.AvailableReplicas > 0 || (deploy.Spec.Replicas != nil && *deploy.Spec.Replicas == 0) {
+		return "", ""
+	}
+	for _, condition := range deploy.Status.Conditions {
+		if condition.Type == appV1.DeploymentProgressing && condition.Status == coreV1.ConditionFalse && condition.Reason == "ProgressDeadlineExceeded" {
+			return condition.Reason, condition.Message
+		}
+	}
+	return "", ""
+}
+
+func (ji *JobInformer) onDeploymentDeleted(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	deploy, ok := obj.(*appV1.Deployment)
+	if !ok {
+		return
+	}
+	jobUuid := jobUuidOfName(deploy.Name)
+	if jobUuid == "" {
+		return
+	}
+	PublishJobEvent(models.JobEvent{
+		JobUuid: jobUuid,
+		Type:    coreV1.EventTypeNormal,
+		Reason:  "DeploymentDeleted",
+		Object:  "Deployment/" + deploy.Name,
+		Message: fmt.Sprintf("deployment %s deleted", deploy.Name),
+		Time:    time.Now().Unix(),
+	})
+
+	// a running job whose deployment was deleted from the cluster is completed
+	if _, deleting := deletingJobs.Load(jobUuid); deleting {
+		return
+	}
+	job, err := NewJobService().GetJobEntityByJobUuid(jobUuid)
+	if err != nil {
+		logs.GetLogger().Errorf("failed to get job, job_uuid: %s, error: %v", jobUuid, err)
+		return
+	}
+	if job.DeleteAt == models.UN_DELETEED_FLAG && job.Status == models.JOB_RUNNING_STATUS {
+		logs.GetLogger().Warnf("not found deployment on the cluster, job_uuid: %s, deployment: %s", jobUuid, deploy.Name)
+		if err = NewJobService().DeleteJobEntityBySpaceUuId(job.SpaceUuid, job.JobUuid, models.JOB_COMPLETED_STATUS); err != nil {
+			logs.GetLogger().Errorf("failed to complete job, job_uuid: %s, error: %v", jobUuid, err)
+		}
+	}
+}
+
+func (ji *JobInformer) onPod(oldObj, obj interface{}) {
+	pod, ok := obj.(*coreV1.Pod)
+	if !ok {
+		return
+	}
+	if old, ok := oldObj.(*coreV1.Pod); ok && old.ResourceVersion == pod.ResourceVersion {
+		return
+	}
+	jobUuid := jobUuidOfPod(pod)
+	if jobUuid == "" {
+		return
+	}
+
+	reason, message := podFailureReason(pod)
+	if reason == "" {
+		if old, ok := oldObj.(*coreV1.Pod); ok && old.Status.Phase != pod.Status.Phase && pod.Status.Phase == coreV1.PodRunning {
+			GetScheduler().Release(jobUuid)
+			PublishJobEvent(models.JobEvent{
+				JobUuid: jobUuid,
+				Type:    coreV1.EventTypeNormal,
+				Reason:  string(coreV1.PodRunning),
+				Object:  "Pod/" + pod.Name,
+				Message: fmt.Sprintf("pod %s is running", pod.Name),
+				Time:    time.Now().Unix(),
+			})
+		}
+		return
+	}
+
+	errMsg := fmt.Sprintf("%s: %s", reason, message)
+	if err := NewJobService().UpdateActiveJobByJobUuid(jobUuid, map[string]interface{}{
+		"error": errMsg,
+	}); err != nil {
+		logs.GetLogger().Errorf("failed to update job error, job_uuid: %s, error: %v", jobUuid, err)
+	}
+	PublishJobEvent(models.JobEvent{
+		JobUuid: jobUuid,
+		Type:    coreV1.EventTypeWarning,
+		Reason:  reason,
+		Object:  "Pod/" + pod.Name,
+		Message: message,
+		Time:    time.Now().Unix(),
+	})
+}
+
+func (ji *JobInformer) onService(obj interface{}, reason string) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	service, ok := obj.(*coreV1.Service)
+	if !ok {
+		return
+	}
+	jobUuid := jobUuidOfName(service.Name)
+	if jobUuid == "" {
+		return
+	}
+	PublishJobEvent(models.JobEvent{
+		JobUuid: jobUuid,
+		Type:    coreV1.EventTypeNormal,
+		Reason:  reason,
+		Object:  "Service/" + service.Name,
+		Message: fmt.Sprintf("service %s, type: %s", service.Name, service.Spec.Type),
+		Time:    time.Now().Unix(),
+	})
+}
+
+func (ji *JobInformer) onEvent(podLister coreListers.PodLister, obj interface{}) {
+	event, ok := obj.(*coreV1.Event)
+	if !ok {
+		return
+	}
+
+	var jobUuid string
+	if event.InvolvedObject.Kind == "Pod" {
+		if pod, err := podLister.Pods(event.Namespace).Get(event.InvolvedObject.Name); err == nil {
+			jobUuid = jobUuidOfPod(pod)
+		}
+	}
+	if jobUuid == "" {
+		jobUuid = jobUuidOfName(event.InvolvedObject.Name)
+	}
+	if jobUuid == "" {
+		return
+	}
+
+	eventTime := event.LastTimestamp.Unix()
+	if event.LastTimestamp.IsZero() {
+		eventTime = event.CreationTimestamp.Unix()
+	}
+	PublishJobEvent(models.JobEvent{
+		JobUuid: jobUuid,
+		Type:    event.Type,
+		Reason:  event.Reason,
+		Object:  event.InvolvedObject.Kind + "/" + event.InvolvedObject.Name,
+		Message: event.Message,
+		Time:    eventTime,
+	})
+}
+
+// failJob marks the job failed for the reason, and deletes its k8s resources
+func failJob(namespace, jobUuid, reason, message string) {
+	logs.GetLogger().Errorf("job_uuid: %s, the job failed, %s: %s", jobUuid, reason, message)
+	PublishJobEvent(models.JobEvent{
+		JobUuid: jobUuid,
+		Type:    coreV1.EventTypeWarning,
+		Reason:  reason,
+		Object:  "Job/" + jobUuid,
+		Message: message,
+		Time:    time.Now().Unix(),
+	})
+	if err := NewJobService().UpdateActiveJobByJobUuid(jobUuid, map[string]interface{}{
+		"status": models.JOB_FAILED_STATUS,
+		"error":  fmt.Sprintf("%s: %s", reason, message),
+	}); err != nil {
+		logs.GetLogger().Errorf("failed to update job status, job_uuid: %s, error: %v", jobUuid, err)
+	}
+	if err := DeleteJob(namespace, jobUuid, message); err != nil {
+		logs.GetLogger().Errorf("failed to delete the failed job, job_uuid: %s, error: %v", jobUuid, err)
+	}
+}
+
+// podFailureReason returns why the pod can not run, e.g. ImagePullBackOff, OOMKilled or Pending due to insufficient GPU.
+func podFailureReason(pod *coreV1.Pod) (string, string) {
+	for _, status := range pod.Status.ContainerStatuses {
+		if waiting := status.State.Waiting; waiting != nil {
+			switch waiting.Reason {
+			case "ImagePullBackOff", "ErrImagePull", "InvalidImageName", "CrashLoopBackOff", "CreateContainerConfigError", "CreateContainerError":
+				return waiting.Reason, waiting.Message
+			}
+		}
+		if terminated := status.LastTerminationState.Terminated; terminated != nil && terminated.Reason == "OOMKilled" {
+			return terminated.Reason, fmt.Sprintf("container %s was killed for exceeding its memory limit, restarts: %d", status.Name, status.RestartCount)
+		}
+		if terminated := status.State.Terminated; terminated != nil && terminated.Reason == "OOMKilled" {
+			return terminated.Reason, fmt.Sprintf("container %s was killed for exceeding its memory limit", status.Name)
+		}
+	}
+
+	if pod.Status.Phase == coreV1.PodPending {
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == coreV1.PodScheduled && condition.Status == coreV1.ConditionFalse && condition.Reason == coreV1.PodReasonUnschedulable {
+				return string(coreV1.PodPending), condition.Message
+			}
+		}
+	}
+	if pod.Status.Phase == coreV1.PodFailed {
+		return string(coreV1.PodFailed), pod.Status.Message
+	}
+	return "", ""
+}
+
+func jobUuidOfPod(pod *coreV1.Pod) string {
+	if jobUuid, ok := pod.Labels["lad_app"]; ok {
+		return jobUuid
+	}
+	return pod.Labels["hub-private"]
+}
+
+// jobUuidOfName extracts the job uuid from the name of the k8s resources created for a job, e.g. deploy-<job_uuid>-xxx
+func jobUuidOfName(name string) string {
+	for _, prefix := range []string{constants.K8S_DEPLOY_NAME_PREFIX, constants.K8S_SERVICE_NAME_PREFIX, constants.K8S_INGRESS_NAME_PREFIX} {
+		if strings.HasPrefix(name, prefix) {
+			name = strings.TrimPrefix(name, prefix)
+			const uuidLen = 36
+			if len(name) >= uuidLen && strings.Count(name[:uuidLen], "-") == 4 {
+				return name[:uuidLen]
+			}
+			return name
+		}
+	}
+	return ""
+}
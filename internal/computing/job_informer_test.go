@@ -0,0 +1,82 @@
+package computing
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/swanchain/go-computing-provider/constants"
+	"github.com/swanchain/go-computing-provider/internal/db"
+	"github.com/swanchain/go-computing-provider/internal/models"
+	appV1 "k8s.io/api/apps/v1"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDeploymentFailureReason(t *testing.T) {
+	deployment := func(replicas, available int32, status coreV1.ConditionStatus, reason string) *appV1.Deployment {
+		deploy := &appV1.Deployment{}
+		deploy.Spec.Replicas = &replicas
+		deploy.Status.AvailableReplicas = available
+		deploy.Status.Conditions = []appV1.DeploymentCondition{
+			{Type: appV1.DeploymentProgressing, Status: status, Reason: reason, Message: "progress deadline exceeded"},
+		}
+		return deploy
+	}
+
+	tests := []struct {
+		name   string
+		deploy *appV1.Deployment
+		failed bool
+	}{
+		{"progressing", deployment(1, 0, coreV1.ConditionTrue, "ReplicaSetUpdated"), false},
+		{"deadline exceeded", deployment(1, 0, coreV1.ConditionFalse, "ProgressDeadlineExceeded"), true},
+		{"deadline exceeded while available", deployment(2, 1, coreV1.ConditionFalse, "ProgressDeadlineExceeded"), false},
+		{"paused", deployment(0, 0, coreV1.ConditionFalse, "ProgressDeadlineExceeded"), false},
+	}
+	for _, tt := range tests {
+		reason, _ := deploymentFailureReason(tt.deploy)
+		if (reason != "") != tt.failed {
+			t.Errorf("%s: expected failed %v, got reason %q", tt.name, tt.failed, reason)
+		}
+	}
+}
+
+func TestJobUuidOfName(t *testing.T) {
+	jobUuid := "0b1c3f5e-2d4a-4c6b-8e9f-a1b2c3d4e5f6"
+	tests := map[string]string{
+		"deploy-" + jobUuid:         jobUuid,
+		"deploy-" + jobUuid + "-rs": jobUuid,
+		"svc-" + jobUuid:            jobUuid,
+		"model-cache-abc":           "",
+	}
+	for name, want := range tests {
+		if got := jobUuidOfName(name); got != want {
+			t.Errorf("%s: expected %q, got %q", name, want, got)
+		}
+	}
+}
+
+func TestDeploymentDeletedCompletesJob(t *testing.T) {
+	db.InitDb(t.TempDir())
+	// the names of the k8s resources are lowercase, the uuid of the job may not be
+	jobUuid := "0B1C3F5E-2D4A-4C6B-8E9F-A1B2C3D4E5F6"
+	if err := NewJobService().SaveJobEntity(&models.JobEntity{
+		JobUuid:   jobUuid,
+		SpaceUuid: "space-1",
+		Status:    models.JOB_RUNNING_STATUS,
+		DeleteAt:  models.UN_DELETEED_FLAG,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	deploy := &appV1.Deployment{ObjectMeta: metaV1.ObjectMeta{Name: constants.K8S_DEPLOY_NAME_PREFIX + strings.ToLower(jobUuid)}}
+	(&JobInformer{}).onDeploymentDeleted(deploy)
+
+	job, err := NewJobService().GetJobEntityByJobUuid(jobUuid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Status != models.JOB_COMPLETED_STATUS || job.DeleteAt != models.DELETED_FLAG {
+		t.Errorf("expected the job to be completed, got status %d, delete_at %d", job.Status, job.DeleteAt)
+	}
+}
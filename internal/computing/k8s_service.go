@@ -11,6 +11,7 @@ import (
 	"github.com/swanchain/go-computing-provider/constants"
 	"github.com/swanchain/go-computing-provider/internal/models"
 	"io"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/remotecommand"
@@ -83,6 +84,14 @@ func (s *K8sService) DeleteDeployment(ctx context.Context, namespace, deployment
 	return s.k8sClient.AppsV1().Deployments(namespace).Delete(ctx, deploymentName, metaV1.DeleteOptions{})
 }
 
+func (s *K8sService) GetDeployment(ctx context.Context, namespace, deploymentName string) (*appV1.Deployment, error) {
+	return s.k8sClient.AppsV1().Deployments(namespace).Get(ctx, deploymentName, metaV1.GetOptions{})
+}
+
+func (s *K8sService) UpdateDeployment(ctx context.Context, namespace string, deploy *appV1.Deployment) (*appV1.Deployment, error) {
+	return s.k8sClient.AppsV1().Deployments(namespace).Update(ctx, deploy, metaV1.UpdateOptions{})
+}
+
 func (s *K8sService) DeletePod(ctx context.Context, namespace, spaceUuid string) error {
 	return s.k8sClient.CoreV1().Pods(namespace).DeleteCollection(ctx, *metaV1.NewDeleteOptions(0), metaV1.ListOptions{
 		LabelSelector: fmt.Sprintf("lad_app=%s", spaceUuid),
@@ -336,6 +345,32 @@ func (s *K8sService) DeleteNameSpace(ctx context.Context, nameSpace string) erro
 	return s.k8sClient.CoreV1().Namespaces().Delete(ctx, nameSpace, metaV1.DeleteOptions{})
 }
 
+func (s *K8sService) ApplyResourceQuota(ctx context.Context, nameSpace string, quota *coreV1.ResourceQuota) error {
+	old, err := s.k8sClient.CoreV1().ResourceQuotas(nameSpace).Get(ctx, quota.Name, metaV1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			_, err = s.k8sClient.CoreV1().ResourceQuotas(nameSpace).Create(ctx, quota, metaV1.CreateOptions{})
+		}
+		return err
+	}
+	old.Spec = quota.Spec
+	_, err = s.k8sClient.CoreV1().ResourceQuotas(nameSpace).Update(ctx, old, metaV1.UpdateOptions{})
+	return err
+}
+
+func (s *K8sService) ApplyLimitRange(ctx context.Context, nameSpace string, limitRange *coreV1.LimitRange) error {
+	old, err := s.k8sClient.CoreV1().LimitRanges(nameSpace).Get(ctx, limitRange.Name, metaV1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			_, err = s.k8sClient.CoreV1().LimitRanges(nameSpace).Create(ctx, limitRange, metaV1.CreateOptions{})
+		}
+		return err
+	}
+	old.Spec = limitRange.Spec
+	_, err = s.k8sClient.CoreV1().LimitRanges(nameSpace).Update(ctx, old, metaV1.UpdateOptions{})
+	return err
+}
+
 func (s *K8sService) ListUsedImage(ctx context.Context, nameSpace string) ([]string, error) {
 	list, err := s.k8sClient.CoreV1().Pods(nameSpace).List(ctx, metaV1.ListOptions{})
 	if err != nil {
@@ -618,6 +653,26 @@ func (s *K8sService) WaitForPodRunningByHttp(namespace, jobUuid, serviceIp strin
 	var podName string
 	var podErr = errors.New("get pod status failed")
 
+	if informer := GetJobInformer(); informer != nil {
+		// the pod and the service share the deadline of the wait
+		deadline := time.Now().Add(20 * time.Minute)
+		podName, err := informer.WaitForPodRunning(namespace, "lad_app", jobUuid, time.Until(deadline))
+		if err != nil {
+			return "", fmt.Errorf("failed waiting for pods to be running: %v", err)
+		}
+		if err = wait.PollImmediate(10*time.Second, time.Until(deadline), func() (bool, error) {
+			resp, err := http.Get(serviceIp)
+			if err != nil {
+				return false, nil
+			}
+			resp.Body.Close()
+			return true, nil
+		}); err != nil {
+			return podName, fmt.Errorf("failed waiting for the service of the pod %s to be reachable: %v", podName, err)
+		}
+		return podName, nil
+	}
+
 	retryErr := retry.OnError(wait.Backoff{
 		Steps:    120,
 		Duration: 10 * time.Second,
@@ -646,6 +701,10 @@ func (s *K8sService) WaitForPodRunningByHttp(namespace, jobUuid, serviceIp strin
 }
 
 func (s *K8sService) WaitForPodRunningByTcp(namespace, jobUuid string) (string, error) {
+	if informer := GetJobInformer(); informer != nil {
+		return informer.WaitForPodRunning(namespace, "hub-private", jobUuid, 10*time.Minute)
+	}
+
 	var podName string
 	err := wait.PollImmediate(time.Second*5, time.Minute*10, func() (done bool, err error) {
 		podList, err := s.k8sClient.CoreV1().Pods(namespace).List(context.TODO(), metaV1.ListOptions{
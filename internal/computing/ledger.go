@@ -0,0 +1,462 @@
+package computing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/internal/contract"
+	"github.com/swanchain/go-computing-provider/internal/contract/ecp"
+	"github.com/swanchain/go-computing-provider/internal/models"
+)
+
+const (
+	LedgerTaskTypeFcp = "fcp"
+	LedgerTaskTypeEcp = "ecp"
+	LedgerTaskTypeUbi = "ubi"
+)
+
+// recordLedger saves an entry of the ledger, a failure is only logged so the ledger never blocks the rewards or the tasks
+func recordLedger(entry models.LedgerEntity) {
+	entry.CreateTime = time.Now().Unix()
+	if entry.EventTime == 0 {
+		entry.EventTime = entry.CreateTime
+	}
+	if err := NewLedgerService().SaveLedgerEntry(&entry); err != nil {
+		logs.GetLogger().Errorf("failed to record the %s of the ledger, tx: %s, task: %s, error: %v", entry.Category,
+			entry.TxHash, entry.TaskUuid, err)
+	}
+}
+
+// blockTime returns the time of the block, or the current time when the header is not available
+func blockTime(client *ethclient.Client, blockNumber uint64) int64 {
+	header, err := client.HeaderByNumber(context.Background(), new(big.Int).SetUint64(blockNumber))
+	if err != nil {
+		logs.GetLogger().Warnf("failed to get the header of block %d, error: %v", blockNumber, err)
+		return time.Now().Unix()
+	}
+	return int64(header.Time)
+}
+
+// recordEventReward records a reward released to the wallet by the event log of a contract
+func recordEventReward(client *ethclient.Client, raw types.Log, category, taskType, taskUuid string, wallet common.Address, amount *big.Int) {
+	seed, err := NewLedgerService().GetLedgerSeed(category, taskUuid)
+	if err != nil {
+		logs.GetLogger().Errorf("failed to get the reward of task %s before the ledger, error: %v", taskUuid, err)
+	} else if seed != nil && raw.BlockNumber <= seed.BlockNumber {
+		// counted in the reward the task had before the ledger existed
+		return
+	}
+	recordLedger(models.LedgerEntity{
+		Kind:        models.LedgerReward,
+		Category:    category,
+		TaskType:    taskType,
+		TaskUuid:    taskUuid,
+		Contract:    raw.Address.Hex(),
+		TxHash:      raw.TxHash.Hex(),
+		LogIndex:    raw.Index,
+		BlockNumber: raw.BlockNumber,
+		Wallet:      wallet.Hex(),
+		Token:       models.LedgerTokenSwan,
+		Amount:      amount.String(),
+		EventTime:   blockTime(client, raw.BlockNumber),
+	})
+}
+
+// updateTaskReward sets the reward of the fcp or ecp job to the total of its rewards in the ledger,
+// so an event processed again or rolled back after a reorg is counted once
+func updateTaskReward(category, taskUuid string) error {
+	total, err := NewLedgerService().SumLedgerAmounts(category, taskUuid)
+	if err != nil {
+		return fmt.Errorf("failed to sum the rewards of task %s, error: %v", taskUuid, err)
+	}
+	switch category {
+	case models.LedgerFcpReward:
+		return NewJobService().UpdateJobReward(taskUuid, total.String())
+	case models.LedgerEcpReward:
+		return NewEcpJobService().UpdateEcpJobReward(taskUuid, total.String())
+	}
+	return nil
+}
+
+// recordUbiReward records the reward of an ubi task settled by the sequencer, the reward is in SWAN. Its time is the
+// block the settlement contract was created in.
+func recordUbiReward(task *models.TaskEntity, reward, settlementContract string) {
+	taskKey := ubiTaskKey(task)
+	recorded, err := NewLedgerService().HasLedgerEntry(models.LedgerUbiReward, taskKey)
+	if err != nil {
+		logs.GetLogger().Errorf("failed to check the reward of ubi task %d in the ledger, error: %v", task.Id, err)
+		return
+	}
+	if recorded {
+		return
+	}
+	amount, err := contract.StrToBalance(reward)
+	if err != nil {
+		logs.GetLogger().Errorf("failed to parse the reward of ubi task %d, reward: %s, error: %v", task.Id, reward, err)
+		return
+	}
+	cpAccountAddress, err := contract.GetCpAccountAddress()
+	if err != nil {
+		logs.GetLogger().Errorf("failed to get cp account contract address, error: %v", err)
+		return
+	}
+	entry := models.LedgerEntity{
+		Kind:     models.LedgerReward,
+		Category: models.LedgerUbiReward,
+		TaskType: LedgerTaskTypeUbi,
+		TaskUuid: taskKey,
+		Contract: settlementContract,
+		Wallet:   cpAccountAddress,
+		Token:    models.LedgerTokenSwan,
+		Amount:   amount.String(),
+	}
+
+	chainUrl, err := conf.GetRpcByNetWorkName()
+	if err != nil {
+		logs.GetLogger().Errorf("failed to get rpc url, error: %v", err)
+		return
+	}
+	client, err := contract.GetEthClient(chainUrl)
+	if err != nil {
+		logs.GetLogger().Errorf("failed to dial rpc, error: %v", err)
+		return
+	}
+	defer client.Close()
+	if blockNumber, err := settlementBlock(client, common.HexToAddress(settlementContract), task.CreateTime); err != nil {
+		logs.GetLogger().Warnf("failed to find the settlement block of ubi task %d, the reward is recorded at the current time, error: %v", task.Id, err)
+	} else {
+		entry.BlockNumber = blockNumber
+		entry.EventTime = blockTime(client, blockNumber)
+	}
+	recordLedger(entry)
+}
+
+const (
+	// settlementLogWindow is the blocks of a query of the logs of a settlement contract, settlementLogWindows the
+	// queries of a search at most
+	settlementLogWindow  = 5000
+	settlementLogWindows = 20
+)
+
+// settlementBlock returns the block the settlement contract of ubi tasks was created in, from its TaskCreated event.
+// The logs are searched back from the chain head to the creation time of the task.
+func settlementBlock(client *ethclient.Client, settlementContract common.Address, since int64) (uint64, error) {
+	parsed, err := ecp.AggregatedTaskMetaData.GetAbi()
+	if err != nil {
+		return 0, err
+	}
+	head, err := client.BlockNumber(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	to := head
+	for i := 0; i < settlementLogWindows; i++ {
+		var from uint64
+		if to >= settlementLogWindow {
+			from = to - settlementLogWindow + 1
+		}
+		eventLogs, err := client.FilterLogs(context.Background(), ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(from),
+			ToBlock:   new(big.Int).SetUint64(to),
+			Addresses: []common.Address{settlementContract},
+			Topics:    [][]common.Hash{{parsed.Events["TaskCreated"].ID}},
+		})
+		if err != nil {
+			return 0, err
+		}
+		if len(eventLogs) > 0 {
+			return eventLogs[0].BlockNumber, nil
+		}
+		if from == 0 {
+			return 0, ethereum.NotFound
+		}
+		header, err := client.HeaderByNumber(context.Background(), new(big.Int).SetUint64(from))
+		if err != nil {
+			return 0, err
+		}
+		if int64(header.Time) < since {
+			return 0, ethereum.NotFound
+		}
+		to = from - 1
+	}
+	return 0, ethereum.NotFound
+}
+
+// ubiTaskKey is the task of an ubi entry in the ledger, the fil-c2 tasks have no uuid
+func ubiTaskKey(task *models.TaskEntity) string {
+	if task.Uuid != "" {
+		return task.Uuid
+	}
+	return strconv.FormatInt(task.Id, 10)
+}
+
+// txFeeReceipt is the fee fields of a receipt, the l1Fee is the data fee of the op stack chains
+type txFeeReceipt struct {
+	From              common.Address  `json:"from"`
+	To                *common.Address `json:"to"`
+	ContractAddress   *common.Address `json:"contractAddress"`
+	BlockNumber       *hexutil.Big    `json:"blockNumber"`
+	GasUsed           hexutil.Uint64  `json:"gasUsed"`
+	EffectiveGasPrice *hexutil.Big    `json:"effectiveGasPrice"`
+	L1Fee             *hexutil.Big    `json:"l1Fee"`
+}
+
+// txGasEntry returns the entry of the fee paid by the sender of a mined transaction, it returns ethereum.NotFound
+// before the transaction is mined
+func txGasEntry(ctx context.Context, client *ethclient.Client, txHash common.Hash, taskType, taskUuid string) (models.LedgerEntity, error) {
+	var receipt *txFeeReceipt
+	if err := client.Client().CallContext(ctx, &receipt, "eth_getTransactionReceipt", txHash); err != nil {
+		return models.LedgerEntity{}, err
+	}
+	if receipt == nil || receipt.BlockNumber == nil {
+		return models.LedgerEntity{}, ethereum.NotFound
+	}
+
+	fee := new(big.Int).SetUint64(uint64(receipt.GasUsed))
+	if receipt.EffectiveGasPrice != nil {
+		fee.Mul(fee, receipt.EffectiveGasPrice.ToInt())
+	}
+	if receipt.L1Fee != nil {
+		fee.Add(fee, receipt.L1Fee.ToInt())
+	}
+	var contractAddress string
+	if receipt.To != nil {
+		contractAddress = receipt.To.Hex()
+	} else if receipt.ContractAddress != nil {
+		contractAddress = receipt.ContractAddress.Hex()
+	}
+
+	blockNumber := receipt.BlockNumber.ToInt().Uint64()
+	return models.LedgerEntity{
+		Kind:        models.LedgerExpense,
+		Category:    models.LedgerGas,
+		TaskType:    taskType,
+		TaskUuid:    taskUuid,
+		Contract:    contractAddress,
+		TxHash:      txHash.Hex(),
+		BlockNumber: blockNumber,
+		Wallet:      receipt.From.Hex(),
+		Token:       models.LedgerTokenEth,
+		Amount:      fee.String(),
+		EventTime:   blockTime(client, blockNumber),
+	}, nil
+}
+
+// recordTxGas records the fee paid by the sender of a mined transaction, it returns ethereum.NotFound before the transaction is mined
+func recordTxGas(ctx context.Context, client *ethclient.Client, txHash common.Hash, taskType, taskUuid string) error {
+	entry, err := txGasEntry(ctx, client, txHash, taskType, taskUuid)
+	if err != nil {
+		return err
+	}
+	recordLedger(entry)
+	return nil
+}
+
+// RecordTxGas records the fee of a transaction sent by a command in the ledger. A transaction not mined yet is saved
+// pending without waiting for it, UpdatePendingTxGas records its fee once it is mined.
+func RecordTxGas(txHash, taskType string) error {
+	chainUrl, err := conf.GetRpcByNetWorkName()
+	if err != nil {
+		return err
+	}
+	client, err := contract.GetEthClient(chainUrl)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err = recordTxGas(ctx, client, common.HexToHash(txHash), taskType, "")
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, ethereum.NotFound) {
+		logs.GetLogger().Warnf("failed to get the receipt of tx %s, its gas is recorded later, error: %v", txHash, err)
+	}
+	return NewLedgerService().SaveLedgerEntry(&models.LedgerEntity{
+		Kind:       models.LedgerExpense,
+		Category:   models.LedgerGas,
+		TaskType:   taskType,
+		TxHash:     common.HexToHash(txHash).Hex(),
+		Token:      models.LedgerTokenEth,
+		Amount:     "0",
+		EventTime:  time.Now().Unix(),
+		CreateTime: time.Now().Unix(),
+		Pending:    true,
+	})
+}
+
+// UpdatePendingTxGas records the fees of the pending transactions mined since they were sent
+func UpdatePendingTxGas() error {
+	chainUrl, err := conf.GetRpcByNetWorkName()
+	if err != nil {
+		return err
+	}
+	client, err := contract.GetEthClient(chainUrl)
+	if err != nil {
+		return fmt.Errorf("failed to dial rpc, error: %v", err)
+	}
+	defer client.Close()
+	return updatePendingTxGas(client)
+}
+
+func updatePendingTxGas(client *ethclient.Client) error {
+	ledgerService := NewLedgerService()
+	entries, err := ledgerService.GetPendingLedgerEntries()
+	if err != nil {
+		return fmt.Errorf("failed to get the pending gas of the ledger, error: %v", err)
+	}
+	for _, pending := range entries {
+		entry, err := txGasEntry(context.Background(), client, common.HexToHash(pending.TxHash), pending.TaskType, pending.TaskUuid)
+		if errors.Is(err, ethereum.NotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get the receipt of tx %s, error: %s", pending.TxHash, ecp.ParseTooManyError(err))
+		}
+		entry.Id = pending.Id
+		entry.CreateTime = pending.CreateTime
+		if err = ledgerService.UpdateLedgerEntry(&entry); err != nil {
+			return fmt.Errorf("failed to record the gas of tx %s, error: %v", pending.TxHash, err)
+		}
+	}
+	return nil
+}
+
+// recordProofGas records the fee of the task contract created for the proof of an ubi task
+func recordProofGas(task *models.TaskEntity) {
+	if task.TxHash == "" {
+		return
+	}
+	chainUrl, err := conf.GetRpcByNetWorkName()
+	if err != nil {
+		logs.GetLogger().Errorf("failed to get rpc url, error: %v", err)
+		return
+	}
+	client, err := contract.GetEthClient(chainUrl)
+	if err != nil {
+		logs.GetLogger().Errorf("failed to dial rpc, error: %v", err)
+		return
+	}
+	defer client.Close()
+
+	if err = recordTxGas(context.Background(), client, common.HexToHash(task.TxHash), LedgerTaskTypeUbi, ubiTaskKey(task)); err != nil {
+		logs.GetLogger().Errorf("failed to record the proof gas of ubi task %d, tx: %s, error: %v", task.Id, task.TxHash, err)
+	}
+}
+
+// ScanSequencerFees records the fees the sequencer moved from the balance of the cp account to its escrow.
+// The first scan starts at the chain head, the block the sequencer contract was created in is not configured.
+func ScanSequencerFees() error {
+	if conf.GetConfig().CONTRACT.Sequencer == "" {
+		return nil
+	}
+	cpAccountAddress, err := contract.GetCpAccountAddress()
+	if err != nil {
+		return fmt.Errorf("failed to get cp account contract address, error: %v", err)
+	}
+	chainUrl, err := conf.GetRpcByNetWorkName()
+	if err != nil {
+		return err
+	}
+	client, err := contract.GetEthClient(chainUrl)
+	if err != nil {
+		return fmt.Errorf("failed to dial rpc, error: %v", err)
+	}
+	defer client.Close()
+
+	sequencer, err := ecp.NewEcpSequencer(common.HexToAddress(conf.GetConfig().CONTRACT.Sequencer), client)
+	if err != nil {
+		return fmt.Errorf("failed to create sequencer contract client, error: %v", err)
+	}
+	if err = checkReorg(client, models.ScannerSequencerFeeId); err != nil {
+		return err
+	}
+	endBlockNumber, err := confirmedBlock(client)
+	if err != nil {
+		return err
+	}
+
+	scannedBlock := loadLastProcessedBlock(models.ScannerSequencerFeeId)
+	if scannedBlock == 0 {
+		return saveScannedBlock(client, endBlockNumber, models.ScannerSequencerFeeId)
+	}
+
+	cpAccount := common.HexToAddress(cpAccountAddress)
+	var step uint64 = 1000
+	for i := uint64(scannedBlock) + 1; i <= endBlockNumber; i = i + step {
+		end := i + step - 1
+		if end > endBlockNumber {
+			end = endBlockNumber
+		}
+		filterOps := &bind.FilterOpts{
+			Start: i,
+			End:   &end,
+		}
+		if err = scanSequencerFees(client, sequencer, filterOps, cpAccount); err != nil {
+			return fmt.Errorf("failed to scan sequencer fees, start: %d, end: %d, error: %s", i, end, ecp.ParseTooManyError(err))
+		}
+		if err = saveScannedBlock(client, end, models.ScannerSequencerFeeId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scanSequencerFees(client *ethclient.Client, sequencer *ecp.EcpSequencer, opts *bind.FilterOpts, cpAccount common.Address) error {
+	transferred, err := sequencer.FilterTransferredToEscrow(opts, []common.Address{cpAccount})
+	if err != nil {
+		return err
+	}
+	defer transferred.Close()
+	for transferred.Next() {
+		recordSequencerFee(client, transferred.Event.Raw, cpAccount, transferred.Event.Amount)
+	}
+	if err = transferred.Error(); err != nil {
+		return err
+	}
+
+	batches, err := sequencer.FilterBatchTransferredToEscrow(opts, nil)
+	if err != nil {
+		return err
+	}
+	defer batches.Close()
+	for batches.Next() {
+		event := batches.Event
+		for i, account := range event.CpAccounts {
+			if account == cpAccount && i < len(event.Amounts) {
+				recordSequencerFee(client, event.Raw, cpAccount, event.Amounts[i])
+			}
+		}
+	}
+	return batches.Error()
+}
+
+func recordSequencerFee(client *ethclient.Client, raw types.Log, cpAccount common.Address, amount *big.Int) {
+	recordLedger(models.LedgerEntity{
+		Kind:        models.LedgerExpense,
+		Category:    models.LedgerSequencerFee,
+		TaskType:    LedgerTaskTypeUbi,
+		Contract:    raw.Address.Hex(),
+		TxHash:      raw.TxHash.Hex(),
+		LogIndex:    raw.Index,
+		BlockNumber: raw.BlockNumber,
+		Wallet:      cpAccount.Hex(),
+		Token:       models.LedgerTokenEth,
+		Amount:      amount.String(),
+		EventTime:   blockTime(client, raw.BlockNumber),
+	})
+}
@@ -0,0 +1,219 @@
+package computing
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/swanchain/go-computing-provider/internal/contract/ecp"
+	"github.com/swanchain/go-computing-provider/internal/db"
+	"github.com/swanchain/go-computing-provider/internal/models"
+)
+
+// fakeRpc serves the json-rpc methods of the handlers, with the params of each call
+func fakeRpc(t *testing.T, handlers map[string]func(params []json.RawMessage) interface{}) *ethclient.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Id     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode the request: %v", err)
+			return
+		}
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.Id}
+		if handler, ok := handlers[req.Method]; ok {
+			resp["result"] = handler(req.Params)
+		} else {
+			resp["error"] = map[string]interface{}{"code": -32601, "message": "method not found: " + req.Method}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+	client, err := ethclient.Dial(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+// fakeHeader is a block header with the number and the time
+func fakeHeader(number uint64, time uint64) map[string]interface{} {
+	zero := common.Hash{}.Hex()
+	return map[string]interface{}{
+		"parentHash":       zero,
+		"sha3Uncles":       zero,
+		"miner":            common.Address{}.Hex(),
+		"stateRoot":        zero,
+		"transactionsRoot": zero,
+		"receiptsRoot":     zero,
+		"logsBloom":        hexutil.Encode(make([]byte, 256)),
+		"difficulty":       "0x0",
+		"number":           hexutil.EncodeUint64(number),
+		"gasLimit":         "0x0",
+		"gasUsed":          "0x0",
+		"timestamp":        hexutil.EncodeUint64(time),
+		"extraData":        "0x",
+	}
+}
+
+func blockParam(t *testing.T, param json.RawMessage) uint64 {
+	t.Helper()
+	var number string
+	if err := json.Unmarshal(param, &number); err != nil {
+		t.Fatal(err)
+	}
+	value, err := hexutil.DecodeUint64(number)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return value
+}
+
+func TestUpdatePendingTxGas(t *testing.T) {
+	db.InitDb(t.TempDir())
+	txHash := common.HexToHash("0x0a")
+	if err := NewLedgerService().SaveLedgerEntry(&models.LedgerEntity{
+		Kind:     models.LedgerExpense,
+		Category: models.LedgerGas,
+		TaskType: LedgerTaskTypeEcp,
+		TxHash:   txHash.Hex(),
+		Token:    models.LedgerTokenEth,
+		Amount:   "0",
+		Pending:  true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var mined bool
+	client := fakeRpc(t, map[string]func(params []json.RawMessage) interface{}{
+		"eth_getTransactionReceipt": func(params []json.RawMessage) interface{} {
+			if !mined {
+				return nil
+			}
+			return map[string]interface{}{
+				"from":              common.HexToAddress("0x01").Hex(),
+				"to":                common.HexToAddress("0x02").Hex(),
+				"blockNumber":       "0x64",
+				"gasUsed":           "0x5208",
+				"effectiveGasPrice": "0x3b9aca00",
+				"l1Fee":             "0x10",
+			}
+		},
+		"eth_getBlockByNumber": func(params []json.RawMessage) interface{} {
+			return fakeHeader(blockParam(t, params[0]), 1700000000)
+		},
+	})
+
+	if err := updatePendingTxGas(client); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := NewLedgerService().GetLedgerEntries(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected the pending gas to be left out of the ledger, got %+v", entries)
+	}
+
+	mined = true
+	if err = updatePendingTxGas(client); err != nil {
+		t.Fatal(err)
+	}
+	if entries, err = NewLedgerService().GetLedgerEntries(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %+v", entries)
+	}
+	entry := entries[0]
+	// 21000 gas at 1 gwei and the l1 fee
+	if entry.Amount != strconv.FormatInt(21000*1e9+16, 10) || entry.BlockNumber != 100 || entry.EventTime != 1700000000 ||
+		entry.TaskType != LedgerTaskTypeEcp || entry.Wallet != common.HexToAddress("0x01").Hex() || entry.Pending {
+		t.Errorf("unexpected gas entry: %+v", entry)
+	}
+	if pending, err := NewLedgerService().GetPendingLedgerEntries(); err != nil || len(pending) != 0 {
+		t.Errorf("expected no pending gas, got %+v, %v", pending, err)
+	}
+}
+
+func TestSettlementBlock(t *testing.T) {
+	parsed, err := ecp.AggregatedTaskMetaData.GetAbi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	settlement := common.HexToAddress("0x0b")
+	var queries []string
+	client := fakeRpc(t, map[string]func(params []json.RawMessage) interface{}{
+		"eth_blockNumber": func(params []json.RawMessage) interface{} {
+			return hexutil.EncodeUint64(12000)
+		},
+		"eth_getLogs": func(params []json.RawMessage) interface{} {
+			var query struct {
+				FromBlock string          `json:"fromBlock"`
+				ToBlock   string          `json:"toBlock"`
+				Address   []string        `json:"address"`
+				Topics    [][]common.Hash `json:"topics"`
+			}
+			if err := json.Unmarshal(params[0], &query); err != nil {
+				t.Fatal(err)
+			}
+			if len(query.Address) != 1 || common.HexToAddress(query.Address[0]) != settlement ||
+				query.Topics[0][0] != parsed.Events["TaskCreated"].ID {
+				t.Errorf("unexpected filter: %+v", query)
+			}
+			from, _ := hexutil.DecodeUint64(query.FromBlock)
+			to, _ := hexutil.DecodeUint64(query.ToBlock)
+			queries = append(queries, fmt.Sprintf("%d-%d", from, to))
+			if from > 6500 || to < 6500 {
+				return []interface{}{}
+			}
+			return []interface{}{map[string]interface{}{
+				"address":          settlement.Hex(),
+				"topics":           []string{parsed.Events["TaskCreated"].ID.Hex()},
+				"data":             "0x",
+				"blockNumber":      hexutil.EncodeUint64(6500),
+				"transactionHash":  common.HexToHash("0x0c").Hex(),
+				"transactionIndex": "0x0",
+				"blockHash":        common.HexToHash("0x0d").Hex(),
+				"logIndex":         "0x0",
+				"removed":          false,
+			}}
+		},
+		"eth_getBlockByNumber": func(params []json.RawMessage) interface{} {
+			number := blockParam(t, params[0])
+			return fakeHeader(number, 1000+number)
+		},
+	})
+
+	blockNumber, err := settlementBlock(client, settlement, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blockNumber != 6500 {
+		t.Errorf("expected the block 6500, got %d", blockNumber)
+	}
+	if len(queries) != 2 || queries[0] != "7001-12000" || queries[1] != "2001-7000" {
+		t.Errorf("unexpected queries: %v", queries)
+	}
+
+	// the search stops at the block of the creation of the task
+	queries = nil
+	if _, err = settlementBlock(client, settlement, 1000+7001+1); !errors.Is(err, ethereum.NotFound) {
+		t.Errorf("expected ethereum.NotFound, got %v", err)
+	}
+	if len(queries) != 1 {
+		t.Errorf("expected one query before the task, got %v", queries)
+	}
+}
@@ -0,0 +1,523 @@
+package computing
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/swanchain/go-computing-provider/build"
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/internal/models"
+	batchv1 "k8s.io/api/batch/v1"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	modelCacheVolumeName = "model-cache"
+	modelCacheMountPath  = "/models"
+	// modelCacheGracePeriod keeps a model just prefetched for a job from the eviction, until the job mounts it
+	modelCacheGracePeriod = 10 * time.Minute
+	// modelCacheNamespace runs the jobs of the model caches of the nodes, apart from the namespaces of the tenants
+	modelCacheNamespace = "cp-model-cache"
+	// modelCacheUid is the user of the model cache jobs, the curl_user of the prefetch image, it owns the cache of the node
+	modelCacheUid int64 = 100
+)
+
+// modelPrefetchScript downloads the model into the cache of the node, resuming the partial file, and prints its size
+const modelPrefetchScript = `set -e
+mkdir -p "$MODEL_DIR"
+if [ ! -f "$MODEL_DIR/$MODEL_NAME" ]; then
+  curl -fsSL --retry 3 -C - -o "$MODEL_DIR/$MODEL_NAME.part" "$MODEL_URL"
+  if [ -n "$MODEL_SHA256" ] && ! echo "$MODEL_SHA256  $MODEL_DIR/$MODEL_NAME.part" | sha256sum -c - >/dev/null; then
+    rm -f "$MODEL_DIR/$MODEL_NAME.part"
+    echo "sha256 mismatch" >&2
+    exit 1
+  fi
+  mv "$MODEL_DIR/$MODEL_NAME.part" "$MODEL_DIR/$MODEL_NAME"
+fi
+stat -c %s "$MODEL_DIR/$MODEL_NAME"`
+
+const modelEvictScript = `rm -rf "$MODEL_DIR"`
+
+// modelOwnerScript hands the cache of the node to the user of the model cache jobs, the caches of the older versions
+// were written by root
+const modelOwnerScript = `chown ${MODEL_UID}:${MODEL_UID} /models
+if [ -d "$MODEL_DIR" ]; then chown -R ${MODEL_UID}:${MODEL_UID} "$MODEL_DIR"; fi`
+
+// modelWaitScript waits for the model files prefetched into the cache of the node, one per line of MODEL_FILES
+const modelWaitScript = `set -e
+end=$(( $(date +%s) + MODEL_TIMEOUT ))
+echo "$MODEL_FILES" | while read -r f; do
+  until [ -f "$f" ]; do
+    if [ "$(date +%s)" -ge "$end" ]; then
+      echo "timed out waiting for the model $f" >&2
+      exit 1
+    fi
+    sleep 5
+  done
+done`
+
+// modelCacheLocks serializes the prefetch and the removal of a model in the cache of a node
+var modelCacheLocks sync.Map
+
+func lockModelCache(key, node string) func() {
+	lock, _ := modelCacheLocks.LoadOrStore(node+"/"+key, new(sync.Mutex))
+	lock.(*sync.Mutex).Lock()
+	return lock.(*sync.Mutex).Unlock
+}
+
+// modelCacheKey addresses a model in the cache by its url and sha256
+func modelCacheKey(modelUrl, sha string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(modelUrl) + "\n" + strings.ToLower(strings.TrimSpace(sha))))
+	return hex.EncodeToString(sum[:])[:24]
+}
+
+// modelCacheDir returns the model cache of the docker host
+func modelCacheDir() string {
+	if dir := conf.GetConfig().ModelCache.Dir; dir != "" {
+		return dir
+	}
+	cpRepoPath, _ := os.LookupEnv("CP_PATH")
+	return filepath.Join(cpRepoPath, "model-cache")
+}
+
+// checkJobModels checks the model files of a job before they are prefetched
+func checkJobModels(jobModels []models.JobModel) error {
+	for _, model := range jobModels {
+		if model.Name == "" || model.Name == "." || model.Name == ".." || model.Name != filepath.Base(model.Name) {
+			return fmt.Errorf("invalid model name: %q", model.Name)
+		}
+		u, err := url.Parse(model.Url)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid url of model %s: %q", model.Name, model.Url)
+		}
+		if !filepath.IsAbs(model.Dir) {
+			return fmt.Errorf("the dir of model %s is not an absolute path: %q", model.Name, model.Dir)
+		}
+		if sum, err := hex.DecodeString(model.Sha256); model.Sha256 != "" && (err != nil || len(sum) != sha256.Size) {
+			return fmt.Errorf("invalid sha256 of model %s: %q", model.Name, model.Sha256)
+		}
+	}
+	return nil
+}
+
+// PrefetchModel downloads the model into the model cache of the docker host, a model in the cache is not downloaded again.
+// It returns the path of the model file in the cache.
+func PrefetchModel(ctx context.Context, model models.JobModel) (string, error) {
+	if err := checkJobModels([]models.JobModel{model}); err != nil {
+		return "", err
+	}
+	key := modelCacheKey(model.Url, model.Sha256)
+	unlock := lockModelCache(key, "")
+	defer unlock()
+
+	modelCacheService := NewModelCacheService()
+	cache, err := modelCacheService.GetModelCache(key, "")
+	if err != nil {
+		return "", err
+	}
+	if cache.Status == models.ModelCacheReady {
+		modelPath := filepath.Join(modelCacheDir(), key, cache.Name)
+		if _, err = os.Stat(modelPath); err == nil {
+			return modelPath, modelCacheService.TouchModelCache(key, "")
+		}
+		logs.GetLogger().Warnf("model %s is missing in the model cache, download it again", modelPath)
+	}
+
+	if err = startModelCache(cache, key, "", model); err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(conf.GetConfig().ModelCache.Timeout)*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	modelPath := filepath.Join(modelCacheDir(), key, model.Name)
+	budget := &downloadBudget{files: make(map[string]int64)}
+	err = downloadSpaceFile(ctx, modelPath, models.SpaceFile{Name: model.Name, URL: model.Url, Sha256: model.Sha256}, budget)
+	var size int64
+	if err == nil {
+		var info os.FileInfo
+		if info, err = os.Stat(modelPath); err == nil {
+			size = info.Size()
+		}
+	}
+	if err = finishModelCache(cache, size, err); err != nil {
+		return "", err
+	}
+	logs.GetLogger().Infof("prefetched model %s (%s) into %s in %s", model.Name, BytesToHumanReadable(size), modelPath,
+		time.Since(start).Round(time.Second))
+	return modelPath, nil
+}
+
+// PrefetchModelToNode downloads the model into the model cache of the k8s node with a job pinned to the node. A model
+// ready in the cache is checked on the node by the job too, and downloaded again when the file is missing.
+// It returns the path of the model file on the node.
+func PrefetchModelToNode(ctx context.Context, nodeName string, model models.JobModel) (string, error) {
+	if err := checkJobModels([]models.JobModel{model}); err != nil {
+		return "", err
+	}
+	key := modelCacheKey(model.Url, model.Sha256)
+	unlock := lockModelCache(key, nodeName)
+	defer unlock()
+
+	hostDir := filepath.Join(conf.GetConfig().ModelCache.HostPath, key)
+	modelCacheService := NewModelCacheService()
+	cache, err := modelCacheService.GetModelCache(key, nodeName)
+	if err != nil {
+		return "", err
+	}
+	ready := cache.Status == models.ModelCacheReady
+
+	if err = startModelCache(cache, key, nodeName, model); err != nil {
+		return "", err
+	}
+	start := time.Now()
+	output, err := runModelCacheJob(ctx, nodeName, "model-prefetch-"+key[:12], modelPrefetchScript, []coreV1.EnvVar{
+		{Name: "MODEL_DIR", Value: filepath.Join(modelCacheMountPath, key)},
+		{Name: "MODEL_NAME", Value: model.Name},
+		{Name: "MODEL_URL", Value: model.Url},
+		{Name: "MODEL_SHA256", Value: strings.ToLower(model.Sha256)},
+	})
+	var size int64
+	if err == nil {
+		lines := strings.Split(strings.TrimSpace(output), "\n")
+		size, err = strconv.ParseInt(strings.TrimSpace(lines[len(lines)-1]), 10, 64)
+		if err != nil {
+			err = fmt.Errorf("failed to read the size of the model, output: %s", output)
+		}
+	}
+	if err = finishModelCache(cache, size, err); err != nil {
+		return "", err
+	}
+	if !ready {
+		logs.GetLogger().Infof("prefetched model %s (%s) into node %s in %s", model.Name, BytesToHumanReadable(size), nodeName,
+			time.Since(start).Round(time.Second))
+	}
+	return filepath.Join(hostDir, model.Name), nil
+}
+
+func startModelCache(cache *models.ModelCacheEntity, key, node string, model models.JobModel) error {
+	cache.Key = key
+	cache.Node = node
+	cache.Name = model.Name
+	cache.Url = model.Url
+	cache.Sha256 = strings.ToLower(model.Sha256)
+	cache.Status = models.ModelCacheDownloading
+	cache.Message = ""
+	if cache.CreateTime == 0 {
+		cache.CreateTime = time.Now().Unix()
+	}
+	return NewModelCacheService().SaveModelCache(cache)
+}
+
+func finishModelCache(cache *models.ModelCacheEntity, size int64, downloadErr error) error {
+	if downloadErr != nil {
+		cache.Status = models.ModelCacheFailed
+		cache.Message = downloadErr.Error()
+	} else {
+		cache.Status = models.ModelCacheReady
+		cache.Size = size
+		cache.UseCount++
+		cache.LastUsedTime = time.Now().Unix()
+	}
+	if err := NewModelCacheService().SaveModelCache(cache); err != nil {
+		logs.GetLogger().Errorf("failed to save the model cache, key: %s, error: %v", cache.Key, err)
+	}
+	if downloadErr != nil {
+		return fmt.Errorf("failed to prefetch model %s, %v", cache.Name, downloadErr)
+	}
+	if conf.GetConfig().ModelCache.MaxSize > 0 {
+		go func(node string) {
+			if err := EvictModelCache(node); err != nil {
+				logs.GetLogger().Errorf("failed to evict the model cache, node: %q, error: %v", node, err)
+			}
+		}(cache.Node)
+	}
+	return nil
+}
+
+// runModelCacheJob runs the script in a job on the node with the model cache of the node mounted, and returns its output.
+// The script runs as modelCacheUid, only the init container handing the cache to it runs as root, with CAP_CHOWN alone.
+func runModelCacheJob(ctx context.Context, nodeName, namePrefix, script string, env []coreV1.EnvVar) (string, error) {
+	k8sService := NewK8sService()
+	if k8sService.k8sClient == nil {
+		return "", fmt.Errorf("not connected to the k8s cluster")
+	}
+	if err := ensureModelCacheNamespace(ctx, k8sService); err != nil {
+		return "", err
+	}
+
+	timeout := time.Duration(conf.GetConfig().ModelCache.Timeout) * time.Second
+	deadline := int64(timeout.Seconds())
+	var backoffLimit int32
+	var ttl int32 = 60
+	var root int64
+	uid := modelCacheUid
+	nonRoot, noEscalation, readOnly := true, false, true
+	hostPathType := coreV1.HostPathDirectoryOrCreate
+	volumeMounts := []coreV1.VolumeMount{{
+		Name:      modelCacheVolumeName,
+		MountPath: modelCacheMountPath,
+	}}
+	job := &batchv1.Job{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      namePrefix + "-" + generateString(5),
+			Namespace: modelCacheNamespace,
+			Labels:    map[string]string{"app": modelCacheVolumeName},
+		},
+		Spec: batchv1.JobSpec{
+			Template: coreV1.PodTemplateSpec{
+				ObjectMeta: metaV1.ObjectMeta{
+					Labels: map[string]string{"app": modelCacheVolumeName},
+				},
+				Spec: coreV1.PodSpec{
+					NodeName:      nodeName,
+					RestartPolicy: coreV1.RestartPolicyNever,
+					InitContainers: []coreV1.Container{{
+						Name:            modelCacheVolumeName + "-owner",
+						Image:           build.ModelPrefetchDockerImage,
+						ImagePullPolicy: coreV1.PullIfNotPresent,
+						Command:         []string{"sh", "-c", modelOwnerScript},
+						Env:             append([]coreV1.EnvVar{{Name: "MODEL_UID", Value: strconv.FormatInt(uid, 10)}}, env...),
+						SecurityContext: &coreV1.SecurityContext{
+							RunAsUser:                &root,
+							AllowPrivilegeEscalation: &noEscalation,
+							ReadOnlyRootFilesystem:   &readOnly,
+							Capabilities: &coreV1.Capabilities{
+								Drop: []coreV1.Capability{"ALL"},
+								Add:  []coreV1.Capability{"CHOWN"},
+							},
+						},
+						VolumeMounts: volumeMounts,
+					}},
+					Containers: []coreV1.Container{{
+						Name:            modelCacheVolumeName,
+						Image:           build.ModelPrefetchDockerImage,
+						ImagePullPolicy: coreV1.PullIfNotPresent,
+						Command:         []string{"sh", "-c", script},
+						Env:             env,
+						SecurityContext: &coreV1.SecurityContext{
+							RunAsUser:                &uid,
+							RunAsGroup:               &uid,
+							RunAsNonRoot:             &nonRoot,
+							AllowPrivilegeEscalation: &noEscalation,
+							ReadOnlyRootFilesystem:   &readOnly,
+							Capabilities:             &coreV1.Capabilities{Drop: []coreV1.Capability{"ALL"}},
+						},
+						VolumeMounts: volumeMounts,
+					}},
+					Volumes: []coreV1.Volume{{
+						Name: modelCacheVolumeName,
+						VolumeSource: coreV1.VolumeSource{
+							HostPath: &coreV1.HostPathVolumeSource{
+								Path: conf.GetConfig().ModelCache.HostPath,
+								Type: &hostPathType,
+							},
+						},
+					}},
+				},
+			},
+			BackoffLimit:            &backoffLimit,
+			ActiveDeadlineSeconds:   &deadline,
+			TTLSecondsAfterFinished: &ttl,
+		},
+	}
+
+	jobClient := k8sService.k8sClient.BatchV1().Jobs(modelCacheNamespace)
+	createdJob, err := jobClient.Create(ctx, job, metaV1.CreateOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to create job, error: %v", err)
+	}
+
+	var succeeded bool
+	err = wait.PollUntilContextTimeout(ctx, 3*time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		current, err := jobClient.Get(ctx, createdJob.Name, metaV1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		succeeded = current.Status.Succeeded > 0
+		return succeeded || current.Status.Failed > 0, nil
+	})
+
+	var output string
+	podList, listErr := k8sService.k8sClient.CoreV1().Pods(modelCacheNamespace).List(context.Background(), metaV1.ListOptions{
+		LabelSelector: fmt.Sprintf("job-name=%s", createdJob.Name),
+	})
+	if listErr == nil && len(podList.Items) > 0 {
+		output, _ = k8sService.GetPodLogByPodName(modelCacheNamespace, podList.Items[0].Name, &coreV1.PodLogOptions{})
+	}
+
+	if err != nil || !succeeded {
+		propagation := metaV1.DeletePropagationBackground
+		jobClient.Delete(context.Background(), createdJob.Name, metaV1.DeleteOptions{PropagationPolicy: &propagation})
+		if err == nil {
+			err = fmt.Errorf("the job failed")
+		}
+		return output, fmt.Errorf("job %s on node %s, %v, output: %s", createdJob.Name, nodeName, err, strings.TrimSpace(output))
+	}
+	return output, nil
+}
+
+func ensureModelCacheNamespace(ctx context.Context, k8sService *K8sService) error {
+	if _, err := k8sService.GetNameSpace(ctx, modelCacheNamespace, metaV1.GetOptions{}); err == nil || !errors.IsNotFound(err) {
+		return err
+	}
+	_, err := k8sService.CreateNameSpace(ctx, &coreV1.Namespace{
+		ObjectMeta: metaV1.ObjectMeta{Name: modelCacheNamespace},
+	}, metaV1.CreateOptions{})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create namespace %s, error: %v", modelCacheNamespace, err)
+	}
+	return nil
+}
+
+// modelsInUse returns the keys of the models of the cache of the node that a container mounts
+func modelsInUse(node string) (map[string]bool, error) {
+	inUse := make(map[string]bool)
+	markInUse := func(cacheDir, source string) {
+		if rel, err := filepath.Rel(cacheDir, source); err == nil && filepath.IsLocal(rel) {
+			inUse[strings.Split(filepath.ToSlash(rel), "/")[0]] = true
+		}
+	}
+
+	if node == "" {
+		containers, err := NewDockerService().c.ContainerList(context.Background(), container.ListOptions{All: true})
+		if err != nil {
+			return nil, err
+		}
+		cacheDir := modelCacheDir()
+		for _, c := range containers {
+			for _, m := range c.Mounts {
+				markInUse(cacheDir, m.Source)
+			}
+		}
+		return inUse, nil
+	}
+
+	k8sService := NewK8sService()
+	if k8sService.k8sClient == nil {
+		return nil, fmt.Errorf("not connected to the k8s cluster")
+	}
+	pods, err := k8sService.k8sClient.CoreV1().Pods("").List(context.Background(), metaV1.ListOptions{
+		FieldSelector: "spec.nodeName=" + node,
+	})
+	if err != nil {
+		return nil, err
+	}
+	hostPath := conf.GetConfig().ModelCache.HostPath
+	for _, pod := range pods.Items {
+		if pod.Labels["app"] == modelCacheVolumeName {
+			continue
+		}
+		for _, volume := range pod.Spec.Volumes {
+			if volume.HostPath != nil {
+				markInUse(hostPath, volume.HostPath.Path)
+			}
+		}
+	}
+	return inUse, nil
+}
+
+// EvictModelCache removes the least recently used models of the cache of the node that no job uses,
+// until the cache fits in ModelCache.MaxSize. The node is empty for the cache of the docker host.
+func EvictModelCache(node string) error {
+	maxSize := int64(conf.GetConfig().ModelCache.MaxSize * 1024 * 1024 * 1024)
+	if maxSize <= 0 {
+		return nil
+	}
+	caches, err := NewModelCacheService().GetModelCaches()
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	var candidates []models.ModelCacheEntity
+	for _, cache := range caches {
+		if cache.Node != node {
+			continue
+		}
+		total += cache.Size
+		if cache.Status != models.ModelCacheDownloading && time.Since(time.Unix(cache.LastUsedTime, 0)) > modelCacheGracePeriod {
+			candidates = append(candidates, cache)
+		}
+	}
+	if total <= maxSize {
+		return nil
+	}
+
+	inUse, err := modelsInUse(node)
+	if err != nil {
+		return err
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].LastUsedTime < candidates[j].LastUsedTime
+	})
+	for _, cache := range candidates {
+		if total <= maxSize {
+			break
+		}
+		if inUse[cache.Key] {
+			continue
+		}
+		if err = removeModelCache(cache.Key, node); err != nil {
+			logs.GetLogger().Warnf("failed to remove model %s from the model cache, node: %q, error: %v", cache.Name, node, err)
+			continue
+		}
+		total -= cache.Size
+		logs.GetLogger().Infof("removed model %s from the model cache, node: %q, last used at %s", cache.Name, node,
+			time.Unix(cache.LastUsedTime, 0).Format(time.DateTime))
+	}
+	return nil
+}
+
+// RemoveModelCache removes the model from the cache of the node, a model mounted by a container is not removed
+func RemoveModelCache(key, node string) error {
+	cache, err := NewModelCacheService().GetModelCache(key, node)
+	if err != nil {
+		return err
+	}
+	if cache.Id == 0 {
+		return fmt.Errorf("not found model %s in the model cache, node: %q", key, node)
+	}
+	inUse, err := modelsInUse(node)
+	if err != nil {
+		return err
+	}
+	if inUse[key] {
+		return fmt.Errorf("the model %s is in use", key)
+	}
+	return removeModelCache(key, node)
+}
+
+func removeModelCache(key, node string) error {
+	unlock := lockModelCache(key, node)
+	defer unlock()
+
+	if node == "" {
+		if err := os.RemoveAll(filepath.Join(modelCacheDir(), key)); err != nil {
+			return err
+		}
+	} else {
+		_, err := runModelCacheJob(context.Background(), node, "model-evict-"+key[:12], modelEvictScript, []coreV1.EnvVar{
+			{Name: "MODEL_DIR", Value: filepath.Join(modelCacheMountPath, key)},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return NewModelCacheService().DeleteModelCache(key, node)
+}
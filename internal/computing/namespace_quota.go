@@ -0,0 +1,198 @@
+package computing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/swanchain/go-computing-provider/constants"
+	"github.com/swanchain/go-computing-provider/internal/models"
+	coreV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	tenantResourceQuotaName = "quota-tenant"
+	tenantLimitRangeName    = "limit-tenant"
+	gpuResourceName         = "nvidia.com/gpu"
+)
+
+// containers that declare no resources (e.g. the depends of a yaml space) get these defaults from the LimitRange,
+// and every active job is granted the same amount on top of its hardware so that they still fit in the quota.
+var (
+	defaultContainerCpu     = resource.MustParse("500m")
+	defaultContainerMemory  = resource.MustParse("512Mi")
+	defaultContainerStorage = resource.MustParse("1Gi")
+)
+
+type tenantResource struct {
+	cpu     resource.Quantity
+	memory  resource.Quantity
+	storage resource.Quantity
+	gpu     resource.Quantity
+
+	maxCpu    resource.Quantity
+	maxMemory resource.Quantity
+}
+
+// SyncNamespaceQuota applies the ResourceQuota and LimitRange of the tenant namespace ns-<wallet>,
+// derived from the hardware of all active jobs of the wallet except the excluded job uuids.
+func SyncNamespaceQuota(walletAddress string, excludeJobUuids ...string) error {
+	if strings.TrimSpace(walletAddress) == "" {
+		return nil
+	}
+
+	jobList, err := NewJobService().GetActiveJobListByWallet(walletAddress)
+	if err != nil {
+		return fmt.Errorf("failed to get active jobs, wallet: %s, error: %v", walletAddress, err)
+	}
+
+	var activeJobs []*models.JobEntity
+	for _, job := range jobList {
+		if !containsJobUuid(excludeJobUuids, job.JobUuid) {
+			activeJobs = append(activeJobs, job)
+		}
+	}
+
+	k8sService := NewK8sService()
+	if k8sService == nil || k8sService.k8sClient == nil {
+		return fmt.Errorf("failed to create k8s client, please check that the k8s service is running normally")
+	}
+
+	nameSpace := constants.K8S_NAMESPACE_NAME_PREFIX + strings.ToLower(walletAddress)
+	total := sumTenantResource(activeJobs)
+	if err = k8sService.ApplyResourceQuota(context.TODO(), nameSpace, generateResourceQuota(nameSpace, total)); err != nil {
+		return fmt.Errorf("failed to apply resource quota, namespace: %s, error: %v", nameSpace, err)
+	}
+	if err = k8sService.ApplyLimitRange(context.TODO(), nameSpace, generateLimitRange(nameSpace, total)); err != nil {
+		return fmt.Errorf("failed to apply limit range, namespace: %s, error: %v", nameSpace, err)
+	}
+	logs.GetLogger().Debugf("synced namespace quota, namespace: %s, jobs: %d, cpu: %s, memory: %s, gpu: %s",
+		nameSpace, len(activeJobs), total.cpu.String(), total.memory.String(), total.gpu.String())
+	return nil
+}
+
+func sumTenantResource(jobs []*models.JobEntity) tenantResource {
+	var total tenantResource
+	for _, job := range jobs {
+		cpu := *resource.NewQuantity(job.Cpu, resource.DecimalSI)
+		memory := *resource.NewQuantity(job.Memory, resource.BinarySI)
+
+		total.cpu.Add(cpu)
+		total.cpu.Add(defaultContainerCpu)
+		total.memory.Add(memory)
+		total.memory.Add(defaultContainerMemory)
+		total.storage.Add(*resource.NewQuantity(job.Storage, resource.BinarySI))
+		total.storage.Add(defaultContainerStorage)
+		total.gpu.Add(*resource.NewQuantity(job.Gpu, resource.DecimalSI))
+
+		if cpu.Cmp(total.maxCpu) > 0 {
+			total.maxCpu = cpu
+		}
+		if memory.Cmp(total.maxMemory) > 0 {
+			total.maxMemory = memory
+		}
+	}
+	return total
+}
+
+func generateResourceQuota(nameSpace string, total tenantResource) *coreV1.ResourceQuota {
+	return &coreV1.ResourceQuota{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      tenantResourceQuotaName,
+			Namespace: nameSpace,
+		},
+		Spec: coreV1.ResourceQuotaSpec{
+			Hard: coreV1.ResourceList{
+				coreV1.ResourceRequestsCPU:                             total.cpu,
+				coreV1.ResourceLimitsCPU:                               total.cpu,
+				coreV1.ResourceRequestsMemory:                          total.memory,
+				coreV1.ResourceLimitsMemory:                            total.memory,
+				coreV1.ResourceRequestsEphemeralStorage:                total.storage,
+				coreV1.ResourceLimitsEphemeralStorage:                  total.storage,
+				coreV1.DefaultResourceRequestsPrefix + gpuResourceName: total.gpu,
+			},
+		},
+	}
+}
+
+func generateLimitRange(nameSpace string, total tenantResource) *coreV1.LimitRange {
+	item := coreV1.LimitRangeItem{
+		Type: coreV1.LimitTypeContainer,
+		Default: coreV1.ResourceList{
+			coreV1.ResourceCPU:              defaultContainerCpu,
+			coreV1.ResourceMemory:           defaultContainerMemory,
+			coreV1.ResourceEphemeralStorage: defaultContainerStorage,
+		},
+		DefaultRequest: coreV1.ResourceList{
+			coreV1.ResourceCPU:              defaultContainerCpu,
+			coreV1.ResourceMemory:           defaultContainerMemory,
+			coreV1.ResourceEphemeralStorage: defaultContainerStorage,
+		},
+	}
+
+	// a single container can never use more than the largest job of the wallet was priced for
+	if total.maxCpu.Cmp(defaultContainerCpu) >= 0 && total.maxMemory.Cmp(defaultContainerMemory) >= 0 {
+		item.Max = coreV1.ResourceList{
+			coreV1.ResourceCPU:    total.maxCpu,
+			coreV1.ResourceMemory: total.maxMemory,
+		}
+	}
+
+	return &coreV1.LimitRange{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      tenantLimitRangeName,
+			Namespace: nameSpace,
+		},
+		Spec: coreV1.LimitRangeSpec{
+			Limits: []coreV1.LimitRangeItem{item},
+		},
+	}
+}
+
+func containsJobUuid(jobUuids []string, jobUuid string) bool {
+	for _, uuid := range jobUuids {
+		if strings.EqualFold(uuid, jobUuid) {
+			return true
+		}
+	}
+	return false
+}
+
+func walletOfNamespace(nameSpace string) string {
+	if !strings.HasPrefix(nameSpace, constants.K8S_NAMESPACE_NAME_PREFIX) {
+		return ""
+	}
+	return strings.TrimPrefix(nameSpace, constants.K8S_NAMESPACE_NAME_PREFIX)
+}
+
+// backfillJobResource records the hardware of jobs deployed before the resources were stored on the job,
+// using the resource limits of the deployment running on the cluster.
+func backfillJobResource(k8sService *K8sService, job *models.JobEntity) {
+	if job.NameSpace == "" || job.K8sDeployName == "" {
+		return
+	}
+	deployment, err := k8sService.k8sClient.AppsV1().Deployments(job.NameSpace).Get(context.TODO(), job.K8sDeployName, metaV1.GetOptions{})
+	if err != nil {
+		return
+	}
+
+	var cpu, memory, storage, gpu resource.Quantity
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		limits := container.Resources.Limits
+		cpu.Add(limits[coreV1.ResourceCPU])
+		memory.Add(limits[coreV1.ResourceMemory])
+		storage.Add(limits[coreV1.ResourceEphemeralStorage])
+		gpu.Add(limits[gpuResourceName])
+	}
+
+	job.Cpu = cpu.Value()
+	job.Memory = memory.Value()
+	job.Storage = storage.Value()
+	job.Gpu = gpu.Value()
+	if err = NewJobService().UpdateJobResourceByJobUuid(job.JobUuid, job.Cpu, job.Memory, job.Storage, job.Gpu); err != nil {
+		logs.GetLogger().Errorf("failed to backfill job resource, job_uuid: %s, error: %v", job.JobUuid, err)
+	}
+}
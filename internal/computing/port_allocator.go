@@ -0,0 +1,216 @@
+package computing
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-connections/nat"
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/internal/models"
+	"github.com/swanchain/go-computing-provider/util"
+)
+
+// portLeasePendingTTL is how long the ports of a job are kept before its container shows up,
+// it covers pulling or building the image of the job
+const portLeasePendingTTL = time.Hour
+
+// portAllocatorLock serializes the allocations, so that concurrent deploys never pick the same port
+var portAllocatorLock sync.Mutex
+
+// allocatePorts leases one host port of API.PortRange for each container port of the job.
+// A port is free when it is not leased in the db and nothing listens on it.
+func allocatePorts(jobUuid, containerName string, ports []int) (map[nat.Port][]nat.PortBinding, []models.PortMap, error) {
+	portRange, err := parsePortRanges(conf.GetConfig().API.PortRange)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse ports in config, error:%v", err)
+	}
+	return leasePorts(portRange, jobUuid, containerName, ports)
+}
+
+// leasePorts leases one host port of portRange for each container port of the job
+func leasePorts(portRange []int, jobUuid, containerName string, ports []int) (map[nat.Port][]nat.PortBinding, []models.PortMap, error) {
+	portAllocatorLock.Lock()
+	defer portAllocatorLock.Unlock()
+
+	leases, err := NewPortLeaseService().GetPortLeases()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get port leases, error: %v", err)
+	}
+	var leasedPort = make(map[int]struct{}, len(leases))
+	for _, lease := range leases {
+		leasedPort[lease.Port] = struct{}{}
+	}
+
+	var newLeases []models.PortLeaseEntity
+	now := time.Now()
+	for _, hostPort := range portRange {
+		if len(newLeases) == len(ports) {
+			break
+		}
+		if _, ok := leasedPort[hostPort]; ok || !util.IsPortAvailable(hostPort) {
+			continue
+		}
+		newLeases = append(newLeases, models.PortLeaseEntity{
+			Port:          hostPort,
+			JobUuid:       jobUuid,
+			ContainerName: containerName,
+			CreateTime:    now.Unix(),
+			ExpireTime:    now.Add(portLeasePendingTTL).Unix(),
+		})
+	}
+	if len(newLeases) < len(ports) {
+		return nil, nil, fmt.Errorf("port number unavailable, need %d, available: %d", len(ports), len(newLeases))
+	}
+
+	if err = NewPortLeaseService().SavePortLeases(newLeases); err != nil {
+		return nil, nil, fmt.Errorf("failed to save port leases, error: %v", err)
+	}
+
+	var mapPorts []models.PortMap
+	var portMap = make(map[nat.Port][]nat.PortBinding)
+	for i, containerPort := range ports {
+		hostPort := newLeases[i].Port
+		portMap[nat.Port(fmt.Sprintf("%d/tcp", containerPort))] = []nat.PortBinding{
+			{
+				HostIP:   "0.0.0.0",
+				HostPort: strconv.Itoa(hostPort),
+			},
+		}
+		mapPorts = append(mapPorts, models.PortMap{
+			ContainerPort: containerPort,
+			ExternalPort:  hostPort,
+		})
+	}
+	return portMap, mapPorts, nil
+}
+
+// confirmPorts keeps the ports of the job until they are released, once its container is started
+func confirmPorts(jobUuid string) {
+	if err := NewPortLeaseService().ConfirmPortLeases(jobUuid); err != nil {
+		logs.GetLogger().Errorf("failed to confirm port leases, job_uuid: %s, error: %v", jobUuid, err)
+	}
+}
+
+func releasePorts(jobUuid string) {
+	portAllocatorLock.Lock()
+	defer portAllocatorLock.Unlock()
+	if err := NewPortLeaseService().DeletePortLeasesByJobUuid(jobUuid); err != nil {
+		logs.GetLogger().Errorf("failed to release ports, job_uuid: %s, error: %v", jobUuid, err)
+	}
+}
+
+// ReconcilePortLeases brings the port leases in line with the running containers:
+// the leases of containers that are gone are released, and the ports bound by job containers without a lease are adopted.
+func ReconcilePortLeases() error {
+	portRange, err := parsePortRanges(conf.GetConfig().API.PortRange)
+	if err != nil {
+		return fmt.Errorf("failed to parse ports in config, error:%v", err)
+	}
+	var inRange = make(map[int]struct{}, len(portRange))
+	for _, p := range portRange {
+		inRange[p] = struct{}{}
+	}
+
+	dockerService := NewDockerService()
+	containers, err := dockerService.c.ContainerList(context.Background(), container.ListOptions{All: true})
+	if err != nil {
+		return fmt.Errorf("failed to list containers, error: %v", err)
+	}
+
+	portAllocatorLock.Lock()
+	defer portAllocatorLock.Unlock()
+
+	leases, err := NewPortLeaseService().GetPortLeases()
+	if err != nil {
+		return fmt.Errorf("failed to get port leases, error: %v", err)
+	}
+
+	var containerNames = make(map[string]struct{})
+	var boundPort = make(map[int]models.PortLeaseEntity)
+	for _, c := range containers {
+		var name string
+		for _, n := range c.Names {
+			name = strings.TrimPrefix(n, "/")
+			containerNames[name] = struct{}{}
+		}
+		jobUuid, ok := c.Labels[dockerJobUuidLabel]
+		if !ok {
+			continue
+		}
+		for _, p := range c.Ports {
+			if _, ok := inRange[int(p.PublicPort)]; ok && p.PublicPort > 0 {
+				boundPort[int(p.PublicPort)] = models.PortLeaseEntity{
+					Port:          int(p.PublicPort),
+					JobUuid:       jobUuid,
+					ContainerName: name,
+					CreateTime:    c.Created,
+				}
+			}
+		}
+	}
+
+	now := time.Now().Unix()
+	var leasedPort = make(map[int]models.PortLeaseEntity, len(leases))
+	for _, lease := range leases {
+		bound, isBound := boundPort[lease.Port]
+		_, exist := containerNames[lease.ContainerName]
+		switch {
+		case isBound && bound.JobUuid != lease.JobUuid:
+			// the port is taken by the container of another job, the container wins
+		case exist || (lease.ExpireTime > 0 && now < lease.ExpireTime):
+			leasedPort[lease.Port] = lease
+			continue
+		}
+		logs.GetLogger().Infof("release port %d of job_uuid: %s, container %s not found", lease.Port, lease.JobUuid, lease.ContainerName)
+		if err = NewPortLeaseService().DeletePortLease(lease.Port); err != nil {
+			logs.GetLogger().Errorf("failed to release port %d, error: %v", lease.Port, err)
+		}
+	}
+
+	for port, bound := range boundPort {
+		if _, ok := leasedPort[port]; ok {
+			continue
+		}
+		logs.GetLogger().Infof("adopt port %d of job_uuid: %s, container: %s", port, bound.JobUuid, bound.ContainerName)
+		if err = NewPortLeaseService().SavePortLeases([]models.PortLeaseEntity{bound}); err != nil {
+			logs.GetLogger().Errorf("failed to adopt port %d, error: %v", port, err)
+		}
+	}
+	return nil
+}
+
+// getPortUsage reports the leased and free ports of API.PortRange
+func getPortUsage() (*models.PortUsage, error) {
+	portRange, err := parsePortRanges(conf.GetConfig().API.PortRange)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ports in config, error:%v", err)
+	}
+	leases, err := NewPortLeaseService().GetPortLeases()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get port leases, error: %v", err)
+	}
+
+	var leasedPort = make(map[int]string, len(leases))
+	for _, lease := range leases {
+		leasedPort[lease.Port] = lease.JobUuid
+	}
+
+	usage := &models.PortUsage{
+		Total:  len(portRange),
+		Leases: []models.PortLease{},
+	}
+	for _, p := range portRange {
+		if jobUuid, ok := leasedPort[p]; ok {
+			usage.Used++
+			usage.Leases = append(usage.Leases, models.PortLease{Port: p, JobUuid: jobUuid})
+		}
+	}
+	usage.Free = usage.Total - usage.Used
+	return usage, nil
+}
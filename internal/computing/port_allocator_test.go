@@ -0,0 +1,94 @@
+package computing
+
+import (
+	"net"
+	"testing"
+
+	"github.com/swanchain/go-computing-provider/internal/db"
+	"github.com/swanchain/go-computing-provider/internal/models"
+)
+
+// freePorts returns n ports nothing listens on, and one port kept busy by a listener
+func freePorts(t *testing.T, n int) ([]int, int) {
+	t.Helper()
+	var ports []int
+	var listeners []net.Listener
+	for i := 0; i <= n; i++ {
+		ln, err := net.Listen("tcp", ":0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		listeners = append(listeners, ln)
+		ports = append(ports, ln.Addr().(*net.TCPAddr).Port)
+	}
+	for _, ln := range listeners[1:] {
+		ln.Close()
+	}
+	t.Cleanup(func() { listeners[0].Close() })
+	return ports[1:], ports[0]
+}
+
+func TestLeasePorts(t *testing.T) {
+	db.InitDb(t.TempDir())
+	free, busy := freePorts(t, 3)
+	portRange := append([]int{busy}, free...)
+
+	_, first, err := leasePorts(portRange, "job-1", "container-1", []int{80, 443})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 2 || first[0].ExternalPort != free[0] || first[1].ExternalPort != free[1] {
+		t.Errorf("expected the free ports %v, got %+v", free[:2], first)
+	}
+
+	// the ports leased to the first job are not handed out again
+	_, second, err := leasePorts(portRange, "job-2", "container-2", []int{8080})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(second) != 1 || second[0].ExternalPort != free[2] {
+		t.Errorf("expected the port %d, got %+v", free[2], second)
+	}
+	if _, _, err = leasePorts(portRange, "job-3", "container-3", []int{8080}); err == nil {
+		t.Errorf("expected no port left")
+	}
+
+	leases, err := NewPortLeaseService().GetPortLeasesByJobUuid("job-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, lease := range leases {
+		if lease.ExpireTime == 0 {
+			t.Errorf("expected the lease of port %d to expire before the container starts", lease.Port)
+		}
+	}
+	confirmPorts("job-1")
+	if leases, _ = NewPortLeaseService().GetPortLeasesByJobUuid("job-1"); len(leases) != 2 || leases[0].ExpireTime != 0 {
+		t.Errorf("expected the confirmed leases to be held, got %+v", leases)
+	}
+
+	releasePorts("job-2")
+	_, third, err := leasePorts(portRange, "job-3", "container-3", []int{8080})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third[0].ExternalPort != free[2] {
+		t.Errorf("expected the released port %d, got %d", free[2], third[0].ExternalPort)
+	}
+}
+
+func TestSavePortLeasesConflict(t *testing.T) {
+	db.InitDb(t.TempDir())
+	if err := NewPortLeaseService().SavePortLeases([]models.PortLeaseEntity{{Port: 40001, JobUuid: "job-1"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a lease of a port already leased fails the whole job
+	err := NewPortLeaseService().SavePortLeases([]models.PortLeaseEntity{{Port: 40002, JobUuid: "job-2"}, {Port: 40001, JobUuid: "job-2"}})
+	if err == nil {
+		t.Fatalf("expected the leased port to be rejected")
+	}
+	if leases, _ := NewPortLeaseService().GetPortLeasesByJobUuid("job-2"); len(leases) != 0 {
+		t.Errorf("expected no lease of the rejected job, got %+v", leases)
+	}
+}
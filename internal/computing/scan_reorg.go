@@ -0,0 +1,132 @@
+package computing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/internal/contract/ecp"
+	"github.com/swanchain/go-computing-provider/internal/models"
+)
+
+// scanBlocksKept is the checkpoints kept per scanner, a reorg deeper than them is rolled back to the oldest one
+const scanBlocksKept = 128
+
+// scannerLedgerCategory is the category of the ledger entries recorded by each scanner
+var scannerLedgerCategory = map[int]string{
+	models.ScannerTaskPaymentId:    models.LedgerEcpReward,
+	models.ScannerFcpTaskManagerId: models.LedgerFcpReward,
+	models.ScannerSequencerFeeId:   models.LedgerSequencerFee,
+	models.ScannerEventIndexerId:   models.LedgerCollateral,
+}
+
+// confirmedBlock returns the last block with the confirmations of the config, the scanners do not go beyond it
+func confirmedBlock(client *ethclient.Client) (uint64, error) {
+	head, err := client.BlockNumber(context.Background())
+	if err != nil {
+		return 0, fmt.Errorf("failed to get chain height, error: %s", ecp.ParseTooManyError(err))
+	}
+	confirmations := conf.GetConfig().RPC.Confirmations
+	if head < confirmations {
+		return 0, nil
+	}
+	return head - confirmations, nil
+}
+
+// saveScannedBlock saves the checkpoint of the scanner with the hash of the block, to detect a reorg on the next scan
+func saveScannedBlock(client *ethclient.Client, block uint64, scannerId int) error {
+	header, err := client.HeaderByNumber(context.Background(), new(big.Int).SetUint64(block))
+	if err != nil {
+		return fmt.Errorf("failed to get the header of block %d, error: %s", block, ecp.ParseTooManyError(err))
+	}
+	scanBlockService := NewScanBlockService()
+	if err = scanBlockService.SaveScanBlock(&models.ScanBlockEntity{
+		ScannerId:   int64(scannerId),
+		BlockNumber: int64(block),
+		BlockHash:   header.Hash().Hex(),
+		CreateTime:  time.Now().Unix(),
+	}); err != nil {
+		return fmt.Errorf("failed to save the hash of block %d, error: %v", block, err)
+	}
+	saveLastProcessedBlock(int64(block), scannerId)
+
+	if block > scanBlocksKept {
+		if err = scanBlockService.DeleteScanBlocksBefore(scannerId, int64(block-scanBlocksKept)); err != nil {
+			logs.GetLogger().Warnf("failed to remove the old block hashes of scanner %d, error: %v", scannerId, err)
+		}
+	}
+	return nil
+}
+
+// checkReorg compares the hashes of the blocks the scanner saved its checkpoints at with the chain. After a reorg, it
+// removes the ledger entries of the orphaned blocks, recomputes the rewards of their tasks, and moves the checkpoint
+// back to the last block still in the chain, so the scan reprocesses the blocks after it.
+func checkReorg(client *ethclient.Client, scannerId int) error {
+	blocks, err := NewScanBlockService().GetScanBlocks(scannerId)
+	if err != nil {
+		return fmt.Errorf("failed to get the block hashes of scanner %d, error: %v", scannerId, err)
+	}
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	forkBlock := blocks[len(blocks)-1].BlockNumber - 1
+	for i, block := range blocks {
+		header, err := client.HeaderByNumber(context.Background(), big.NewInt(block.BlockNumber))
+		if err != nil && !errors.Is(err, ethereum.NotFound) {
+			return fmt.Errorf("failed to get the header of block %d, error: %s", block.BlockNumber, ecp.ParseTooManyError(err))
+		}
+		if header != nil && header.Hash().Hex() == block.BlockHash {
+			if i == 0 {
+				return nil
+			}
+			forkBlock = block.BlockNumber
+			break
+		}
+	}
+	if forkBlock < 0 {
+		forkBlock = 0
+	}
+
+	logs.GetLogger().Warnf("detected a reorg of the blocks scanned by scanner %d, rolling back to block %d", scannerId, forkBlock)
+	return rollbackScannedBlocks(scannerId, forkBlock)
+}
+
+// rollbackScannedBlocks removes what the scanner recorded after the block, and moves its checkpoint back to the block
+func rollbackScannedBlocks(scannerId int, forkBlock int64) error {
+	if category, ok := scannerLedgerCategory[scannerId]; ok {
+		entries, err := NewLedgerService().RemoveLedgerEntriesAfter(category, uint64(forkBlock))
+		if err != nil {
+			return fmt.Errorf("failed to remove the ledger entries after block %d, error: %v", forkBlock, err)
+		}
+		tasks := make(map[string]bool)
+		for _, entry := range entries {
+			logs.GetLogger().Warnf("removed the %s of orphaned block %d, tx: %s, task: %s, amount: %s", entry.Category,
+				entry.BlockNumber, entry.TxHash, entry.TaskUuid, entry.Amount)
+			if entry.TaskUuid != "" && !tasks[entry.TaskUuid] {
+				tasks[entry.TaskUuid] = true
+				if err = updateTaskReward(category, entry.TaskUuid); err != nil {
+					logs.GetLogger().Errorf("%v", err)
+				}
+			}
+		}
+	}
+
+	if scannerId == models.ScannerEventIndexerId {
+		if err := NewChainEventService().DeleteChainEventsAfter(uint64(forkBlock)); err != nil {
+			return fmt.Errorf("failed to remove the events after block %d, error: %v", forkBlock, err)
+		}
+	}
+
+	if err := NewScanBlockService().DeleteScanBlocksAfter(scannerId, forkBlock); err != nil {
+		return fmt.Errorf("failed to remove the block hashes after block %d, error: %v", forkBlock, err)
+	}
+	saveLastProcessedBlock(forkBlock, scannerId)
+	return nil
+}
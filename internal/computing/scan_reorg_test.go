@@ -0,0 +1,151 @@
+package computing
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/swanchain/go-computing-provider/internal/db"
+	"github.com/swanchain/go-computing-provider/internal/models"
+)
+
+// fakeChain serves the headers of the blocks, the time of a block is its number plus the fork offset from the fork block
+func fakeChain(t *testing.T, forkBlock uint64, forkOffset uint64) *ethclient.Client {
+	return fakeRpc(t, map[string]func(params []json.RawMessage) interface{}{
+		"eth_getBlockByNumber": func(params []json.RawMessage) interface{} {
+			number := blockParam(t, params[0])
+			if number > forkBlock {
+				return fakeHeader(number, 1700000000+number+forkOffset)
+			}
+			return fakeHeader(number, 1700000000+number)
+		},
+	})
+}
+
+func saveTestScanBlocks(t *testing.T, scannerId int, blocks ...uint64) {
+	t.Helper()
+	for _, block := range blocks {
+		header := &types.Header{Number: new(big.Int).SetUint64(block), Time: 1700000000 + block, Difficulty: new(big.Int)}
+		if err := NewScanBlockService().SaveScanBlock(&models.ScanBlockEntity{
+			ScannerId:   int64(scannerId),
+			BlockNumber: int64(block),
+			BlockHash:   header.Hash().Hex(),
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	saveLastProcessedBlock(int64(blocks[len(blocks)-1]), scannerId)
+}
+
+func saveTestReward(t *testing.T, txHash string, blockNumber uint64, amount string) {
+	t.Helper()
+	if err := NewLedgerService().SaveLedgerEntry(&models.LedgerEntity{
+		Kind:        models.LedgerReward,
+		Category:    models.LedgerEcpReward,
+		TaskUuid:    "task-1",
+		TxHash:      common.HexToHash(txHash).Hex(),
+		BlockNumber: blockNumber,
+		Amount:      amount,
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func lastProcessedBlock(t *testing.T, scannerId int) int64 {
+	t.Helper()
+	var scan models.ScanChainEntity
+	if err := db.DB.Where("id=?", scannerId).Find(&scan).Error; err != nil {
+		t.Fatal(err)
+	}
+	return scan.BlockNumber
+}
+
+func TestCheckReorgWithoutFork(t *testing.T) {
+	db.InitDb(t.TempDir())
+	saveTestScanBlocks(t, models.ScannerTaskPaymentId, 10, 11, 12)
+
+	if err := checkReorg(fakeChain(t, 100, 0), models.ScannerTaskPaymentId); err != nil {
+		t.Fatal(err)
+	}
+	if got := lastProcessedBlock(t, models.ScannerTaskPaymentId); got != 12 {
+		t.Errorf("expected the checkpoint to stay at 12, got %d", got)
+	}
+}
+
+func TestCheckReorgRollsBackOrphanedBlocks(t *testing.T) {
+	db.InitDb(t.TempDir())
+	if err := NewEcpJobService().SaveEcpJobEntity(&models.EcpJobEntity{Uuid: "task-1", Reward: "30", CreateTime: 1}); err != nil {
+		t.Fatal(err)
+	}
+	saveTestScanBlocks(t, models.ScannerTaskPaymentId, 10, 11, 12)
+	saveTestReward(t, "0x01", 9, "10")
+	saveTestReward(t, "0x02", 12, "20")
+
+	// the blocks after 10 were replaced
+	if err := checkReorg(fakeChain(t, 10, 1), models.ScannerTaskPaymentId); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := lastProcessedBlock(t, models.ScannerTaskPaymentId); got != 10 {
+		t.Errorf("expected the checkpoint to move back to 10, got %d", got)
+	}
+	blocks, err := NewScanBlockService().GetScanBlocks(models.ScannerTaskPaymentId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 1 || blocks[0].BlockNumber != 10 {
+		t.Errorf("expected only the block 10 to be kept, got %+v", blocks)
+	}
+	total, err := NewLedgerService().SumLedgerAmounts(models.LedgerEcpReward, "task-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total.String() != "10" {
+		t.Errorf("expected the reward of the orphaned block to be removed, got %s", total)
+	}
+	if job, _ := NewEcpJobService().GetEcpJobByUuid("task-1"); job.Reward != "10" {
+		t.Errorf("expected the reward of the job to be recomputed to 10, got %s", job.Reward)
+	}
+}
+
+func TestCheckReorgDeeperThanCheckpoints(t *testing.T) {
+	db.InitDb(t.TempDir())
+	saveTestScanBlocks(t, models.ScannerTaskPaymentId, 10, 11, 12)
+
+	// none of the saved blocks is in the chain, the scan goes back before the oldest one
+	if err := checkReorg(fakeChain(t, 5, 1), models.ScannerTaskPaymentId); err != nil {
+		t.Fatal(err)
+	}
+	if got := lastProcessedBlock(t, models.ScannerTaskPaymentId); got != 9 {
+		t.Errorf("expected the checkpoint to move back to 9, got %d", got)
+	}
+}
+
+func TestRollbackScannedBlocksOfEventIndexer(t *testing.T) {
+	db.InitDb(t.TempDir())
+	saveTestScanBlocks(t, models.ScannerEventIndexerId, 20, 21)
+	for _, block := range []uint64{20, 21} {
+		if err := db.DB.Create(&models.CollateralEventEntity{ChainEvent: models.ChainEvent{
+			TxHash: common.BigToHash(new(big.Int).SetUint64(block)).Hex(), BlockNumber: block,
+		}}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := rollbackScannedBlocks(models.ScannerEventIndexerId, 20); err != nil {
+		t.Fatal(err)
+	}
+	var events []models.CollateralEventEntity
+	if err := db.DB.Find(&events).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].ChainEvent.BlockNumber != 20 {
+		t.Errorf("expected the events after block 20 to be removed, got %+v", events)
+	}
+	if got := lastProcessedBlock(t, models.ScannerEventIndexerId); got != 20 {
+		t.Errorf("expected the checkpoint at 20, got %d", got)
+	}
+}
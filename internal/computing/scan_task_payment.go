@@ -12,9 +12,7 @@ import (
 	"github.com/swanchain/go-computing-provider/internal/db"
 	"github.com/swanchain/go-computing-provider/internal/models"
 	"io"
-	"math/big"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -38,50 +36,60 @@ func (tps *TaskPaymentService) ScannerChainGetTaskPayment() {
 	if conf.GetConfig().CONTRACT.EdgeTaskPayment == "" {
 		return
 	}
-	chainRpc, err := conf.GetRpcByNetWorkName()
+	if err := tps.dial(); err != nil {
+		logs.GetLogger().Errorf("%v", err)
+		return
+	}
+	defer tps.client.Close()
+
+	cpAccountAddress, err := contract.GetCpAccountAddress()
 	if err != nil {
-		logs.GetLogger().Errorf("failed to get chain rpc, error: %v", err)
+		logs.GetLogger().Errorf("failed to get cp account contract address, error: %v", err)
 		return
 	}
+
+	if err := tps.scanAndProcessEvents(cpAccountAddress); err != nil {
+		logs.GetLogger().Errorf("%v", err)
+	}
+}
+
+// dial connects the service to the task payment contract, the caller closes the client
+func (tps *TaskPaymentService) dial() error {
+	chainRpc, err := conf.GetRpcByNetWorkName()
+	if err != nil {
+		return fmt.Errorf("failed to get chain rpc, error: %v", err)
+	}
 	client, err := contract.GetEthClient(chainRpc)
 	if err != nil {
-		logs.GetLogger().Errorf("error: %v", err)
-		return
+		return fmt.Errorf("failed to dial rpc, error: %v", err)
 	}
-	defer client.Close()
 
 	contractAddress := common.HexToAddress(conf.GetConfig().CONTRACT.EdgeTaskPayment)
 	taskPayment, err := ecp.NewTaskPayment(contractAddress, client)
 	if err != nil {
-		logs.GetLogger().Errorf("faile to create task payment client, error: %v", err)
-		return
+		client.Close()
+		return fmt.Errorf("faile to create task payment client, error: %v", err)
 	}
 
 	tps.payment = taskPayment
 	tps.client = client
-
-	cpAccountAddress, err := contract.GetCpAccountAddress()
-	if err != nil {
-		logs.GetLogger().Errorf("failed to get cp account contract address, error: %v", err)
-		return
-	}
-
-	if err := tps.scanAndProcessEvents(cpAccountAddress); err != nil {
-		logs.GetLogger().Errorf("%v", err)
-	}
+	return nil
 }
 
 func (tps *TaskPaymentService) scanAndProcessEvents(cpAccountAddress string) error {
+	if err := checkReorg(tps.client, models.ScannerTaskPaymentId); err != nil {
+		return err
+	}
+
 	lastProcessedBlock := loadLastProcessedBlock(models.ScannerTaskPaymentId)
-	header, err := tps.client.HeaderByNumber(context.Background(), nil)
+	currentBlock, err := confirmedBlock(tps.client)
 	if err != nil {
 		time.Sleep(10 * time.Second)
-		if strings.Contains(err.Error(), "Too Many Requests") {
-			return fmt.Errorf("failed to get chain header, error: Too Many Requests")
-		}
-		return fmt.Errorf("failed to get chain header, error: %v", err)
+		return err
+	}
+	if uint64(lastProcessedBlock) >= currentBlock {
+		return nil
 	}
-	currentBlock := header.Number.Uint64()
 
 	filterOpts := &bind.FilterOpts{
 		Start:   uint64(lastProcessedBlock + 1),
@@ -99,24 +107,50 @@ func (tps *TaskPaymentService) scanAndProcessEvents(cpAccountAddress string) err
 		logs.GetLogger().Infof("handle event: TaskUUID=%s, Account=%s, CPAccount=%s, Beneficiary=%s, TransferAmount=%s",
 			event.TaskUUID, event.Account.Hex(), event.CpAccount.Hex(), event.Beneficiary.Hex(), event.TransferAmount.String())
 
-		lastProcessedBlock = int64(event.Raw.BlockNumber)
-		handleEdgeTask(event.TaskUUID, cpAccountAddress, lastProcessedBlock, event.TransferAmount)
+		if err = tps.recordEdgeTaskReward(event); err != nil {
+			logs.GetLogger().Errorf("%v", err)
+		}
+		handleEdgeTask(event.TaskUUID, cpAccountAddress)
 	}
 	if iter.Error() != nil {
 		return fmt.Errorf("failed to iterator events, error: %v", iter.Error())
 	}
-	saveLastProcessedBlock(lastProcessedBlock, models.ScannerTaskPaymentId)
-	return nil
+	return saveScannedBlock(tps.client, currentBlock, models.ScannerTaskPaymentId)
 }
 
-func handleEdgeTask(taskUuid, cpAccountAddress string, blockNumber int64, amount *big.Int) {
+// scanEdgeTaskRewards records the rewards of the transfer events in the range, without touching the containers
+func (tps *TaskPaymentService) scanEdgeTaskRewards(opts *bind.FilterOpts, cpAccountAddress string) error {
+	iter, err := tps.payment.FilterTransferToCPBeneficiary(opts, []common.Address{common.HexToAddress(cpAccountAddress)})
+	if err != nil {
+		return err
+	}
+	defer iter.Close()
+	for iter.Next() {
+		if err = tps.recordEdgeTaskReward(iter.Event); err != nil {
+			logs.GetLogger().Errorf("%v", err)
+		}
+	}
+	return iter.Error()
+}
+
+// recordEdgeTaskReward records the transfer in the ledger, then sets the reward of the job to the total of its transfers
+// and of the reward it had before the ledger, so processing an event again does not count it twice
+func (tps *TaskPaymentService) recordEdgeTaskReward(event *ecp.TaskPaymentTransferToCPBeneficiary) error {
+	recordEventReward(tps.client, event.Raw, models.LedgerEcpReward, LedgerTaskTypeEcp, event.TaskUUID, event.Beneficiary, event.TransferAmount)
+	total, err := NewLedgerService().SumLedgerAmounts(models.LedgerEcpReward, event.TaskUUID)
+	if err != nil {
+		return fmt.Errorf("failed to sum the rewards of edge task %s, error: %v", event.TaskUUID, err)
+	}
+	return NewEcpJobService().UpdateEcpJobEntityRewardAndBlock(event.TaskUUID, int64(event.Raw.BlockNumber), total.String())
+}
+
+func handleEdgeTask(taskUuid, cpAccountAddress string) {
 	ecpJob, err := NewEcpJobService().GetEcpJobByUuid(taskUuid)
 	if err != nil {
 		logs.GetLogger().Errorf("failed to get edge task, error: %v", err)
 		return
 	}
 
-	NewEcpJobService().UpdateEcpJobEntityRewardAndBlock(taskUuid, blockNumber, ecpJob.Reward+balanceToFloat(amount))
 	status, err := getTaskStatus(taskUuid, cpAccountAddress)
 	if err != nil {
 		logs.GetLogger().Errorf("%v", err)
@@ -129,6 +163,7 @@ func handleEdgeTask(taskUuid, cpAccountAddress string, blockNumber int64, amount
 		}
 		logs.GetLogger().Infof("scanner_deleted, task_uuid: %s", taskUuid)
 		NewEcpJobService().DeleteContainerByUuid(ecpJob.Uuid)
+		releasePorts(ecpJob.Uuid)
 	}
 }
 
@@ -151,6 +186,7 @@ func checkAgain(cpAccountAddress string, blockNumber int64) {
 				return
 			}
 			NewEcpJobService().DeleteContainerByUuid(job.Uuid)
+			releasePorts(job.Uuid)
 		}
 	}
 }
@@ -232,16 +268,3 @@ type TaskStatus struct {
 		Status string `json:"status"`
 	} `json:"data"`
 }
-
-func balanceToFloat(balance *big.Int) float64 {
-	var ethValue float64
-	if balance.String() == "0" {
-		ethValue = 0
-	} else {
-		fbalance := new(big.Float)
-		fbalance.SetString(balance.String())
-		etherQuotient := new(big.Float).Quo(fbalance, new(big.Float).SetInt(big.NewInt(1e18)))
-		ethValue, _ = etherQuotient.Float64()
-	}
-	return ethValue
-}
@@ -0,0 +1,77 @@
+package computing
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/swanchain/go-computing-provider/internal/contract/ecp"
+	"github.com/swanchain/go-computing-provider/internal/db"
+	"github.com/swanchain/go-computing-provider/internal/models"
+)
+
+func TestRecordEdgeTaskRewardKeepsRewardBeforeLedger(t *testing.T) {
+	repo := t.TempDir()
+	db.InitDb(repo)
+	if err := NewEcpJobService().SaveEcpJobEntity(&models.EcpJobEntity{Uuid: "task-1", Reward: "5000", CreateTime: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.DB.Save(&models.ScanChainEntity{Id: models.ScannerTaskPaymentId, BlockNumber: 100}).Error; err != nil {
+		t.Fatal(err)
+	}
+	// the upgrade records the reward of the job in the ledger
+	db.InitDb(repo)
+
+	// the rpc serves no block, the ledger falls back to the current time
+	tps := &TaskPaymentService{client: fakeRpc(t, nil)}
+	transfer := func(txHash string, blockNumber uint64, amount int64) {
+		t.Helper()
+		err := tps.recordEdgeTaskReward(&ecp.TaskPaymentTransferToCPBeneficiary{
+			TaskUUID:       "task-1",
+			Beneficiary:    common.HexToAddress("0x02"),
+			TransferAmount: big.NewInt(amount),
+			Raw:            types.Log{TxHash: common.HexToHash(txHash), BlockNumber: blockNumber},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	job := func() *models.EcpJobEntity {
+		t.Helper()
+		job, err := NewEcpJobService().GetEcpJobByUuid("task-1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return job
+	}
+
+	// a transfer the scanner had reached is in the reward already
+	transfer("0x01", 90, 7)
+	if got := job().Reward; got != "5000" {
+		t.Fatalf("expected the reward before the ledger, 5000, got %s", got)
+	}
+
+	transfer("0x02", 120, 7)
+	transfer("0x02", 120, 7)
+	if got := job(); got.Reward != "5007" || got.LastBlockNumber != 120 {
+		t.Fatalf("expected the reward 5007 paid in the block 120, got %s in the block %d", got.Reward, got.LastBlockNumber)
+	}
+
+	// a backfill of an older block does not move the last block back
+	transfer("0x03", 110, 7)
+	if got := job(); got.Reward != "5014" || got.LastBlockNumber != 120 {
+		t.Fatalf("expected the reward 5014 paid in the block 120, got %s in the block %d", got.Reward, got.LastBlockNumber)
+	}
+
+	// a reorg rolls back the transfers, not the reward before the ledger
+	if _, err := NewLedgerService().RemoveLedgerEntriesAfter(models.LedgerEcpReward, 50); err != nil {
+		t.Fatal(err)
+	}
+	if err := updateTaskReward(models.LedgerEcpReward, "task-1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := job().Reward; got != "5000" {
+		t.Errorf("expected the reward before the ledger after the rollback, 5000, got %s", got)
+	}
+}
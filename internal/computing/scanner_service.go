@@ -67,15 +67,19 @@ func (taskManager *TaskManagerContract) Scan() error {
 	var end uint64
 	var err error
 
+	if err = checkReorg(taskManager.ethClient, models.ScannerFcpTaskManagerId); err != nil {
+		return err
+	}
+
 	scannedBlock := loadLastProcessedBlock(models.ScannerFcpTaskManagerId)
 	start := uint64(scannedBlock)
 	if scannedBlock != 0 {
 		start = uint64(scannedBlock) + 1
 	}
 
-	endBlockNumber, err = taskManager.ethClient.BlockNumber(context.Background())
+	endBlockNumber, err = confirmedBlock(taskManager.ethClient)
 	if err != nil {
-		return fmt.Errorf("failed to get chain height, error: %s", ecp.ParseTooManyError(err))
+		return err
 	}
 
 	var step uint64 = 1000
@@ -93,7 +97,9 @@ func (taskManager *TaskManagerContract) Scan() error {
 			return fmt.Errorf("failed to scan task, start: %d, end: %d, error: %s", i, end, ecp.ParseTooManyError(err))
 		}
 		logs.GetLogger().Infof("successfully to scan task, start: %d, end: %d", i, end)
-		saveLastProcessedBlock(int64(end), models.ScannerFcpTaskManagerId)
+		if err := saveScannedBlock(taskManager.ethClient, end, models.ScannerFcpTaskManagerId); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -112,12 +118,11 @@ func (taskManager *TaskManagerContract) scanTaskRewards(opts *bind.FilterOpts) (
 			}
 		}
 	}
-	return nil
+	return iterator.Error()
 }
 
 func (taskManager *TaskManagerContract) parseRewardReleased(event *fcp.FcpTaskManagerRewardReleased) error {
 	raw := event.Raw
-	amount := contract.BalanceToStr(event.RewardAmount)
 
 	var taskUUIDs []string
 	paras, err := TransactionInputParas(taskManager.ethClient, taskManager.sigMethods, raw.TxHash)
@@ -176,7 +181,8 @@ func (taskManager *TaskManagerContract) parseRewardReleased(event *fcp.FcpTaskMa
 	if taskUUID == "" {
 		return fmt.Errorf("tx not found task equal to log task uuid hash, taskuuid_event: %s, taskuuid_raw: %s", taskUUIDHash, raw.TxHash.Hex())
 	}
-	return NewJobService().UpdateJobReward(taskUUID, amount)
+	recordEventReward(taskManager.ethClient, raw, models.LedgerFcpReward, LedgerTaskTypeFcp, taskUUID, event.Beneficiary, event.RewardAmount)
+	return updateTaskReward(models.LedgerFcpReward, taskUUID)
 }
 
 func TransactionInputParas(ethClient *ethclient.Client, sigMethods map[string]abi.Method, txHash common.Hash) ([]interface{}, error) {
@@ -0,0 +1,394 @@
+package computing
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/swanchain/go-computing-provider/constants"
+	"github.com/swanchain/go-computing-provider/internal/models"
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// a reservation is kept until the pod of the job is running, or dropped after this period
+	scheduleReservationTTL = 20 * time.Minute
+
+	// gpu fit outweighs bin-packing, so the gpus of a model are used up node by node
+	scheduleGpuWeight = 2.0
+)
+
+var (
+	scheduler     *Scheduler
+	schedulerOnce sync.Once
+)
+
+// ScheduleRequest is the hardware a job needs, the memory and storage are in GiB.
+type ScheduleRequest struct {
+	Cpu     int64
+	Memory  float64
+	Storage float64
+	// Gpus maps the requested gpu model to the number of gpus
+	Gpus map[string]int
+	// NodeName restricts the placement to this node when set, e.g. to resize a running job
+	NodeName string
+}
+
+// Placement is the node chosen for a job and the gpus reserved on it.
+type Placement struct {
+	NodeName     string
+	Architecture string
+	// GpuName is the gpu model of the node that matched the first requested model
+	GpuName  string
+	GpuIndex []string
+	PrepareG []models.PodGpu
+}
+
+type reservation struct {
+	nodeName   string
+	cpu        int64
+	memory     int64
+	storage    int64
+	gpuIndex   map[string][]string
+	createTime time.Time
+}
+
+// Scheduler places FCP jobs over the nodes of the cluster. It scores the nodes by the free gpus of the requested models
+// and the cpu/memory headroom, and reserves the chosen capacity until the pod of the job is running, so that two
+// jobs received at the same time can not be placed on the same free gpus.
+type Scheduler struct {
+	lock         sync.Mutex
+	reservations map[string]*reservation
+}
+
+type nodeCapacity struct {
+	node         coreV1.Node
+	architecture string
+	totalCpu     int64
+	totalMemory  int64
+	freeCpu      int64
+	freeMemory   int64
+	freeStorage  int64
+	freeGpuIndex map[string][]string
+}
+
+func GetScheduler() *Scheduler {
+	schedulerOnce.Do(func() {
+		scheduler = &Scheduler{
+			reservations: make(map[string]*reservation),
+		}
+	})
+	return scheduler
+}
+
+// Schedule chooses the best node for the job and reserves the requested hardware on it. It returns a nil placement
+// and the reasons when no node can run the job.
+func (s *Scheduler) Schedule(jobUuid string, req ScheduleRequest) (*Placement, []string, error) {
+	jobUuid = strings.ToLower(jobUuid)
+	logs.GetLogger().Infof("job_uuid: %s, schedule: needCpu: %d, needMemory: %.2f, needStorage: %.2f, needGpu: %v, node: %s",
+		jobUuid, req.Cpu, req.Memory, req.Storage, req.Gpus, req.NodeName)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	nodes, err := s.snapshot(jobUuid)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var best *Placement
+	var bestScore float64
+	var noAvailableStrMap = make(map[string][]string)
+	var candidates int
+	for _, nc := range nodes {
+		if req.NodeName != "" && nc.node.Name != req.NodeName {
+			continue
+		}
+		candidates++
+
+		placement, noAvailableStr := nc.fit(req)
+		if placement == nil {
+			noAvailableStrMap[nc.node.Name] = noAvailableStr
+			logs.GetLogger().Warnf("the job_uuid: %s is not available for this node=%s resource. Reason: %s",
+				jobUuid, nc.node.Name, strings.Join(noAvailableStr, ";"))
+			continue
+		}
+		score := nc.score(req, placement)
+		logs.GetLogger().Infof("job_uuid: %s, node: %s, score: %.4f", jobUuid, nc.node.Name, score)
+		if best == nil || score > bestScore {
+			best, bestScore = placement, score
+		}
+	}
+
+	if best == nil {
+		if candidates == 1 {
+			for _, msgs := range noAvailableStrMap {
+				return nil, msgs, nil
+			}
+		}
+		var noAvailableSummary []string
+		noAvailableSummary = append(noAvailableSummary, fmt.Sprintf("cpu need: %d", req.Cpu))
+		noAvailableSummary = append(noAvailableSummary, fmt.Sprintf("memory need: %f", req.Memory))
+		noAvailableSummary = append(noAvailableSummary, fmt.Sprintf("storage need: %f", req.Storage))
+		if len(req.Gpus) > 0 {
+			noAvailableSummary = append(noAvailableSummary, fmt.Sprintf("needGpu: %v", req.Gpus))
+		}
+		noAvailableSummary = append(noAvailableSummary, "not found available node")
+		return nil, noAvailableSummary, nil
+	}
+
+	r := &reservation{
+		nodeName:   best.NodeName,
+		cpu:        req.Cpu,
+		memory:     int64(req.Memory * 1024 * 1024 * 1024),
+		storage:    int64(req.Storage * 1024 * 1024 * 1024),
+		gpuIndex:   make(map[string][]string),
+		createTime: time.Now(),
+	}
+	for _, g := range best.PrepareG {
+		gName := normalizeGpuName(g.Gname)
+		r.gpuIndex[gName] = append(r.gpuIndex[gName], g.Gindex...)
+	}
+	s.reservations[jobUuid] = r
+	logs.GetLogger().Infof("job_uuid: %s, scheduled to node: %s, gpu: %v", jobUuid, best.NodeName, best.PrepareG)
+	return best, nil, nil
+}
+
+// Release drops the reservation of the job, it is called once the pod of the job is running or the deployment failed.
+func (s *Scheduler) Release(jobUuid string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.reservations, strings.ToLower(jobUuid))
+}
+
+// snapshot collects the free capacity of every node, minus the capacity reserved for jobs that have no running pod yet.
+// The caller must hold the lock.
+func (s *Scheduler) snapshot(jobUuid string) ([]*nodeCapacity, error) {
+	k8sService := NewK8sService()
+	activePods, err := k8sService.GetAllActivePod(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := k8sService.k8sClient.CoreV1().Nodes().List(context.TODO(), metaV1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	nodeGpuSummary, nodeNameMachineId, err := k8sService.GetNodeGpuSummary(context.TODO())
+	if err != nil {
+		logs.GetLogger().Errorf("Failed collect k8s gpu, error: %+v", err)
+		return nil, err
+	}
+
+	// a reservation is taken over by the pod created for it, an older pod of the same job (e.g. before a resize) is not
+	var runningJobs = make(map[string]time.Time)
+	for _, pod := range activePods {
+		if uuid := strings.ToLower(jobUuidOfPod(&pod)); uuid != "" && pod.CreationTimestamp.After(runningJobs[uuid]) {
+			runningJobs[uuid] = pod.CreationTimestamp.Time
+		}
+	}
+	for uuid, r := range s.reservations {
+		podCreated, running := runningJobs[uuid]
+		if (running && podCreated.After(r.createTime)) || uuid == jobUuid || time.Since(r.createTime) > scheduleReservationTTL {
+			delete(s.reservations, uuid)
+		}
+	}
+
+	var capacities []*nodeCapacity
+	for _, node := range nodes.Items {
+		nodeGpu, remainderResource, _ := GetNodeResource(activePods, &node)
+		nc := &nodeCapacity{
+			node:         node,
+			totalCpu:     node.Status.Capacity.Cpu().Value(),
+			totalMemory:  node.Status.Capacity.Memory().Value(),
+			freeCpu:      remainderResource[ResourceCpu],
+			freeMemory:   remainderResource[ResourceMem],
+			freeStorage:  remainderResource[ResourceStorage],
+			freeGpuIndex: make(map[string][]string),
+		}
+		if _, ok := node.Labels[constants.CPU_INTEL]; ok {
+			nc.architecture = constants.CPU_INTEL
+		}
+		if _, ok := node.Labels[constants.CPU_AMD]; ok {
+			nc.architecture = constants.CPU_AMD
+		}
+
+		var usedGpuIndex = make(map[string][]string)
+		for gName, gData := range nodeGpu {
+			usedGpuIndex[normalizeGpuName(gName)] = append(usedGpuIndex[normalizeGpuName(gName)], gData.UsedIndex...)
+		}
+		for _, r := range s.reservations {
+			if r.nodeName != node.Name {
+				continue
+			}
+			nc.freeCpu -= r.cpu
+			nc.freeMemory -= r.memory
+			nc.freeStorage -= r.storage
+			for gName, index := range r.gpuIndex {
+				usedGpuIndex[gName] = append(usedGpuIndex[gName], index...)
+			}
+		}
+		for gName, gData := range nodeGpuSummary[node.Name] {
+			nc.freeGpuIndex[gName] = difference(gData.FreeIndex, usedGpuIndex[gName])
+		}
+
+		logs.GetLogger().Infof("nodeName: %s, machineId&productUuid: %s, remainingCpu: %d, remainingMemory: %.2f, remainingStorage: %.2f, remainingGpu: %+v",
+			node.Name, nodeNameMachineId[node.Name], nc.freeCpu, float64(nc.freeMemory/1024/1024/1024), float64(nc.freeStorage/1024/1024/1024), nc.freeGpuIndex)
+		capacities = append(capacities, nc)
+	}
+
+	// keep the order stable, so equally scored nodes are always chosen the same way
+	sort.Slice(capacities, func(i, j int) bool {
+		return capacities[i].node.Name < capacities[j].node.Name
+	})
+	return capacities, nil
+}
+
+// fit returns the placement of the request on the node, or the reasons why the node can not run it.
+func (nc *nodeCapacity) fit(req ScheduleRequest) (*Placement, []string) {
+	var noAvailableStr []string
+	remainderMemory := float64(nc.freeMemory / 1024 / 1024 / 1024)
+	remainderStorage := float64(nc.freeStorage / 1024 / 1024 / 1024)
+	if nc.freeCpu < req.Cpu {
+		noAvailableStr = append(noAvailableStr, fmt.Sprintf("cpu need: %d, remainder: %d", req.Cpu, nc.freeCpu))
+	}
+	if remainderMemory < req.Memory {
+		noAvailableStr = append(noAvailableStr, fmt.Sprintf("memory need: %f, remainder: %f", req.Memory, remainderMemory))
+	}
+	if remainderStorage < req.Storage {
+		noAvailableStr = append(noAvailableStr, fmt.Sprintf("storage need: %f, remainder: %f", req.Storage, remainderStorage))
+	}
+
+	placement := &Placement{
+		NodeName:     nc.node.Name,
+		Architecture: nc.architecture,
+	}
+	var gpuModels []string
+	for model := range req.Gpus {
+		gpuModels = append(gpuModels, model)
+	}
+	sort.Strings(gpuModels)
+	for _, model := range gpuModels {
+		num := req.Gpus[model]
+		if num <= 0 {
+			continue
+		}
+		gName := nc.matchGpu(model)
+		if gName == "" {
+			noAvailableStr = append(noAvailableStr, fmt.Sprintf("gpu need name:%s, num:%d, remainder: %d", model, num, 0))
+			continue
+		}
+		freeIndex := nc.freeGpuIndex[gName]
+		if len(freeIndex) < num {
+			noAvailableStr = append(noAvailableStr, fmt.Sprintf("gpu need name:%s, num:%d, remainder: %d", model, num, len(freeIndex)))
+			continue
+		}
+		if placement.GpuName == "" {
+			placement.GpuName = gName
+		}
+		gIndex := freeIndex[:num]
+		placement.GpuIndex = append(placement.GpuIndex, gIndex...)
+		placement.PrepareG = append(placement.PrepareG, models.PodGpu{
+			Gname:  model,
+			Guse:   len(gIndex),
+			Gindex: gIndex,
+		})
+	}
+
+	if len(noAvailableStr) > 0 {
+		return nil, noAvailableStr
+	}
+	return placement, nil
+}
+
+// matchGpu returns the gpu model of the node that matches the requested model, preferring the model with most free gpus.
+func (nc *nodeCapacity) matchGpu(model string) string {
+	model = normalizeGpuName(model)
+	if _, ok := nc.freeGpuIndex[model]; ok {
+		return model
+	}
+	var matched string
+	for gName, freeIndex := range nc.freeGpuIndex {
+		if strings.Contains(gName, model) && (matched == "" || len(freeIndex) > len(nc.freeGpuIndex[matched])) {
+			matched = gName
+		}
+	}
+	return matched
+}
+
+// score ranks a feasible node: the gpus of a model are best-fit, so large free blocks are kept for large jobs,
+// and the cpu and memory are bin-packed onto the most allocated node. Cpu jobs keep off the nodes with free gpus.
+func (nc *nodeCapacity) score(req ScheduleRequest, placement *Placement) float64 {
+	var binPack float64
+	if nc.totalCpu > 0 {
+		binPack += float64(nc.totalCpu-nc.freeCpu+req.Cpu) / float64(nc.totalCpu)
+	}
+	if nc.totalMemory > 0 {
+		binPack += (float64(nc.totalMemory-nc.freeMemory) + req.Memory*1024*1024*1024) / float64(nc.totalMemory)
+	}
+	binPack = binPack / 2
+
+	var gpuFit float64
+	if len(placement.PrepareG) > 0 {
+		for _, g := range placement.PrepareG {
+			gName := nc.matchGpu(g.Gname)
+			gpuFit += float64(g.Guse) / float64(len(nc.freeGpuIndex[gName]))
+		}
+		gpuFit = gpuFit / float64(len(placement.PrepareG))
+	} else {
+		for _, freeIndex := range nc.freeGpuIndex {
+			if len(freeIndex) > 0 {
+				gpuFit = -1
+				break
+			}
+		}
+	}
+	return scheduleGpuWeight*gpuFit + binPack
+}
+
+// normalizeGpuName converts the gpu model of a request, a pod annotation or a node label to the form of the gpu summary.
+func normalizeGpuName(name string) string {
+	name = strings.ReplaceAll(name, " ", "-")
+	name = strings.ReplaceAll(name, "_", "-")
+	return strings.ToUpper(name)
+}
+
+// nodeNameOfPodSpec returns the node a pod is pinned to by node affinity or by the hostname node selector.
+func nodeNameOfPodSpec(spec coreV1.PodSpec) string {
+	if spec.Affinity != nil && spec.Affinity.NodeAffinity != nil && spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution != nil {
+		for _, term := range spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+			for _, expr := range term.MatchExpressions {
+				if expr.Key == coreV1.LabelHostname && expr.Operator == coreV1.NodeSelectorOpIn && len(expr.Values) > 0 {
+					return expr.Values[0]
+				}
+			}
+		}
+	}
+	return spec.NodeSelector[coreV1.LabelHostname]
+}
+
+// generateNodeAffinity pins the pod to the node chosen by the scheduler.
+func generateNodeAffinity(nodeName string) *coreV1.Affinity {
+	if nodeName == "" {
+		return nil
+	}
+	return &coreV1.Affinity{
+		NodeAffinity: &coreV1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &coreV1.NodeSelector{
+				NodeSelectorTerms: []coreV1.NodeSelectorTerm{{
+					MatchExpressions: []coreV1.NodeSelectorRequirement{{
+						Key:      coreV1.LabelHostname,
+						Operator: coreV1.NodeSelectorOpIn,
+						Values:   []string{nodeName},
+					}},
+				}},
+			},
+		},
+	}
+}
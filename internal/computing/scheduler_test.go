@@ -0,0 +1,108 @@
+package computing
+
+import (
+	"reflect"
+	"testing"
+
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const gib = 1024 * 1024 * 1024
+
+func testNode(name string, cpu, memory int64, freeGpuIndex map[string][]string) *nodeCapacity {
+	return &nodeCapacity{
+		node:         coreV1.Node{ObjectMeta: metaV1.ObjectMeta{Name: name}},
+		totalCpu:     cpu,
+		totalMemory:  memory * gib,
+		freeCpu:      cpu,
+		freeMemory:   memory * gib,
+		freeStorage:  100 * gib,
+		freeGpuIndex: freeGpuIndex,
+	}
+}
+
+func TestNodeCapacityFit(t *testing.T) {
+	nc := testNode("node-1", 8, 32, map[string][]string{"NVIDIA-GEFORCE-RTX-4090": {"0", "1", "2"}})
+
+	placement, reasons := nc.fit(ScheduleRequest{Cpu: 4, Memory: 16, Storage: 10, Gpus: map[string]int{"NVIDIA GeForce RTX 4090": 2}})
+	if placement == nil {
+		t.Fatalf("expected a placement, got %v", reasons)
+	}
+	if placement.GpuName != "NVIDIA-GEFORCE-RTX-4090" || !reflect.DeepEqual(placement.GpuIndex, []string{"0", "1"}) {
+		t.Errorf("unexpected placement: %+v", placement)
+	}
+
+	placement, reasons = nc.fit(ScheduleRequest{Cpu: 16, Memory: 16, Gpus: map[string]int{"4090": 4}})
+	if placement != nil {
+		t.Fatalf("expected no placement, got %+v", placement)
+	}
+	if len(reasons) != 2 {
+		t.Errorf("expected the cpu and gpu reasons, got %v", reasons)
+	}
+}
+
+func TestNodeCapacityMatchGpu(t *testing.T) {
+	nc := testNode("node-1", 8, 32, map[string][]string{
+		"NVIDIA-GEFORCE-RTX-4090":   {"0"},
+		"NVIDIA-GEFORCE-RTX-4090-D": {"1", "2"},
+		"NVIDIA-A100":               {"3"},
+	})
+
+	tests := map[string]string{
+		"NVIDIA A100":             "NVIDIA-A100",
+		"NVIDIA GeForce RTX 4090": "NVIDIA-GEFORCE-RTX-4090",
+		"4090":                    "NVIDIA-GEFORCE-RTX-4090-D",
+		"H100":                    "",
+	}
+	for model, want := range tests {
+		if got := nc.matchGpu(model); got != want {
+			t.Errorf("%s: expected %q, got %q", model, want, got)
+		}
+	}
+}
+
+func TestNodeCapacityScore(t *testing.T) {
+	// the gpus are best-fit, the node with the free block of the requested size is preferred
+	gpuReq := ScheduleRequest{Cpu: 2, Memory: 8, Gpus: map[string]int{"A100": 2}}
+	exact := testNode("exact", 16, 64, map[string][]string{"NVIDIA-A100": {"0", "1"}})
+	large := testNode("large", 16, 64, map[string][]string{"NVIDIA-A100": {"0", "1", "2", "3"}})
+	exactPlacement, _ := exact.fit(gpuReq)
+	largePlacement, _ := large.fit(gpuReq)
+	if exact.score(gpuReq, exactPlacement) <= large.score(gpuReq, largePlacement) {
+		t.Errorf("expected the exact gpu fit to score higher")
+	}
+
+	// a cpu job keeps off the nodes with free gpus
+	cpuReq := ScheduleRequest{Cpu: 2, Memory: 8}
+	cpuOnly := testNode("cpu", 16, 64, map[string][]string{})
+	gpuNode := testNode("gpu", 16, 64, map[string][]string{"NVIDIA-A100": {"0"}})
+	cpuPlacement, _ := cpuOnly.fit(cpuReq)
+	gpuPlacement, _ := gpuNode.fit(cpuReq)
+	if cpuOnly.score(cpuReq, cpuPlacement) <= gpuNode.score(cpuReq, gpuPlacement) {
+		t.Errorf("expected the cpu job to prefer the node without free gpus")
+	}
+
+	// the cpu and memory are bin-packed onto the most allocated node
+	busy := testNode("busy", 16, 64, map[string][]string{})
+	busy.freeCpu, busy.freeMemory = 4, 16*gib
+	busyPlacement, _ := busy.fit(cpuReq)
+	if busy.score(cpuReq, busyPlacement) <= cpuOnly.score(cpuReq, cpuPlacement) {
+		t.Errorf("expected the most allocated node to score higher")
+	}
+}
+
+func TestNodeAffinityRoundTrip(t *testing.T) {
+	spec := coreV1.PodSpec{Affinity: generateNodeAffinity("node-1")}
+	if got := nodeNameOfPodSpec(spec); got != "node-1" {
+		t.Errorf("expected node-1, got %q", got)
+	}
+	if generateNodeAffinity("") != nil {
+		t.Errorf("expected no affinity without a node")
+	}
+
+	spec = coreV1.PodSpec{NodeSelector: map[string]string{coreV1.LabelHostname: "node-2"}}
+	if got := nodeNameOfPodSpec(spec); got != "node-2" {
+		t.Errorf("expected node-2, got %q", got)
+	}
+}
@@ -0,0 +1,176 @@
+package computing
+
+import (
+	"os"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/swanchain/go-computing-provider/conf"
+	coreV1 "k8s.io/api/core/v1"
+)
+
+// the job types of the tenant workloads, Security.PrivilegedJobTypes lists the ones that still run privileged
+const (
+	SecurityJobMining    = "mining"
+	SecurityJobInference = "inference"
+	SecurityJobUbi       = "ubi"
+	SecurityJobSpace     = "space"
+	SecurityJobImage     = "image"
+)
+
+const (
+	securityProfileUnconfined = "unconfined"
+	securityTmpVolumeName     = "security-tmp"
+)
+
+func isPrivilegedJobType(jobType string) bool {
+	for _, t := range conf.GetConfig().Security.PrivilegedJobTypes {
+		if strings.EqualFold(strings.TrimSpace(t), jobType) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyDockerSecurityProfile sets the security profile of the config on the host config of a tenant container.
+func applyDockerSecurityProfile(jobType string, hostConfig *container.HostConfig) {
+	security := conf.GetConfig().Security
+	if security.PidsLimit > 0 {
+		pidsLimit := security.PidsLimit
+		hostConfig.PidsLimit = &pidsLimit
+	}
+
+	if isPrivilegedJobType(jobType) {
+		hostConfig.Privileged = true
+		// a privileged container can not run in the user namespace remapped by the daemon
+		hostConfig.UsernsMode = "host"
+		return
+	}
+
+	hostConfig.Privileged = false
+	hostConfig.CapDrop = security.DropCapabilities
+	hostConfig.CapAdd = security.AddCapabilities
+	if security.NoNewPrivileges {
+		hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, "no-new-privileges:true")
+	}
+	if seccomp := strings.TrimSpace(security.SeccompProfile); seccomp != "" {
+		if seccomp == securityProfileUnconfined {
+			hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, "seccomp="+securityProfileUnconfined)
+		} else if profile, err := os.ReadFile(seccomp); err != nil {
+			logs.GetLogger().Errorf("failed to read seccomp profile %s, use the default profile, error: %v", seccomp, err)
+		} else {
+			// the docker api takes the content of the profile, not its path
+			hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, "seccomp="+string(profile))
+		}
+	}
+	if apparmor := strings.TrimSpace(security.AppArmorProfile); apparmor != "" {
+		hostConfig.SecurityOpt = append(hostConfig.SecurityOpt, "apparmor="+apparmor)
+	}
+	if security.ReadOnlyRootfs {
+		hostConfig.ReadonlyRootfs = true
+		if hostConfig.Tmpfs == nil {
+			hostConfig.Tmpfs = make(map[string]string)
+		}
+		hostConfig.Tmpfs["/tmp"] = "rw,exec"
+	}
+}
+
+// applyPodSecurityProfile sets the security profile of the config on all containers of a tenant pod.
+// The pids limit of a pod can not be set per pod in k8s, it is up to the podPidsLimit of the kubelet.
+func applyPodSecurityProfile(jobType string, template *coreV1.PodTemplateSpec) {
+	security := conf.GetConfig().Security
+	privileged := isPrivilegedJobType(jobType)
+
+	if security.UserNamespace && !privileged {
+		hostUsers := false
+		template.Spec.HostUsers = &hostUsers
+	}
+
+	var mountTmp bool
+	for i := range template.Spec.Containers {
+		c := &template.Spec.Containers[i]
+		c.SecurityContext = containerSecurityContext(security, privileged)
+		if privileged || !security.ReadOnlyRootfs {
+			continue
+		}
+		var hasTmp bool
+		for _, vm := range c.VolumeMounts {
+			if vm.MountPath == "/tmp" {
+				hasTmp = true
+				break
+			}
+		}
+		if !hasTmp {
+			c.VolumeMounts = append(c.VolumeMounts, coreV1.VolumeMount{
+				Name:      securityTmpVolumeName,
+				MountPath: "/tmp",
+			})
+			mountTmp = true
+		}
+	}
+	if mountTmp {
+		template.Spec.Volumes = append(template.Spec.Volumes, coreV1.Volume{
+			Name: securityTmpVolumeName,
+			VolumeSource: coreV1.VolumeSource{
+				EmptyDir: &coreV1.EmptyDirVolumeSource{},
+			},
+		})
+	}
+}
+
+func containerSecurityContext(security conf.Security, privileged bool) *coreV1.SecurityContext {
+	if privileged {
+		return &coreV1.SecurityContext{
+			Privileged: &privileged,
+		}
+	}
+
+	allowPrivilegeEscalation := !security.NoNewPrivileges
+	readOnlyRootFilesystem := security.ReadOnlyRootfs
+	securityContext := &coreV1.SecurityContext{
+		Privileged:               &privileged,
+		AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+		ReadOnlyRootFilesystem:   &readOnlyRootFilesystem,
+		Capabilities: &coreV1.Capabilities{
+			Drop: toCapabilities(security.DropCapabilities),
+			Add:  toCapabilities(security.AddCapabilities),
+		},
+		SeccompProfile: &coreV1.SeccompProfile{
+			Type: coreV1.SeccompProfileTypeRuntimeDefault,
+		},
+	}
+
+	switch seccomp := strings.TrimSpace(security.SeccompProfile); seccomp {
+	case "":
+	case securityProfileUnconfined:
+		securityContext.SeccompProfile.Type = coreV1.SeccompProfileTypeUnconfined
+	default:
+		securityContext.SeccompProfile.Type = coreV1.SeccompProfileTypeLocalhost
+		securityContext.SeccompProfile.LocalhostProfile = &seccomp
+	}
+
+	switch apparmor := strings.TrimSpace(security.AppArmorProfile); apparmor {
+	case "":
+		// the runtime default applies to the nodes with AppArmor enabled
+	case securityProfileUnconfined:
+		securityContext.AppArmorProfile = &coreV1.AppArmorProfile{Type: coreV1.AppArmorProfileTypeUnconfined}
+	default:
+		securityContext.AppArmorProfile = &coreV1.AppArmorProfile{
+			Type:             coreV1.AppArmorProfileTypeLocalhost,
+			LocalhostProfile: &apparmor,
+		}
+	}
+	return securityContext
+}
+
+func toCapabilities(names []string) []coreV1.Capability {
+	var capabilities []coreV1.Capability
+	for _, name := range names {
+		name = strings.ToUpper(strings.TrimPrefix(strings.TrimSpace(name), "CAP_"))
+		if name != "" {
+			capabilities = append(capabilities, coreV1.Capability(name))
+		}
+	}
+	return capabilities
+}
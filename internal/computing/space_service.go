@@ -190,7 +190,7 @@ func ReceiveJob(c *gin.Context) {
 		}
 	}
 
-	available, gpuProductName, gpuIndex, gpuNum, noAvailableMsgs, err := checkResourceAvailableForSpace(jobData.UUID, jobData.JobType, spaceDetail.Data.Space.ActiveOrder.Config)
+	available, nodeName, gpuProductName, gpuIndex, gpuNum, noAvailableMsgs, err := checkResourceAvailableForSpace(jobData.UUID, jobData.JobType, spaceDetail.Data.Space.ActiveOrder.Config)
 	if err != nil {
 		NewJobService().UpdateJobEntityStatusByJobUuid(jobEntity.JobUuid, models.JOB_FAILED_STATUS)
 		logs.GetLogger().Errorf("failed to check job resource, error: %+v", err)
@@ -205,6 +205,14 @@ func ReceiveJob(c *gin.Context) {
 		return
 	}
 
+	var deploying bool
+	defer func() {
+		if !deploying {
+			GetScheduler().Release(jobData.UUID)
+			CleanSpaceResources(jobData.UUID)
+		}
+	}()
+
 	deployParam, err := DownloadSpaceResources(jobData.UUID, spaceDetail.Data.Files)
 	if err != nil {
 		NewJobService().UpdateJobEntityStatusByJobUuid(jobEntity.JobUuid, models.JOB_FAILED_STATUS)
@@ -278,6 +286,7 @@ func ReceiveJob(c *gin.Context) {
 		}
 	}
 
+	deploying = true
 	go func() {
 		go func() {
 			if err = submitJob(&jobData); err != nil {
@@ -287,7 +296,7 @@ func ReceiveJob(c *gin.Context) {
 			logs.GetLogger().Infof("successfully uploaded to MCS, jobuuid: %s", jobData.UUID)
 		}()
 
-		DeploySpaceTask(jobData, deployParam, hostName, gpuProductName, serviceNodePort, jobData.JobType, jobData.IpWhiteList, gpuIndex, int(gpuNum))
+		DeploySpaceTask(jobData, deployParam, hostName, nodeName, gpuProductName, serviceNodePort, jobData.JobType, jobData.IpWhiteList, gpuIndex, int(gpuNum))
 	}()
 
 	jobData.NodeIdJobSourceUriSignature = ""
@@ -377,13 +386,13 @@ func ReNewJob(c *gin.Context) {
 		return
 	}
 
-	leftTime := jobEntity.ExpireTime - time.Now().Unix()
+	leftTime := GetJobExpireTime(jobEntity) - time.Now().Unix()
 	if leftTime < 0 {
 		c.JSON(http.StatusOK, util.CreateErrorResponse(util.BadParamError, "The job was terminated due to its expiration date"))
 		return
 	} else {
 		if getJobExpiredTime(jobEntity) <= 0 {
-			jobEntity.ExpireTime = time.Now().Unix() + leftTime + int64(jobData.Duration)
+			jobEntity.ExpireTime = jobEntity.ExpireTime + int64(jobData.Duration)
 		} else {
 			jobEntity.ExpireTime = getJobExpiredTime(jobEntity)
 		}
@@ -395,6 +404,9 @@ func ReNewJob(c *gin.Context) {
 			return
 		}
 
+		if err = SyncNamespaceQuota(jobEntity.WalletAddress); err != nil {
+			logs.GetLogger().Errorf("failed to sync namespace quota, taskUuid: %s, error: %v", jobData.TaskUuid, err)
+		}
 	}
 	c.JSON(http.StatusOK, util.CreateSuccessResponse("success"))
 }
@@ -406,37 +418,10 @@ func CancelJob(c *gin.Context) {
 		return
 	}
 
-	nodeIdAndTaskUuidSignature := c.Query("node_id_task_uuid_signature")
-	if len(nodeIdAndTaskUuidSignature) == 0 {
-		c.JSON(http.StatusBadRequest, util.CreateErrorResponse(util.SignatureError, "missing node_id_task_uuid_signature field"))
+	if !verifyHubTaskSignature(c, taskUuid, c.Query("node_id_task_uuid_signature")) {
 		return
 	}
 
-	if conf.GetConfig().HUB.VerifySign {
-		cpRepoPath, _ := os.LookupEnv("CP_PATH")
-		nodeID := GetNodeId(cpRepoPath)
-
-		cpAccountAddress, err := contract.GetCpAccountAddress()
-		if err != nil {
-			logs.GetLogger().Errorf("get cp account contract address failed, error: %v", err)
-			c.JSON(http.StatusBadRequest, util.CreateErrorResponse(util.GetCpAccountError))
-			return
-		}
-
-		signature, err := verifySignatureForHub(conf.GetConfig().HUB.OrchestratorPk, fmt.Sprintf("%s%s%s", cpAccountAddress, nodeID, taskUuid), nodeIdAndTaskUuidSignature)
-		if err != nil {
-			logs.GetLogger().Errorf("verifySignature for space job failed, error: %+v", err)
-			c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.ServerError, "verify sign data failed"))
-			return
-		}
-
-		if !signature {
-			logs.GetLogger().Errorf("space job sign verifing, task_id: %s,  verify: %v", taskUuid, signature)
-			c.JSON(http.StatusInternalServerError, util.CreateErrorResponse(util.SignatureError, "signature verify failed"))
-			return
-		}
-	}
-
 	jobEntity, err := NewJobService().GetJobEntityByTaskUuid(taskUuid)
 	if err != nil {
 		logs.GetLogger().Errorf("Failed get job from db, taskUuid: %s, error: %+v", taskUuid, err)
@@ -960,6 +945,13 @@ func DeployImage(c *gin.Context) {
 		}
 	}
 
+	if err = checkDeployImagePolicy(&deployJob); err != nil {
+		NewJobService().UpdateJobEntityStatusByJobUuid(jobEntity.JobUuid, models.JOB_REJECTED_STATUS)
+		logs.GetLogger().Warnf("job_uuid: %s, rejected by the image policy: %v", deployJob.Uuid, err)
+		c.JSON(http.StatusForbidden, util.CreateErrorResponse(util.ImagePolicyError, err.Error()))
+		return
+	}
+
 	if checkGpuUsage() >= conf.GetConfig().API.GpuUtilizationRejectThreshold {
 		NewJobService().UpdateJobEntityStatusByJobUuid(jobEntity.JobUuid, models.JOB_REJECTED_STATUS)
 		logs.GetLogger().Errorf("space job gpu occupancy rate exceeds the set threshold, rejecting the task. job_uuid: %s", jobData.UUID)
@@ -1209,6 +1201,7 @@ func doMiningTaskForK8s(c *gin.Context, zkTask models.ZkTaskReq, taskEntity *mod
 		*job.Spec.BackoffLimit = 1
 		*job.Spec.TTLSecondsAfterFinished = 300
 
+		applyPodSecurityProfile(SecurityJobUbi, &job.Spec.Template)
 		if _, err = k8sService.k8sClient.BatchV1().Jobs(namespace).Create(context.TODO(), job, metaV1.CreateOptions{}); err != nil {
 			logs.GetLogger().Errorf("Failed creating ubi task job: %v", err)
 			return
@@ -1234,6 +1227,7 @@ func doMiningTaskForK8s(c *gin.Context, zkTask models.ZkTaskReq, taskEntity *mod
 			}
 			return true, nil // All pods are running
 		})
+		GetScheduler().Release(zkTask.Uuid)
 		if err != nil {
 			logs.GetLogger().Errorf("Failed waiting pods create: %v", err)
 			return
@@ -1308,8 +1302,34 @@ func handlePodEvent(conn *websocket.Conn, jobUuid string, walletAddress string)
 			buffer.WriteString("\n")
 		}
 	}
-	client.HandleLogs(strings.NewReader(buffer.String()))
 
+	if GetJobInformer() == nil {
+		client.HandleLogs(strings.NewReader(buffer.String()))
+		return
+	}
+
+	// keep the connection open and follow the new events of the job from the informer
+	_, jobEvents, unsubscribe := SubscribeJobEvents(jobUuid)
+	reader, writer := io.Pipe()
+	go func() {
+		defer unsubscribe()
+		defer writer.Close()
+
+		if _, err := writer.Write([]byte(buffer.String())); err != nil {
+			return
+		}
+		for {
+			select {
+			case event := <-jobEvents:
+				if _, err := fmt.Fprintf(writer, "[%s] %s: %s\n", event.Type, event.Reason, event.Message); err != nil {
+					return
+				}
+			case <-client.stopCh:
+				return
+			}
+		}
+	}()
+	client.HandleLogs(reader)
 }
 
 func handleConnection(conn *websocket.Conn, jobDetail models.JobEntity, logType string) {
@@ -1337,7 +1357,7 @@ func handleConnection(conn *websocket.Conn, jobDetail models.JobEntity, logType
 	}
 }
 
-func DeploySpaceTask(jobData models.JobData, deployParam DeployParam, hostName string, gpuProductName string, nodePort int32, jobType int, ipWhiteList []string, gpuIndex []string, gpuNum int) {
+func DeploySpaceTask(jobData models.JobData, deployParam DeployParam, hostName string, nodeName string, gpuProductName string, nodePort int32, jobType int, ipWhiteList []string, gpuIndex []string, gpuNum int) {
 	saveGpuCache(gpuProductName, gpuNum)
 	updateJobStatus(jobData.UUID, models.DEPLOY_UPLOAD_RESULT)
 	var success bool
@@ -1345,6 +1365,7 @@ func DeploySpaceTask(jobData models.JobData, deployParam DeployParam, hostName s
 	var walletAddress string
 	defer func() {
 		deleteGpuCache(gpuProductName, gpuNum)
+		CleanSpaceResources(jobData.UUID)
 		if !success {
 			k8sNameSpace := constants.K8S_NAMESPACE_NAME_PREFIX + strings.ToLower(walletAddress)
 			DeleteJob(k8sNameSpace, jobUuid, "failed to deploy space")
@@ -1381,6 +1402,7 @@ func DeploySpaceTask(jobData models.JobData, deployParam DeployParam, hostName s
 
 	deploy.WithIpWhiteList(ipWhiteList)
 	deploy.WithSpaceName(spaceName)
+	deploy.WithNodeName(nodeName)
 	deploy.WithGpuProductName(gpuProductName)
 	deploy.WithGpuIndex(gpuIndex)
 	deploy.WithSpacePath(deployParam.BuildImagePath)
@@ -1410,7 +1432,7 @@ func DeploySpaceTask(jobData models.JobData, deployParam DeployParam, hostName s
 		}
 		success = true
 	} else {
-		imageName, dockerfilePath := BuildImagesByDockerfile(jobData.UUID, spaceName, deployParam.BuildImagePath)
+		imageName, dockerfilePath := BuildImagesByDockerfile(jobData.UUID, walletAddress, spaceName, deployParam.BuildImagePath)
 
 		clusterRuntime, err := NewK8sService().GetClusterRuntime()
 		if err != nil {
@@ -1435,6 +1457,53 @@ func DeploySpaceTask(jobData models.JobData, deployParam DeployParam, hostName s
 	return
 }
 
+// checkDeployImagePolicy checks the image of the deploy request, or the FROM images of its Dockerfile,
+// and pins the images checked in their registries to the digests checked
+func checkDeployImagePolicy(job *models.FcpDeployImageReq) error {
+	if !imagePolicyEnabled() {
+		return nil
+	}
+	switch job.DeployType {
+	case 1:
+		images, err := dockerfileBaseImages(job.DeployContent)
+		if err != nil {
+			return err
+		}
+		pinned, err := checkImagePolicy(images...)
+		if err != nil {
+			return err
+		}
+		job.DeployContent = pinDockerfileImages(job.DeployContent, pinned)
+		return nil
+	case 2:
+		yamlStruct, err := handlerYamlStr(job.DeployContent)
+		if err != nil {
+			return fmt.Errorf("failed to parse yaml content, error: %v", err)
+		}
+		var images []string
+		for _, imageName := range []string{yamlStruct.Services.Image, job.DeployConfig.Image} {
+			if imageName != "" {
+				images = append(images, imageName)
+			}
+		}
+		pinned, err := checkImagePolicy(images...)
+		if err != nil {
+			return err
+		}
+		// the job runs the image of the deploy config, the images of the services are pinned too
+		job.DeployConfig.Image = pinImage(pinned, job.DeployConfig.Image)
+		job.DeployContent, err = pinYamlImages(job.DeployContent, pinned)
+		return err
+	default:
+		pinned, err := checkImagePolicy(job.DeployConfig.Image)
+		if err != nil {
+			return err
+		}
+		job.DeployConfig.Image = pinImage(pinned, job.DeployConfig.Image)
+		return nil
+	}
+}
+
 func DeployImageSpaceTask(jobData models.JobData, job models.FcpDeployImageReq, hostName string, nodeName string, gpuIndex []string, prepareGpu []models.PodGpu) {
 	for _, g := range job.Resource.Gpus {
 		saveGpuCache(g.GpuModel, g.GPU)
@@ -1498,7 +1567,7 @@ func DeployImageSpaceTask(jobData models.JobData, job models.FcpDeployImageReq,
 			compatibleContainerd(deployJob.Image)
 		}
 	} else if job.DeployType == 1 {
-		buildParams, err := parseDockerfileContentForFcp(job.Uuid, job.DeployContent)
+		buildParams, err := parseDockerfileContentForFcp(job.Uuid, job.DeployContent, spaceBuildCacheKey(job.WalletAddress, job.Name))
 		if err != nil {
 			logs.GetLogger().Errorln(err)
 			return
@@ -1527,7 +1596,7 @@ func DeployImageSpaceTask(jobData models.JobData, job models.FcpDeployImageReq,
 	deploy := NewDeploy(job.Uuid, jobUuid, hostName, job.WalletAddress, "", int64(job.Duration), constants.SPACE_TYPE_PUBLIC, models.SpaceHardware{}, 1)
 	deploy.WithIpWhiteList(job.IpWhiteList)
 	deploy.WithSpaceName(job.Name)
-	deploy.WithGpuProductName(nodeName)
+	deploy.WithNodeName(nodeName)
 	deploy.WithGpuIndex(gpuIndex)
 	deploy.WithImage(deployJob.Image)
 
@@ -1576,7 +1645,7 @@ func handlerYamlStr(content string) (*models.YamlContent, error) {
 	return &yamlContent, nil
 }
 
-func parseDockerfileContentForFcp(jobUuid, dockerfileContent string) (*models.DeployJobParam, error) {
+func parseDockerfileContentForFcp(jobUuid, dockerfileContent, cacheKey string) (*models.DeployJobParam, error) {
 	var deployParam = new(models.DeployJobParam)
 	cpRepoPath, _ := os.LookupEnv("CP_PATH")
 	buildFolder := filepath.Join(cpRepoPath, "build/fcp", jobUuid)
@@ -1605,7 +1674,7 @@ func parseDockerfileContentForFcp(jobUuid, dockerfileContent string) (*models.De
 		return nil, fmt.Errorf("failed to parse dockerfile, path: %s", dockerfileFile)
 	}
 
-	if err = NewDockerService().BuildImage(jobUuid, buildFolder, imageName); err != nil {
+	if err = NewDockerService().BuildImage(context.TODO(), jobUuid, buildFolder, imageName, cacheKey); err != nil {
 		logs.GetLogger().Errorf("failed to building %s image, job_uuid: %s, error: %v", imageName, jobUuid, err)
 		NewJobService().UpdateJobEntityStatusByJobUuid(jobUuid, models.JOB_FAILED_STATUS)
 	}
@@ -1644,7 +1713,7 @@ func parseDockerfileConfigForFcp(job models.FcpDeployImageReq) (*models.DeployJo
 			return nil, fmt.Errorf("failed not found Dockerfile, path: %s", dockerfileFile)
 		}
 
-		if err = NewDockerService().BuildImage(job.Uuid, buildFolder, imageName); err != nil {
+		if err = NewDockerService().BuildImage(context.TODO(), job.Uuid, buildFolder, imageName, spaceBuildCacheKey(job.WalletAddress, job.Name)); err != nil {
 			logs.GetLogger().Errorf("failed to building %s image, job_uuid: %s, error: %v", imageName, job.Uuid, err)
 			NewJobService().UpdateJobEntityStatusByJobUuid(job.Uuid, models.JOB_FAILED_STATUS)
 		}
@@ -1690,6 +1759,10 @@ func generateDockerfileForFcp(config models.FcpDeployImageReq) (string, []string
 
 func DeleteJob(namespace, jobUuid string, msg string) error {
 	jobUuid = strings.ToLower(jobUuid)
+	GetScheduler().Release(jobUuid)
+	deletingJobs.Store(jobUuid, struct{}{})
+	// the informer may see the deployment deleted after DeleteJob returned
+	defer time.AfterFunc(time.Minute, func() { deletingJobs.Delete(jobUuid) })
 	deployName := constants.K8S_DEPLOY_NAME_PREFIX + jobUuid
 	serviceName := constants.K8S_SERVICE_NAME_PREFIX + jobUuid
 	ingressName := constants.K8S_INGRESS_NAME_PREFIX + jobUuid
@@ -1759,6 +1832,10 @@ func DeleteJob(namespace, jobUuid string, msg string) error {
 		}
 	}
 
+	if err := SyncNamespaceQuota(walletOfNamespace(namespace), jobUuid); err != nil {
+		logs.GetLogger().Errorf("failed to sync namespace quota, job_uuid: %s, error: %v", jobUuid, err)
+	}
+
 	if msg != "" {
 		logs.GetLogger().Infof("%s, job_uuid: %s", msg, jobUuid)
 	} else {
@@ -1836,7 +1913,22 @@ func getSpaceDetail(jobSourceURI string) (models.SpaceJSON, error) {
 	return spaceJson, nil
 }
 
-func checkResourceAvailableForSpace(jobUuid string, jobType int, resourceConfig models.SpaceHardware) (bool, string, []string, int64, []string, error) {
+func checkResourceAvailableForSpace(jobUuid string, jobType int, resourceConfig models.SpaceHardware) (bool, string, string, []string, int64, []string, error) {
+	req, gpuNum := spaceScheduleRequest(jobType, resourceConfig)
+	placement, noAvailableMsgs, err := GetScheduler().Schedule(jobUuid, req)
+	if err != nil {
+		return false, "", "", nil, 0, nil, err
+	}
+	if placement == nil {
+		return false, "", "", nil, 0, noAvailableMsgs, nil
+	}
+	if gpuNum == 0 {
+		return true, placement.NodeName, "", nil, 0, nil, nil
+	}
+	return true, placement.NodeName, placement.GpuName, placement.GpuIndex, gpuNum, nil, nil
+}
+
+func spaceScheduleRequest(jobType int, resourceConfig models.SpaceHardware) (ScheduleRequest, int64) {
 	var taskType string
 	var hardwareDetail models.Resource
 	if jobType == 1 {
@@ -1845,111 +1937,18 @@ func checkResourceAvailableForSpace(jobUuid string, jobType int, resourceConfig
 		taskType, hardwareDetail = getHardwareDetail(resourceConfig.Description)
 	}
 
-	k8sService := NewK8sService()
-
-	activePods, err := k8sService.GetAllActivePod(context.TODO())
-	if err != nil {
-		return false, "", nil, 0, nil, err
-	}
-
-	nodes, err := k8sService.k8sClient.CoreV1().Nodes().List(context.TODO(), metaV1.ListOptions{})
-	if err != nil {
-		return false, "", nil, 0, nil, err
-	}
-
-	nodeGpuSummary, nodeNameMachineId, err := k8sService.GetNodeGpuSummary(context.TODO())
-	if err != nil {
-		logs.GetLogger().Errorf("Failed collect k8s gpu, error: %+v", err)
-		return false, "", nil, 0, nil, err
-	}
-
-	gpuName := strings.ToUpper(strings.ReplaceAll(hardwareDetail.Gpu.Unit, " ", "-"))
-	gpuNum := hardwareDetail.Gpu.Quantity
-	needCpu := hardwareDetail.Cpu.Quantity
-	needMemory := float64(hardwareDetail.Memory.Quantity)
-	needStorage := float64(hardwareDetail.Storage.Quantity)
-	logs.GetLogger().Infof("job_uuid: %s, checkResourceForSpace: needCpu: %d, needMemory: %.2f, needStorage: %.2f, needGpu: %s, gpuNum: %d", jobUuid, needCpu, needMemory, needStorage, gpuName, gpuNum)
-
-	type gpuData struct {
-		Total     int
-		Free      int
-		FreeIndex []string
+	req := ScheduleRequest{
+		Cpu:     hardwareDetail.Cpu.Quantity,
+		Memory:  float64(hardwareDetail.Memory.Quantity),
+		Storage: float64(hardwareDetail.Storage.Quantity),
 	}
-
-	var noAvailableStrMap = make(map[string][]string)
-	for _, node := range nodes.Items {
-		var noAvailableStr []string
-		var nodeName = node.Name
-		var nodeGpuInfo = nodeGpuSummary[nodeName]
-		nodeGpu, remainderResource, _ := GetNodeResource(activePods, &node)
-		remainderCpu := remainderResource[ResourceCpu]
-		remainderMemory := float64(remainderResource[ResourceMem] / 1024 / 1024 / 1024)
-		remainderStorage := float64(remainderResource[ResourceStorage] / 1024 / 1024 / 1024)
-
-		freeGpuMap := make(map[string]gpuData)
-		for gn, gd := range nodeGpuInfo {
-			freeGpuMap[gn] = gpuData{
-				Total:     gd.Total,
-				Free:      gd.Free,
-				FreeIndex: gd.FreeIndex,
-			}
-		}
-
-		logs.GetLogger().Infof("nodeName: %s, machineId&productUuid: %s, remainingCpu: %d, remainingMemory: %.2f, remainingStorage: %.2f, remainingGpu: %+v", node.Name, nodeNameMachineId[node.Name], remainderCpu, remainderMemory, remainderStorage, freeGpuMap)
-
-		if remainderCpu < needCpu {
-			noAvailableStr = append(noAvailableStr, fmt.Sprintf("cpu need: %d, remainder: %d", needCpu, remainderCpu))
-		}
-		if remainderMemory < needMemory {
-			noAvailableStr = append(noAvailableStr, fmt.Sprintf("memory need: %f, remainder: %f", needMemory, remainderMemory))
-		}
-		if remainderStorage < needStorage {
-			noAvailableStr = append(noAvailableStr, fmt.Sprintf("storage need: %f, remainder: %f", needStorage, remainderStorage))
-		}
-
-		if taskType == "CPU" {
-			if len(noAvailableStr) == 0 {
-				return true, "", nil, 0, nil, nil
-			} else {
-				noAvailableStrMap[nodeName] = noAvailableStr
-				logs.GetLogger().Warnf("the job_uuid: %s is not available for this node=%s resource. Reason: %s",
-					jobUuid, node.Name, strings.Join(noAvailableStr, ";"))
-			}
-		} else if taskType == "GPU" {
-			for gname, gData := range nodeGpuInfo {
-				logs.GetLogger().Infof("useGpuSummaryOnNode: %+v, useGpuSummaryInK8SOn: %+v", gData.UsedIndex, nodeGpu[gname].UsedIndex)
-				if strings.Contains(gname, gpuName) {
-					gpuName = gname
-					remainingGpu := difference(gData.FreeIndex, nodeGpu[gpuName].UsedIndex)
-
-					if int64(len(remainingGpu)) < gpuNum {
-						noAvailableStr = append(noAvailableStr, fmt.Sprintf("gpu need name:%s, num:%d, remainder: %d", hardwareDetail.Gpu.Unit, hardwareDetail.Gpu.Quantity, len(remainingGpu)))
-					}
-					if len(noAvailableStr) == 0 {
-						return true, gpuName, remainingGpu, gpuNum, nil, nil
-					}
-				}
-			}
-			noAvailableStrMap[nodeName] = noAvailableStr
-			logs.GetLogger().Warnf("the job_uuid: %s is not available for this node=%s resource. Reason: %s",
-				jobUuid, node.Name, strings.Join(noAvailableStr, ";"))
-		}
-	}
-
-	if len(nodes.Items) > 1 {
-		var noAvailableSummary []string
-		noAvailableSummary = append(noAvailableSummary, fmt.Sprintf("cpu need: %d", needCpu))
-		noAvailableSummary = append(noAvailableSummary, fmt.Sprintf("memory need: %f", needMemory))
-		noAvailableSummary = append(noAvailableSummary, fmt.Sprintf("storage need: %f", needStorage))
-		if hardwareDetail.Gpu.Quantity >= 1 {
-			noAvailableSummary = append(noAvailableSummary, fmt.Sprintf("gpu need name:%s, num:%d,", hardwareDetail.Gpu.Unit, hardwareDetail.Gpu.Quantity))
+	if taskType == "GPU" && hardwareDetail.Gpu.Quantity > 0 {
+		req.Gpus = map[string]int{
+			hardwareDetail.Gpu.Unit: int(hardwareDetail.Gpu.Quantity),
 		}
-		noAvailableSummary = append(noAvailableSummary, "not found available node")
-		return false, "", nil, 0, noAvailableSummary, nil
-	} else {
-		nodeName := nodes.Items[0].Name
-		return false, "", nil, 0, noAvailableStrMap[nodeName], nil
+		return req, hardwareDetail.Gpu.Quantity
 	}
+	return req, 0
 }
 
 func checkGpuUsage() float64 {
@@ -1974,153 +1973,26 @@ func checkGpuUsage() float64 {
 }
 
 func checkResourceAvailableForImage(jobUuid string, hardwareType string, resourceConfig models.K8sResourceForImage) (string, bool, string, []string, []models.PodGpu, []string, error) {
-	k8sService := NewK8sService()
-	activePods, err := k8sService.GetAllActivePod(context.TODO())
-	if err != nil {
-		return "", false, "", nil, nil, nil, err
+	req := ScheduleRequest{
+		Cpu:     resourceConfig.Cpu,
+		Memory:  resourceConfig.Memory,
+		Storage: resourceConfig.Storage,
 	}
-
-	nodes, err := k8sService.k8sClient.CoreV1().Nodes().List(context.TODO(), metaV1.ListOptions{})
-	if err != nil {
-		return "", false, "", nil, nil, nil, err
+	if hardwareType == "GPU" {
+		req.Gpus = make(map[string]int)
+		for _, g := range resourceConfig.Gpus {
+			req.Gpus[g.GpuModel] += g.GPU
+		}
 	}
 
-	nodeGpuSummary, nodeNameMachineId, err := k8sService.GetNodeGpuSummary(context.TODO())
+	placement, noAvailableMsgs, err := GetScheduler().Schedule(jobUuid, req)
 	if err != nil {
-		logs.GetLogger().Errorf("Failed collect k8s gpu, error: %+v", err)
 		return "", false, "", nil, nil, nil, err
 	}
-
-	var reqGpuMap = make(map[string]int)
-	var needGpuCount int
-	for _, g := range resourceConfig.Gpus {
-		reqGpuMap[g.GpuModel] = g.GPU
-		needGpuCount += g.GPU
-	}
-
-	needCpu := resourceConfig.Cpu
-	needMemory := resourceConfig.Memory
-	needStorage := resourceConfig.Storage
-	logs.GetLogger().Infof("checkResourceForSpace: needCpu: %d, needMemory: %.2f, needStorage: %.2f, needGpu: %v", needCpu, needMemory, needStorage, reqGpuMap)
-
-	type gpuData struct {
-		Total     int
-		Free      int
-		FreeIndex []string
-	}
-
-	var noAvailableStrMap = make(map[string][]string)
-	var nodeName, architecture string
-	for _, node := range nodes.Items {
-		if _, ok := node.Labels[constants.CPU_INTEL]; ok {
-			architecture = constants.CPU_INTEL
-		}
-		if _, ok := node.Labels[constants.CPU_AMD]; ok {
-			architecture = constants.CPU_AMD
-		}
-
-		var noAvailableStr []string
-		nodeName = node.Name
-		var nodeGpuInfo = nodeGpuSummary[nodeName]
-		nodeGpu, remainderResource, _ := GetNodeResource(activePods, &node)
-		remainderCpu := remainderResource[ResourceCpu]
-		remainderMemory := float64(remainderResource[ResourceMem] / 1024 / 1024 / 1024)
-		remainderStorage := float64(remainderResource[ResourceStorage] / 1024 / 1024 / 1024)
-
-		freeGpuMap := make(map[string]gpuData)
-		for gn, gd := range nodeGpuInfo {
-			freeGpuMap[gn] = gpuData{
-				Total:     gd.Total,
-				Free:      gd.Free,
-				FreeIndex: gd.FreeIndex,
-			}
-		}
-
-		logs.GetLogger().Infof("nodeName: %s, machineId&productUuid: %s, remainingCpu: %d, remainingMemory: %.2f, remainingStorage: %.2f, remainingGpu: %+v", node.Name, nodeNameMachineId[node.Name], remainderCpu, remainderMemory, remainderStorage, freeGpuMap)
-
-		if remainderCpu < needCpu {
-			noAvailableStr = append(noAvailableStr, fmt.Sprintf("cpu need: %d, remainder: %d", needCpu, remainderCpu))
-		}
-		if remainderMemory < needMemory {
-			noAvailableStr = append(noAvailableStr, fmt.Sprintf("memory need: %f, remainder: %f", needMemory, remainderMemory))
-		}
-		if remainderStorage < needStorage {
-			noAvailableStr = append(noAvailableStr, fmt.Sprintf("storage need: %f, remainder: %f", needStorage, remainderStorage))
-		}
-
-		if hardwareType == "CPU" {
-			if len(noAvailableStr) == 0 {
-				return architecture, true, nodeName, nil, nil, nil, nil
-			} else {
-				noAvailableStrMap[nodeName] = noAvailableStr
-				logs.GetLogger().Warnf("the job_uuid: %s is not available for this node=%s resource. Reason: %s",
-					jobUuid, node.Name, strings.Join(noAvailableStr, ";"))
-			}
-		} else if hardwareType == "GPU" {
-			var newGpuIndex []string
-			var flag bool
-			var prepare []models.PodGpu
-
-			var gpuNoAvailableStr []string
-			for gname, gData := range nodeGpuInfo {
-				logs.GetLogger().Infof("useGpuSummaryOnNode: %+v, useGpuSummaryInK8SOn: %+v", gData.UsedIndex, nodeGpu[gname].UsedIndex)
-				var count int
-				var gIndex []string
-				remainingGpu := difference(gData.FreeIndex, nodeGpu[gname].UsedIndex)
-				for _, reqG := range resourceConfig.Gpus {
-					if strings.ToUpper(strings.ReplaceAll(reqG.GpuModel, " ", "-")) == gname {
-						if reqG.GPU <= len(remainingGpu) {
-							gIndex = remainingGpu[:reqG.GPU]
-							newGpuIndex = append(newGpuIndex, gIndex...)
-							count += reqG.GPU
-							prepare = append(prepare, models.PodGpu{
-								Gname:  reqG.GpuModel,
-								Guse:   len(gIndex),
-								Gindex: gIndex,
-							})
-						} else {
-							gpuNoAvailableStr = append(gpuNoAvailableStr,
-								fmt.Sprintf("gpu need name:%s, num:%d, remainder: %d", gname, reqG.GPU, len(gIndex)))
-							break
-						}
-					}
-				}
-
-				if count == needGpuCount {
-					gpuNoAvailableStr = nil
-					flag = true
-					break
-				} else {
-					logs.GetLogger().Warnf("the job_uuid: %s is not available for this node=%s resource. Reason: %s",
-						jobUuid, node.Name, strings.Join(gpuNoAvailableStr, ";"))
-				}
-			}
-
-			if flag {
-				return architecture, true, nodeName, newGpuIndex, prepare, nil, nil
-			} else {
-				if gpuNoAvailableStr != nil {
-					noAvailableStr = append(gpuNoAvailableStr, gpuNoAvailableStr...)
-					noAvailableStrMap[node.Name] = noAvailableStr
-				}
-			}
-		}
-	}
-
-	if len(nodes.Items) > 1 {
-		var noAvailableSummary []string
-		noAvailableSummary = append(noAvailableSummary, fmt.Sprintf("cpu need: %d", needCpu))
-		noAvailableSummary = append(noAvailableSummary, fmt.Sprintf("memory need: %f", needMemory))
-		noAvailableSummary = append(noAvailableSummary, fmt.Sprintf("storage need: %f", needStorage))
-		if len(resourceConfig.Gpus) >= 1 {
-			noAvailableSummary = append(noAvailableSummary, fmt.Sprintf("needGpu: %v", reqGpuMap))
-		}
-		noAvailableSummary = append(noAvailableSummary, "not found available node")
-		return architecture, false, "", nil, nil, noAvailableSummary, nil
-	} else {
-		nodeName := nodes.Items[0].Name
-		return architecture, false, "", nil, nil, noAvailableStrMap[nodeName], nil
+	if placement == nil {
+		return "", false, "", nil, nil, noAvailableMsgs, nil
 	}
+	return placement.Architecture, true, placement.NodeName, placement.GpuIndex, placement.PrepareG, nil, nil
 }
 
 func checkResourceAvailableForUbi(taskId, taskType int, gpuName string, resource *models.TaskResource) (string, string, int64, int64, int64, []string, []string, error) {
@@ -0,0 +1,340 @@
+package computing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/internal/contract"
+	"github.com/swanchain/go-computing-provider/internal/contract/ecp"
+	"github.com/swanchain/go-computing-provider/internal/models"
+	"github.com/swanchain/go-computing-provider/wallet"
+)
+
+const (
+	topUpReceiptTimeout = 3 * time.Minute
+	topUpCapWindow      = 24 * time.Hour
+)
+
+// topUpLock keeps a single top up running, the daemon checks the balances on its interval and when a ubi task is
+// rejected for the lack of funds
+var topUpLock sync.Mutex
+
+// topUpTarget is a balance the policy keeps above its minimum
+type topUpTarget struct {
+	kind      string
+	min       float64
+	target    float64
+	capKinds  []string
+	dailyCap  float64
+	unit      string
+	toAddress string
+	balance   func() (*big.Int, error)
+	send      func(amount string) (string, error)
+}
+
+// TopUpBalances tops up the balances of the TopUp config below their minimum to their target, from the From address
+// of the config or the owner address. The ETH sent to the sequencer and the worker address, and the SWAN deposited to
+// the collateral, within 24 hours stay below the daily caps. Every top up, failed or skipped by a cap, is saved in the
+// audit trail, the entries saved are returned.
+func TopUpBalances() ([]models.TopUpEntity, error) {
+	if !topUpLock.TryLock() {
+		return nil, fmt.Errorf("a top up is already running")
+	}
+	defer topUpLock.Unlock()
+	return topUpBalances()
+}
+
+// topUpBalancesInBackground starts a top up unless one is already running, and syncs the balances after it
+func topUpBalancesInBackground() {
+	if !topUpLock.TryLock() {
+		return
+	}
+	go func() {
+		defer topUpLock.Unlock()
+		if _, err := topUpBalances(); err != nil {
+			logs.GetLogger().Errorf("failed to top up the balances, error: %v", err)
+			return
+		}
+		GetCpBalance()
+	}()
+}
+
+// topUpBalances tops up the balances with the topUpLock held
+func topUpBalances() ([]models.TopUpEntity, error) {
+	config := conf.GetConfig().TopUp
+	cpAccountAddress, err := contract.GetCpAccountAddress()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cp account contract address, error: %v", err)
+	}
+	ownerAddress, workerAddress, err := GetOwnerAddressAndWorkerAddress()
+	if err != nil {
+		return nil, err
+	}
+	fromAddress := config.From
+	if fromAddress == "" {
+		fromAddress = ownerAddress
+	}
+
+	chainUrl, err := conf.GetRpcByNetWorkName()
+	if err != nil {
+		return nil, err
+	}
+	client, err := contract.GetEthClient(chainUrl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial rpc, error: %v", err)
+	}
+	defer client.Close()
+
+	updatePendingTopUps(client)
+
+	localWallet, err := wallet.SetupWallet(wallet.WalletRepo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the wallet, error: %v", err)
+	}
+	ki, err := localWallet.FindKey(fromAddress)
+	if err != nil || ki == nil {
+		return nil, fmt.Errorf("the key of the top up address %s is not in the wallet", fromAddress)
+	}
+
+	ethKinds := []string{models.TopUpKindSequencer, models.TopUpKindWorker}
+	swanKinds := []string{models.TopUpKindFcpCollateral, models.TopUpKindEcpCollateral}
+	targets := []topUpTarget{
+		{
+			kind:      models.TopUpKindSequencer,
+			min:       config.SequencerMinBalance,
+			target:    config.SequencerTargetBalance,
+			capKinds:  ethKinds,
+			dailyCap:  config.DailyEthCap,
+			unit:      "ETH",
+			toAddress: cpAccountAddress,
+			balance: func() (*big.Int, error) {
+				sequencerStub, err := ecp.NewSequencerStub(client, ecp.WithSequencerCpAccountAddress(cpAccountAddress))
+				if err != nil {
+					return nil, err
+				}
+				return sequencerStub.GetCPBalance()
+			},
+			send: func(amount string) (string, error) {
+				return localWallet.SequencerDeposit(context.TODO(), fromAddress, amount, cpAccountAddress)
+			},
+		},
+		{
+			kind:      models.TopUpKindWorker,
+			min:       config.WorkerMinBalance,
+			target:    config.WorkerTargetBalance,
+			capKinds:  ethKinds,
+			dailyCap:  config.DailyEthCap,
+			unit:      "ETH",
+			toAddress: workerAddress,
+			balance: func() (*big.Int, error) {
+				return wallet.BalanceWei(client, workerAddress)
+			},
+			send: func(amount string) (string, error) {
+				return localWallet.WalletSend(context.TODO(), fromAddress, workerAddress, amount, 0)
+			},
+		},
+		{
+			kind:      models.TopUpKindFcpCollateral,
+			min:       config.FcpMinCollateral,
+			target:    config.FcpTargetCollateral,
+			capKinds:  swanKinds,
+			dailyCap:  config.DailySwanCap,
+			unit:      "SWAN",
+			toAddress: cpAccountAddress,
+			balance: func() (*big.Int, error) {
+				info, err := fcpCollateralInfo(client, conf.GetConfig().CONTRACT.JobCollateral, cpAccountAddress)
+				return info.AvailableBalance, err
+			},
+			send: func(amount string) (string, error) {
+				return localWallet.WalletCollateral(context.TODO(), fromAddress, amount, cpAccountAddress, "fcp")
+			},
+		},
+		{
+			kind:      models.TopUpKindEcpCollateral,
+			min:       config.EcpMinCollateral,
+			target:    config.EcpTargetCollateral,
+			capKinds:  swanKinds,
+			dailyCap:  config.DailySwanCap,
+			unit:      "SWAN",
+			toAddress: cpAccountAddress,
+			balance: func() (*big.Int, error) {
+				info, err := ecpCollateralInfo(client, conf.GetConfig().CONTRACT.ZkCollateral, cpAccountAddress)
+				return info.Balance, err
+			},
+			send: func(amount string) (string, error) {
+				return localWallet.WalletCollateral(context.TODO(), fromAddress, amount, cpAccountAddress, "ecp")
+			},
+		},
+	}
+
+	var topUps []models.TopUpEntity
+	var errs []error
+	for _, target := range targets {
+		if target.min <= 0 {
+			continue
+		}
+		if target.kind == models.TopUpKindWorker && fromAddress == workerAddress {
+			continue
+		}
+		topUp, err := topUpBalance(client, fromAddress, target)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to top up the %s, error: %v", target.kind, err))
+		}
+		if topUp != nil {
+			topUps = append(topUps, *topUp)
+		}
+	}
+	return topUps, errors.Join(errs...)
+}
+
+// topUpBalance tops up the balance of the target when it is below the minimum, it returns the entry saved in the audit
+// trail, or nil when the balance needs no top up
+func topUpBalance(client *ethclient.Client, fromAddress string, target topUpTarget) (*models.TopUpEntity, error) {
+	if target.target <= target.min {
+		return nil, fmt.Errorf("the target %s %s is not above the minimum %s %s", formatAmount(target.target), target.unit,
+			formatAmount(target.min), target.unit)
+	}
+	minWei, err := contract.StrToBalance(formatAmount(target.min))
+	if err != nil {
+		return nil, err
+	}
+	targetWei, err := contract.StrToBalance(formatAmount(target.target))
+	if err != nil {
+		return nil, err
+	}
+	balance, err := target.balance()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the balance, error: %s", ecp.ParseTooManyError(err))
+	}
+	if balance.Cmp(minWei) >= 0 {
+		return nil, nil
+	}
+
+	topUpService := NewTopUpService()
+	topUp := &models.TopUpEntity{
+		Kind:        target.kind,
+		FromAddress: fromAddress,
+		ToAddress:   target.toAddress,
+		Balance:     balance.String(),
+		CreateTime:  time.Now().Unix(),
+	}
+
+	amount := new(big.Int).Sub(targetWei, balance)
+	spent, err := topUpService.SumTopUpAmounts(target.capKinds, time.Now().Add(-topUpCapWindow).Unix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the top ups of the last 24 hours, error: %v", err)
+	}
+	capWei, err := contract.StrToBalance(formatAmount(target.dailyCap))
+	if err != nil {
+		return nil, err
+	}
+	left := new(big.Int).Sub(capWei, spent)
+	if left.Sign() <= 0 {
+		logs.GetLogger().Warnf("the %s balance %s %s is below the minimum %s %s, but the daily cap of %s %s is reached",
+			target.kind, contract.BalanceToStr(balance), target.unit, formatAmount(target.min), target.unit, formatAmount(target.dailyCap), target.unit)
+		// a skip is saved once a day, not on every check
+		last, err := topUpService.GetLastTopUp(target.kind)
+		if err == nil && last != nil && last.Status == models.TopUpSkipped && last.CreateTime >= time.Now().Add(-topUpCapWindow).Unix() {
+			return nil, nil
+		}
+		topUp.Amount = "0"
+		topUp.Status = models.TopUpSkipped
+		topUp.Message = fmt.Sprintf("the daily cap of %s %s is reached", formatAmount(target.dailyCap), target.unit)
+		return topUp, topUpService.SaveTopUp(topUp)
+	}
+	if amount.Cmp(left) > 0 {
+		amount = left
+		topUp.Message = fmt.Sprintf("limited by the daily cap of %s %s", formatAmount(target.dailyCap), target.unit)
+	}
+	topUp.Amount = amount.String()
+
+	logs.GetLogger().Infof("topping up the %s from %s, balance: %s %s, amount: %s %s", target.kind, fromAddress,
+		contract.BalanceToStr(balance), target.unit, contract.BalanceToExactStr(amount), target.unit)
+	txHash, err := target.send(contract.BalanceToExactStr(amount))
+	if err != nil {
+		topUp.Status = models.TopUpFailed
+		topUp.Message = err.Error()
+		if saveErr := topUpService.SaveTopUp(topUp); saveErr != nil {
+			logs.GetLogger().Errorf("failed to save the top up, error: %v", saveErr)
+		}
+		return topUp, err
+	}
+	topUp.TxHash = txHash
+	topUp.Status = models.TopUpPending
+	if err = topUpService.SaveTopUp(topUp); err != nil {
+		return topUp, fmt.Errorf("failed to save the top up, tx: %s, error: %v", txHash, err)
+	}
+
+	topUp.Status, err = waitTopUpReceipt(client, txHash)
+	if err != nil {
+		topUp.Message = err.Error()
+	}
+	if saveErr := topUpService.SaveTopUp(topUp); saveErr != nil {
+		logs.GetLogger().Errorf("failed to save the top up, tx: %s, error: %v", txHash, saveErr)
+	}
+	return topUp, err
+}
+
+// updatePendingTopUps sets the status of the top ups whose receipt was not received before the timeout
+func updatePendingTopUps(client *ethclient.Client) {
+	topUpService := NewTopUpService()
+	topUps, err := topUpService.GetPendingTopUps()
+	if err != nil {
+		logs.GetLogger().Errorf("failed to get the pending top ups, error: %v", err)
+		return
+	}
+	for _, topUp := range topUps {
+		receipt, err := client.TransactionReceipt(context.Background(), common.HexToHash(topUp.TxHash))
+		if err != nil {
+			continue
+		}
+		topUp.Status = models.TopUpSuccess
+		if receipt.Status != types.ReceiptStatusSuccessful {
+			topUp.Status = models.TopUpFailed
+			topUp.Message = fmt.Sprintf("the top up tx %s failed", topUp.TxHash)
+		}
+		if err = topUpService.SaveTopUp(&topUp); err != nil {
+			logs.GetLogger().Errorf("failed to save the top up, tx: %s, error: %v", topUp.TxHash, err)
+		}
+	}
+}
+
+// waitTopUpReceipt waits for the receipt of the top up tx, it stays pending when there is none before the timeout
+func waitTopUpReceipt(client *ethclient.Client, txHash string) (string, error) {
+	timeout := time.After(topUpReceiptTimeout)
+	ticker := time.NewTicker(3 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-timeout:
+			return models.TopUpPending, nil
+		case <-ticker.C:
+			receipt, err := client.TransactionReceipt(context.Background(), common.HexToHash(txHash))
+			if err != nil {
+				if !errors.Is(err, ethereum.NotFound) {
+					logs.GetLogger().Warnf("failed to get the receipt of the top up tx %s, error: %s", txHash, ecp.ParseTooManyError(err))
+				}
+				continue
+			}
+			if receipt.Status != types.ReceiptStatusSuccessful {
+				return models.TopUpFailed, fmt.Errorf("the top up tx %s failed", txHash)
+			}
+			return models.TopUpSuccess, nil
+		}
+	}
+}
+
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', -1, 64)
+}
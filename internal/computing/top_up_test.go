@@ -0,0 +1,140 @@
+package computing
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/swanchain/go-computing-provider/internal/contract"
+	"github.com/swanchain/go-computing-provider/internal/db"
+	"github.com/swanchain/go-computing-provider/internal/models"
+)
+
+func saveTestTopUp(t *testing.T, kind, status, amount string, createTime time.Time) {
+	t.Helper()
+	wei, err := contract.StrToBalance(amount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = NewTopUpService().SaveTopUp(&models.TopUpEntity{
+		Kind:       kind,
+		Amount:     wei.String(),
+		Status:     status,
+		CreateTime: createTime.Unix(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testTopUpTarget(balance string, sent *[]string) topUpTarget {
+	return topUpTarget{
+		kind:     models.TopUpKindSequencer,
+		min:      1,
+		target:   2,
+		capKinds: []string{models.TopUpKindSequencer, models.TopUpKindWorker},
+		dailyCap: 0.3,
+		unit:     "ETH",
+		balance: func() (*big.Int, error) {
+			return contract.StrToBalance(balance)
+		},
+		send: func(amount string) (string, error) {
+			*sent = append(*sent, amount)
+			// stops before waiting for the receipt, the amount is saved with the failure
+			return "", fmt.Errorf("not sent")
+		},
+	}
+}
+
+func TestSumTopUpAmounts(t *testing.T) {
+	db.InitDb(t.TempDir())
+	now := time.Now()
+	saveTestTopUp(t, models.TopUpKindSequencer, models.TopUpSuccess, "0.1", now)
+	saveTestTopUp(t, models.TopUpKindWorker, models.TopUpPending, "0.05", now)
+	saveTestTopUp(t, models.TopUpKindSequencer, models.TopUpFailed, "1", now)
+	saveTestTopUp(t, models.TopUpKindSequencer, models.TopUpSkipped, "0", now)
+	saveTestTopUp(t, models.TopUpKindFcpCollateral, models.TopUpSuccess, "10", now)
+	saveTestTopUp(t, models.TopUpKindSequencer, models.TopUpSuccess, "5", now.Add(-25*time.Hour))
+
+	total, err := NewTopUpService().SumTopUpAmounts([]string{models.TopUpKindSequencer, models.TopUpKindWorker},
+		now.Add(-topUpCapWindow).Unix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := contract.BalanceToExactStr(total); got != "0.15" {
+		t.Errorf("expected the success and the pending top ups of the window, 0.15, got %s", got)
+	}
+}
+
+func TestTopUpBalanceClampedToDailyCap(t *testing.T) {
+	db.InitDb(t.TempDir())
+	saveTestTopUp(t, models.TopUpKindWorker, models.TopUpSuccess, "0.2", time.Now().Add(-time.Hour))
+	saveTestTopUp(t, models.TopUpKindSequencer, models.TopUpSuccess, "1", time.Now().Add(-25*time.Hour))
+
+	var sent []string
+	topUp, err := topUpBalance(nil, "0xf", testTopUpTarget("0.5", &sent))
+	if err == nil {
+		t.Fatalf("expected the error of the send")
+	}
+	if len(sent) != 1 || sent[0] != "0.1" {
+		t.Fatalf("expected 0.1 ETH left in the daily cap to be sent, got %v", sent)
+	}
+	if topUp == nil || topUp.Status != models.TopUpFailed || topUp.Amount != "100000000000000000" {
+		t.Fatalf("unexpected top up: %+v", topUp)
+	}
+
+	sent = nil
+	if topUp, err = topUpBalance(nil, "0xf", testTopUpTarget("1.5", &sent)); topUp != nil || err != nil || len(sent) != 0 {
+		t.Errorf("expected no top up above the minimum, got %+v, %v, %v", topUp, err, sent)
+	}
+}
+
+func TestTopUpBalanceSkipSavedOncePerWindow(t *testing.T) {
+	db.InitDb(t.TempDir())
+	saveTestTopUp(t, models.TopUpKindWorker, models.TopUpPending, "0.3", time.Now())
+
+	var sent []string
+	topUp, err := topUpBalance(nil, "0xf", testTopUpTarget("0.5", &sent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if topUp == nil || topUp.Status != models.TopUpSkipped || !strings.Contains(topUp.Message, "daily cap") {
+		t.Fatalf("expected a skip on the daily cap, got %+v", topUp)
+	}
+	if topUp, err = topUpBalance(nil, "0xf", testTopUpTarget("0.5", &sent)); topUp != nil || err != nil {
+		t.Fatalf("expected the skip to be saved once, got %+v, %v", topUp, err)
+	}
+	if len(sent) != 0 {
+		t.Errorf("expected nothing sent over the cap, got %v", sent)
+	}
+
+	topUps, err := NewTopUpService().GetTopUps(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var skips int
+	for _, topUp := range topUps {
+		if topUp.Status == models.TopUpSkipped {
+			skips++
+		}
+	}
+	if skips != 1 {
+		t.Errorf("expected 1 skip saved, got %d", skips)
+	}
+}
+
+func TestTopUpBalanceTargetNotAboveMin(t *testing.T) {
+	db.InitDb(t.TempDir())
+	var sent []string
+	target := testTopUpTarget("0.5", &sent)
+	target.target = target.min
+
+	topUp, err := topUpBalance(nil, "0xf", target)
+	if err == nil || !strings.Contains(err.Error(), "not above the minimum") {
+		t.Fatalf("expected the target to be rejected, got %v", err)
+	}
+	if topUp != nil || len(sent) != 0 {
+		t.Errorf("expected no top up, got %+v, %v", topUp, sent)
+	}
+}
@@ -0,0 +1,276 @@
+package computing
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/go-connections/nat"
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/swanchain/go-computing-provider/build"
+	"github.com/swanchain/go-computing-provider/conf"
+)
+
+const (
+	traefikServiceContainerName = "traefik-service"
+	traefikNetwork              = "traefik-net"
+
+	// traefikConfigLabel keeps the hash of the traefik settings, the container is recreated when they change
+	traefikConfigLabel  = "cp.traefik.config"
+	traefikCertResolver = "cp"
+
+	traefikTLSModeAcme = "acme"
+	traefikTLSModeFile = "file"
+
+	traefikAcmeDir    = "/etc/traefik/acme"
+	traefikDynamicDir = "/etc/traefik/dynamic"
+	traefikCertFile   = "/etc/traefik/certs/tls.crt"
+	traefikKeyFile    = "/etc/traefik/certs/tls.key"
+	traefikCACertFile = "/etc/traefik/certs/acme-ca.pem"
+)
+
+type traefikSettings struct {
+	cmd          []string
+	env          []string
+	binds        []string
+	portBindings nat.PortMap
+	// the content of the certificate files, so that renewed certificates recreate the container
+	certDigest []string
+}
+
+// EnsureTraefikService starts the traefik service, or recreates it when the TLS settings of the config changed.
+func EnsureTraefikService() error {
+	settings, err := generateTraefikSettings()
+	if err != nil {
+		return err
+	}
+
+	dockerService := NewDockerService()
+	running, _, err := dockerService.CheckRunningContainer(traefikServiceContainerName)
+	if err != nil {
+		return fmt.Errorf("check %s container failed, error: %v", traefikServiceContainerName, err)
+	}
+	if running {
+		if info, err := dockerService.c.ContainerInspect(context.Background(), traefikServiceContainerName); err == nil &&
+			info.Config != nil && info.Config.Labels[traefikConfigLabel] == settings.hash() {
+			return nil
+		}
+		logs.GetLogger().Infof("the traefik settings changed, recreating %s", traefikServiceContainerName)
+	}
+	return startTraefikService(settings)
+}
+
+func startTraefikService(settings traefikSettings) error {
+	dockerService := NewDockerService()
+	dockerService.RemoveContainerByName(traefikServiceContainerName)
+	if err := dockerService.CreateNetwork(traefikNetwork); err != nil {
+		return err
+	}
+
+	err := dockerService.PullImage(build.TraefikServerDockerImage)
+	if err != nil {
+		return fmt.Errorf("pull %s image failed, error: %v", build.TraefikServerDockerImage, err)
+	}
+
+	err = dockerService.ContainerCreateAndStart(&container.Config{
+		Image:        build.TraefikServerDockerImage,
+		Cmd:          settings.cmd,
+		Env:          settings.env,
+		Labels:       map[string]string{traefikConfigLabel: settings.hash()},
+		AttachStdout: true,
+		AttachStderr: true,
+		Tty:          true,
+	}, &container.HostConfig{
+		PortBindings: settings.portBindings,
+		Binds:        settings.binds,
+		RestartPolicy: container.RestartPolicy{
+			Name:              container.RestartPolicyOnFailure,
+			MaximumRetryCount: 3,
+		},
+		Privileged: true,
+	}, &network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{
+			traefikNetwork: {},
+		},
+	}, traefikServiceContainerName)
+	if err != nil {
+		return fmt.Errorf("create traefik-service container failed, error: %v", err)
+	}
+	return nil
+}
+
+func generateTraefikSettings() (traefikSettings, error) {
+	settings := traefikSettings{
+		cmd: []string{
+			"--api.insecure=true",
+			"--log.level=INFO",
+			"--providers.docker.exposedbydefault=false",
+			"--providers.docker=true",
+			"--entrypoints.web.address=:80",
+		},
+		binds: []string{"/var/run/docker.sock:/var/run/docker.sock"},
+		portBindings: nat.PortMap{
+			"80/tcp": {{
+				HostIP:   "0.0.0.0",
+				HostPort: strconv.Itoa(traefikListenPortMapHost),
+			}},
+			"8080/tcp": {{
+				HostIP:   "0.0.0.0",
+				HostPort: "9080",
+			}},
+		},
+	}
+
+	tlsConfig := conf.GetConfig().TLS
+	if tlsConfig.Mode == "" {
+		return settings, nil
+	}
+
+	settings.cmd = append(settings.cmd,
+		"--entrypoints.websecure.address=:443",
+		"--entrypoints.websecure.http.tls=true",
+		// the redirect goes to the host port, which is not the port of the entrypoint in the container
+		fmt.Sprintf("--entrypoints.web.http.redirections.entrypoint.to=:%d", tlsConfig.HttpsPort),
+		"--entrypoints.web.http.redirections.entrypoint.scheme=https",
+	)
+	settings.portBindings["443/tcp"] = []nat.PortBinding{{
+		HostIP:   "0.0.0.0",
+		HostPort: strconv.Itoa(tlsConfig.HttpsPort),
+	}}
+
+	cpRepoPath, _ := os.LookupEnv("CP_PATH")
+	traefikPath := filepath.Join(cpRepoPath, "traefik")
+
+	switch tlsConfig.Mode {
+	case traefikTLSModeFile:
+		certFile, keyFile := conf.GetConfig().LOG.CrtFile, conf.GetConfig().LOG.KeyFile
+		if _, err := os.Stat(certFile); err != nil {
+			return settings, fmt.Errorf("not found the certificate of LOG.CrtFile, error: %v", err)
+		}
+		if _, err := os.Stat(keyFile); err != nil {
+			return settings, fmt.Errorf("not found the key of LOG.KeyFile, error: %v", err)
+		}
+
+		dynamicPath := filepath.Join(traefikPath, "dynamic")
+		if err := os.MkdirAll(dynamicPath, 0755); err != nil {
+			return settings, fmt.Errorf("failed to create %s, error: %v", dynamicPath, err)
+		}
+		dynamicConfig := fmt.Sprintf("tls:\n  certificates:\n    - certFile: %s\n      keyFile: %s\n"+
+			"  stores:\n    default:\n      defaultCertificate:\n        certFile: %s\n        keyFile: %s\n",
+			traefikCertFile, traefikKeyFile, traefikCertFile, traefikKeyFile)
+		if err := os.WriteFile(filepath.Join(dynamicPath, "tls.yml"), []byte(dynamicConfig), 0644); err != nil {
+			return settings, fmt.Errorf("failed to write the traefik tls config, error: %v", err)
+		}
+
+		settings.cmd = append(settings.cmd,
+			"--providers.file.directory="+traefikDynamicDir,
+			"--providers.file.watch=true",
+		)
+		settings.binds = append(settings.binds,
+			dynamicPath+":"+traefikDynamicDir+":ro",
+			certFile+":"+traefikCertFile+":ro",
+			keyFile+":"+traefikKeyFile+":ro",
+		)
+		for _, f := range []string{certFile, keyFile} {
+			content, err := os.ReadFile(f)
+			if err != nil {
+				return settings, fmt.Errorf("failed to read %s, error: %v", f, err)
+			}
+			settings.certDigest = append(settings.certDigest, fmt.Sprintf("%x", sha256.Sum256(content)))
+		}
+
+	case traefikTLSModeAcme:
+		acmePath := filepath.Join(traefikPath, "acme")
+		if err := os.MkdirAll(acmePath, 0700); err != nil {
+			return settings, fmt.Errorf("failed to create %s, error: %v", acmePath, err)
+		}
+		resolver := "--certificatesresolvers." + traefikCertResolver + ".acme"
+		settings.cmd = append(settings.cmd,
+			resolver+".email="+tlsConfig.AcmeEmail,
+			resolver+".storage="+traefikAcmeDir+"/acme.json",
+		)
+		settings.binds = append(settings.binds, acmePath+":"+traefikAcmeDir)
+
+		if tlsConfig.AcmeCAServer != "" {
+			settings.cmd = append(settings.cmd, resolver+".caserver="+tlsConfig.AcmeCAServer)
+		}
+		if tlsConfig.AcmeCACert != "" {
+			if _, err := os.Stat(tlsConfig.AcmeCACert); err != nil {
+				return settings, fmt.Errorf("not found the ACME CA certificate, error: %v", err)
+			}
+			settings.binds = append(settings.binds, tlsConfig.AcmeCACert+":"+traefikCACertFile+":ro")
+			settings.env = append(settings.env, "LEGO_CA_CERTIFICATES="+traefikCACertFile)
+		}
+
+		switch tlsConfig.AcmeChallenge {
+		case "http":
+			settings.cmd = append(settings.cmd, resolver+".httpchallenge.entrypoint=web")
+		case "dns":
+			if tlsConfig.DnsProvider == "" {
+				return settings, fmt.Errorf("TLS.DnsProvider is required by the dns challenge")
+			}
+			settings.cmd = append(settings.cmd, resolver+".dnschallenge.provider="+tlsConfig.DnsProvider)
+			if len(tlsConfig.DnsResolvers) > 0 {
+				settings.cmd = append(settings.cmd, resolver+".dnschallenge.resolvers="+strings.Join(tlsConfig.DnsResolvers, ","))
+			}
+			settings.env = append(settings.env, tlsConfig.DnsProviderEnv...)
+		default:
+			return settings, fmt.Errorf("unsupported TLS.AcmeChallenge: %s, only support http and dns", tlsConfig.AcmeChallenge)
+		}
+
+	default:
+		return settings, fmt.Errorf("unsupported TLS.Mode: %s, only support acme and file", tlsConfig.Mode)
+	}
+	return settings, nil
+}
+
+func (s traefikSettings) hash() string {
+	h := sha256.New()
+	for _, part := range [][]string{s.cmd, s.env, s.binds, s.certDigest} {
+		h.Write([]byte(strings.Join(part, "\n")))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// setTraefikRouterLabels routes the host to the container, over https when TLS is enabled.
+func setTraefikRouterLabels(labelMap map[string]string, routerName, host string) {
+	tlsConfig := conf.GetConfig().TLS
+	labelMap["traefik.enable"] = "true"
+	labelMap[fmt.Sprintf("traefik.http.routers.%s.rule", routerName)] = fmt.Sprintf("Host(`%s`)", host)
+	if tlsConfig.Mode == "" {
+		labelMap[fmt.Sprintf("traefik.http.routers.%s.entrypoints", routerName)] = "web"
+		return
+	}
+
+	labelMap[fmt.Sprintf("traefik.http.routers.%s.entrypoints", routerName)] = "websecure"
+	labelMap[fmt.Sprintf("traefik.http.routers.%s.tls", routerName)] = "true"
+	if tlsConfig.Mode == traefikTLSModeAcme {
+		labelMap[fmt.Sprintf("traefik.http.routers.%s.tls.certresolver", routerName)] = traefikCertResolver
+		if tlsConfig.AcmeChallenge == "dns" {
+			// one wildcard certificate for all endpoints rather than one certificate per endpoint
+			domain := strings.TrimPrefix(conf.GetConfig().API.Domain, ".")
+			labelMap[fmt.Sprintf("traefik.http.routers.%s.tls.domains[0].main", routerName)] = domain
+			labelMap[fmt.Sprintf("traefik.http.routers.%s.tls.domains[0].sans", routerName)] = "*." + domain
+		}
+	}
+}
+
+// traefikServiceUrl returns the url of the host served by traefik
+func traefikServiceUrl(host string) string {
+	tlsConfig := conf.GetConfig().TLS
+	if tlsConfig.Mode == "" {
+		return fmt.Sprintf("http://%s:%d", host, traefikListenPortMapHost)
+	}
+	if tlsConfig.HttpsPort == 443 {
+		return "https://" + host
+	}
+	return fmt.Sprintf("https://%s:%d", host, tlsConfig.HttpsPort)
+}
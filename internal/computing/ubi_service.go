@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/network"
-	"github.com/docker/go-connections/nat"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/filswan/go-swan-lib/logs"
@@ -29,7 +28,7 @@ import (
 	"k8s.io/apimachinery/pkg/api/resource"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/wait"
-	"math"
+	"math/big"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -482,6 +481,7 @@ func doFilC2TaskForK8s(c *gin.Context, zkTask models.ZkTaskReq, taskEntity *mode
 		*job.Spec.BackoffLimit = 1
 		*job.Spec.TTLSecondsAfterFinished = 300
 
+		applyPodSecurityProfile(SecurityJobUbi, &job.Spec.Template)
 		if _, err = k8sService.k8sClient.BatchV1().Jobs(namespace).Create(context.TODO(), job, metaV1.CreateOptions{}); err != nil {
 			logs.GetLogger().Errorf("Failed creating ubi task job: %v", err)
 			return
@@ -507,6 +507,7 @@ func doFilC2TaskForK8s(c *gin.Context, zkTask models.ZkTaskReq, taskEntity *mode
 			}
 			return true, nil // All pods are running
 		})
+		GetScheduler().Release(taskId)
 		if err != nil {
 			logs.GetLogger().Errorf("Failed waiting pods create: %v", err)
 			return
@@ -920,6 +921,7 @@ func DoUbiTaskForK8s(c *gin.Context) {
 		*job.Spec.BackoffLimit = 1
 		*job.Spec.TTLSecondsAfterFinished = 300
 
+		applyPodSecurityProfile(SecurityJobUbi, &job.Spec.Template)
 		if _, err = k8sService.k8sClient.BatchV1().Jobs(namespace).Create(context.TODO(), job, metaV1.CreateOptions{}); err != nil {
 			logs.GetLogger().Errorf("Failed creating ubi task job: %v", err)
 			return
@@ -1267,8 +1269,8 @@ func DoUbiTaskForDocker(c *gin.Context) {
 			Binds:       []string{fmt.Sprintf("%s:/var/tmp/filecoin-proof-parameters", filC2Param)},
 			Resources:   needResource,
 			NetworkMode: network.NetworkHost,
-			Privileged:  true,
 		}
+		applyDockerSecurityProfile(SecurityJobUbi, hostConfig)
 		containerConfig := &container.Config{
 			Image:        ubiTaskImage,
 			Cmd:          execCommand,
@@ -1596,8 +1598,8 @@ func doFilC2Task(c *gin.Context, zkTask models.ZkTaskReq, taskEntity *models.Tas
 			Binds:       []string{fmt.Sprintf("%s:/var/tmp/filecoin-proof-parameters", filC2Param)},
 			Resources:   needResource,
 			NetworkMode: network.NetworkHost,
-			Privileged:  true,
 		}
+		applyDockerSecurityProfile(SecurityJobUbi, hostConfig)
 		containerConfig := &container.Config{
 			Image:        ubiTaskImage,
 			Cmd:          execCommand,
@@ -1970,6 +1972,11 @@ func GetCpResource(c *gin.Context) {
 		return
 	}
 
+	portUsage, err := getPortUsage()
+	if err != nil {
+		logs.GetLogger().Errorf("failed to get port usage, error: %v", err)
+	}
+
 	cpRepo, _ := os.LookupEnv("CP_PATH")
 	c.JSON(http.StatusOK, models.ClusterResource{
 		Region:           location,
@@ -1978,6 +1985,7 @@ func GetCpResource(c *gin.Context) {
 		NodeId:           GetNodeId(cpRepo),
 		CpAccountAddress: cpAccountAddress,
 		ClientType:       "ECP",
+		Ports:            portUsage,
 	})
 }
 
@@ -2030,7 +2038,7 @@ func submitUBIProof(c2Proof models.UbiC2Proof, task *models.TaskEntity) {
 		return
 	}
 
-	var sequencerBalance float64
+	sequencerBalance := new(big.Int)
 	if conf.GetConfig().UBI.EnableSequencer {
 		cpAccountAddress, err := contract.GetCpAccountAddress()
 		if err != nil {
@@ -2044,17 +2052,11 @@ func submitUBIProof(c2Proof models.UbiC2Proof, task *models.TaskEntity) {
 				logs.GetLogger().Errorf("failed to get cp sequencer contract, error: %v", err)
 				return err
 			}
-			sequencerBalanceStr, err := sequencerStub.GetCPBalance()
+			sequencerBalance, err = sequencerStub.GetCPBalance()
 			if err != nil {
 				logs.GetLogger().Errorf("failed to get cp sequencer contract, error: %v", err)
 				return err
 			}
-
-			sequencerBalance, err = strconv.ParseFloat(sequencerBalanceStr, 64)
-			if err != nil {
-				logs.GetLogger().Errorf("failed to convert numbers for cp sequencer balance, sequencerBalance: %s, error: %v", sequencerBalanceStr, err)
-				return err
-			}
 			return nil
 		}, 3, 2*time.Second)
 		if err != nil {
@@ -2095,13 +2097,14 @@ loopTask:
 	}
 
 	if conf.GetConfig().UBI.EnableSequencer && conf.GetConfig().UBI.AutoChainProof {
-		if sequencerBalance <= 0 {
+		if sequencerBalance.Sign() <= 0 {
 			logs.GetLogger().Infof("taskId: %s starting to create task contract", c2Proof.TaskId)
 			taskContractAddress, err := taskStub.CreateTaskContract(c2Proof.Proof, task, remainingTime)
 			if taskContractAddress != "" {
 				task.Status = models.TASK_SUBMITTED_STATUS
 				task.Contract = taskContractAddress
 				task.Sequencer = 0
+				recordProofGas(task)
 				logs.GetLogger().Infof("successfully submitted to the chain, taskId: %s task contract address: %s", c2Proof.TaskId, taskContractAddress)
 			} else {
 				task.Status = models.TASK_FAILED_STATUS
@@ -2118,7 +2121,7 @@ loopTask:
 			}
 		}
 	} else if conf.GetConfig().UBI.EnableSequencer && !conf.GetConfig().UBI.AutoChainProof {
-		if sequencerBalance > 0 {
+		if sequencerBalance.Sign() > 0 {
 			logs.GetLogger().Infof("taskId: %s starting to use sequencer to submit proof", c2Proof.TaskId)
 			if err = submitTaskToSequencer(c2Proof.Proof, task, remainingTime, false); err != nil {
 				logs.GetLogger().Errorf("failed to submitted to the sequencer, taskId: %d, error: %v", task.Id, err)
@@ -2128,7 +2131,7 @@ loopTask:
 			}
 		} else {
 			task.Status = models.TASK_FAILED_STATUS
-			logs.GetLogger().Warnf("taskId: %d, sequencer insufficient balance, sequencerBalance: %.6f", task.Id, sequencerBalance)
+			logs.GetLogger().Warnf("taskId: %d, sequencer insufficient balance, sequencerBalance: %s", task.Id, contract.BalanceToStr2(sequencerBalance))
 		}
 	} else {
 		logs.GetLogger().Infof("taskId: %s starting to create task contract", c2Proof.TaskId)
@@ -2137,6 +2140,7 @@ loopTask:
 			task.Status = models.TASK_SUBMITTED_STATUS
 			task.Contract = taskContractAddress
 			task.Sequencer = 0
+			recordProofGas(task)
 			logs.GetLogger().Infof("taskId: %s, taskContractAddress: %s", c2Proof.TaskId, taskContractAddress)
 		} else if err != nil {
 			task.Status = models.TASK_FAILED_STATUS
@@ -2270,12 +2274,39 @@ func updateEcpTaskStatus() {
 			NewEcpJobService().UpdateEcpJobEntity(entity.Uuid, status)
 		} else {
 			NewEcpJobService().UpdateEcpJobEntity(entity.Uuid, models.TerminatedStatus)
+			releasePorts(entity.Uuid)
 		}
 	}
 }
 
 func CronTaskForEcp() {
-	if conf.GetConfig().API.AutoDeleteImage {
+	WatchContainerEvents()
+	StartHealthMonitor()
+
+	if err := ReconcilePortLeases(); err != nil {
+		logs.GetLogger().Errorf("failed to reconcile port leases, error: %v", err)
+	}
+	go func() {
+		ticker := time.NewTicker(10 * time.Minute)
+		for range ticker.C {
+			if err := ReconcilePortLeases(); err != nil {
+				logs.GetLogger().Errorf("failed to reconcile port leases, error: %v", err)
+			}
+		}
+	}()
+
+	go func() {
+		// pick up the renewed certificates of TLS.Mode file, and bring traefik back if it stopped
+		ticker := time.NewTicker(time.Hour)
+		for range ticker.C {
+			if err := EnsureTraefikService(); err != nil {
+				logs.GetLogger().Errorf("failed to check traefik service, error: %v", err)
+			}
+		}
+	}()
+
+	PrewarmImages()
+	if conf.GetConfig().API.AutoDeleteImage || conf.GetConfig().ImageCache.MaxSize > 0 {
 		go func() {
 			ticker := time.NewTicker(2 * time.Hour)
 			for range ticker.C {
@@ -2346,9 +2377,65 @@ func CronTaskForEcp() {
 		ticker := time.NewTicker(30 * time.Minute)
 		for range ticker.C {
 			NewTaskPaymentService().ScannerChainGetTaskPayment()
+			if err := ScanSequencerFees(); err != nil {
+				logs.GetLogger().Errorf("failed to scan sequencer fees, error: %v", err)
+			}
+			if err := UpdatePendingTxGas(); err != nil {
+				logs.GetLogger().Errorf("failed to record the pending gas, error: %v", err)
+			}
 		}
 	}()
 
+	go SubscribeChainEvents(context.Background())
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				logs.GetLogger().Errorf("Index chain events, error: %+v", err)
+			}
+		}()
+
+		ticker := time.NewTicker(5 * time.Minute)
+		for range ticker.C {
+			if err := ScanChainEvents(); err != nil {
+				logs.GetLogger().Errorf("failed to index chain events, error: %v", err)
+			}
+		}
+	}()
+
+	if conf.GetConfig().TopUp.Enable {
+		go func() {
+			defer func() {
+				if err := recover(); err != nil {
+					logs.GetLogger().Errorf("Top up balances, error: %+v", err)
+				}
+			}()
+
+			ticker := time.NewTicker(time.Duration(conf.GetConfig().TopUp.Interval) * time.Second)
+			for range ticker.C {
+				if _, err := TopUpBalances(); err != nil {
+					logs.GetLogger().Errorf("failed to top up the balances, error: %v", err)
+				}
+			}
+		}()
+	}
+
+	if conf.GetConfig().Alert.Enable {
+		go func() {
+			defer func() {
+				if err := recover(); err != nil {
+					logs.GetLogger().Errorf("Check alerts, error: %+v", err)
+				}
+			}()
+
+			ticker := time.NewTicker(time.Duration(conf.GetConfig().Alert.Interval) * time.Second)
+			for range ticker.C {
+				if err := CheckAlerts(); err != nil {
+					logs.GetLogger().Errorf("failed to check the alerts, error: %v", err)
+				}
+			}
+		}()
+	}
+
 	go func() {
 		GetCpBalance()
 		ticker := time.NewTicker(30 * time.Minute)
@@ -2385,7 +2472,7 @@ func syncTaskStatusForSequencerService() error {
 			}
 			var taskAddress = item.SequenceTaskAddr
 			if t, ok := taskMap[item.Id]; ok {
-				item.Reward = "0.00"
+				item.Reward = "0"
 				item.SequenceCid = t.SequenceCid
 				item.SettlementCid = t.SettlementCid
 				item.SequenceTaskAddr = t.SequenceTaskAddr
@@ -2396,10 +2483,9 @@ func syncTaskStatusForSequencerService() error {
 					status = models.TASK_NSC_STATUS
 				} else if t.SettlementTaskAddr != "" {
 					status = models.TASK_REWARDED_STATUS
-					if len(t.Reward) >= 4 {
-						item.Reward = t.Reward[:4]
-					} else {
-						item.Reward = t.Reward
+					recordUbiReward(item, t.Reward, t.SettlementTaskAddr)
+					if reward, err := contract.StrToBalance(t.Reward); err == nil {
+						item.Reward = reward.String()
 					}
 				} else {
 					switch t.Status {
@@ -2492,6 +2578,9 @@ func syncUbiMiningTaskStatus() {
 				default:
 					status = models.TASK_UNKNOWN_STATUS
 				}
+				if status == models.TASK_REWARDED_STATUS && t.Reward != "" {
+					recordUbiReward(item, t.Reward, t.SettlementTaskAddr)
+				}
 
 				if item.Status == status {
 					continue
@@ -2662,6 +2751,7 @@ func submitTaskToSequencer(proof string, task *models.TaskEntity, timeOut int64,
 				task.Status = models.TASK_SUBMITTED_STATUS
 				task.Contract = taskContractAddress
 				task.Sequencer = 0
+				recordProofGas(task)
 				logs.GetLogger().Infof("successfully submitted to the chain, taskId: %d task contract address: %s", task.Id, taskContractAddress)
 				return nil
 			} else {
@@ -2700,7 +2790,20 @@ func submitTaskToSequencer(proof string, task *models.TaskEntity, timeOut int64,
 	return err
 }
 
-func checkBalance(cpAccountAddress string) (bool, error) {
+var (
+	// minSequencerBalance and minWorkerBalance are the balances in wei above which an ubi task can submit its proof
+	minSequencerBalance = big.NewInt(1e12)
+	minWorkerBalance    = big.NewInt(1e13)
+)
+
+func checkBalance(cpAccountAddress string) (ok bool, err error) {
+	defer func() {
+		if !ok && conf.GetConfig().TopUp.Enable {
+			// top up in the background, the tasks are accepted again once the deposit is mined and the balance synced
+			topUpBalancesInBackground()
+		}
+	}()
+
 	cpBalance, err := NewCpBalanceService().GetCpBalance(cpAccountAddress)
 	if err != nil || cpBalance == nil {
 		if err != nil {
@@ -2710,30 +2813,39 @@ func checkBalance(cpAccountAddress string) (bool, error) {
 			return false, fmt.Errorf("not found cp balance")
 		}
 	}
-	logs.GetLogger().Infof("cpAccount: %s, sequencer balance: %f ETH, worker address balance: %f ETH", cpAccountAddress, cpBalance.SequencerBalance, cpBalance.WorkerBalance)
+	sequencerBalance, err := contract.ParseWei(cpBalance.SequencerBalance)
+	if err != nil {
+		return false, err
+	}
+	workerBalance, err := contract.ParseWei(cpBalance.WorkerBalance)
+	if err != nil {
+		return false, err
+	}
+	sequencerBalanceStr, workerBalanceStr := contract.BalanceToStr2(sequencerBalance), contract.BalanceToStr2(workerBalance)
+	logs.GetLogger().Infof("cpAccount: %s, sequencer balance: %s ETH, worker address balance: %s ETH", cpAccountAddress, sequencerBalanceStr, workerBalanceStr)
 
 	if conf.GetConfig().UBI.EnableSequencer && !conf.GetConfig().UBI.AutoChainProof {
-		if cpBalance.SequencerBalance > 0.000001 {
+		if sequencerBalance.Cmp(minSequencerBalance) > 0 {
 			return true, nil
 		} else {
-			return false, fmt.Errorf("No sufficient balance in the sequencer account, current balance: %f ETH", cpBalance.SequencerBalance)
+			return false, fmt.Errorf("No sufficient balance in the sequencer account, current balance: %s ETH", sequencerBalanceStr)
 		}
 	}
 
 	if conf.GetConfig().UBI.EnableSequencer && conf.GetConfig().UBI.AutoChainProof {
-		if cpBalance.SequencerBalance > 0.000001 || cpBalance.WorkerBalance > 0.00001 {
+		if sequencerBalance.Cmp(minSequencerBalance) > 0 || workerBalance.Cmp(minWorkerBalance) > 0 {
 			return true, nil
 		} else {
-			return false, fmt.Errorf("Not enough funds in the sequencer account and worker address, sequencer balance: %f ETH, worker address: %f ETH", cpBalance.SequencerBalance,
-				cpBalance.WorkerBalance)
+			return false, fmt.Errorf("Not enough funds in the sequencer account and worker address, sequencer balance: %s ETH, worker address: %s ETH", sequencerBalanceStr,
+				workerBalanceStr)
 		}
 	}
 
 	if !conf.GetConfig().UBI.EnableSequencer && conf.GetConfig().UBI.AutoChainProof {
-		if cpBalance.WorkerBalance > 0.00001 {
+		if workerBalance.Cmp(minWorkerBalance) > 0 {
 			return true, nil
 		} else {
-			return false, fmt.Errorf("No sufficient balance in the worker address, current balance: %f ETH", cpBalance.WorkerBalance)
+			return false, fmt.Errorf("No sufficient balance in the worker address, current balance: %s ETH", workerBalanceStr)
 		}
 	}
 
@@ -2758,7 +2870,7 @@ func GetCpBalance() {
 		logs.GetLogger().Errorf("failed to dial rpc, cpAccount: %d, error: %v", cpAccountAddress, err)
 		return
 	}
-	client.Close()
+	defer client.Close()
 
 	_, workerAddress, err := GetOwnerAddressAndWorkerAddress()
 	if err != nil {
@@ -2766,7 +2878,7 @@ func GetCpBalance() {
 		return
 	}
 
-	workerBalance, err := wallet.BalanceNumber(client, workerAddress)
+	workerBalance, err := wallet.BalanceWei(client, workerAddress)
 	if err != nil {
 		logs.GetLogger().Errorf("failed to get worker banlance, cpAccount: %s,error: %v", cpAccountAddress, err)
 		return
@@ -2777,25 +2889,19 @@ func GetCpBalance() {
 		logs.GetLogger().Errorf("failed to get cp sequencer contract, cpAccount: %s, error: %v", cpAccountAddress, err)
 		return
 	}
-	sequencerBalanceStr, err := sequencerStub.GetCPBalance()
+	sequencerBalance, err := sequencerStub.GetCPBalance()
 	if err != nil {
 		logs.GetLogger().Errorf("failed to get cp sequencer contract, cpAccount: %s, error: %v", cpAccountAddress, err)
 		return
 	}
 
-	sequencerBalance, err := strconv.ParseFloat(sequencerBalanceStr, 64)
-	if err != nil {
-		logs.GetLogger().Errorf("failed to convert numbers for cp sequencer balance, cpAccount: %s, sequencerBalance: %s, error: %v", cpAccountAddress, sequencerBalanceStr, err)
-		return
-	}
-
-	logs.GetLogger().Infof("cpAccount: %s, sequencer balance: %s ETH, worker address balance: %s ETH", cpAccountAddress, fmt.Sprintf("%.6f", sequencerBalance),
-		fmt.Sprintf("%.6f", workerBalance))
+	logs.GetLogger().Infof("cpAccount: %s, sequencer balance: %s ETH, worker address balance: %s ETH", cpAccountAddress, contract.BalanceToStr2(sequencerBalance),
+		contract.BalanceToStr2(workerBalance))
 
 	var cpBalance = models.CpBalanceEntity{
 		CpAccount:        cpAccountAddress,
-		WorkerBalance:    roundToSixDecimal(workerBalance),
-		SequencerBalance: roundToSixDecimal(sequencerBalance),
+		WorkerBalance:    workerBalance.String(),
+		SequencerBalance: sequencerBalance.String(),
 	}
 	cpBalanceEntity, err := NewCpBalanceService().GetCpBalance(cpAccountAddress)
 	if err != nil || cpBalanceEntity.CpAccount == "" {
@@ -2811,9 +2917,6 @@ func GetCpBalance() {
 	}
 }
 
-func roundToSixDecimal(num float64) float64 {
-	return math.Round(num*1e6) / 1e6
-}
 func RetryFn(fn func() error, maxRetries int, delay time.Duration) error {
 	var err error
 	for i := 0; i < maxRetries; i++ {
@@ -2827,55 +2930,3 @@ func RetryFn(fn func() error, maxRetries int, delay time.Duration) error {
 	return fmt.Errorf("after %d attempts, last error: %w", maxRetries, err)
 }
 
-func RestartTraefikService() error {
-	traefikServiceContainerName := "traefik-service"
-	dockerService := NewDockerService()
-	dockerService.RemoveContainerByName(traefikServiceContainerName)
-	if err := dockerService.CreateNetwork("traefik-net"); err != nil {
-		return err
-	}
-
-	err := dockerService.PullImage(build.TraefikServerDockerImage)
-	if err != nil {
-		return fmt.Errorf("pull %s image failed, error: %v", build.UBIResourceExporterDockerImage, err)
-	}
-
-	err = dockerService.ContainerCreateAndStart(&container.Config{
-		Image: build.TraefikServerDockerImage,
-		Cmd: []string{
-			"--api.insecure=true",
-			"--log.level=INFO",
-			"--providers.docker.exposedbydefault=false",
-			"--providers.docker=true",
-			"--entrypoints.web.address=:80",
-		},
-		AttachStdout: true,
-		AttachStderr: true,
-		Tty:          true,
-	}, &container.HostConfig{
-		PortBindings: map[nat.Port][]nat.PortBinding{
-			"80/tcp": {{
-				HostIP:   "0.0.0.0",
-				HostPort: strconv.Itoa(traefikListenPortMapHost),
-			}},
-			"8080/tcp": {{
-				HostIP:   "0.0.0.0",
-				HostPort: "9080",
-			}},
-		},
-		Binds: []string{"/var/run/docker.sock:/var/run/docker.sock"},
-		RestartPolicy: container.RestartPolicy{
-			Name:              container.RestartPolicyOnFailure,
-			MaximumRetryCount: 3,
-		},
-		Privileged: true,
-	}, &network.NetworkingConfig{
-		EndpointsConfig: map[string]*network.EndpointSettings{
-			"traefik-net": {},
-		},
-	}, traefikServiceContainerName)
-	if err != nil {
-		return fmt.Errorf("create traefik-service container failed, error: %v", err)
-	}
-	return nil
-}
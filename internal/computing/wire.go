@@ -31,3 +31,48 @@ func NewCpBalanceService() CpBalanceService {
 	wire.Build(cpBalanceSet)
 	return CpBalanceService{}
 }
+
+func NewPortLeaseService() PortLeaseService {
+	wire.Build(portLeaseSet)
+	return PortLeaseService{}
+}
+
+func NewImageCacheService() ImageCacheService {
+	wire.Build(imageCacheSet)
+	return ImageCacheService{}
+}
+
+func NewModelCacheService() ModelCacheService {
+	wire.Build(modelCacheSet)
+	return ModelCacheService{}
+}
+
+func NewLedgerService() LedgerService {
+	wire.Build(ledgerSet)
+	return LedgerService{}
+}
+
+func NewBackfillService() BackfillService {
+	wire.Build(backfillSet)
+	return BackfillService{}
+}
+
+func NewScanBlockService() ScanBlockService {
+	wire.Build(scanBlockSet)
+	return ScanBlockService{}
+}
+
+func NewChainEventService() ChainEventService {
+	wire.Build(chainEventSet)
+	return ChainEventService{}
+}
+
+func NewAlertService() AlertService {
+	wire.Build(alertSet)
+	return AlertService{}
+}
+
+func NewTopUpService() TopUpService {
+	wire.Build(topUpSet)
+	return TopUpService{}
+}
@@ -55,3 +55,75 @@ func NewCpBalanceService() CpBalanceService {
 	}
 	return cpBalanceService
 }
+
+func NewPortLeaseService() PortLeaseService {
+	gormDB := db.NewDbService()
+	portLeaseService := PortLeaseService{
+		DB: gormDB,
+	}
+	return portLeaseService
+}
+
+func NewImageCacheService() ImageCacheService {
+	gormDB := db.NewDbService()
+	imageCacheService := ImageCacheService{
+		DB: gormDB,
+	}
+	return imageCacheService
+}
+
+func NewModelCacheService() ModelCacheService {
+	gormDB := db.NewDbService()
+	modelCacheService := ModelCacheService{
+		DB: gormDB,
+	}
+	return modelCacheService
+}
+
+func NewLedgerService() LedgerService {
+	gormDB := db.NewDbService()
+	ledgerService := LedgerService{
+		DB: gormDB,
+	}
+	return ledgerService
+}
+
+func NewBackfillService() BackfillService {
+	gormDB := db.NewDbService()
+	backfillService := BackfillService{
+		DB: gormDB,
+	}
+	return backfillService
+}
+
+func NewScanBlockService() ScanBlockService {
+	gormDB := db.NewDbService()
+	scanBlockService := ScanBlockService{
+		DB: gormDB,
+	}
+	return scanBlockService
+}
+
+func NewChainEventService() ChainEventService {
+	gormDB := db.NewDbService()
+	chainEventService := ChainEventService{
+		DB: gormDB,
+	}
+	return chainEventService
+}
+
+func NewAlertService() AlertService {
+	gormDB := db.NewDbService()
+	alertService := AlertService{
+		DB: gormDB,
+	}
+	return alertService
+}
+
+func NewTopUpService() TopUpService {
+	gormDB := db.NewDbService()
+	topUpService := TopUpService{
+		DB: gormDB,
+	}
+	return topUpService
+}
@@ -104,20 +104,21 @@ func (s *SequencerStub) Withdraw(amount *big.Int) (string, error) {
 	return transaction.Hash().String(), nil
 }
 
-func (s *SequencerStub) GetCPBalance() (string, error) {
+// GetCPBalance returns the balance of the cp account in the sequencer in wei, it is negative when the cp account owes fees
+func (s *SequencerStub) GetCPBalance() (*big.Int, error) {
 	if s.cpAccountAddress == "" || len(strings.TrimSpace(s.cpAccountAddress)) == 0 {
 		cpAccountAddress, err := contract.GetCpAccountAddress()
 		if err != nil {
-			return "", fmt.Errorf("get cp account contract address failed, error: %v", err)
+			return nil, fmt.Errorf("get cp account contract address failed, error: %v", err)
 		}
 		s.cpAccountAddress = cpAccountAddress
 	}
 
 	balance, err := s.sequencer.GetCPBalance(&bind.CallOpts{}, common.HexToAddress(s.cpAccountAddress))
 	if err != nil {
-		return "", fmt.Errorf("address: %s, ECP sequencer client get cp balance tx error: %+v", s.cpAccountAddress, err)
+		return nil, fmt.Errorf("address: %s, ECP sequencer client get cp balance tx error: %+v", s.cpAccountAddress, err)
 	}
-	return contract.BalanceToStr2(balance), nil
+	return balance, nil
 }
 
 func (s *SequencerStub) privateKeyToPublicKey() (common.Address, error) {
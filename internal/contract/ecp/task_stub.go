@@ -112,6 +112,7 @@ outerLoop:
 					continue
 				}
 				taskContractAddress = contractAddress.Hex()
+				task.TxHash = transaction.Hash().Hex()
 				break outerLoop
 			} else {
 				logs.GetLogger().Warnf("taskId: %d create task contract is nil, retrying", task.Id)
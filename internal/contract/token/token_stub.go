@@ -9,6 +9,7 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/internal/contract"
 	"math/big"
 	"strings"
 )
@@ -69,16 +70,7 @@ func (s *Stub) BalanceOf() (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("address: %s, read token contract balance, error: %+v", publicAddress, err)
 	}
-	var ethValue string
-	if balance.String() == "0" {
-		ethValue = "0.0000"
-	} else {
-		fbalance := new(big.Float)
-		fbalance.SetString(balance.String())
-		etherQuotient := new(big.Float).Quo(fbalance, new(big.Float).SetInt(big.NewInt(1e18)))
-		ethValue = etherQuotient.Text('f', 4)
-	}
-	return ethValue, nil
+	return contract.BalanceToStr(balance), nil
 }
 
 func (s *Stub) Approve(amount *big.Int) (string, error) {
@@ -10,33 +10,83 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
 func BalanceToStr(balance *big.Int) string {
-	var ethValue string
-	if balance.String() == "0" {
-		ethValue = "0.0000"
-	} else {
-		fbalance := new(big.Float)
-		fbalance.SetString(balance.String())
-		etherQuotient := new(big.Float).Quo(fbalance, new(big.Float).SetInt(big.NewInt(1e18)))
-		ethValue = etherQuotient.Text('f', 4)
-	}
-	return ethValue
+	return FormatBalance(balance, 4)
 }
 
 func BalanceToStr2(balance *big.Int) string {
-	var ethValue string
-	if balance.String() == "0" {
-		ethValue = "0.000000"
+	return FormatBalance(balance, 6)
+}
+
+// BalanceToExactStr formats the wei of a balance in ether without rounding, the trailing zeros are trimmed
+func BalanceToExactStr(balance *big.Int) string {
+	return FormatBalance(balance, -1)
+}
+
+// FormatBalance formats the wei of a balance in ether with the decimals, the digits beyond them are truncated.
+// A negative decimals keeps all the digits of the balance and trims the trailing zeros.
+func FormatBalance(balance *big.Int, decimals int) string {
+	digits := new(big.Int).Abs(balance).String()
+	if len(digits) <= 18 {
+		digits = strings.Repeat("0", 19-len(digits)) + digits
+	}
+	value := digits[:len(digits)-18]
+	fraction := digits[len(digits)-18:]
+	if decimals < 0 {
+		fraction = strings.TrimRight(fraction, "0")
+	} else if decimals <= 18 {
+		fraction = fraction[:decimals]
 	} else {
-		fbalance := new(big.Float)
-		fbalance.SetString(balance.String())
-		etherQuotient := new(big.Float).Quo(fbalance, new(big.Float).SetInt(big.NewInt(1e18)))
-		ethValue = etherQuotient.Text('f', 6)
+		fraction += strings.Repeat("0", decimals-18)
+	}
+	if fraction != "" {
+		value += "." + fraction
+	}
+	if balance.Sign() < 0 {
+		value = "-" + value
+	}
+	return value
+}
+
+// FormatWei formats a wei amount stored as a string in ether with the decimals, an empty amount is zero
+func FormatWei(wei string, decimals int) string {
+	balance, err := ParseWei(wei)
+	if err != nil {
+		return wei
+	}
+	return FormatBalance(balance, decimals)
+}
+
+// ParseWei parses a wei amount stored as a string, an empty amount is zero
+func ParseWei(wei string) (*big.Int, error) {
+	wei = strings.TrimSpace(wei)
+	if wei == "" {
+		return new(big.Int), nil
+	}
+	balance, ok := new(big.Int).SetString(wei, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid wei amount: %s", wei)
+	}
+	return balance, nil
+}
+
+// StrToBalance parses an amount in ether into wei, the digits beyond 18 decimals are truncated
+func StrToBalance(value string) (*big.Int, error) {
+	value = strings.TrimSpace(value)
+	integer, fraction, _ := strings.Cut(value, ".")
+	if len(fraction) > 18 {
+		fraction = fraction[:18]
+	}
+	fraction += strings.Repeat("0", 18-len(fraction))
+	balance, ok := new(big.Int).SetString(integer+fraction, 10)
+	if !ok || value == "" {
+		return nil, fmt.Errorf("invalid amount: %s", value)
 	}
-	return ethValue
+	return balance, nil
 }
 
 func GetCpAccountAddress() (string, error) {
@@ -0,0 +1,85 @@
+package contract
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestFormatBalance(t *testing.T) {
+	wei := func(s string) *big.Int {
+		v, _ := new(big.Int).SetString(s, 10)
+		return v
+	}
+	tests := []struct {
+		balance  *big.Int
+		decimals int
+		want     string
+	}{
+		{wei("0"), 4, "0.0000"},
+		{wei("0"), -1, "0"},
+		{wei("1000000000000000000"), 2, "1.00"},
+		{wei("1234567890000000000"), 4, "1.2345"},
+		{wei("1234567890000000000"), -1, "1.23456789"},
+		{wei("1"), -1, "0.000000000000000001"},
+		{wei("1"), 4, "0.0000"},
+		{wei("123000000000000000000"), 0, "123"},
+		{wei("-1500000000000000000"), 2, "-1.50"},
+		{wei("5"), 20, "0.00000000000000000500"},
+	}
+	for _, tt := range tests {
+		if got := FormatBalance(tt.balance, tt.decimals); got != tt.want {
+			t.Errorf("FormatBalance(%s, %d): expected %s, got %s", tt.balance, tt.decimals, tt.want, got)
+		}
+	}
+}
+
+func TestStrToBalance(t *testing.T) {
+	tests := map[string]string{
+		"1":                       "1000000000000000000",
+		"0.5":                     "500000000000000000",
+		".5":                      "500000000000000000",
+		" 2.25 ":                  "2250000000000000000",
+		"0.000000000000000001":    "1",
+		"0.0000000000000000019":   "1",
+		"-1.5":                    "-1500000000000000000",
+		"123456789.123456789":     "123456789123456789000000000",
+		"0.100000000000000000000": "100000000000000000",
+	}
+	for value, want := range tests {
+		got, err := StrToBalance(value)
+		if err != nil {
+			t.Errorf("%q: %v", value, err)
+			continue
+		}
+		if got.String() != want {
+			t.Errorf("%q: expected %s, got %s", value, want, got)
+		}
+		// the exact string of the balance parses back to the same wei
+		if back, err := StrToBalance(BalanceToExactStr(got)); err != nil || back.Cmp(got) != 0 {
+			t.Errorf("%q: expected %s to round trip, got %v %v", value, BalanceToExactStr(got), back, err)
+		}
+	}
+
+	for _, value := range []string{"", "abc", "1.2.3", "1e18"} {
+		if _, err := StrToBalance(value); err == nil {
+			t.Errorf("%q: expected an invalid amount", value)
+		}
+	}
+}
+
+func TestParseWei(t *testing.T) {
+	if v, err := ParseWei(""); err != nil || v.Sign() != 0 {
+		t.Errorf("expected an empty amount to be zero, got %v %v", v, err)
+	}
+	if v, err := ParseWei(" 1000 "); err != nil || v.Int64() != 1000 {
+		t.Errorf("expected 1000, got %v %v", v, err)
+	}
+	for _, wei := range []string{"1.5", "0x10", "abc"} {
+		if _, err := ParseWei(wei); err == nil {
+			t.Errorf("%q: expected an invalid wei amount", wei)
+		}
+	}
+	if got := FormatWei("", 2); got != "0.00" {
+		t.Errorf("expected 0.00, got %s", got)
+	}
+}
@@ -3,12 +3,17 @@ package db
 import (
 	_ "embed"
 	"fmt"
+	"github.com/filswan/go-mcs-sdk/mcs/api/common/logs"
+	"github.com/swanchain/go-computing-provider/internal/contract"
 	"github.com/swanchain/go-computing-provider/internal/models"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
+	"math/big"
 	"os"
 	"path"
+	"strings"
 	"time"
 )
 
@@ -48,9 +53,149 @@ func InitDb(cpRepoPath string) {
 		&models.CpInfoEntity{},
 		&models.EcpJobEntity{},
 		&models.ScanChainEntity{},
-		&models.CpBalanceEntity{}); err != nil {
+		&models.CpBalanceEntity{},
+		&models.PortLeaseEntity{},
+		&models.ImageCacheEntity{},
+		&models.ModelCacheEntity{},
+		&models.LedgerEntity{},
+		&models.BackfillEntity{},
+		&models.ScanBlockEntity{},
+		&models.AccountEventEntity{},
+		&models.CollateralEventEntity{},
+		&models.SequencerEventEntity{},
+		&models.TaskEventEntity{},
+		&models.AlertEntity{},
+		&models.TopUpEntity{}); err != nil {
 		panic("failed to auto migrate for provider db")
 	}
+	if err = migrateWeiColumns(); err != nil {
+		panic(fmt.Sprintf("failed to migrate the amounts of provider db to wei, error: %v", err))
+	}
+	if err = seedLedgerRewards(); err != nil {
+		panic(fmt.Sprintf("failed to record the rewards of provider db in the ledger, error: %v", err))
+	}
+}
+
+// weiColumn is an ether amount column replaced by a column of the amount in wei
+type weiColumn struct {
+	table string
+	from  string
+	to    string
+}
+
+// migrateWeiColumns copies the ether amounts of the old columns into their wei columns. The old columns are floats
+// or strings truncated to a few decimals, they are converted as they are stored, an amount that is not a number is
+// recorded as 0. The old columns are kept so the db still opens with the previous release, a row is converted once,
+// while its wei column is empty.
+func migrateWeiColumns() error {
+	for _, c := range []weiColumn{
+		{"t_job", "reward", "reward_wei"},
+		{"t_task", "reward", "reward_wei"},
+		{"t_ecp_job", "reward", "reward_wei"},
+		{"t_cp_balance", "worker_balance", "worker_balance_wei"},
+		{"t_cp_balance", "sequencer_balance", "sequencer_balance_wei"},
+	} {
+		if !DB.Migrator().HasColumn(c.table, c.from) {
+			continue
+		}
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			var rows []struct {
+				RowId  int64
+				Amount string
+			}
+			query := fmt.Sprintf("SELECT rowid AS row_id, CAST(%s AS TEXT) AS amount FROM %s WHERE %s IS NOT NULL AND %s != '' "+
+				"AND (%s IS NULL OR %s = '')", c.from, c.table, c.from, c.from, c.to, c.to)
+			if err := tx.Raw(query).Scan(&rows).Error; err != nil {
+				return err
+			}
+			for _, row := range rows {
+				wei, err := etherToWei(row.Amount)
+				if err != nil {
+					logs.GetLogger().Warnf("%s.%s of row %d: %v, it is recorded as 0", c.table, c.from, row.RowId, err)
+					wei = "0"
+				}
+				if err = tx.Exec(fmt.Sprintf("UPDATE %s SET %s = ? WHERE rowid = ?", c.table, c.to), wei, row.RowId).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ledgerReward is the reward column of a job table summed from the events of a scanner
+type ledgerReward struct {
+	table     string
+	uuid      string
+	category  string
+	taskType  string
+	scannerId int64
+}
+
+// seedLedgerRewards records the rewards the jobs had before the ledger existed, so the rewards summed from the ledger
+// keep them. An entry is at the block the scanner had reached, the events up to it are counted in the entry.
+func seedLedgerRewards() error {
+	for _, r := range []ledgerReward{
+		{"t_job", "task_uuid", models.LedgerFcpReward, "fcp", models.ScannerFcpTaskManagerId},
+		{"t_ecp_job", "uuid", models.LedgerEcpReward, "ecp", models.ScannerTaskPaymentId},
+	} {
+		var rows []struct {
+			Uuid       string
+			Reward     string
+			CreateTime int64
+		}
+		query := fmt.Sprintf("SELECT %s AS uuid, reward_wei AS reward, create_time FROM %s WHERE %s != '' AND reward_wei IS NOT NULL "+
+			"AND reward_wei NOT IN ('', '0') AND NOT EXISTS (SELECT 1 FROM t_ledger WHERE category = ? AND task_uuid = %s.%s)",
+			r.uuid, r.table, r.uuid, r.table, r.uuid)
+		if err := DB.Raw(query, r.category).Scan(&rows).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			continue
+		}
+
+		var scanned models.ScanChainEntity
+		if err := DB.Where("id=?", r.scannerId).Limit(1).Find(&scanned).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if _, err := contract.ParseWei(row.Reward); err != nil {
+				return fmt.Errorf("%s.reward_wei of %s: %v", r.table, row.Uuid, err)
+			}
+			entry := models.LedgerEntity{
+				Kind:        models.LedgerReward,
+				Category:    r.category,
+				TaskType:    r.taskType,
+				TaskUuid:    row.Uuid,
+				BlockNumber: uint64(max(scanned.BlockNumber, 0)),
+				Token:       models.LedgerTokenSwan,
+				Amount:      row.Reward,
+				EventTime:   row.CreateTime,
+				CreateTime:  time.Now().Unix(),
+			}
+			if err := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&entry).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// etherToWei converts an ether amount stored by a float or decimal column, like 0.5 or 1.0e-05, into wei
+func etherToWei(amount string) (string, error) {
+	value, _, err := big.ParseFloat(strings.TrimSpace(amount), 10, 256, big.ToNearestEven)
+	if err != nil {
+		return "", fmt.Errorf("invalid amount %q", amount)
+	}
+	wei, err := contract.StrToBalance(value.Text('f', 18))
+	if err != nil {
+		return "", err
+	}
+	return wei.String(), nil
 }
 
 func NewDbService() *gorm.DB {
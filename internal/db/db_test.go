@@ -0,0 +1,108 @@
+package db
+
+import (
+	"path"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestEtherToWei(t *testing.T) {
+	tests := map[string]string{
+		"0":            "0",
+		"1":            "1000000000000000000",
+		"0.1":          "100000000000000000",
+		" 12.3456 ":    "12345600000000000000",
+		"0.0000000001": "100000000",
+		"1e-18":        "1",
+	}
+	for amount, want := range tests {
+		got, err := etherToWei(amount)
+		if err != nil {
+			t.Errorf("%q: %v", amount, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("%q: expected %s, got %s", amount, want, got)
+		}
+	}
+	if _, err := etherToWei("abc"); err == nil {
+		t.Errorf("expected an invalid amount to fail")
+	}
+}
+
+func TestMigrateWeiColumns(t *testing.T) {
+	repo := t.TempDir()
+	old, err := gorm.Open(sqlite.Open(path.Join(repo, cpDBName)), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		"CREATE TABLE t_job (id integer PRIMARY KEY AUTOINCREMENT, task_uuid text, reward real)",
+		"INSERT INTO t_job (task_uuid, reward) VALUES ('task-1', 0.25), ('task-2', NULL), ('task-3', 3), ('task-4', 'N/A')",
+		"CREATE TABLE t_cp_balance (id integer PRIMARY KEY, cp_account text, worker_balance text, sequencer_balance text)",
+		"INSERT INTO t_cp_balance (id, cp_account, worker_balance, sequencer_balance) VALUES (1, '0x01', '0.0123', '')",
+	} {
+		if err = old.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	sqlDB, _ := old.DB()
+	sqlDB.Close()
+
+	// the node starts with an amount that is not a number, the old columns are kept for the previous release
+	InitDb(repo)
+	if !DB.Migrator().HasColumn("t_job", "reward") || !DB.Migrator().HasColumn("t_cp_balance", "worker_balance") {
+		t.Errorf("expected the ether columns to be kept")
+	}
+
+	var rewards []struct {
+		TaskUuid  string
+		RewardWei *string
+	}
+	if err = DB.Raw("SELECT task_uuid, reward_wei FROM t_job ORDER BY id").Scan(&rewards).Error; err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"task-1": "250000000000000000", "task-3": "3000000000000000000", "task-4": "0"}
+	for _, r := range rewards {
+		if want[r.TaskUuid] == "" {
+			if r.RewardWei != nil && *r.RewardWei != "" {
+				t.Errorf("%s: expected no reward, got %s", r.TaskUuid, *r.RewardWei)
+			}
+			continue
+		}
+		if r.RewardWei == nil || *r.RewardWei != want[r.TaskUuid] {
+			t.Errorf("%s: expected %s, got %v", r.TaskUuid, want[r.TaskUuid], r.RewardWei)
+		}
+	}
+
+	var balance struct {
+		WorkerBalanceWei string
+	}
+	if err = DB.Raw("SELECT worker_balance_wei FROM t_cp_balance WHERE id = 1").Scan(&balance).Error; err != nil {
+		t.Fatal(err)
+	}
+	if balance.WorkerBalanceWei != "12300000000000000" {
+		t.Errorf("expected the worker balance 12300000000000000, got %s", balance.WorkerBalanceWei)
+	}
+
+	// the migration runs once, opening the db again keeps the amounts written since
+	if err = DB.Exec("UPDATE t_job SET reward_wei = '7' WHERE task_uuid = 'task-1'").Error; err != nil {
+		t.Fatal(err)
+	}
+	InitDb(repo)
+	var reward string
+	if err = DB.Raw("SELECT reward_wei FROM t_job WHERE task_uuid = 'task-1'").Scan(&reward).Error; err != nil {
+		t.Fatal(err)
+	}
+	if reward != "7" {
+		t.Errorf("expected the reward to be kept, got %s", reward)
+	}
+	if err = DB.Raw("SELECT worker_balance_wei FROM t_cp_balance WHERE id = 1").Scan(&balance).Error; err != nil {
+		t.Fatal(err)
+	}
+	if balance.WorkerBalanceWei != "12300000000000000" {
+		t.Errorf("expected the worker balance to be kept, got %s", balance.WorkerBalanceWei)
+	}
+}
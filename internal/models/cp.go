@@ -28,6 +28,27 @@ type Job struct {
 	Url    string
 }
 
+type JobControlReq struct {
+	TaskUuid                string `json:"task_uuid"`
+	NodeIdTaskUuidSignature string `json:"node_id_task_uuid_signature"`
+}
+
+type JobResizeReq struct {
+	TaskUuid                string `json:"task_uuid"`
+	NodeIdTaskUuidSignature string `json:"node_id_task_uuid_signature"`
+	BidPrice                string `json:"bid_price"` // Amount users are willing to pay for the remaining duration
+	Cpu                     int64  `json:"cpu"`       // unit vCPU
+	Memory                  int64  `json:"memory"`    // unit GiB
+	Storage                 int64  `json:"storage"`   // unit GiB, 0: unchanged
+	Gpu                     int64  `json:"gpu"`       // the gpu model of the job is unchanged
+}
+
+type JobResizeResp struct {
+	TaskUuid   string  `json:"task_uuid"`
+	Price      float64 `json:"price"`
+	ExpireTime int64   `json:"expire_time"`
+}
+
 type SpaceJsonWithNoData struct {
 	Files []SpaceFile `json:"files"`
 	Owner struct {
@@ -53,6 +74,8 @@ type SpaceFile struct {
 	URL          string `json:"url"`
 	SymmetricKey string `json:"symmetric_key"`
 	Iv           string `json:"iv"`
+	Sha256       string `json:"sha256,omitempty"` // the hex sha256 of the content of the file, after decryption
+	Size         int64  `json:"size,omitempty"`   // the bytes of the file as downloaded
 }
 
 type SpaceHardware struct {
@@ -117,7 +117,7 @@ type TaskEntity struct {
 	CheckCode          string `json:"check_code"`
 	BlockHash          string `json:"block_hash"`
 	Sign               string `json:"sign"`
-	Reward             string `json:"reward"`
+	Reward             string `json:"reward" gorm:"column:reward_wei"` // wei
 	SequenceCid        string `json:"sequence_cid"`
 	SettlementCid      string `json:"settlement_cid"`
 	SequenceTaskAddr   string `json:"sequence_task_addr"`
@@ -150,6 +150,7 @@ const (
 	JOB_TERMINATED_STATUS = 3
 	JOB_COMPLETED_STATUS  = 4
 	JOB_FAILED_STATUS     = 5
+	JOB_PAUSED_STATUS     = 6
 )
 
 func GetJobStatus(status int) string {
@@ -169,6 +170,8 @@ func GetJobStatus(status int) string {
 		statusStr = "terminated"
 	case JOB_COMPLETED_STATUS:
 		statusStr = "completed"
+	case JOB_PAUSED_STATUS:
+		statusStr = "paused"
 	default:
 		statusStr = "unknown"
 	}
@@ -227,7 +230,7 @@ type JobEntity struct {
 	BuildLog        string `json:"build_log" gorm:"build_log"`
 	BuildLogPath    string `json:"build_log_path"`
 	ContainerLog    string `json:"container_log" gorm:"container_log"`
-	Reward          string `json:"reward" gorm:"reward"`
+	Reward          string `json:"reward" gorm:"column:reward_wei"` // wei
 	ExpireTime      int64  `json:"expire_time" gorm:"expire_time"`
 	CreateTime      int64  `json:"create_time" gorm:"create_time"`
 	Error           string `json:"error" gorm:"error"`
@@ -238,6 +241,12 @@ type JobEntity struct {
 	StartedBlock    uint64 `json:"started_block" gorm:"column:started_block;not null;default:0"`
 	ScannedBlock    uint64 `json:"scanned_block" gorm:"column:scanned_block;not null;default:0"`
 	EndedBlock      uint64 `json:"ended_block" gorm:"column:ended_block;not null;default:0"`
+	Cpu             int64  `json:"cpu" gorm:"column:cpu;default:0"`
+	Memory          int64  `json:"memory" gorm:"column:memory;default:0"`   // unit bytes
+	Storage         int64  `json:"storage" gorm:"column:storage;default:0"` // unit bytes
+	Gpu             int64  `json:"gpu" gorm:"column:gpu;default:0"`
+	PausedAt        int64  `json:"paused_at" gorm:"column:paused_at;default:0"`             // 0: not paused
+	PausedDuration  int64  `json:"paused_duration" gorm:"column:paused_duration;default:0"` // unit seconds, excluding the current pause
 }
 
 func (*JobEntity) TableName() string {
@@ -351,28 +360,38 @@ func ExistResource(name string) bool {
 }
 
 type EcpJobEntity struct {
-	Id              int64   `json:"id" gorm:"primaryKey;autoIncrement"`
-	Uuid            string  `json:"uuid" gorm:"uuid"`
-	Name            string  `json:"name" gorm:"name"`
-	Image           string  `json:"image" gorm:"image"`
-	Env             string  `json:"env" gorm:"env"`
-	Cmd             string  `json:"cmd" gorm:"type:json"`
-	Status          string  `json:"status"` // created|restarting|running|removing|paused|exited|dead
-	Message         string  `json:"message"`
-	Reward          float64 `json:"reward"`
-	Cpu             int64   `json:"cpu"`
-	JobType         int     `json:"job_type"`
-	Memory          int64   `json:"memory"`
-	Storage         int64   `json:"storage"`
-	GpuName         string  `json:"gpu_name"`
-	GpuIndex        string  `json:"gpu_index"  gorm:"type:json"`          // =
-	ContainerName   string  `json:"container_name" gorm:"container_name"` // =
-	HealthUrlPath   string  `json:"health_url_path"`
-	ServiceUrl      string  `json:"service_url" gorm:"service_url"`
-	PortMap         string  `json:"port_map" gorm:"port_map"`
-	LastBlockNumber int64   `json:"last_block_number" gorm:"last_block_number"`
-	CreateTime      int64   `json:"create_time" gorm:"create_time"`
-	DeleteAt        int     `json:"delete_at" gorm:"delete_at; default:0"` // 1 deleted
+	Id              int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Uuid            string `json:"uuid" gorm:"uuid"`
+	Name            string `json:"name" gorm:"name"`
+	Image           string `json:"image" gorm:"image"`
+	Env             string `json:"env" gorm:"env"`
+	Cmd             string `json:"cmd" gorm:"type:json"`
+	Status          string `json:"status"` // created|restarting|running|removing|paused|exited|dead
+	Message         string `json:"message"`
+	Reward          string `json:"reward" gorm:"column:reward_wei"` // wei
+	Cpu             int64  `json:"cpu"`
+	JobType         int    `json:"job_type"`
+	Memory          int64  `json:"memory"`
+	Storage         int64  `json:"storage"`
+	GpuName         string `json:"gpu_name"`
+	GpuIndex        string `json:"gpu_index"  gorm:"type:json"`          // =
+	ContainerName   string `json:"container_name" gorm:"container_name"` // =
+	HealthUrlPath   string `json:"health_url_path"`
+	ServiceUrl      string `json:"service_url" gorm:"service_url"`
+	PortMap         string `json:"port_map" gorm:"port_map"`
+	LastBlockNumber int64  `json:"last_block_number" gorm:"last_block_number"`
+	CreateTime      int64  `json:"create_time" gorm:"create_time"`
+	DeleteAt        int    `json:"delete_at" gorm:"delete_at; default:0"` // 1 deleted
+	Stage           string `json:"stage"`
+	Stages          string `json:"stages" gorm:"type:json"` // []JobStage of the deploy pipeline
+	HealthPort      int    `json:"health_port"`             // the container port checked by the health monitor
+	Health          string `json:"health"`                  // healthy|unhealthy
+	HealthFailures  int    `json:"health_failures"`         // the consecutive failed checks
+	HealthMessage   string `json:"health_message"`
+	LastCheckTime   int64  `json:"last_check_time"`
+	StartedTime     int64  `json:"started_time"` // when the container started the last time
+	RestartCount    int    `json:"restart_count"`
+	DockerRestarts  int    `json:"docker_restarts"` // the restarts of the container by docker, at the last check
 }
 
 const (
@@ -388,10 +407,109 @@ const (
 	TerminatedStatus = "terminated"
 )
 
+const (
+	HealthyStatus   = "healthy"
+	UnhealthyStatus = "unhealthy"
+)
+
 func (*EcpJobEntity) TableName() string {
 	return "t_ecp_job"
 }
 
+type PortLeaseEntity struct {
+	Id            int64  `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	Port          int    `json:"port" gorm:"column:port;uniqueIndex"`
+	JobUuid       string `json:"job_uuid" gorm:"column:job_uuid;index"`
+	ContainerName string `json:"container_name" gorm:"column:container_name"`
+	CreateTime    int64  `json:"create_time" gorm:"column:create_time"`
+	ExpireTime    int64  `json:"expire_time" gorm:"column:expire_time"` // 0: held until released, otherwise released when the container is not found after it
+}
+
+func (*PortLeaseEntity) TableName() string {
+	return "t_port_lease"
+}
+
+type ImageCacheEntity struct {
+	Id           int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Image        string `json:"image" gorm:"uniqueIndex"`
+	Size         int64  `json:"size"`
+	UseCount     int    `json:"use_count"`
+	LastUsedTime int64  `json:"last_used_time"`
+	CreateTime   int64  `json:"create_time"`
+}
+
+func (*ImageCacheEntity) TableName() string {
+	return "t_image_cache"
+}
+
+// ModelCacheEntity is a model file in the model cache of a node, Node is empty for the cache of the local docker host
+type ModelCacheEntity struct {
+	Id           int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Key          string `json:"key" gorm:"uniqueIndex:idx_model_cache_key_node"`
+	Node         string `json:"node" gorm:"uniqueIndex:idx_model_cache_key_node"`
+	Name         string `json:"name"`
+	Url          string `json:"url"`
+	Sha256       string `json:"sha256"`
+	Size         int64  `json:"size"`
+	Status       string `json:"status"`
+	Message      string `json:"message"`
+	UseCount     int    `json:"use_count"`
+	LastUsedTime int64  `json:"last_used_time"`
+	CreateTime   int64  `json:"create_time"`
+}
+
+func (*ModelCacheEntity) TableName() string {
+	return "t_model_cache"
+}
+
+const (
+	ModelCacheDownloading = "downloading"
+	ModelCacheReady       = "ready"
+	ModelCacheFailed      = "failed"
+)
+
+// LedgerEntity is a reward or an expense of the provider, the amount is in wei of the token.
+// An on-chain entry is unique by its category, tx hash and log index, an off-chain one by its category and task.
+// The reward of a job saved before the ledger existed is an entry without tx hash, at the block scanned then.
+type LedgerEntity struct {
+	Id          int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Kind        string `json:"kind" gorm:"index"`
+	Category    string `json:"category" gorm:"uniqueIndex:idx_ledger_event"`
+	TaskType    string `json:"task_type"`
+	TaskUuid    string `json:"task_uuid" gorm:"uniqueIndex:idx_ledger_event"`
+	Contract    string `json:"contract"`
+	TxHash      string `json:"tx_hash" gorm:"uniqueIndex:idx_ledger_event"`
+	LogIndex    uint   `json:"log_index" gorm:"uniqueIndex:idx_ledger_event"`
+	BlockNumber uint64 `json:"block_number"`
+	Wallet      string `json:"wallet"`
+	Token       string `json:"token"`
+	Amount      string `json:"amount"`
+	EventTime   int64  `json:"event_time" gorm:"index"`
+	CreateTime  int64  `json:"create_time"`
+	Pending     bool   `json:"pending" gorm:"default:false"` // the gas of a transaction not mined yet, its fee is set once it is mined
+}
+
+func (*LedgerEntity) TableName() string {
+	return "t_ledger"
+}
+
+const (
+	LedgerReward     = "reward"
+	LedgerExpense    = "expense"
+	LedgerDeposit    = "deposit"
+	LedgerWithdrawal = "withdrawal"
+
+	LedgerFcpReward    = "fcp_reward"
+	LedgerEcpReward    = "ecp_reward"
+	LedgerUbiReward    = "ubi_reward"
+	LedgerGas          = "gas"
+	LedgerSequencerFee = "sequencer_fee"
+	LedgerCollateral   = "collateral"
+
+	LedgerTokenSwan = "SWAN"
+	LedgerTokenEth  = "ETH"
+)
+
 type ScanChainEntity struct {
 	Id          int64  `json:"id" gorm:"primaryKey"`
 	BlockNumber int64  `json:"block_number" gorm:"block_number"`
@@ -405,13 +523,183 @@ func (*ScanChainEntity) TableName() string {
 const (
 	ScannerTaskPaymentId    = 1
 	ScannerFcpTaskManagerId = 2
+	ScannerSequencerFeeId   = 3
+	ScannerEventIndexerId   = 4
+)
+
+// ScanBlockEntity is the hash of a block a scanner saved its checkpoint at, the scanner compares it with the chain
+// on its next scan to detect a reorg of the blocks it processed
+type ScanBlockEntity struct {
+	Id          int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	ScannerId   int64  `json:"scanner_id" gorm:"uniqueIndex:idx_scan_block"`
+	BlockNumber int64  `json:"block_number" gorm:"uniqueIndex:idx_scan_block"`
+	BlockHash   string `json:"block_hash"`
+	CreateTime  int64  `json:"create_time"`
+}
+
+func (*ScanBlockEntity) TableName() string {
+	return "t_scan_block"
+}
+
+// BackfillEntity is the checkpoint of a backfill of the reward events of a kind in a block range,
+// ScannedBlock is the last block of the windows already scanned.
+type BackfillEntity struct {
+	Id           int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Kind         string `json:"kind" gorm:"uniqueIndex:idx_backfill_range"`
+	FromBlock    uint64 `json:"from_block" gorm:"uniqueIndex:idx_backfill_range"`
+	ToBlock      uint64 `json:"to_block" gorm:"uniqueIndex:idx_backfill_range"`
+	ScannedBlock uint64 `json:"scanned_block"`
+	Status       string `json:"status"`
+	Message      string `json:"message"`
+	CreateTime   int64  `json:"create_time"`
+	UpdateTime   int64  `json:"update_time"`
+}
+
+func (*BackfillEntity) TableName() string {
+	return "t_backfill"
+}
+
+const (
+	BackfillRunning = "running"
+	BackfillFailed  = "failed"
+	BackfillDone    = "done"
+)
+
+// ChainEvent is the log of an indexed contract event, embedded in the typed event tables. A confirmed event was scanned
+// below the confirmation depth, an unconfirmed one comes from the websocket subscription and may still be reorged.
+type ChainEvent struct {
+	Source      string `json:"source" gorm:"index"`
+	Contract    string `json:"contract"`
+	Event       string `json:"event"`
+	TxHash      string `json:"tx_hash" gorm:"uniqueIndex:,composite:log"`
+	LogIndex    uint   `json:"log_index" gorm:"uniqueIndex:,composite:log"`
+	BlockNumber uint64 `json:"block_number" gorm:"index"`
+	BlockHash   string `json:"block_hash"`
+	EventTime   int64  `json:"event_time"`
+	Confirmed   bool   `json:"confirmed"`
+	Data        string `json:"data"` // the arguments of the event in json
+}
+
+// AccountEventEntity is an event of the cp account contract, the old and the new owner, worker, beneficiary,
+// task types or multiaddrs of a change
+type AccountEventEntity struct {
+	Id         int64      `json:"id" gorm:"primaryKey;autoIncrement"`
+	ChainEvent ChainEvent `json:"chain_event" gorm:"embedded"`
+	CpAccount  string     `json:"cp_account"`
+	OldValue   string     `json:"old_value"`
+	NewValue   string     `json:"new_value"`
+}
+
+func (*AccountEventEntity) TableName() string {
+	return "t_account_event"
+}
+
+// CollateralEventEntity is an event of the fcp or ecp collateral contract about the cp account, the amount is in wei
+type CollateralEventEntity struct {
+	Id         int64      `json:"id" gorm:"primaryKey;autoIncrement"`
+	ChainEvent ChainEvent `json:"chain_event" gorm:"embedded"`
+	CpAccount  string     `json:"cp_account"`
+	Wallet     string     `json:"wallet"`
+	TaskUuid   string     `json:"task_uuid"`
+	Amount     string     `json:"amount"`
+}
+
+func (*CollateralEventEntity) TableName() string {
+	return "t_collateral_event"
+}
+
+// SequencerEventEntity is an event of the sequencer contract about the cp account, the amount is in wei
+type SequencerEventEntity struct {
+	Id         int64      `json:"id" gorm:"primaryKey;autoIncrement"`
+	ChainEvent ChainEvent `json:"chain_event" gorm:"embedded"`
+	CpAccount  string     `json:"cp_account"`
+	Amount     string     `json:"amount"`
+}
+
+func (*SequencerEventEntity) TableName() string {
+	return "t_sequencer_event"
+}
+
+// TaskEventEntity is an event of the fcp task manager or the ecp task payment contract about the cp account,
+// the amount is in wei. The task uuid is the keccak256 hash of the uuid when the event only indexes it.
+type TaskEventEntity struct {
+	Id         int64      `json:"id" gorm:"primaryKey;autoIncrement"`
+	ChainEvent ChainEvent `json:"chain_event" gorm:"embedded"`
+	CpAccount  string     `json:"cp_account"`
+	Wallet     string     `json:"wallet"`
+	TaskUuid   string     `json:"task_uuid" gorm:"index"`
+	Amount     string     `json:"amount"`
+}
+
+func (*TaskEventEntity) TableName() string {
+	return "t_task_event"
+}
+
+const (
+	EventSourceAccount       = "account"
+	EventSourceFcpCollateral = "fcp_collateral"
+	EventSourceEcpCollateral = "ecp_collateral"
+	EventSourceSequencer     = "sequencer"
+	EventSourceFcpTask       = "fcp_task"
+	EventSourceEcpPayment    = "ecp_payment"
+)
+
+// AlertEntity is the state of an alert: whether it is firing and when it was last sent, or the last value the
+// alert compares with, e.g. the frozen collateral or the last block checked for slashes
+type AlertEntity struct {
+	Id         int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Key        string `json:"key" gorm:"uniqueIndex"`
+	Firing     bool   `json:"firing"`
+	Severity   string `json:"severity"`
+	Value      string `json:"value"`
+	Message    string `json:"message"`
+	SentTime   int64  `json:"sent_time"`
+	UpdateTime int64  `json:"update_time"`
+}
+
+func (*AlertEntity) TableName() string {
+	return "t_alert"
+}
+
+// TopUpEntity is the audit trail of the automatic top ups, the balance before the top up and the amount are in wei
+type TopUpEntity struct {
+	Id          int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Kind        string `json:"kind" gorm:"index"`
+	FromAddress string `json:"from_address"`
+	ToAddress   string `json:"to_address"`
+	Balance     string `json:"balance"`
+	Amount      string `json:"amount"`
+	TxHash      string `json:"tx_hash"`
+	Status      string `json:"status"`
+	Message     string `json:"message"`
+	CreateTime  int64  `json:"create_time" gorm:"index"`
+	UpdateTime  int64  `json:"update_time"`
+}
+
+func (*TopUpEntity) TableName() string {
+	return "t_top_up"
+}
+
+const (
+	TopUpKindSequencer     = "sequencer"
+	TopUpKindWorker        = "worker"
+	TopUpKindFcpCollateral = "fcp_collateral"
+	TopUpKindEcpCollateral = "ecp_collateral"
+)
+
+const (
+	TopUpPending = "pending"
+	TopUpSuccess = "success"
+	TopUpFailed  = "failed"
+	TopUpSkipped = "skipped"
 )
 
+// CpBalanceEntity is the last balances of the worker address and of the cp account in the sequencer, in wei
 type CpBalanceEntity struct {
-	Id               int64   `json:"id" gorm:"primaryKey"`
-	CpAccount        string  `json:"cp_account" gorm:"cp_account"`
-	WorkerBalance    float64 `json:"worker_balance" gorm:"worker_balance"`
-	SequencerBalance float64 `json:"sequencer_balance" gorm:"sequencer_balance"`
+	Id               int64  `json:"id" gorm:"primaryKey"`
+	CpAccount        string `json:"cp_account" gorm:"cp_account"`
+	WorkerBalance    string `json:"worker_balance" gorm:"column:worker_balance_wei"`
+	SequencerBalance string `json:"sequencer_balance" gorm:"column:sequencer_balance_wei"`
 }
 
 func (*CpBalanceEntity) TableName() string {
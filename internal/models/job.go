@@ -22,6 +22,15 @@ type EcpImageJobReq struct {
 	IpWhiteList   []string          `json:"ip_white_list"`
 	DeployType    int               `json:"deploy_type"` // 0: field; 1: dockerfile; 2: yaml
 	DeployContent string            `json:"deploy_content"`
+	Models        []JobModel        `json:"models,omitempty"`
+}
+
+// JobModel is a model file of a job, it is served from the model cache of the node and mounted read-only at Dir/Name
+type JobModel struct {
+	Name   string `json:"name"`
+	Url    string `json:"url"`
+	Sha256 string `json:"sha256,omitempty"`
+	Dir    string `json:"dir"`
 }
 
 type DeployJobParam struct {
@@ -38,7 +47,9 @@ type DeployJobParam struct {
 
 	BuildImagePath string
 	BuildImageName string
+	BuildCacheKey  string   // the builds of the same key reuse the build cache
 	IpWhiteList    []string `json:"ip_white_list"`
+	Models         []JobModel
 
 	PrepareG []PodGpu
 }
@@ -64,11 +75,62 @@ type PortMap struct {
 }
 
 type EcpJobStatusResp struct {
-	Uuid               string    `json:"uuid"`
-	Status             string    `json:"status"`
-	ServiceUrl         string    `json:"service_url,omitempty"`
-	HealthPath         string    `json:"health_path,omitempty"`
-	Price              float64   `json:"price"`
-	ServicePortMapping []PortMap `json:"service_port_mapping,omitempty"`
-	Message            string    `json:"message,omitempty"`
+	Uuid               string     `json:"uuid"`
+	Status             string     `json:"status"`
+	ServiceUrl         string     `json:"service_url,omitempty"`
+	HealthPath         string     `json:"health_path,omitempty"`
+	Price              float64    `json:"price"`
+	ServicePortMapping []PortMap  `json:"service_port_mapping,omitempty"`
+	Message            string     `json:"message,omitempty"`
+	Stage              string     `json:"stage,omitempty"`
+	Stages             []JobStage `json:"stages,omitempty"`
+	Health             *JobHealth `json:"health,omitempty"`
+}
+
+type JobHealth struct {
+	Status        string `json:"status"`
+	Failures      int    `json:"failures"`
+	RestartCount  int    `json:"restart_count"`
+	Uptime        int64  `json:"uptime"` // seconds since the container started the last time
+	LastCheckTime int64  `json:"last_check_time"`
+	Message       string `json:"message,omitempty"`
+}
+
+// the stages of deploying an ECP job, in order
+const (
+	DeployStagePending     = "pending"
+	DeployStagePrefetching = "prefetching"
+	DeployStagePulling     = "pulling"
+	DeployStageBuilding    = "building"
+	DeployStageCreating    = "creating"
+	DeployStageStarting    = "starting"
+	DeployStageHealthCheck = "health_check"
+	DeployStageReady       = "ready"
+	DeployStageFailed      = "failed"
+)
+
+type JobStage struct {
+	Stage     string `json:"stage"`
+	StartTime int64  `json:"start_time"`
+	EndTime   int64  `json:"end_time,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// BuildLogRecord is a line of the structured build log of a job, a step of the Dockerfile or an output line of it
+type BuildLogRecord struct {
+	Time     int64   `json:"time"`
+	Step     string  `json:"step"`
+	Status   string  `json:"status"`           // started | cached | done | error | log
+	Stream   string  `json:"stream,omitempty"` // stdout | stderr of a log line
+	Message  string  `json:"message,omitempty"`
+	Duration float64 `json:"duration,omitempty"` // the seconds of a done step
+}
+
+type JobEvent struct {
+	JobUuid string `json:"job_uuid"`
+	Type    string `json:"type"` // Normal | Warning
+	Reason  string `json:"reason"`
+	Object  string `json:"object"`
+	Message string `json:"message"`
+	Time    int64  `json:"time"`
 }
@@ -8,6 +8,19 @@ type ClusterResource struct {
 	NodeName         string          `json:"node_name,omitempty"`
 	Runtime          string          `json:"runtime,omitempty"`
 	ClientType       string          `json:"client_type"`
+	Ports            *PortUsage      `json:"ports,omitempty"`
+}
+
+type PortUsage struct {
+	Total  int         `json:"total"`
+	Free   int         `json:"free"`
+	Used   int         `json:"used"`
+	Leases []PortLease `json:"leases"`
+}
+
+type PortLease struct {
+	Port    int    `json:"port"`
+	JobUuid string `json:"job_uuid"`
 }
 
 type NodeResource struct {
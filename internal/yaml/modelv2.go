@@ -235,7 +235,8 @@ func getProtocol(proto string) corev1.Protocol {
 }
 
 type ModelResource struct {
-	Name string `yaml:"name"`
-	Url  string `yaml:"url"`
-	Dir  string `yaml:"dir"`
+	Name   string `yaml:"name"`
+	Url    string `yaml:"url"`
+	Dir    string `yaml:"dir"`
+	Sha256 string `yaml:"sha256"`
 }
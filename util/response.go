@@ -69,6 +69,9 @@ const (
 	ReadPriceError             = 4026
 	ReadLogError               = 4027
 	RejectTaskError            = 4028
+	JobStateError              = 4029
+	UpdateDeploymentError      = 4030
+	ImagePolicyError           = 4031
 
 	ProofParamError   = 7001
 	ProofReadLogError = 7002
@@ -113,6 +116,9 @@ var codeMsg = map[int]string{
 	ReadPriceError:             "An error occurred while read price info",
 	ReadLogError:               "failed to read logs",
 	RejectTaskError:            "GPU occupancy rate exceeds the set threshold, rejecting the task",
+	JobStateError:              "The current state of the job does not allow this operation",
+	UpdateDeploymentError:      "An error occurred while update the deployment of job",
+	ImagePolicyError:           "The image is not allowed by the image policy of the provider",
 
 	ProofReadLogError: "An error occurred while read the log of proof",
 	ProofError:        "An error occurred while executing the calculation task",
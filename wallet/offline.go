@@ -0,0 +1,281 @@
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/swanchain/go-computing-provider/internal/contract/account"
+	"github.com/swanchain/go-computing-provider/internal/contract/ecp"
+	"github.com/swanchain/go-computing-provider/internal/contract/fcp"
+	"github.com/swanchain/go-computing-provider/internal/contract/token"
+	"golang.org/x/xerrors"
+)
+
+// OfflineTxFileVersion is the version of the format of the files of the offline transactions
+const OfflineTxFileVersion = 1
+
+// OfflineTx is a transaction built on the node and signed on another machine, so the key of the sender never
+// touches the node. The method and the arguments are decoded for the review, the signer decodes them again from
+// the data. Raw and Hash are set once the transaction is signed.
+type OfflineTx struct {
+	Contract  string          `json:"contract"`
+	Method    string          `json:"method"`
+	Args      []string        `json:"args"`
+	ChainId   *big.Int        `json:"chain_id"`
+	From      common.Address  `json:"from"`
+	To        *common.Address `json:"to"`
+	Nonce     uint64          `json:"nonce"`
+	Value     *big.Int        `json:"value"`
+	Gas       uint64          `json:"gas"`
+	GasTipCap *big.Int        `json:"gas_tip_cap"`
+	GasFeeCap *big.Int        `json:"gas_fee_cap"`
+	Data      hexutil.Bytes   `json:"data"`
+	Raw       hexutil.Bytes   `json:"raw,omitempty"`
+	Hash      string          `json:"hash,omitempty"`
+}
+
+// OfflineTxFile is the file the unsigned transactions are exported to, and the signed transactions are written to
+type OfflineTxFile struct {
+	Version      int         `json:"version"`
+	CreateTime   time.Time   `json:"create_time"`
+	Transactions []OfflineTx `json:"transactions"`
+}
+
+var contractMetaData = map[string]*bind.MetaData{
+	ContractCpAccount:     account.AccountMetaData,
+	ContractSwanToken:     token.TokenMetaData,
+	ContractFcpCollateral: fcp.SwanCreditCollateralMetaData,
+	ContractEcpCollateral: ecp.EcpCollateralMetaData,
+	ContractSequencer:     ecp.EcpSequencerMetaData,
+}
+
+// BuildOfflineTxs builds the unsigned transactions of the calls, with the nonces of the senders and the gas
+// estimated on the latest block. The fee cap is 1.5 times the suggested gas price, as the commands set it.
+func BuildOfflineTxs(ctx context.Context, client *ethclient.Client, calls []TxCall) ([]OfflineTx, error) {
+	chainId, err := client.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the chain id, error: %v", err)
+	}
+	gasPrice, err := client.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the suggested gas price, error: %v", err)
+	}
+	gasTipCap, err := client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the suggested gas tip cap, error: %v", err)
+	}
+	gasFeeCap := new(big.Int).Div(new(big.Int).Mul(gasPrice, big.NewInt(3)), big.NewInt(2))
+	if gasFeeCap.Cmp(gasTipCap) < 0 {
+		gasFeeCap = gasTipCap
+	}
+
+	nonces := make(map[common.Address]uint64)
+	var txs []OfflineTx
+	for _, call := range calls {
+		if call.AfterPrevious {
+			return nil, fmt.Errorf("the %s call depends on the previous transaction being mined, it can not be signed offline", call.Contract)
+		}
+		nonce, ok := nonces[call.From]
+		if !ok {
+			if nonce, err = client.PendingNonceAt(ctx, call.From); err != nil {
+				return nil, fmt.Errorf("address: %s, failed to get the nonce, error: %v", call.From, err)
+			}
+		}
+		nonces[call.From] = nonce + 1
+
+		gas, err := client.EstimateGas(ctx, ethereum.CallMsg{
+			From:  call.From,
+			To:    call.To,
+			Value: call.Value,
+			Data:  call.Data,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("the %s transaction reverts in the simulation, error: %v", call.Contract, err)
+		}
+
+		tx := OfflineTx{
+			Contract:  call.Contract,
+			ChainId:   chainId,
+			From:      call.From,
+			To:        call.To,
+			Nonce:     nonce,
+			Value:     call.Value,
+			Gas:       gas * 6 / 5, // the state may change before the transaction is broadcast
+			GasTipCap: gasTipCap,
+			GasFeeCap: gasFeeCap,
+			Data:      call.Data,
+		}
+		if tx.Method, tx.Args, _, err = tx.DecodeCall(); err != nil {
+			return nil, err
+		}
+		txs = append(txs, tx)
+	}
+	return txs, nil
+}
+
+// DecodeCall decodes the method and the arguments of the transaction from its data
+func (t OfflineTx) DecodeCall() (string, []string, []interface{}, error) {
+	if t.Contract == "" {
+		if len(t.Data) > 0 {
+			return "", nil, nil, fmt.Errorf("the transfer has data")
+		}
+		return "transfer", nil, nil, nil
+	}
+	metaData, ok := contractMetaData[t.Contract]
+	if !ok {
+		return "", nil, nil, fmt.Errorf("unknown contract: %s", t.Contract)
+	}
+	parsed, err := metaData.GetAbi()
+	if err != nil {
+		return "", nil, nil, err
+	}
+	var bin []byte
+	if t.To == nil {
+		if t.Contract != ContractCpAccount {
+			return "", nil, nil, fmt.Errorf("the %s contract is not deployed by the commands", t.Contract)
+		}
+		bin = common.FromHex(account.AccountBin)
+	}
+	method, inputs, values, err := decodeCall(parsed, bin, t.To == nil, t.Data)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	return method, formatArgs(inputs, values), values, nil
+}
+
+// Transaction returns the unsigned transaction
+func (t OfflineTx) Transaction() *types.Transaction {
+	return types.NewTx(&types.DynamicFeeTx{
+		ChainID:   t.ChainId,
+		Nonce:     t.Nonce,
+		GasTipCap: t.GasTipCap,
+		GasFeeCap: t.GasFeeCap,
+		Gas:       t.Gas,
+		To:        t.To,
+		Value:     t.Value,
+		Data:      t.Data,
+	})
+}
+
+// WriteOfflineTxFile writes the transactions to the file
+func WriteOfflineTxFile(path string, txs []OfflineTx) error {
+	data, err := json.MarshalIndent(OfflineTxFile{
+		Version:      OfflineTxFileVersion,
+		CreateTime:   time.Now(),
+		Transactions: txs,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// ReadOfflineTxFile reads the transactions of the file
+func ReadOfflineTxFile(path string) ([]OfflineTx, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file OfflineTxFile
+	if err = json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse the transaction file %s, error: %v", path, err)
+	}
+	if file.Version != OfflineTxFileVersion {
+		return nil, fmt.Errorf("unsupported version %d of the transaction file %s", file.Version, path)
+	}
+	if len(file.Transactions) == 0 {
+		return nil, fmt.Errorf("the transaction file %s has no transaction", path)
+	}
+	for i, tx := range file.Transactions {
+		if tx.ChainId == nil || tx.Value == nil || tx.GasTipCap == nil || tx.GasFeeCap == nil {
+			return nil, fmt.Errorf("the transaction %d of the file %s misses the chain id, the value or the fee caps", i+1, path)
+		}
+	}
+	return file.Transactions, nil
+}
+
+// SignOfflineTxs signs the transactions with the keys of their senders in the keystore, it needs no network
+func (w *LocalWallet) SignOfflineTxs(txs []OfflineTx) ([]OfflineTx, error) {
+	defer w.Close()
+
+	var signed []OfflineTx
+	for _, tx := range txs {
+		if len(tx.Raw) > 0 {
+			return nil, fmt.Errorf("the transaction %s is already signed", tx.Hash)
+		}
+		method, args, _, err := tx.DecodeCall()
+		if err != nil {
+			return nil, err
+		}
+		if method != tx.Method {
+			return nil, fmt.Errorf("the data of the transaction calls %s, not %s", method, tx.Method)
+		}
+		tx.Args = args
+
+		ki, err := w.Get(KNamePrefix + tx.From.Hex())
+		if err != nil || ki.PrivateKey == "" {
+			return nil, xerrors.Errorf("the address: %s, private key %w", tx.From.Hex(), ErrKeyInfoNotFound)
+		}
+		privateKey, err := crypto.HexToECDSA(ki.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("parses private key error: %+v", err)
+		}
+		signedTx, err := types.SignTx(tx.Transaction(), types.LatestSignerForChainID(tx.ChainId), privateKey)
+		if err != nil {
+			return nil, err
+		}
+		if tx.Raw, err = signedTx.MarshalBinary(); err != nil {
+			return nil, err
+		}
+		tx.Hash = signedTx.Hash().Hex()
+		signed = append(signed, tx)
+	}
+	return signed, nil
+}
+
+// BroadcastOfflineTx sends a signed transaction, after checking it is the transaction of the file signed by its
+// sender for the chain of the client
+func BroadcastOfflineTx(ctx context.Context, client *ethclient.Client, tx OfflineTx) (*types.Transaction, error) {
+	if len(tx.Raw) == 0 {
+		return nil, fmt.Errorf("the transaction is not signed, sign it with wallet sign-tx")
+	}
+	var signedTx types.Transaction
+	if err := signedTx.UnmarshalBinary(tx.Raw); err != nil {
+		return nil, fmt.Errorf("failed to decode the signed transaction, error: %v", err)
+	}
+
+	chainId, err := client.ChainID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the chain id, error: %v", err)
+	}
+	if signedTx.ChainId().Cmp(chainId) != 0 {
+		return nil, fmt.Errorf("the transaction is signed for the chain %s, not the chain %s of the rpc", signedTx.ChainId(), chainId)
+	}
+	sender, err := types.Sender(types.LatestSignerForChainID(chainId), &signedTx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to recover the signer of the transaction, error: %v", err)
+	}
+	if sender != tx.From {
+		return nil, fmt.Errorf("the transaction is signed by %s, not %s", sender.Hex(), tx.From.Hex())
+	}
+	signer := types.LatestSignerForChainID(chainId)
+	if signer.Hash(tx.Transaction()) != signer.Hash(&signedTx) {
+		return nil, fmt.Errorf("the signed transaction %s differs from the transaction of the file", signedTx.Hash().Hex())
+	}
+
+	if err = client.SendTransaction(ctx, &signedTx); err != nil {
+		return nil, err
+	}
+	return &signedTx, nil
+}
@@ -0,0 +1,228 @@
+package wallet
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/swanchain/go-computing-provider/internal/contract/token"
+)
+
+// fakeChainRpc serves what the offline transactions need of a chain with the chain id, the raw transactions sent
+// are appended to sent
+func fakeChainRpc(t *testing.T, chainId uint64, sent *[]hexutil.Bytes) *ethclient.Client {
+	return fakeRpc(t, map[string]func(params []json.RawMessage) interface{}{
+		"eth_chainId":              func(params []json.RawMessage) interface{} { return hexutil.EncodeUint64(chainId) },
+		"eth_gasPrice":             func(params []json.RawMessage) interface{} { return "0x64" },
+		"eth_maxPriorityFeePerGas": func(params []json.RawMessage) interface{} { return "0xa" },
+		"eth_getTransactionCount":  func(params []json.RawMessage) interface{} { return "0x5" },
+		"eth_estimateGas":          func(params []json.RawMessage) interface{} { return hexutil.EncodeUint64(21000) },
+		"eth_sendRawTransaction": func(params []json.RawMessage) interface{} {
+			var raw hexutil.Bytes
+			if err := json.Unmarshal(params[0], &raw); err != nil {
+				return err
+			}
+			*sent = append(*sent, raw)
+			return crypto.Keccak256Hash(raw).Hex()
+		},
+	})
+}
+
+// testKeystore saves a new key in a keystore of the directory, and returns its address
+func testKeystore(t *testing.T, dir string) common.Address {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks, err := OpenOrInitKeystore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ks.Close()
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	if err = ks.Put(KNamePrefix+addr.Hex(), KeyInfo{PrivateKey: hex.EncodeToString(crypto.FromECDSA(key))}); err != nil {
+		t.Fatal(err)
+	}
+	return addr
+}
+
+// signTestTxs signs the transactions with a wallet of the keystore, the wallet is closed once they are signed
+func signTestTxs(t *testing.T, dir string, txs []OfflineTx) ([]OfflineTx, error) {
+	t.Helper()
+	ks, err := OpenOrInitKeystore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewWallet(ks).SignOfflineTxs(txs)
+}
+
+func testOfflineTxs(t *testing.T, client *ethclient.Client, from common.Address) []OfflineTx {
+	t.Helper()
+	approve, err := newContractCall(ContractSwanToken, token.TokenMetaData, from.Hex(), testToken, nil,
+		"approve", common.HexToAddress(testSequencer), big.NewInt(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	send, err := SendCalls(from.Hex(), testCpAccount, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	txs, err := BuildOfflineTxs(context.Background(), client, []TxCall{approve, send[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return txs
+}
+
+func TestBuildOfflineTxs(t *testing.T) {
+	var sent []hexutil.Bytes
+	client := fakeChainRpc(t, 2024, &sent)
+	from := common.HexToAddress(testFrom)
+	txs := testOfflineTxs(t, client, from)
+
+	if len(txs) != 2 {
+		t.Fatalf("expected 2 transactions, got %d", len(txs))
+	}
+	if txs[0].Method != "approve" || txs[0].Contract != ContractSwanToken || len(txs[0].Args) != 2 {
+		t.Errorf("expected the decoded approval, got %+v", txs[0])
+	}
+	if txs[1].Method != "transfer" || txs[1].Value.Cmp(big.NewInt(1e18)) != 0 {
+		t.Errorf("expected the transfer of 1 ether, got %+v", txs[1])
+	}
+	// the nonces of the same sender follow each other, the gas and the fee cap have a margin
+	if txs[0].Nonce != 5 || txs[1].Nonce != 6 {
+		t.Errorf("expected the nonces 5 and 6, got %d and %d", txs[0].Nonce, txs[1].Nonce)
+	}
+	if txs[0].ChainId.Int64() != 2024 || txs[0].Gas != 25200 || txs[0].GasTipCap.Int64() != 10 || txs[0].GasFeeCap.Int64() != 150 {
+		t.Errorf("expected the chain 2024, the gas 25200, the tip cap 10 and the fee cap 150, got %+v", txs[0])
+	}
+
+	// the transactions round trip through the file
+	file := path.Join(t.TempDir(), "txs.json")
+	if err := WriteOfflineTxFile(file, txs); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadOfflineTxFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read) != 2 || read[0].Transaction().Hash() != txs[0].Transaction().Hash() || read[1].Transaction().Hash() != txs[1].Transaction().Hash() {
+		t.Errorf("expected the transactions of the file to be the built ones, got %+v", read)
+	}
+
+	// a call that needs the previous transaction mined can not be built ahead
+	deposit := TxCall{Contract: ContractSequencer, From: from, AfterPrevious: true}
+	if _, err = BuildOfflineTxs(context.Background(), client, []TxCall{deposit}); err == nil {
+		t.Errorf("expected the call after the previous one to be rejected")
+	}
+}
+
+func TestSignAndBroadcastOfflineTx(t *testing.T) {
+	dir := t.TempDir()
+	from := testKeystore(t, dir)
+	var sent []hexutil.Bytes
+	client := fakeChainRpc(t, 2024, &sent)
+
+	txs := testOfflineTxs(t, client, from)
+	if _, err := BroadcastOfflineTx(context.Background(), client, txs[0]); err == nil {
+		t.Errorf("expected the unsigned transaction to be rejected")
+	}
+	signed, err := signTestTxs(t, dir, txs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(signed) != 2 || len(signed[0].Raw) == 0 || signed[0].Hash == "" {
+		t.Fatalf("expected the signed transactions, got %+v", signed)
+	}
+	if _, err = signTestTxs(t, dir, signed); err == nil {
+		t.Errorf("expected the signed transaction not to be signed again")
+	}
+
+	// a transaction signed for another chain
+	if _, err = BroadcastOfflineTx(context.Background(), fakeChainRpc(t, 1, &sent), signed[0]); err == nil ||
+		!strings.Contains(err.Error(), "chain") {
+		t.Errorf("expected the other chain to be rejected, got %v", err)
+	}
+
+	// a transaction of the file claimed by another sender
+	other := signed[0]
+	other.From = common.HexToAddress(testFrom)
+	if _, err = BroadcastOfflineTx(context.Background(), client, other); err == nil || !strings.Contains(err.Error(), "signed by") {
+		t.Errorf("expected the other sender to be rejected, got %v", err)
+	}
+
+	// a field of the file changed after the signature
+	tampered := signed[1]
+	tampered.Value = new(big.Int).Mul(tampered.Value, big.NewInt(10))
+	if _, err = BroadcastOfflineTx(context.Background(), client, tampered); err == nil || !strings.Contains(err.Error(), "differs") {
+		t.Errorf("expected the tampered value to be rejected, got %v", err)
+	}
+	tampered = signed[1]
+	to := common.HexToAddress(testSequencer)
+	tampered.To = &to
+	if _, err = BroadcastOfflineTx(context.Background(), client, tampered); err == nil || !strings.Contains(err.Error(), "differs") {
+		t.Errorf("expected the tampered recipient to be rejected, got %v", err)
+	}
+	if len(sent) != 0 {
+		t.Fatalf("expected no rejected transaction to be sent, got %d", len(sent))
+	}
+
+	tx, err := BroadcastOfflineTx(context.Background(), client, signed[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Hash().Hex() != signed[0].Hash || len(sent) != 1 || hexutil.Encode(sent[0]) != hexutil.Encode(signed[0].Raw) {
+		t.Errorf("expected the signed transaction %s to be sent, got %s", signed[0].Hash, tx.Hash().Hex())
+	}
+	if sender, _ := types.Sender(types.LatestSignerForChainID(big.NewInt(2024)), tx); sender != from {
+		t.Errorf("expected the transaction to be signed by %s, got %s", from.Hex(), sender.Hex())
+	}
+}
+
+func TestSignOfflineTxsRejectsMismatch(t *testing.T) {
+	dir := t.TempDir()
+	from := testKeystore(t, dir)
+	var sent []hexutil.Bytes
+	txs := testOfflineTxs(t, fakeChainRpc(t, 2024, &sent), from)
+
+	// the method of the file does not match the data
+	mismatch := txs[0]
+	mismatch.Method = "transfer"
+	if _, err := signTestTxs(t, dir, []OfflineTx{mismatch}); err == nil || !strings.Contains(err.Error(), "calls approve") {
+		t.Errorf("expected the other method to be rejected, got %v", err)
+	}
+
+	// a transfer that carries data
+	transfer := txs[1]
+	transfer.Data = txs[0].Data
+	if _, _, _, err := transfer.DecodeCall(); err == nil {
+		t.Errorf("expected the transfer with data to be rejected")
+	}
+	if _, err := signTestTxs(t, dir, []OfflineTx{transfer}); err == nil {
+		t.Errorf("expected the transfer with data not to be signed")
+	}
+
+	// the data of another contract
+	unknown := txs[0]
+	unknown.Contract = ContractSequencer
+	if _, err := signTestTxs(t, dir, []OfflineTx{unknown}); err == nil {
+		t.Errorf("expected the data of another contract to be rejected")
+	}
+
+	// a sender without a key in the keystore
+	stranger := txs[0]
+	stranger.From = common.HexToAddress(testFrom)
+	if _, err := signTestTxs(t, dir, []OfflineTx{stranger}); err == nil {
+		t.Errorf("expected the sender without a key to be rejected")
+	}
+}
@@ -0,0 +1,336 @@
+package wallet
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/swanchain/go-computing-provider/conf"
+	"github.com/swanchain/go-computing-provider/internal/contract"
+	"github.com/swanchain/go-computing-provider/internal/contract/account"
+	"github.com/swanchain/go-computing-provider/internal/contract/ecp"
+	"github.com/swanchain/go-computing-provider/internal/contract/fcp"
+	"github.com/swanchain/go-computing-provider/internal/contract/token"
+)
+
+// The names of the contracts the commands call
+const (
+	ContractCpAccount     = "cp account"
+	ContractSwanToken     = "SWAN token"
+	ContractFcpCollateral = "FCP collateral"
+	ContractEcpCollateral = "ECP collateral"
+	ContractSequencer     = "sequencer"
+)
+
+// TxCall is a transaction a command sends, built without signing it
+type TxCall struct {
+	Contract string // the name of the contract called, empty for a transfer
+	From     common.Address
+	To       *common.Address // nil for the deployment of the contract
+	Value    *big.Int
+	Data     []byte
+	abi      *abi.ABI
+	bin      []byte // the code of the contract deployed, the constructor arguments follow it in the data
+	// AfterPrevious is set when the call depends on the previous call of the command being mined, e.g. a deposit
+	// after the approval of the tokens
+	AfterPrevious bool
+}
+
+// TxPreview is a call decoded and simulated against the latest block, with the fee it would cost
+type TxPreview struct {
+	Call     TxCall
+	Method   string
+	Args     []string // the decoded arguments of the method, as name=value
+	Gas      uint64
+	GasPrice *big.Int // the suggested gas price
+	Fee      *big.Int // the gas times the suggested gas price
+	MaxFee   *big.Int // the gas times the fee cap the commands set, 1.5 times the suggested gas price
+	Revert   string   // why the call reverts, empty when it succeeds
+	Note     string
+}
+
+func newContractCall(name string, metaData *bind.MetaData, from, to string, value *big.Int, method string, args ...interface{}) (TxCall, error) {
+	if to == "" {
+		return TxCall{}, fmt.Errorf("the %s contract is not configured", name)
+	}
+	parsed, err := metaData.GetAbi()
+	if err != nil {
+		return TxCall{}, err
+	}
+	data, err := parsed.Pack(method, args...)
+	if err != nil {
+		return TxCall{}, fmt.Errorf("failed to encode the %s call, error: %v", method, err)
+	}
+	if value == nil {
+		value = new(big.Int)
+	}
+	toAddress := common.HexToAddress(to)
+	return TxCall{
+		Contract: name,
+		From:     common.HexToAddress(from),
+		To:       &toAddress,
+		Value:    value,
+		Data:     data,
+		abi:      parsed,
+	}, nil
+}
+
+// decodeCall decodes the method and the arguments of the data of a call of the contract, or of its deployment
+func decodeCall(parsed *abi.ABI, bin []byte, deploy bool, data []byte) (string, abi.Arguments, []interface{}, error) {
+	if deploy {
+		if !bytes.HasPrefix(data, bin) {
+			return "", nil, nil, fmt.Errorf("the data does not deploy the code of the contract")
+		}
+		values, err := parsed.Constructor.Inputs.Unpack(data[len(bin):])
+		if err != nil {
+			return "", nil, nil, fmt.Errorf("failed to decode the constructor arguments, error: %v", err)
+		}
+		return "constructor", parsed.Constructor.Inputs, values, nil
+	}
+	if len(data) < 4 {
+		return "", nil, nil, fmt.Errorf("the data does not call a method")
+	}
+	method, err := parsed.MethodById(data[:4])
+	if err != nil {
+		return "", nil, nil, err
+	}
+	values, err := method.Inputs.Unpack(data[4:])
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("failed to decode the %s call, error: %v", method.Name, err)
+	}
+	return method.Name, method.Inputs, values, nil
+}
+
+func formatArgs(inputs abi.Arguments, values []interface{}) []string {
+	var args []string
+	for i, input := range inputs {
+		args = append(args, fmt.Sprintf("%s=%v", input.Name, values[i]))
+	}
+	return args
+}
+
+func cpAccountOrDefault(cpAccountAddress string) (string, error) {
+	if strings.TrimSpace(cpAccountAddress) != "" {
+		return cpAccountAddress, nil
+	}
+	cpAccountAddress, err := contract.GetCpAccountAddress()
+	if err != nil {
+		return "", fmt.Errorf("get cp account contract address failed, error: %v", err)
+	}
+	return cpAccountAddress, nil
+}
+
+// SendCalls returns the transfer of WalletSend
+func SendCalls(from, to, amount string) ([]TxCall, error) {
+	value, err := convertToWei(amount)
+	if err != nil {
+		return nil, err
+	}
+	toAddress := common.HexToAddress(to)
+	return []TxCall{{
+		From:  common.HexToAddress(from),
+		To:    &toAddress,
+		Value: value,
+	}}, nil
+}
+
+// CollateralCalls returns the approval of the tokens and the deposit of WalletCollateral
+func CollateralCalls(from, amount, cpAccountAddress, collateralType string) ([]TxCall, error) {
+	value, err := convertToWei(amount)
+	if err != nil {
+		return nil, err
+	}
+	if cpAccountAddress, err = cpAccountOrDefault(cpAccountAddress); err != nil {
+		return nil, err
+	}
+
+	var collateralName, collateralAddress string
+	var collateralMetaData *bind.MetaData
+	switch collateralType {
+	case "fcp":
+		collateralName, collateralAddress, collateralMetaData = ContractFcpCollateral, conf.GetConfig().CONTRACT.JobCollateral, fcp.SwanCreditCollateralMetaData
+	case "ecp":
+		collateralName, collateralAddress, collateralMetaData = ContractEcpCollateral, conf.GetConfig().CONTRACT.ZkCollateral, ecp.EcpCollateralMetaData
+	default:
+		return nil, fmt.Errorf("not support collateral type")
+	}
+	if collateralAddress == "" {
+		return nil, fmt.Errorf("the %s contract is not configured", collateralName)
+	}
+
+	approve, err := newContractCall(ContractSwanToken, token.TokenMetaData, from, conf.GetConfig().CONTRACT.SwanToken, nil,
+		"approve", common.HexToAddress(collateralAddress), value)
+	if err != nil {
+		return nil, err
+	}
+	deposit, err := newContractCall(collateralName, collateralMetaData, from, collateralAddress, nil,
+		"deposit", common.HexToAddress(cpAccountAddress), value)
+	if err != nil {
+		return nil, err
+	}
+	deposit.AfterPrevious = true
+	return []TxCall{approve, deposit}, nil
+}
+
+// CollateralWithdrawCalls returns the call of CollateralWithdraw, CollateralWithdrawRequest or CollateralWithdrawConfirm,
+// the method is withdraw, requestWithdraw or confirmWithdraw
+func CollateralWithdrawCalls(owner, amount, cpAccountAddress, withdrawType, method string) ([]TxCall, error) {
+	cpAccountAddress, err := cpAccountOrDefault(cpAccountAddress)
+	if err != nil {
+		return nil, err
+	}
+	args := []interface{}{common.HexToAddress(cpAccountAddress)}
+	if method != "confirmWithdraw" {
+		value, err := convertToWei(amount)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, value)
+	}
+
+	var call TxCall
+	switch withdrawType {
+	case "fcp":
+		call, err = newContractCall(ContractFcpCollateral, fcp.SwanCreditCollateralMetaData, owner, conf.GetConfig().CONTRACT.JobCollateral, nil, method, args...)
+	case "ecp":
+		call, err = newContractCall(ContractEcpCollateral, ecp.EcpCollateralMetaData, owner, conf.GetConfig().CONTRACT.ZkCollateral, nil, method, args...)
+	default:
+		return nil, fmt.Errorf("not support withdraw type")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []TxCall{call}, nil
+}
+
+// SequencerDepositCalls returns the call of SequencerDeposit
+func SequencerDepositCalls(from, amount, cpAccountAddress string) ([]TxCall, error) {
+	value, err := convertToWei(amount)
+	if err != nil {
+		return nil, err
+	}
+	if cpAccountAddress, err = cpAccountOrDefault(cpAccountAddress); err != nil {
+		return nil, err
+	}
+	call, err := newContractCall(ContractSequencer, ecp.EcpSequencerMetaData, from, conf.GetConfig().CONTRACT.Sequencer, value,
+		"deposit", common.HexToAddress(cpAccountAddress))
+	if err != nil {
+		return nil, err
+	}
+	return []TxCall{call}, nil
+}
+
+// SequencerWithdrawCalls returns the call of SequencerWithdraw
+func SequencerWithdrawCalls(owner, amount, cpAccountAddress string) ([]TxCall, error) {
+	value, err := convertToWei(amount)
+	if err != nil {
+		return nil, err
+	}
+	if cpAccountAddress, err = cpAccountOrDefault(cpAccountAddress); err != nil {
+		return nil, err
+	}
+	call, err := newContractCall(ContractSequencer, ecp.EcpSequencerMetaData, owner, conf.GetConfig().CONTRACT.Sequencer, nil,
+		"withdraw", common.HexToAddress(cpAccountAddress), value)
+	if err != nil {
+		return nil, err
+	}
+	return []TxCall{call}, nil
+}
+
+// AccountCalls returns a call of the owner to the cp account contract, e.g. changeWorker or changeTaskTypes
+func AccountCalls(owner, cpAccountAddress, method string, args ...interface{}) ([]TxCall, error) {
+	cpAccountAddress, err := cpAccountOrDefault(cpAccountAddress)
+	if err != nil {
+		return nil, err
+	}
+	call, err := newContractCall(ContractCpAccount, account.AccountMetaData, owner, cpAccountAddress, nil, method, args...)
+	if err != nil {
+		return nil, err
+	}
+	return []TxCall{call}, nil
+}
+
+// AccountCreateCalls returns the deployment of the cp account contract of the owner
+func AccountCreateCalls(owner, nodeId string, multiAddresses []string, beneficiary, worker string, taskTypes []uint8) ([]TxCall, error) {
+	parsed, err := account.AccountMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	args, err := parsed.Pack("", nodeId, multiAddresses, common.HexToAddress(beneficiary), common.HexToAddress(worker),
+		common.HexToAddress(conf.GetConfig().CONTRACT.CpAccountRegister), taskTypes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the constructor arguments, error: %v", err)
+	}
+	bin := common.FromHex(account.AccountBin)
+	return []TxCall{{
+		Contract: ContractCpAccount,
+		From:     common.HexToAddress(owner),
+		Value:    new(big.Int),
+		Data:     append(append([]byte{}, bin...), args...),
+		abi:      parsed,
+		bin:      bin,
+	}}, nil
+}
+
+// PreviewTxs decodes the calls, runs eth_call and eth_estimateGas on each to surface the reverts, and prices them
+// with the suggested gas price
+func PreviewTxs(ctx context.Context, client *ethclient.Client, calls []TxCall) ([]TxPreview, error) {
+	gasPrice, err := client.SuggestGasPrice(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the suggested gas price, error: %v", err)
+	}
+
+	var previews []TxPreview
+	var previousReverts bool
+	for i, call := range calls {
+		preview := TxPreview{
+			Call:     call,
+			Method:   "transfer",
+			GasPrice: gasPrice,
+		}
+		if call.abi != nil {
+			method, inputs, values, err := decodeCall(call.abi, call.bin, call.To == nil, call.Data)
+			if err != nil {
+				return nil, err
+			}
+			preview.Method = method
+			preview.Args = formatArgs(inputs, values)
+		}
+
+		msg := ethereum.CallMsg{
+			From:  call.From,
+			To:    call.To,
+			Value: call.Value,
+			Data:  call.Data,
+		}
+		if call.abi != nil {
+			if _, err = client.CallContract(ctx, msg, nil); err != nil {
+				preview.Revert = err.Error()
+			}
+		}
+		if preview.Revert == "" {
+			if preview.Gas, err = client.EstimateGas(ctx, msg); err != nil {
+				preview.Revert = err.Error()
+			}
+		}
+		if call.AfterPrevious && i > 0 {
+			preview.Note = fmt.Sprintf("simulated before the %s is mined", previews[i-1].Method)
+			if preview.Revert != "" && !previousReverts {
+				preview.Note += ", it may revert only for the lack of it"
+			}
+		}
+		previousReverts = preview.Revert != ""
+
+		preview.Fee = new(big.Int).Mul(gasPrice, new(big.Int).SetUint64(preview.Gas))
+		preview.MaxFee = new(big.Int).Div(new(big.Int).Mul(preview.Fee, big.NewInt(3)), big.NewInt(2))
+		previews = append(previews, preview)
+	}
+	return previews, nil
+}
@@ -0,0 +1,205 @@
+package wallet
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/swanchain/go-computing-provider/internal/contract/account"
+	"github.com/swanchain/go-computing-provider/internal/contract/ecp"
+	"github.com/swanchain/go-computing-provider/internal/contract/token"
+)
+
+const (
+	testFrom      = "0x00000000000000000000000000000000000000a1"
+	testToken     = "0x00000000000000000000000000000000000000b1"
+	testSequencer = "0x00000000000000000000000000000000000000b2"
+	testCpAccount = "0x00000000000000000000000000000000000000c1"
+)
+
+// fakeRpc serves the json-rpc methods of the handlers, a handler returning an error answers with the error
+func fakeRpc(t *testing.T, handlers map[string]func(params []json.RawMessage) interface{}) *ethclient.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Id     json.RawMessage   `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode the request: %v", err)
+			return
+		}
+		resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.Id}
+		if handler, ok := handlers[req.Method]; !ok {
+			resp["error"] = map[string]interface{}{"code": -32601, "message": "method not found: " + req.Method}
+		} else if result := handler(req.Params); result != nil {
+			if err, isErr := result.(error); isErr {
+				resp["error"] = map[string]interface{}{"code": 3, "message": err.Error()}
+			} else {
+				resp["result"] = result
+			}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+	client, err := ethclient.Dial(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+func TestDecodeCall(t *testing.T) {
+	approve, err := newContractCall(ContractSwanToken, token.TokenMetaData, testFrom, testToken, nil,
+		"approve", common.HexToAddress(testSequencer), big.NewInt(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	method, inputs, values, err := decodeCall(approve.abi, nil, false, approve.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if method != "approve" || !reflect.DeepEqual(values, []interface{}{common.HexToAddress(testSequencer), big.NewInt(1000)}) {
+		t.Errorf("expected the approval of 1000, got %s %v", method, values)
+	}
+	if args := formatArgs(inputs, values); len(args) != 2 || !strings.HasSuffix(args[1], "=1000") {
+		t.Errorf("expected the formatted arguments, got %v", args)
+	}
+
+	deposit, err := newContractCall(ContractSequencer, ecp.EcpSequencerMetaData, testFrom, testSequencer, big.NewInt(5),
+		"deposit", common.HexToAddress(testCpAccount))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if method, _, values, err = decodeCall(deposit.abi, nil, false, deposit.Data); err != nil || method != "deposit" ||
+		values[0] != common.HexToAddress(testCpAccount) {
+		t.Errorf("expected the deposit to the cp account, got %s %v %v", method, values, err)
+	}
+	if deposit.Value.Int64() != 5 {
+		t.Errorf("expected the value of the call to be kept, got %s", deposit.Value)
+	}
+
+	// the data of another contract, and the truncated data, are rejected
+	if _, _, _, err = decodeCall(deposit.abi, nil, false, approve.Data); err == nil {
+		t.Errorf("expected the approval not to decode as a sequencer call")
+	}
+	if _, _, _, err = decodeCall(approve.abi, nil, false, approve.Data[:3]); err == nil {
+		t.Errorf("expected the data without a method to be rejected")
+	}
+	if _, _, _, err = decodeCall(approve.abi, nil, false, approve.Data[:20]); err == nil {
+		t.Errorf("expected the truncated arguments to be rejected")
+	}
+	if _, err = newContractCall(ContractSequencer, ecp.EcpSequencerMetaData, testFrom, "", nil, "deposit",
+		common.HexToAddress(testCpAccount)); err == nil {
+		t.Errorf("expected the contract without an address to be rejected")
+	}
+}
+
+func TestDecodeConstructor(t *testing.T) {
+	parsed, err := account.AccountMetaData.GetAbi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	args, err := parsed.Pack("", "node-1", []string{"/ip4/127.0.0.1/tcp/9085"}, common.HexToAddress(testFrom),
+		common.HexToAddress(testFrom), common.HexToAddress(testCpAccount), []uint8{1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bin := common.FromHex(account.AccountBin)
+	data := append(append([]byte{}, bin...), args...)
+
+	method, _, values, err := decodeCall(parsed, bin, true, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if method != "constructor" || values[0] != "node-1" || !reflect.DeepEqual(values[5], []uint8{1, 2}) {
+		t.Errorf("expected the constructor arguments, got %s %v", method, values)
+	}
+
+	// the deployment of other code is rejected
+	data[0] ^= 0xff
+	if _, _, _, err = decodeCall(parsed, bin, true, data); err == nil {
+		t.Errorf("expected the deployment of other code to be rejected")
+	}
+}
+
+func TestPreviewTxs(t *testing.T) {
+	approve, err := newContractCall(ContractSwanToken, token.TokenMetaData, testFrom, testToken, nil,
+		"approve", common.HexToAddress(testSequencer), big.NewInt(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deposit, err := newContractCall(ContractSequencer, ecp.EcpSequencerMetaData, testFrom, testSequencer, nil,
+		"deposit", common.HexToAddress(testCpAccount))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deposit.AfterPrevious = true
+	send, err := SendCalls(testFrom, testCpAccount, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var calls int
+	client := fakeRpc(t, map[string]func(params []json.RawMessage) interface{}{
+		"eth_gasPrice": func(params []json.RawMessage) interface{} { return "0x64" },
+		"eth_call": func(params []json.RawMessage) interface{} {
+			calls++
+			var msg struct {
+				To common.Address `json:"to"`
+			}
+			json.Unmarshal(params[0], &msg)
+			if msg.To == common.HexToAddress(testSequencer) {
+				return errString("execution reverted: insufficient allowance")
+			}
+			return "0x"
+		},
+		"eth_estimateGas": func(params []json.RawMessage) interface{} { return hexutil.EncodeUint64(21000) },
+	})
+
+	previews, err := PreviewTxs(context.Background(), client, []TxCall{approve, deposit, send[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(previews) != 3 {
+		t.Fatalf("expected 3 previews, got %d", len(previews))
+	}
+	if previews[0].Method != "approve" || previews[0].Revert != "" || previews[0].Gas != 21000 {
+		t.Errorf("expected the approval to succeed, got %+v", previews[0])
+	}
+	if previews[0].Fee.Int64() != 2100000 || previews[0].MaxFee.Int64() != 3150000 {
+		t.Errorf("expected the fee 2100000 and the max fee 3150000, got %s %s", previews[0].Fee, previews[0].MaxFee)
+	}
+
+	// the deposit reverts in the simulation only because the approval is not mined yet
+	if !strings.Contains(previews[1].Revert, "insufficient allowance") || previews[1].Fee.Sign() != 0 {
+		t.Errorf("expected the deposit to revert without a fee, got %+v", previews[1])
+	}
+	if want := "simulated before the approve is mined, it may revert only for the lack of it"; previews[1].Note != want {
+		t.Errorf("expected the note %q, got %q", want, previews[1].Note)
+	}
+
+	// the transfer is not simulated with eth_call
+	if previews[2].Method != "transfer" || previews[2].Revert != "" || previews[2].Note != "" {
+		t.Errorf("expected the transfer to succeed, got %+v", previews[2])
+	}
+	if calls != 2 {
+		t.Errorf("expected eth_call for the 2 contract calls, got %d", calls)
+	}
+}
+
+type errString string
+
+func (e errString) Error() string {
+	return string(e)
+}
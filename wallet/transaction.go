@@ -3,11 +3,12 @@ package wallet
 import (
 	"context"
 	"crypto/ecdsa"
+	"fmt"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
-	"log"
+	"github.com/swanchain/go-computing-provider/internal/contract"
 	"math/big"
 )
 
@@ -18,56 +19,38 @@ func Balance(ctx context.Context, client *ethclient.Client, addr string) (string
 		return "", err
 	}
 
-	var ethValue string
-	if balance.String() == "0" {
-		ethValue = "0.0000"
-	} else {
-		fbalance := new(big.Float)
-		fbalance.SetString(balance.String())
-		etherQuotient := new(big.Float).Quo(fbalance, new(big.Float).SetInt(big.NewInt(1e18)))
-		ethValue = etherQuotient.Text('f', 4)
-	}
-	return ethValue, nil
+	return contract.BalanceToStr(balance), nil
 }
 
-func BalanceNumber(client *ethclient.Client, addr string) (float64, error) {
-	account := common.HexToAddress(addr)
-	balance, err := client.BalanceAt(context.Background(), account, nil)
-	if err != nil {
-		return 0, err
-	}
-
-	balanceEther := new(big.Float).SetInt(balance)
-	weiPerEther := new(big.Float).SetFloat64(1e18) // 1 ether = 1e18 wei
-	balanceEther.Quo(balanceEther, weiPerEther)
-	balanceFloat64, _ := balanceEther.Float64()
-	return balanceFloat64, nil
+// BalanceWei returns the balance of the address in wei
+func BalanceWei(client *ethclient.Client, addr string) (*big.Int, error) {
+	return client.BalanceAt(context.Background(), common.HexToAddress(addr), nil)
 }
 
 func sendTransaction(client *ethclient.Client, privateK string, to string, amount *big.Int, nonce uint64) (string, error) {
 	privateKey, err := crypto.HexToECDSA(privateK)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 
 	publicKey := privateKey.Public()
 	publicKeyECDSA, ok := publicKey.(*ecdsa.PublicKey)
 	if !ok {
-		log.Fatal("cannot assert type: publicKey is not of type *ecdsa.PublicKey")
+		return "", fmt.Errorf("cannot assert type: publicKey is not of type *ecdsa.PublicKey")
 	}
 
 	fromAddress := crypto.PubkeyToAddress(*publicKeyECDSA)
 	if nonce == 0 {
 		nonce, err = client.PendingNonceAt(context.Background(), fromAddress)
 		if err != nil {
-			log.Fatal(err)
+			return "", err
 		}
 	}
 
 	gasLimit := uint64(21000) // in units
 	gasPrice, err := client.SuggestGasPrice(context.Background())
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 
 	toAddress := common.HexToAddress(to)
@@ -76,17 +59,17 @@ func sendTransaction(client *ethclient.Client, privateK string, to string, amoun
 
 	chainID, err := client.NetworkID(context.Background())
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 
 	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(chainID), privateKey)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 
 	err = client.SendTransaction(context.Background(), signedTx)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 	return signedTx.Hash().String(), nil
 }
@@ -140,8 +140,16 @@ func (w *LocalWallet) WalletImport(ctx context.Context, ki *KeyInfo) (string, er
 	return "", nil
 }
 
+// WalletBalance is the balance and the nonce of a wallet address, Error is why they are not read
+type WalletBalance struct {
+	Address string `json:"address"`
+	Balance string `json:"balance"`
+	Nonce   uint64 `json:"nonce"`
+	Error   string `json:"error,omitempty"`
+}
+
 func (w *LocalWallet) WalletList(ctx context.Context, contractFlag bool) error {
-	addressList, err := w.addressList(ctx)
+	wallets, err := w.WalletBalances(ctx, contractFlag)
 	if err != nil {
 		return err
 	}
@@ -151,17 +159,41 @@ func (w *LocalWallet) WalletList(ctx context.Context, contractFlag bool) error {
 	nonceKey := "Nonce"
 	errorKey := "Error"
 
+	tw := tablewriter.New(
+		tablewriter.Col(addressKey),
+		tablewriter.Col(balanceKey),
+		tablewriter.Col(nonceKey),
+		tablewriter.NewLineCol(errorKey))
+
+	for _, wallet := range wallets {
+		tw.Write(map[string]interface{}{
+			addressKey: wallet.Address,
+			balanceKey: wallet.Balance,
+			errorKey:   wallet.Error,
+			nonceKey:   wallet.Nonce,
+		})
+	}
+	return tw.Flush(os.Stdout)
+}
+
+// WalletBalances returns the balances of the wallet addresses, in SWAN with contractFlag, otherwise in ETH
+func (w *LocalWallet) WalletBalances(ctx context.Context, contractFlag bool) ([]WalletBalance, error) {
+	addressList, err := w.addressList(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	chainRpc, err := conf.GetRpcByNetWorkName()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	client, err := contract.GetEthClient(chainRpc)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer client.Close()
 
-	var wallets []map[string]interface{}
+	wallets := make([]WalletBalance, 0, len(addressList))
 	for _, addr := range addressList {
 		var balance string
 		if contractFlag {
@@ -183,25 +215,14 @@ func (w *LocalWallet) WalletList(ctx context.Context, contractFlag bool) error {
 			errmsg = err.Error()
 		}
 
-		wallet := map[string]interface{}{
-			addressKey: addr,
-			balanceKey: balance,
-			errorKey:   errmsg,
-			nonceKey:   nonce,
-		}
-		wallets = append(wallets, wallet)
-	}
-
-	tw := tablewriter.New(
-		tablewriter.Col(addressKey),
-		tablewriter.Col(balanceKey),
-		tablewriter.Col(nonceKey),
-		tablewriter.NewLineCol(errorKey))
-
-	for _, wallet := range wallets {
-		tw.Write(wallet)
+		wallets = append(wallets, WalletBalance{
+			Address: addr,
+			Balance: balance,
+			Nonce:   nonce,
+			Error:   errmsg,
+		})
 	}
-	return tw.Flush(os.Stdout)
+	return wallets, nil
 }
 
 func (w *LocalWallet) WalletNew(ctx context.Context) (string, error) {
@@ -823,15 +844,5 @@ func (w *LocalWallet) addressList(ctx context.Context) ([]string, error) {
 }
 
 func convertToWei(ethValue string) (*big.Int, error) {
-	ethFloat, ok := new(big.Float).SetString(ethValue)
-	if !ok {
-		return nil, fmt.Errorf("conversion to float failed")
-	}
-	weiConversion := new(big.Float).SetFloat64(1e18)
-	weiFloat := new(big.Float).Mul(ethFloat, weiConversion)
-	weiInt, acc := new(big.Int).SetString(weiFloat.Text('f', 0), 10)
-	if !acc {
-		return nil, fmt.Errorf("conversion to Wei failed")
-	}
-	return weiInt, nil
+	return contract.StrToBalance(ethValue)
 }